@@ -1,19 +1,40 @@
 package main
 
 import (
-	"database/sql"
-	"github.com/go-chi/chi/v5"
+	"context"
+	"errors"
+	"github.com/XSAM/otelsql"
 	_ "github.com/lib/pq"
 	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
 	"go_project/internal/config"
 	"go_project/internal/handlers"
+	"go_project/internal/keyring"
+	"go_project/internal/logging"
+	"go_project/internal/metrics"
 	"go_project/internal/middleware"
+	"go_project/internal/migrations"
 	"go_project/internal/repositories"
 	"go_project/internal/services"
+	"go_project/internal/tracing"
+	"go_project/internal/utils"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 )
 
+const (
+	defaultReadTimeout     = 15 * time.Second
+	defaultWriteTimeout    = 30 * time.Second
+	defaultIdleTimeout     = 2 * time.Minute
+	defaultShutdownTimeout = 20 * time.Second
+	defaultRequestTimeout  = 10 * time.Second
+	defaultMetricsAddr     = "127.0.0.1:9090"
+)
+
 func main() {
 	cfg, err := config.LoadConfig("config")
 	if err != nil {
@@ -23,84 +44,276 @@ func main() {
 	if dbURL == "" {
 		logrus.Fatal("DATABASE_URL environment variable not set")
 	}
+
+	// JSON-логи с request_id/user_id/trace_id и маскированием email, номеров карт и токенов
+	logging.Setup(logrus.InfoLevel)
+
+	// Каждый SQL-запрос становится дочерним спаном операции, в рамках которой он выполнен
+	db, err := otelsql.Open("postgres", dbURL,
+		otelsql.WithAttributes(attribute.String("db.system", "postgresql")),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{OmitConnResetSession: true, OmitRows: true}))
+	if err != nil {
+		logrus.Fatal("Failed to connect to DB", err)
+	}
+	defer db.Close()
+	if err := db.Ping(); err != nil {
+		logrus.Fatal("Database ping failed:", err)
+	}
+
+	// Подкоманда migrate управляет схемой БД и не требует остальных настроек
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(db, os.Args[2:]); err != nil {
+			logrus.Fatal(err)
+		}
+		return
+	}
+	metrics.RegisterDB(db, "postgres")
+	migrator, err := migrations.New(db)
+	if err != nil {
+		logrus.Fatal("cannot load migrations: ", err)
+	}
+	if err := migrator.CheckCurrent(context.Background()); err != nil {
+		logrus.Fatalf("database schema is not up to date (expected version %d): %v", migrator.Latest(), err)
+	}
+
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.Tracing.Endpoint, cfg.Tracing.ServiceName, cfg.Tracing.SampleRatio)
+	if err != nil {
+		logrus.Fatal("cannot configure tracing: ", err)
+	}
+
 	jwtSecret := cfg.Auth.JWTSecret
-	if jwtSecret == "" {
+	if jwtSecret == "" && (cfg.Auth.JWTAlgorithm == "" || cfg.Auth.JWTAlgorithm == services.JWTAlgorithmHS256) {
 		logrus.Fatal("JWT_SECRET not set")
 	}
-	encryptionKey := cfg.Auth.EncryptionKey
-	if encryptionKey == "" {
-		logrus.Fatal("CARD_ENC_KEY not set")
+	cardKeys, err := loadKeyring(cfg)
+	if err != nil {
+		logrus.Fatal("cannot load card encryption keys: ", err)
 	}
 	hmacSecret := cfg.Auth.HMACSecret
 	if hmacSecret == "" {
 		logrus.Fatal("HMAC_SECRET not set")
 	}
+	pinKey := cfg.Auth.PINKey
+	if pinKey == "" {
+		logrus.Fatal("PIN_KEY not set")
+	}
+	panIndexKey := cfg.Auth.PANIndexKey
+	if panIndexKey == "" {
+		logrus.Fatal("PAN_INDEX_KEY not set")
+	}
 
 	_ = cfg.SMTP.Host
 	_ = cfg.SMTP.Port
 
-	logrus.SetFormatter(&logrus.JSONFormatter{})
-	logrus.SetLevel(logrus.InfoLevel)
-
-	db, err := sql.Open("postgres", dbURL)
-	if err != nil {
-		logrus.Fatal("Failed to connect to DB", err)
-	}
-	defer db.Close()
-	if err := db.Ping(); err != nil {
-		logrus.Fatal("Database ping failed:", err)
-	}
-
 	userRepo := repositories.NewUserRepository(db)
 	accountRepo := repositories.NewAccountRepository(db)
 	cardRepo := repositories.NewCardRepository(db)
 	transactionRepo := repositories.NewTransactionRepository(db)
 	creditRepo := repositories.NewCreditRepository(db)
+	tokenRepo := repositories.NewTokenRepository(db)
+	recoveryCodeRepo := repositories.NewRecoveryCodeRepository(db)
+	adminActionRepo := repositories.NewAdminActionRepository(db)
+	loginAttemptRepo := repositories.NewLoginAttemptRepository(db)
+	signingKeyRepo := repositories.NewSigningKeyRepository(db)
+	oauthRepo := repositories.NewOAuthRepository(db)
+	sessionRepo := repositories.NewSessionRepository(db)
+	apiKeyRepo := repositories.NewAPIKeyRepository(db)
 	scheduleRepo := repositories.NewPaymentScheduleRepository(db)
+	txManager := repositories.NewTxManager(db)
 
-	authService := services.NewAuthService(userRepo, jwtSecret)
-	accountService := services.NewAccountService(accountRepo)
-	cardService := services.NewCardService(cardRepo, accountRepo, encryptionKey)
-	transactionService := services.NewTransactionService(accountRepo, transactionRepo, hmacSecret)
-	creditService := services.NewCreditService(creditRepo, accountRepo, scheduleRepo)
+	var hs256Until time.Time
+	if cfg.Auth.JWTHS256AcceptUntil != "" {
+		if hs256Until, err = time.Parse(time.RFC3339, cfg.Auth.JWTHS256AcceptUntil); err != nil {
+			logrus.Fatal("invalid jwt_hs256_accept_until: ", err)
+		}
+	}
+	jwtKeyService, err := services.NewJWTKeyService(signingKeyRepo, cardKeys, cfg.Auth.JWTAlgorithm, jwtSecret, hs256Until,
+		cfg.Auth.JWTIssuer, cfg.Auth.JWTAudience, cfg.Auth.JWTKeyRotation)
+	if err != nil {
+		logrus.Fatal("invalid JWT signing configuration: ", err)
+	}
+	if err := jwtKeyService.Init(context.Background()); err != nil {
+		logrus.Fatal("cannot load JWT signing keys: ", err)
+	}
+	var breached *services.BreachedPasswords
+	if cfg.Password.BreachedDir != "" {
+		if breached, err = services.NewBreachedPasswords(cfg.Password.BreachedDir); err != nil {
+			logrus.Fatal("cannot open breached password list: ", err)
+		}
+	}
+	passwordPolicy := services.NewPasswordPolicy(cfg.Password.MinLength, cfg.Password.MinCharClasses, breached)
+	mailer := utils.NewMailer(cfg.SMTP.Host, cfg.SMTP.Port, cfg.SMTP.User, cfg.SMTP.Pass, cfg.SMTP.From)
+	authService := services.NewAuthService(userRepo, tokenRepo, recoveryCodeRepo, loginAttemptRepo, sessionRepo, apiKeyRepo, cardKeys, jwtKeyService, passwordPolicy, mailer, cfg.Server.PublicURL, cfg.Auth.AccessTokenTTL, cfg.Auth.RefreshTokenTTL)
+	keyRateProvider := services.NewKeyRateProvider()
+	accountService := services.NewAccountService(accountRepo, keyRateProvider)
+	cardService := services.NewCardService(txManager, cardRepo, accountRepo, cardKeys, pinKey, panIndexKey)
+	cardKeyRotationService := services.NewCardKeyRotationService(cardRepo, signingKeyRepo, userRepo, cardKeys)
+	transactionService := services.NewTransactionService(txManager, accountRepo, transactionRepo, userRepo, cardService, hmacSecret, services.FeeAccountID)
+	creditService := services.NewCreditService(txManager, creditRepo, accountRepo, scheduleRepo)
+	adminService := services.NewAdminService(txManager, userRepo, accountRepo, creditRepo, scheduleRepo, adminActionRepo, transactionService)
+	oauthService := services.NewOAuthService(oauthRepo, jwtKeyService)
+	apiKeyService := services.NewAPIKeyService(apiKeyRepo)
 
-	creditService.StartOverduePayments()
+	// Фоновые задачи останавливаются по SIGINT/SIGTERM после завершения HTTP-запросов
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	jobsCtx, cancelJobs := context.WithCancel(context.Background())
+	var jobs sync.WaitGroup
+	startJob := func(run func(ctx context.Context)) {
+		jobs.Add(1)
+		go func() {
+			defer jobs.Done()
+			run(jobsCtx)
+		}()
+	}
+	startJob(creditService.RunOverduePayments)
+	startJob(authService.RunTokenCleanup)
+	startJob(jwtKeyService.RunRotation)
+	startJob(oauthService.RunCodeCleanup)
+	startJob(cardKeyRotationService.RunReencryption)
+	startJob(func(ctx context.Context) {
+		if _, err := cardService.BackfillPANIndex(ctx); err != nil {
+			logrus.Error("PAN index backfill failed: ", err)
+		}
+	})
 
 	authHandler := handlers.NewAuthHandler(authService)
 	accountHandler := handlers.NewAccountHandler(accountService)
 	cardHandler := handlers.NewCardHandler(cardService)
-	transactionHandler := handlers.NewTransactionHandler(transactionService)
+	transactionHandler := handlers.NewTransactionHandler(transactionService, authService)
 	creditHandler := handlers.NewCreditHandler(creditService)
+	adminHandler := handlers.NewAdminHandler(adminService, cardKeyRotationService)
+	jwksHandler := handlers.NewJWKSHandler(jwtKeyService)
+	oauthHandler := handlers.NewOAuthHandler(oauthService)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
+	healthHandler := handlers.NewHealthHandler(db, migrator, keyRateProvider)
 
-	r := chi.NewRouter()
-
-	// middleware для логирования запросов
-	r.Use(func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			start := time.Now()
-			next.ServeHTTP(w, r)
-			logrus.Infof("%s %s %v", r.Method, r.RequestURI, time.Since(start))
-		})
-	})
-
-	r.Post("/register", authHandler.Register)
-	r.Post("/login", authHandler.Login)
-
-	r.Route("/", func(pr chi.Router) {
-		pr.Use(middleware.JWTAuthMiddleware(jwtSecret))
-		pr.Post("/accounts", accountHandler.CreateAccount)
-		pr.Post("/cards", cardHandler.CreateCard)
-		pr.Post("/transfer", transactionHandler.Transfer)
-		pr.Get("/analytics", transactionHandler.Analytics)
-		pr.Post("/credits", creditHandler.CreateCredit)
-		pr.Get("/credits/{creditId}/schedule", creditHandler.GetPaymentSchedule)
-		pr.Get("/accounts/{accountId}/balance", accountHandler.GetBalance)
-		pr.Get("/accounts/{accountId}/predict", accountHandler.PredictBalance)
-	})
+	routeTimeouts := make(map[string]time.Duration, len(cfg.Server.RouteTimeouts))
+	for _, rt := range cfg.Server.RouteTimeouts {
+		routeTimeouts[rt.Route] = rt.Timeout
+	}
+	trustedProxies, err := middleware.ParseTrustedProxies(cfg.Server.TrustedProxies)
+	if err != nil {
+		logrus.Fatal("Invalid trusted_proxies: ", err)
+	}
+	r := (&routes{
+		auth:           authHandler,
+		account:        accountHandler,
+		card:           cardHandler,
+		transaction:    transactionHandler,
+		credit:         creditHandler,
+		admin:          adminHandler,
+		jwks:           jwksHandler,
+		oauth:          oauthHandler,
+		apiKey:         apiKeyHandler,
+		health:         healthHandler,
+		jwtKeys:        jwtKeyService,
+		authService:    authService,
+		oauthService:   oauthService,
+		apiKeyService:  apiKeyService,
+		requestTimeout: durationOr(cfg.Server.RequestTimeout, defaultRequestTimeout),
+		routeTimeouts:  routeTimeouts,
+		trustedProxies: trustedProxies,
+	}).router()
 	//	Запуск HTTP-сервера
 	port := cfg.Server.Port
-	logrus.Infof("Server started on port %s", port)
-	if err := http.ListenAndServe(":"+port, r); err != nil {
-		logrus.Fatal(err)
+	srv := &http.Server{
+		Addr:              ":" + port,
+		Handler:           r,
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       durationOr(cfg.Server.ReadTimeout, defaultReadTimeout),
+		WriteTimeout:      durationOr(cfg.Server.WriteTimeout, defaultWriteTimeout),
+		IdleTimeout:       durationOr(cfg.Server.IdleTimeout, defaultIdleTimeout),
+	}
+	// Метрики отдаются на отдельном внутреннем порту, который не публикуется наружу
+	metricsSrv := &http.Server{
+		Addr:              cfg.Server.MetricsAddr,
+		Handler:           metricsRouter(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	if metricsSrv.Addr == "" {
+		metricsSrv.Addr = defaultMetricsAddr
+	}
+	serverErr := make(chan error, 2)
+	go func() {
+		logrus.Infof("Server started on port %s", port)
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+	go func() {
+		logrus.Infof("Metrics server started on %s", metricsSrv.Addr)
+		if err := metricsSrv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	select {
+	case err := <-serverErr:
+		logrus.Error("HTTP server failed: ", err)
+	case <-ctx.Done():
+		logrus.Info("Shutdown signal received, draining requests")
+	}
+	stop()
+
+	// Сначала перестаём принимать запросы и дожидаемся текущих, затем останавливаем фоновые задачи:
+	// задачи и обработчики работают с одной БД, которая закрывается последней
+	healthHandler.SetDraining()
+	if delay := cfg.Server.PreStopDelay; delay > 0 {
+		logrus.Infof("Waiting %s for the load balancer to stop routing requests", delay)
+		time.Sleep(delay)
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), durationOr(cfg.Server.ShutdownTimeout, defaultShutdownTimeout))
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logrus.Error("HTTP server shutdown did not complete: ", err)
+	}
+	if err := metricsSrv.Shutdown(shutdownCtx); err != nil {
+		logrus.Error("Metrics server shutdown did not complete: ", err)
+	}
+	cancelJobs()
+	jobsDone := make(chan struct{})
+	go func() {
+		jobs.Wait()
+		close(jobsDone)
+	}()
+	select {
+	case <-jobsDone:
+	case <-shutdownCtx.Done():
+		logrus.Warn("Background jobs did not stop in time")
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		logrus.Error("Failed to flush traces: ", err)
+	}
+	logrus.Info("Server stopped")
+}
+
+func durationOr(value, fallback time.Duration) time.Duration {
+	if value > 0 {
+		return value
+	}
+	return fallback
+}
+
+// loadKeyring собирает ключи шифрования карт: из файла keyring_file, если он задан,
+// иначе из encryption_keys. Одиночный encryption_key добавляется под идентификатором
+// keyring.LegacyKeyID, чтобы карты, выпущенные до ротации, оставались читаемыми.
+func loadKeyring(cfg *config.Config) (*keyring.Keyring, error) {
+	if cfg.Auth.KeyringFile != "" {
+		return keyring.LoadFile(cfg.Auth.KeyringFile)
+	}
+	keys := make(map[string]string, len(cfg.Auth.EncryptionKeys)+1)
+	for id, key := range cfg.Auth.EncryptionKeys {
+		keys[id] = key
+	}
+	activeID := cfg.Auth.ActiveKeyID
+	if cfg.Auth.EncryptionKey != "" {
+		if _, ok := keys[keyring.LegacyKeyID]; !ok {
+			keys[keyring.LegacyKeyID] = cfg.Auth.EncryptionKey
+		}
+		if activeID == "" && len(cfg.Auth.EncryptionKeys) == 0 {
+			activeID = keyring.LegacyKeyID
+		}
 	}
+	return keyring.New(activeID, keys)
 }
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go_project/internal/migrations"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+const migrateUsage = `usage: migrate <command>
+  up              применить все миграции
+  down [N]        откатить N последних миграций (по умолчанию 1)
+  to VERSION      привести схему к версии VERSION (вверх или вниз)
+  status          показать применённые и ожидающие миграции
+  force VERSION   отметить версию применённой без выполнения скриптов`
+
+// runMigrate выполняет подкоманду migrate.
+func runMigrate(db *sql.DB, args []string) error {
+	migrator, err := migrations.New(db)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	switch args[0] {
+	case "up":
+		return migrator.Up(ctx)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps <= 0 {
+				return errors.New(migrateUsage)
+			}
+		}
+		return migrator.Down(ctx, steps)
+	case "to", "force":
+		if len(args) < 2 {
+			return errors.New(migrateUsage)
+		}
+		version, err := strconv.Atoi(args[1])
+		if err != nil || version < 0 {
+			return errors.New(migrateUsage)
+		}
+		if args[0] == "force" {
+			return migrator.Force(ctx, version)
+		}
+		return migrator.To(ctx, version)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, st := range statuses {
+			state, appliedAt := "pending", ""
+			if st.Applied {
+				state = "applied"
+				appliedAt = st.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if st.Modified {
+				state = "modified"
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", st.Version, st.Name, state, appliedAt)
+		}
+		return w.Flush()
+	default:
+		return errors.New(migrateUsage)
+	}
+}
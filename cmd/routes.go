@@ -0,0 +1,153 @@
+package main
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go_project/internal/handlers"
+	"go_project/internal/middleware"
+	"go_project/internal/models"
+	"go_project/internal/openapi"
+	"go_project/internal/problem"
+	"go_project/internal/services"
+	"net/http"
+	"net/netip"
+	"time"
+)
+
+// routes — обработчики и сервисы, из которых собирается маршрутизатор API. Каждый маршрут
+// должен быть описан в internal/openapi/openapi.json, это проверяет TestRoutesDocumented.
+type routes struct {
+	auth        *handlers.AuthHandler
+	account     *handlers.AccountHandler
+	card        *handlers.CardHandler
+	transaction *handlers.TransactionHandler
+	credit      *handlers.CreditHandler
+	admin       *handlers.AdminHandler
+	jwks        *handlers.JWKSHandler
+	oauth       *handlers.OAuthHandler
+	apiKey      *handlers.APIKeyHandler
+	health      *handlers.HealthHandler
+
+	jwtKeys       *services.JWTKeyService
+	authService   *services.AuthService
+	oauthService  *services.OAuthService
+	apiKeyService *services.APIKeyService
+
+	requestTimeout time.Duration
+	routeTimeouts  map[string]time.Duration
+	trustedProxies []netip.Prefix
+}
+
+// router регистрирует middleware и все маршруты API.
+func (rt *routes) router() chi.Router {
+	r := chi.NewRouter()
+	r.Use(middleware.ClientIP(rt.trustedProxies))
+	r.Use(middleware.RequestID)
+	r.Use(middleware.Tracing)
+	r.Use(middleware.AccessLog)
+	r.Use(middleware.Metrics)
+	r.Use(middleware.Deadlines(r, rt.requestTimeout, rt.routeTimeouts))
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		problem.Write(w, r, problem.CodeNotFound)
+	})
+	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		problem.Write(w, r, problem.CodeMethodNotAllowed)
+	})
+
+	r.Get("/healthz", rt.health.Healthz)
+	r.Get("/readyz", rt.health.Readyz)
+	r.Get("/openapi.json", openapi.ServeSpec)
+	r.Get("/docs", openapi.ServeUI)
+	r.Get("/docs/{asset}", openapi.ServeUIAsset)
+	r.Get("/.well-known/jwks.json", rt.jwks.JWKS)
+	r.Post("/register", rt.auth.Register)
+	r.Post("/login", rt.auth.Login)
+	r.Post("/login/2fa", rt.auth.LoginSecondFactor)
+	r.Post("/token/refresh", rt.auth.Refresh)
+	r.Post("/verify-email", rt.auth.VerifyEmail)
+	r.Post("/password/forgot", rt.auth.ForgotPassword)
+	r.Post("/password/reset", rt.auth.ResetPassword)
+	r.Post("/oauth/token", rt.oauth.Token)
+
+	r.Route("/", func(pr chi.Router) {
+		pr.Use(middleware.JWTAuthMiddleware(rt.jwtKeys, rt.authService, nil))
+		pr.Post("/logout", rt.auth.Logout)
+		pr.Post("/logout/all", rt.auth.LogoutAll)
+		pr.Get("/sessions", rt.auth.ListSessions)
+		pr.Delete("/sessions/{sessionId}", rt.auth.RevokeSession)
+		pr.With(middleware.RequireStepUp(rt.authService)).Post("/api-keys", rt.apiKey.CreateKey)
+		pr.Get("/api-keys", rt.apiKey.ListKeys)
+		pr.Delete("/api-keys/{keyId}", rt.apiKey.RevokeKey)
+		pr.Post("/verify-email/resend", rt.auth.ResendVerification)
+		pr.Post("/2fa/enroll", rt.auth.EnrollTOTP)
+		pr.Post("/2fa/confirm", rt.auth.ConfirmTOTP)
+		pr.Post("/2fa/disable", rt.auth.DisableTOTP)
+		pr.Post("/2fa/recovery-codes", rt.auth.RegenerateRecoveryCodes)
+		pr.Post("/2fa/step-up", rt.auth.StepUp)
+		pr.Post("/accounts", rt.account.CreateAccount)
+		pr.Post("/cards", rt.card.CreateCard)
+		pr.Post("/cards/virtual", rt.card.CreateVirtualCard)
+		pr.With(middleware.RequireStepUp(rt.authService)).Get("/cards/{cardId}/pan", rt.card.RevealCard)
+		pr.Get("/cards/{cardId}/limits", rt.card.GetLimits)
+		pr.Put("/cards/{cardId}/limits", rt.card.UpdateLimits)
+		pr.Post("/cards/{cardId}/authorize", rt.card.Authorize)
+		pr.Put("/cards/{cardId}/pin", rt.card.SetPIN)
+		pr.Post("/cards/{cardId}/pin/change", rt.card.ChangePIN)
+		pr.Post("/cards/{cardId}/pin/verify", rt.card.VerifyPIN)
+		pr.With(middleware.RequireStepUp(rt.authService)).Post("/credits", rt.credit.CreateCredit)
+		pr.Get("/credits/{creditId}/schedule", rt.credit.GetPaymentSchedule)
+		pr.Get("/oauth/authorize", rt.oauth.ConsentScreen)
+		pr.Post("/oauth/authorize", rt.oauth.Authorize)
+		pr.Get("/oauth/consents", rt.oauth.ListConsents)
+		pr.Delete("/oauth/consents/{clientId}", rt.oauth.RevokeConsent)
+
+		// API сотрудников банка: чтение доступно операторам, аудиторам и администраторам,
+		// изменения — только операторам и администраторам
+		pr.Route("/admin", func(ar chi.Router) {
+			ar.Use(middleware.RequireRole(models.RoleOperator, models.RoleAdmin, models.RoleAuditor))
+			ar.Get("/users", rt.admin.SearchUsers)
+			ar.Get("/users/{userId}", rt.admin.GetUser)
+			ar.Get("/accounts/{accountId}", rt.admin.GetAccount)
+			ar.Get("/credits/{creditId}", rt.admin.GetCredit)
+			ar.With(middleware.RequireRole(models.RoleOperator, models.RoleAdmin)).Post("/accounts/{accountId}/freeze", rt.admin.FreezeAccount)
+			ar.With(middleware.RequireRole(models.RoleOperator, models.RoleAdmin)).Post("/accounts/{accountId}/unfreeze", rt.admin.UnfreezeAccount)
+			ar.With(middleware.RequireRole(models.RoleAdmin)).Post("/accounts/{accountId}/adjust", rt.admin.AdjustBalance)
+			ar.With(middleware.RequireRole(models.RoleAdmin)).Put("/users/{userId}/role", rt.admin.SetRole)
+			ar.With(middleware.RequireRole(models.RoleAdmin, models.RoleAuditor)).Get("/actions", rt.admin.ListActions)
+			ar.With(middleware.RequireRole(models.RoleAdmin, models.RoleAuditor)).Get("/card-keys/progress", rt.admin.KeyRotationProgress)
+			ar.With(middleware.RequireRole(models.RoleAdmin)).Post("/oauth/clients", rt.oauth.RegisterClient)
+		})
+	})
+
+	// Маршруты, доступные также по персональным API-ключам (Authorization: ApiKey ...) в пределах их областей
+	r.Group(func(kr chi.Router) {
+		kr.Use(middleware.JWTAuthMiddleware(rt.jwtKeys, rt.authService, rt.apiKeyService))
+		kr.With(middleware.RequireScope(models.ScopeAccountsRead)).Get("/accounts/{accountId}/balance", rt.account.GetBalance)
+		kr.With(middleware.RequireScope(models.ScopeAccountsRead)).Get("/accounts/{accountId}/predict", rt.account.PredictBalance)
+		kr.With(middleware.RequireScope(models.ScopeTransactionsRead)).Get("/analytics", rt.transaction.Analytics)
+		kr.With(middleware.RequireScope(models.ScopePaymentsWrite)).Post("/transfer", rt.transaction.Transfer)
+		kr.With(middleware.RequireScope(models.ScopePaymentsWrite)).Post("/transfer/card", rt.transaction.CardTransfer)
+	})
+
+	// API для сторонних приложений: токены OAuth2, доступ ограничен областями (scopes)
+	r.Route("/partner", func(or chi.Router) {
+		or.Use(middleware.OAuthMiddleware(rt.jwtKeys, rt.oauthService))
+		or.With(middleware.RequireScope(models.ScopeRatesRead)).Get("/key-rate", rt.account.KeyRate)
+		or.Group(func(ur chi.Router) {
+			ur.Use(middleware.RequireOAuthUser)
+			ur.With(middleware.RequireScope(models.ScopeAccountsRead)).Get("/accounts", rt.account.ListAccounts)
+			ur.With(middleware.RequireScope(models.ScopeAccountsRead)).Get("/accounts/{accountId}/balance", rt.account.GetBalance)
+			ur.With(middleware.RequireScope(models.ScopeTransactionsRead)).Get("/transactions", rt.transaction.ListTransactions)
+			ur.With(middleware.RequireScope(models.ScopePaymentsWrite)).Post("/payments", rt.transaction.Transfer)
+		})
+	})
+	return r
+}
+
+// metricsRouter обслуживает внутренний порт: метрики раскрывают объёмы операций и не требуют
+// аутентификации, поэтому на публичном порту не регистрируются.
+func metricsRouter() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promhttp.Handler())
+	return mux
+}
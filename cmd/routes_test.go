@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/go-chi/chi/v5"
+	"go_project/internal/openapi"
+	"net/http"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// TestRoutesDocumented сверяет маршруты, зарегистрированные в routes.router, с операциями openapi.json:
+// каждый маршрут должен быть описан, а каждая описанная операция — существовать.
+func TestRoutesDocumented(t *testing.T) {
+	var doc struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(openapi.Spec(), &doc); err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+	documented := make(map[string]bool)
+	for path, item := range doc.Paths {
+		for method := range item {
+			// Кроме операций, элемент пути может содержать общие parameters, summary и т. п.
+			switch method {
+			case "get", "put", "post", "delete", "patch", "head", "options":
+				documented[strings.ToUpper(method)+" "+path] = true
+			}
+		}
+	}
+
+	registered := make(map[string]bool)
+	walk := func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		registered[method+" "+route] = true
+		return nil
+	}
+	if err := chi.Walk((&routes{}).router(), walk); err != nil {
+		t.Fatal(err)
+	}
+	if len(registered) == 0 {
+		t.Fatal("no routes registered")
+	}
+
+	for _, route := range sortedKeys(registered) {
+		if !documented[route] {
+			t.Errorf("route %s is not documented in internal/openapi/openapi.json", route)
+		}
+	}
+	for _, route := range sortedKeys(documented) {
+		if !registered[route] {
+			t.Errorf("operation %s is documented but not registered", route)
+		}
+	}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -1,10 +1,33 @@
 package config
 
-import "github.com/spf13/viper"
+import (
+	"github.com/spf13/viper"
+	"time"
+)
 
 type Config struct {
 	Server struct {
 		Port string
+		// Адрес клиентского приложения для ссылок в письмах, например https://bank.example.com
+		PublicURL string `mapstructure:"public_url"`
+		// Адрес внутреннего порта с метриками Prometheus, например 127.0.0.1:9090
+		MetricsAddr string `mapstructure:"metrics_addr"`
+		// Адреса и подсети балансировщиков, которым доверяется X-Forwarded-For и X-Real-IP
+		TrustedProxies []string `mapstructure:"trusted_proxies"`
+
+		// Таймауты HTTP-сервера и время на завершение текущих запросов при остановке;
+		// нулевые значения заменяются значениями по умолчанию
+		ReadTimeout     time.Duration `mapstructure:"read_timeout"`
+		WriteTimeout    time.Duration `mapstructure:"write_timeout"`
+		IdleTimeout     time.Duration `mapstructure:"idle_timeout"`
+		ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+		// Пауза между переводом /readyz в 503 и остановкой приёма соединений, чтобы балансировщик
+		// успел исключить экземпляр; 0 — без паузы
+		PreStopDelay time.Duration `mapstructure:"pre_stop_delay"`
+
+		// Дедлайн обработки запроса по умолчанию и для отдельных маршрутов
+		RequestTimeout time.Duration  `mapstructure:"request_timeout"`
+		RouteTimeouts  []RouteTimeout `mapstructure:"route_timeouts"`
 	}
 	Database struct {
 		URL string
@@ -13,6 +36,38 @@ type Config struct {
 		JWTSecret     string `mapstructure:"jwt_secret"`
 		HMACSecret    string `mapstructure:"hmac_secret"`
 		EncryptionKey string `mapstructure:"encryption_key"`
+		PINKey        string `mapstructure:"pin_key"`
+		// Ключ HMAC-индекса номеров карт для поиска карты получателя перевода
+		PANIndexKey string `mapstructure:"pan_index_key"`
+		// Версионированные ключи шифрования карт: идентификатор -> ключ
+		EncryptionKeys map[string]string `mapstructure:"encryption_keys"`
+		ActiveKeyID    string            `mapstructure:"active_key_id"`
+		KeyringFile    string            `mapstructure:"keyring_file"`
+		// Сроки жизни токенов доступа и обновления, например 15m и 720h
+		AccessTokenTTL  time.Duration `mapstructure:"access_token_ttl"`
+		RefreshTokenTTL time.Duration `mapstructure:"refresh_token_ttl"`
+
+		// Подпись JWT: HS256 (jwt_secret), RS256 или EdDSA (ключи в БД с ротацией)
+		JWTAlgorithm   string        `mapstructure:"jwt_algorithm"`
+		JWTIssuer      string        `mapstructure:"jwt_issuer"`
+		JWTAudience    string        `mapstructure:"jwt_audience"`
+		JWTKeyRotation time.Duration `mapstructure:"jwt_key_rotation"`
+		// Момент в RFC 3339, до которого при RS256/EdDSA принимаются старые токены HS256
+		JWTHS256AcceptUntil string `mapstructure:"jwt_hs256_accept_until"`
+	}
+	// Политика паролей; нулевые значения заменяются значениями по умолчанию
+	Password struct {
+		MinLength      int `mapstructure:"min_length"`
+		MinCharClasses int `mapstructure:"min_char_classes"`
+		// Каталог с локальной копией базы утёкших паролей (файлы <префикс SHA-1>.txt)
+		BreachedDir string `mapstructure:"breached_dir"`
+	}
+	// Трассировка OpenTelemetry; без endpoint спаны не экспортируются
+	Tracing struct {
+		// Адрес OTLP/HTTP-коллектора, например http://localhost:4318
+		Endpoint    string
+		ServiceName string  `mapstructure:"service_name"`
+		SampleRatio float64 `mapstructure:"sample_ratio"`
 	}
 	SMTP struct {
 		Host string
@@ -23,6 +78,13 @@ type Config struct {
 	}
 }
 
+// RouteTimeout — дедлайн для маршрута; Route задаётся как "МЕТОД шаблон" или шаблон chi,
+// например "POST /transfer" или "/accounts/{accountId}/predict"
+type RouteTimeout struct {
+	Route   string
+	Timeout time.Duration
+}
+
 func LoadConfig(path string) (*Config, error) {
 	viper.SetConfigName("config")
 	viper.SetConfigType("yaml")
@@ -1,11 +1,7 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
-	"errors"
-	"github.com/go-chi/chi/v5"
-	"github.com/sirupsen/logrus"
 	"go_project/internal/services"
 	"net/http"
 )
@@ -21,10 +17,9 @@ func NewAccountHandler(service *services.AccountService) *AccountHandler {
 // CreateAccount обрабатывает POST /accounts (создание нового счета).
 func (h *AccountHandler) CreateAccount(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value("userID").(string)
-	account, err := h.service.CreateAccount(userID)
+	account, err := h.service.CreateAccount(r.Context(), userID)
 	if err != nil {
-		logrus.Error("Failed to create account: ", err)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		writeError(w, r, err, "create account")
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -35,19 +30,14 @@ func (h *AccountHandler) CreateAccount(w http.ResponseWriter, r *http.Request) {
 // GetBalance обрабатывает GET /accounts/{accountId}/balance (просмотр баланса).
 func (h *AccountHandler) GetBalance(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value("userID").(string)
-	accountID := chi.URLParam(r, "accountId")
+	accountID, ok := uuidParam(w, r, "accountId")
+	if !ok {
+		return
+	}
 
-	balance, err := h.service.GetBalance(userID, accountID)
+	balance, err := h.service.GetBalance(r.Context(), userID, accountID)
 	if err != nil {
-		if errors.Is(err, services.ErrForbidden) {
-			http.Error(w, "Forbidden", http.StatusForbidden)
-			return
-		}
-		if errors.Is(err, sql.ErrNoRows) {
-			http.Error(w, "Not found", http.StatusNotFound)
-			return
-		}
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		writeError(w, r, err, "get balance")
 		return
 	}
 
@@ -58,17 +48,13 @@ func (h *AccountHandler) GetBalance(w http.ResponseWriter, r *http.Request) {
 // PredictBalance обрабатывает GET /accounts/{accountId}/predict (прогноз баланса).
 func (h *AccountHandler) PredictBalance(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value("userID").(string)
-	accountID := chi.URLParam(r, "accountId")
-	predicted, rate, err := h.service.PredictBalance(userID, accountID)
+	accountID, ok := uuidParam(w, r, "accountId")
+	if !ok {
+		return
+	}
+	predicted, rate, err := h.service.PredictBalance(r.Context(), userID, accountID)
 	if err != nil {
-		if errors.Is(err, services.ErrForbidden) {
-			http.Error(w, "Forbidden", http.StatusForbidden)
-		} else if errors.Is(err, services.ErrAccountNotFound) {
-			http.Error(w, "Account not found", http.StatusNotFound)
-		} else {
-			logrus.Error("Failed to predict the balance: ", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-		}
+		writeError(w, r, err, "predict the balance")
 		return
 	}
 	resp := map[string]interface{}{
@@ -78,3 +64,26 @@ func (h *AccountHandler) PredictBalance(w http.ResponseWriter, r *http.Request)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(resp)
 }
+
+// ListAccounts обрабатывает GET /partner/accounts (список счетов пользователя).
+func (h *AccountHandler) ListAccounts(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	accounts, err := h.service.ListAccounts(r.Context(), userID)
+	if err != nil {
+		writeError(w, r, err, "list accounts")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(accounts)
+}
+
+// KeyRate обрабатывает GET /partner/key-rate (текущая ключевая ставка ЦБ РФ).
+func (h *AccountHandler) KeyRate(w http.ResponseWriter, r *http.Request) {
+	rate, err := h.service.KeyRate(r.Context())
+	if err != nil {
+		writeError(w, r, err, "get the key rate")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]float64{"key_rate": rate})
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"encoding/json"
+	"go_project/internal/services"
+	"net/http"
+	"strconv"
+)
+
+type AdminHandler struct {
+	service     *services.AdminService
+	keyRotation *services.CardKeyRotationService
+}
+
+func NewAdminHandler(service *services.AdminService, keyRotation *services.CardKeyRotationService) *AdminHandler {
+	return &AdminHandler{service: service, keyRotation: keyRotation}
+}
+
+// adminActionRequest — код причины обязателен для любого изменения, комментарий — по желанию
+type adminActionRequest struct {
+	ReasonCode string `json:"reason_code" validate:"required"`
+	Comment    string `json:"comment" validate:"max=1000"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// SearchUsers обрабатывает GET /admin/users?q= (поиск клиентов по id, email или имени).
+func (h *AdminHandler) SearchUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := h.service.SearchUsers(r.Context(), r.URL.Query().Get("q"))
+	if err != nil {
+		writeError(w, r, err, "search users")
+		return
+	}
+	writeJSON(w, http.StatusOK, users)
+}
+
+// GetUser обрабатывает GET /admin/users/{userId} (данные клиента и его счета).
+func (h *AdminHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := uuidParam(w, r, "userId")
+	if !ok {
+		return
+	}
+	overview, err := h.service.GetCustomer(r.Context(), userID)
+	if err != nil {
+		writeError(w, r, err, "get customer")
+		return
+	}
+	writeJSON(w, http.StatusOK, overview)
+}
+
+// SetRole обрабатывает PUT /admin/users/{userId}/role (назначение роли).
+func (h *AdminHandler) SetRole(w http.ResponseWriter, r *http.Request) {
+	actorID := r.Context().Value("userID").(string)
+	userID, ok := uuidParam(w, r, "userId")
+	if !ok {
+		return
+	}
+	var req struct {
+		Role string `json:"role" validate:"required,oneof=customer operator admin auditor"`
+		adminActionRequest
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	action, err := h.service.SetRole(r.Context(), actorID, userID, req.Role, req.ReasonCode, req.Comment)
+	if err != nil {
+		writeError(w, r, err, "set role")
+		return
+	}
+	writeJSON(w, http.StatusOK, action)
+}
+
+// GetAccount обрабатывает GET /admin/accounts/{accountId} (просмотр любого счёта).
+func (h *AdminHandler) GetAccount(w http.ResponseWriter, r *http.Request) {
+	accountID, ok := uuidParam(w, r, "accountId")
+	if !ok {
+		return
+	}
+	acc, err := h.service.GetAccount(r.Context(), accountID)
+	if err != nil {
+		writeError(w, r, err, "get account")
+		return
+	}
+	writeJSON(w, http.StatusOK, acc)
+}
+
+// FreezeAccount обрабатывает POST /admin/accounts/{accountId}/freeze (заморозка счёта).
+func (h *AdminHandler) FreezeAccount(w http.ResponseWriter, r *http.Request) {
+	h.setFrozen(w, r, true)
+}
+
+// UnfreezeAccount обрабатывает POST /admin/accounts/{accountId}/unfreeze (снятие заморозки).
+func (h *AdminHandler) UnfreezeAccount(w http.ResponseWriter, r *http.Request) {
+	h.setFrozen(w, r, false)
+}
+
+func (h *AdminHandler) setFrozen(w http.ResponseWriter, r *http.Request, frozen bool) {
+	actorID := r.Context().Value("userID").(string)
+	accountID, ok := uuidParam(w, r, "accountId")
+	if !ok {
+		return
+	}
+	var req adminActionRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	action, err := h.service.SetAccountFrozen(r.Context(), actorID, accountID, frozen, req.ReasonCode, req.Comment)
+	if err != nil {
+		writeError(w, r, err, "change account freeze")
+		return
+	}
+	writeJSON(w, http.StatusOK, action)
+}
+
+// AdjustBalance обрабатывает POST /admin/accounts/{accountId}/adjust (корректировка баланса).
+func (h *AdminHandler) AdjustBalance(w http.ResponseWriter, r *http.Request) {
+	actorID := r.Context().Value("userID").(string)
+	accountID, ok := uuidParam(w, r, "accountId")
+	if !ok {
+		return
+	}
+	var req struct {
+		Amount float64 `json:"amount" validate:"required,min=-9999999999999.99,max=9999999999999.99,decimals=2"`
+		adminActionRequest
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	action, err := h.service.AdjustBalance(r.Context(), actorID, accountID, req.Amount, req.ReasonCode, req.Comment)
+	if err != nil {
+		writeError(w, r, err, "adjust balance")
+		return
+	}
+	writeJSON(w, http.StatusCreated, action)
+}
+
+// GetCredit обрабатывает GET /admin/credits/{creditId} (кредит и график платежей).
+func (h *AdminHandler) GetCredit(w http.ResponseWriter, r *http.Request) {
+	creditID, ok := uuidParam(w, r, "creditId")
+	if !ok {
+		return
+	}
+	overview, err := h.service.GetCredit(r.Context(), creditID)
+	if err != nil {
+		writeError(w, r, err, "get credit")
+		return
+	}
+	writeJSON(w, http.StatusOK, overview)
+}
+
+// ListActions обрабатывает GET /admin/actions?target_id=&limit=&offset= (журнал действий сотрудников).
+func (h *AdminHandler) ListActions(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	offset, _ := strconv.Atoi(q.Get("offset"))
+	actions, err := h.service.ListActions(r.Context(), q.Get("target_id"), limit, offset)
+	if err != nil {
+		writeError(w, r, err, "list admin actions")
+		return
+	}
+	writeJSON(w, http.StatusOK, actions)
+}
+
+// KeyRotationProgress обрабатывает GET /admin/card-keys/progress (ход перешифрования карт, ключей JWT и TOTP-секретов).
+func (h *AdminHandler) KeyRotationProgress(w http.ResponseWriter, r *http.Request) {
+	progress, err := h.keyRotation.Progress(r.Context())
+	if err != nil {
+		writeError(w, r, err, "get key rotation progress")
+		return
+	}
+	writeJSON(w, http.StatusOK, progress)
+}
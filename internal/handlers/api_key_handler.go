@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"go_project/internal/models"
+	"go_project/internal/services"
+	"net/http"
+)
+
+type APIKeyHandler struct {
+	service *services.APIKeyService
+}
+
+func NewAPIKeyHandler(service *services.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{service: service}
+}
+
+// CreateKey обрабатывает POST /api-keys (создание персонального API-ключа).
+// Ключ возвращается только в этом ответе.
+func (h *APIKeyHandler) CreateKey(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	var req struct {
+		Name          string   `json:"name" validate:"required,max=100"`
+		Scopes        []string `json:"scopes" validate:"required,max=20"`
+		AllowedIPs    []string `json:"allowed_ips" validate:"max=50"`
+		ExpiresInDays int      `json:"expires_in_days" validate:"min=0,max=365"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	apiKey, key, err := h.service.CreateKey(r.Context(), userID, req.Name, req.Scopes, req.AllowedIPs, req.ExpiresInDays)
+	if err != nil {
+		writeError(w, r, err, "create API key")
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusCreated, struct {
+		*models.APIKey
+		Key string `json:"key"`
+	}{apiKey, key})
+}
+
+// ListKeys обрабатывает GET /api-keys (ключи пользователя без секретов).
+func (h *APIKeyHandler) ListKeys(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	keys, err := h.service.ListKeys(r.Context(), userID)
+	if err != nil {
+		writeError(w, r, err, "list API keys")
+		return
+	}
+	writeJSON(w, http.StatusOK, keys)
+}
+
+// RevokeKey обрабатывает DELETE /api-keys/{keyId} (отзыв ключа).
+func (h *APIKeyHandler) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	keyID, ok := uuidParam(w, r, "keyId")
+	if !ok {
+		return
+	}
+	if err := h.service.RevokeKey(r.Context(), userID, keyID); err != nil {
+		writeError(w, r, err, "revoke API key")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
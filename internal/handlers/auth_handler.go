@@ -2,9 +2,11 @@ package handlers
 
 import (
 	"encoding/json"
-	"errors"
+	"go_project/internal/models"
 	"go_project/internal/services"
+	"net"
 	"net/http"
+	"time"
 )
 
 type AuthHandler struct {
@@ -18,22 +20,16 @@ func NewAuthHandler(service *services.AuthService) *AuthHandler {
 // Register обрабатывает POST /register (регистрацию пользователя).
 func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		Email    string `json:"email"`
-		Username string `json:"username"`
-		Password string `json:"password"`
+		Email    string `json:"email" validate:"required,max=254"`
+		Username string `json:"username" validate:"required"`
+		Password string `json:"password" validate:"required"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Bad Request", http.StatusBadRequest)
+	if !decodeJSON(w, r, &req) {
 		return
 	}
-	user, err := h.service.RegisterUser(req.Email, req.Username, req.Password)
+	user, err := h.service.RegisterUser(r.Context(), req.Email, req.Username, req.Password)
 	if err != nil {
-		switch {
-		case errors.Is(err, services.ErrEmailInUse), errors.Is(err, services.ErrUsernameTaken):
-			http.Error(w, err.Error(), http.StatusBadRequest)
-		default:
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-		}
+		writeError(w, r, err, "register user")
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -44,18 +40,276 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 // Login обрабатывает POST /login (аутентификацию и выдачу JWT).
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email      string `json:"email" validate:"required,max=254"`
+		Password   string `json:"password" validate:"required"`
+		DeviceName string `json:"device_name" validate:"max=100"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	result, err := h.service.LoginUser(r.Context(), req.Email, req.Password, deviceInfo(r, req.DeviceName))
+	if err != nil {
+		writeError(w, r, err, "log in")
+		return
+	}
+	// При включённой двухфакторной аутентификации вместо токенов возвращается mfa_token для LoginSecondFactor
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// clientIP возвращает IP-адрес клиента без порта.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// deviceInfo собирает данные устройства клиента для сессии; name — необязательное название из запроса.
+func deviceInfo(r *http.Request, name string) models.DeviceInfo {
+	return models.DeviceInfo{Name: name, IP: clientIP(r), UserAgent: r.UserAgent()}
+}
+
+// codeRequest — код из приложения-аутентификатора там, где резервный код не принимается
+type codeRequest struct {
+	Code string `json:"code" validate:"required,max=16"`
+}
+
+// secondFactorRequest — код из приложения-аутентификатора или одноразовый резервный код
+type secondFactorRequest struct {
+	Code         string `json:"code" validate:"max=16"`
+	RecoveryCode string `json:"recovery_code" validate:"max=32"`
+}
+
+// LoginSecondFactor обрабатывает POST /login/2fa (второй шаг входа при включённой 2FA).
+func (h *AuthHandler) LoginSecondFactor(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		MFAToken   string `json:"mfa_token" validate:"required"`
+		DeviceName string `json:"device_name" validate:"max=100"`
+		secondFactorRequest
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Bad Request", http.StatusBadRequest)
+	if !decodeJSON(w, r, &req) {
 		return
 	}
-	token, err := h.service.LoginUser(req.Email, req.Password)
+	tokens, err := h.service.CompleteLogin(r.Context(), req.MFAToken, req.Code, req.RecoveryCode, deviceInfo(r, req.DeviceName))
 	if err != nil {
-		http.Error(w, "Invalid token", http.StatusUnauthorized)
+		writeError(w, r, err, "complete login")
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"token": token})
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// EnrollTOTP обрабатывает POST /2fa/enroll (выдачу секрета и URI для QR-кода).
+func (h *AuthHandler) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	secret, uri, err := h.service.EnrollTOTP(r.Context(), userID)
+	if err != nil {
+		writeError(w, r, err, "enroll TOTP")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]string{
+		"secret":           secret,
+		"provisioning_uri": uri,
+	})
+}
+
+// ConfirmTOTP обрабатывает POST /2fa/confirm (включение 2FA по первому коду и выдачу резервных кодов).
+func (h *AuthHandler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	var req codeRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	codes, err := h.service.ConfirmTOTP(r.Context(), userID, req.Code)
+	if err != nil {
+		writeError(w, r, err, "confirm TOTP")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string][]string{"recovery_codes": codes})
+}
+
+// DisableTOTP обрабатывает POST /2fa/disable (отключение 2FA по коду или резервному коду).
+func (h *AuthHandler) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	var req secondFactorRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if err := h.service.DisableTOTP(r.Context(), userID, req.Code, req.RecoveryCode); err != nil {
+		writeError(w, r, err, "disable TOTP")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RegenerateRecoveryCodes обрабатывает POST /2fa/recovery-codes (выдачу нового набора резервных кодов).
+func (h *AuthHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	var req codeRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	codes, err := h.service.RegenerateRecoveryCodes(r.Context(), userID, req.Code)
+	if err != nil {
+		writeError(w, r, err, "regenerate recovery codes")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string][]string{"recovery_codes": codes})
+}
+
+// StepUp обрабатывает POST /2fa/step-up (подтверждение вторым фактором перед чувствительной операцией).
+func (h *AuthHandler) StepUp(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	var req secondFactorRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	token, expiresIn, err := h.service.StepUp(r.Context(), userID, req.Code, req.RecoveryCode)
+	if err != nil {
+		writeError(w, r, err, "verify step-up")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"step_up_token": token,
+		"expires_in":    expiresIn,
+	})
+}
+
+// Refresh обрабатывает POST /token/refresh (обмен токена обновления на новую пару токенов).
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RefreshToken string `json:"refresh_token" validate:"required"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	tokens, err := h.service.RefreshTokens(r.Context(), req.RefreshToken, deviceInfo(r, ""))
+	if err != nil {
+		writeError(w, r, err, "refresh tokens")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
+}
+
+// Logout обрабатывает POST /logout (отзыв текущего токена доступа и токена обновления).
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	tokenID := r.Context().Value("tokenID").(string)
+	sessionID := r.Context().Value("sessionID").(string)
+	expiresAt := r.Context().Value("tokenExpiresAt").(time.Time)
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	// Тело запроса необязательно: без него отзывается только токен доступа
+	if r.ContentLength != 0 {
+		if !decodeJSON(w, r, &req) {
+			return
+		}
+	}
+	if err := h.service.Logout(r.Context(), userID, tokenID, sessionID, expiresAt, req.RefreshToken); err != nil {
+		writeError(w, r, err, "log out")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LogoutAll обрабатывает POST /logout/all (завершение всех сессий пользователя).
+func (h *AuthHandler) LogoutAll(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	if err := h.service.LogoutAll(r.Context(), userID); err != nil {
+		writeError(w, r, err, "log out everywhere")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListSessions обрабатывает GET /sessions (активные сессии пользователя).
+func (h *AuthHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	sessionID := r.Context().Value("sessionID").(string)
+	sessions, err := h.service.ListSessions(r.Context(), userID, sessionID)
+	if err != nil {
+		writeError(w, r, err, "list sessions")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sessions)
+}
+
+// RevokeSession обрабатывает DELETE /sessions/{sessionId} (завершение сессии на другом устройстве).
+func (h *AuthHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	sessionID, ok := uuidParam(w, r, "sessionId")
+	if !ok {
+		return
+	}
+	if err := h.service.RevokeSession(r.Context(), userID, sessionID); err != nil {
+		writeError(w, r, err, "revoke session")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// VerifyEmail обрабатывает POST /verify-email (подтверждение адреса по токену из письма).
+func (h *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token string `json:"token" validate:"required"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if err := h.service.VerifyEmail(r.Context(), req.Token); err != nil {
+		writeError(w, r, err, "verify email")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ResendVerification обрабатывает POST /verify-email/resend (повторную отправку письма с подтверждением).
+func (h *AuthHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	if err := h.service.ResendVerification(r.Context(), userID); err != nil {
+		writeError(w, r, err, "resend verification email")
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ForgotPassword обрабатывает POST /password/forgot (запрос ссылки для сброса пароля).
+// Ответ всегда 202, чтобы нельзя было проверить, зарегистрирован ли адрес.
+func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email string `json:"email" validate:"required,max=254"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	h.service.RequestPasswordReset(r.Context(), req.Email)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ResetPassword обрабатывает POST /password/reset (установку нового пароля по токену из письма).
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token    string `json:"token" validate:"required"`
+		Password string `json:"password" validate:"required"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if err := h.service.ResetPassword(r.Context(), req.Token, req.Password); err != nil {
+		writeError(w, r, err, "reset password")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
 }
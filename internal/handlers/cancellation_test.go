@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"go_project/internal/middleware"
+	"go_project/internal/repositories"
+	"go_project/internal/services"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// blockingDriver — драйвер БД, запросы которого не завершаются, пока не отменён их контекст.
+// Каналы started и canceled позволяют тесту дождаться начала запроса и узнать, чем он завершился.
+type blockingDriver struct {
+	started  chan struct{}
+	canceled chan error
+}
+
+func newBlockingDB(t *testing.T) (*sql.DB, *blockingDriver) {
+	d := &blockingDriver{started: make(chan struct{}, 1), canceled: make(chan error, 1)}
+	db := sql.OpenDB(d)
+	t.Cleanup(func() { db.Close() })
+	return db, d
+}
+
+func (d *blockingDriver) Connect(context.Context) (driver.Conn, error) {
+	return &blockingConn{d: d}, nil
+}
+func (d *blockingDriver) Driver() driver.Driver            { return d }
+func (d *blockingDriver) Open(string) (driver.Conn, error) { return &blockingConn{d: d}, nil }
+
+type blockingConn struct {
+	d *blockingDriver
+}
+
+func (c *blockingConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *blockingConn) Close() error                        { return nil }
+func (c *blockingConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (c *blockingConn) QueryContext(ctx context.Context, _ string, _ []driver.NamedValue) (driver.Rows, error) {
+	c.d.started <- struct{}{}
+	select {
+	case <-ctx.Done():
+	case <-time.After(10 * time.Second):
+		// Контекст не был отменён: завершаем запрос, чтобы тест упал, а не завис
+		return nil, errors.New("query was not cancelled")
+	}
+	c.d.canceled <- ctx.Err()
+	return nil, ctx.Err()
+}
+
+// balanceRouter обслуживает GET /accounts/{accountId}/balance от имени пользователя user-1 поверх db.
+func balanceRouter(db *sql.DB, routeTimeouts map[string]time.Duration) chi.Router {
+	handler := NewAccountHandler(services.NewAccountService(repositories.NewAccountRepository(db), nil))
+	r := chi.NewRouter()
+	r.Use(middleware.Deadlines(r, 0, routeTimeouts))
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), "userID", "user-1")))
+		})
+	})
+	r.Get("/accounts/{accountId}/balance", handler.GetBalance)
+	return r
+}
+
+func waitQueryStarted(t *testing.T, d *blockingDriver) {
+	t.Helper()
+	select {
+	case <-d.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("query was not started")
+	}
+}
+
+func TestClientDisconnectCancelsQuery(t *testing.T) {
+	db, d := newBlockingDB(t)
+	srv := httptest.NewServer(balanceRouter(db, nil))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/accounts/00000000-0000-0000-0000-000000000001/balance", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if resp, err := srv.Client().Do(req); err == nil {
+			resp.Body.Close()
+		}
+	}()
+
+	waitQueryStarted(t, d)
+	cancel()
+	select {
+	case err := <-d.canceled:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("query finished with %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("query kept running after the client disconnected")
+	}
+	<-done
+}
+
+func TestRouteDeadlineCancelsQuery(t *testing.T) {
+	for _, key := range []string{"GET /accounts/{accountId}/balance", "/accounts/{accountId}/balance"} {
+		t.Run(key, func(t *testing.T) {
+			db, d := newBlockingDB(t)
+			router := balanceRouter(db, map[string]time.Duration{key: 50 * time.Millisecond})
+
+			rec := httptest.NewRecorder()
+			finished := make(chan struct{})
+			go func() {
+				defer close(finished)
+				router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/accounts/00000000-0000-0000-0000-000000000001/balance", nil))
+			}()
+
+			waitQueryStarted(t, d)
+			select {
+			case err := <-d.canceled:
+				if !errors.Is(err, context.DeadlineExceeded) {
+					t.Fatalf("query finished with %v, want context.DeadlineExceeded", err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("query was not cancelled by the route deadline")
+			}
+			<-finished
+			if rec.Code == http.StatusOK {
+				t.Fatalf("got status %d for a cancelled query", rec.Code)
+			}
+		})
+	}
+}
+
+func TestDeadlineAppliesOnlyToConfiguredRoute(t *testing.T) {
+	db, d := newBlockingDB(t)
+	router := balanceRouter(db, map[string]time.Duration{"POST /transfer": 10 * time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "/accounts/00000000-0000-0000-0000-000000000001/balance", nil).WithContext(ctx)
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+
+	waitQueryStarted(t, d)
+	select {
+	case err := <-d.canceled:
+		t.Fatalf("query was cancelled with %v by another route's deadline", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	cancel()
+	<-d.canceled
+	<-finished
+}
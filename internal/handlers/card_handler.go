@@ -2,10 +2,10 @@ package handlers
 
 import (
 	"encoding/json"
-	"errors"
-	"github.com/sirupsen/logrus"
+	"go_project/internal/models"
 	"go_project/internal/services"
 	"net/http"
+	"time"
 )
 
 type CardHandler struct {
@@ -20,30 +20,204 @@ func NewCardHandler(service *services.CardService) *CardHandler {
 func (h *CardHandler) CreateCard(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value("userID").(string)
 	var req struct {
-		AccountID string `json:"account_id"`
+		AccountID string `json:"account_id" validate:"required,uuid"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Bad Request", http.StatusBadRequest)
+	if !decodeJSON(w, r, &req) {
 		return
 	}
-	cardNum, expiry, cvv, err := h.service.CreateCard(userID, req.AccountID)
+	card, err := h.service.CreateCard(r.Context(), userID, req.AccountID)
 	if err != nil {
-		if errors.Is(err, services.ErrForbidden) {
-			http.Error(w, "Forbidden", http.StatusForbidden)
-		} else if errors.Is(err, services.ErrAccountNotFound) {
-			http.Error(w, "Account not found", http.StatusNotFound)
-		} else {
-			logrus.Error("Failed to create card: ", err)
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
-		}
+		writeError(w, r, err, "create card")
 		return
 	}
-	response := map[string]string{
-		"card_number": cardNum,
-		"expiry":      expiry,
-		"cvv":         cvv,
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(card)
+}
+
+// CreateVirtualCard обрабатывает POST /cards/virtual (выпуск одноразовой или привязываемой к продавцу карты).
+func (h *CardHandler) CreateVirtualCard(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	var req struct {
+		AccountID  string  `json:"account_id" validate:"required,uuid"`
+		Type       string  `json:"type" validate:"required,oneof=single_use merchant_locked"`
+		AmountCap  float64 `json:"amount_cap" validate:"min=0,max=9999999999999.99,decimals=2"`
+		TTLMinutes int     `json:"ttl_minutes" validate:"min=0"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	ttl := time.Duration(req.TTLMinutes) * time.Minute
+	card, err := h.service.CreateVirtualCard(r.Context(), userID, req.AccountID, req.Type, req.AmountCap, ttl)
+	if err != nil {
+		writeError(w, r, err, "create virtual card")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(card)
+}
+
+// RevealCard обрабатывает GET /cards/{cardId}/pan (показ полного номера карты, требует подтверждения вторым фактором).
+func (h *CardHandler) RevealCard(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	cardID, ok := uuidParam(w, r, "cardId")
+	if !ok {
+		return
+	}
+	details, err := h.service.RevealCard(r.Context(), userID, cardID)
+	if err != nil {
+		writeError(w, r, err, "reveal card")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(details)
+}
+
+// GetLimits обрабатывает GET /cards/{cardId}/limits (лимиты и настройки каналов карты).
+func (h *CardHandler) GetLimits(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	cardID, ok := uuidParam(w, r, "cardId")
+	if !ok {
+		return
+	}
+	limits, err := h.service.GetLimits(r.Context(), userID, cardID)
+	if err != nil {
+		writeError(w, r, err, "get card limits")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(limits)
+}
+
+// UpdateLimits обрабатывает PUT /cards/{cardId}/limits (изменение лимитов и настроек каналов карты).
+func (h *CardHandler) UpdateLimits(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	cardID, ok := uuidParam(w, r, "cardId")
+	if !ok {
+		return
+	}
+	var req struct {
+		DailyLimit          float64 `json:"daily_limit" validate:"min=0,max=9999999999999.99,decimals=2"`
+		MonthlyLimit        float64 `json:"monthly_limit" validate:"min=0,max=9999999999999.99,decimals=2"`
+		PerTransactionLimit float64 `json:"per_transaction_limit" validate:"min=0,max=9999999999999.99,decimals=2"`
+		OnlineEnabled       bool    `json:"online_enabled"`
+		ContactlessEnabled  bool    `json:"contactless_enabled"`
+		ATMEnabled          bool    `json:"atm_enabled"`
+		ForeignEnabled      bool    `json:"foreign_enabled"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	limits, err := h.service.UpdateLimits(r.Context(), userID, models.CardLimits{
+		CardID:              cardID,
+		DailyLimit:          req.DailyLimit,
+		MonthlyLimit:        req.MonthlyLimit,
+		PerTransactionLimit: req.PerTransactionLimit,
+		OnlineEnabled:       req.OnlineEnabled,
+		ContactlessEnabled:  req.ContactlessEnabled,
+		ATMEnabled:          req.ATMEnabled,
+		ForeignEnabled:      req.ForeignEnabled,
+	})
+	if err != nil {
+		writeError(w, r, err, "update card limits")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(limits)
+}
+
+// Authorize обрабатывает POST /cards/{cardId}/authorize (авторизация операции по карте).
+func (h *CardHandler) Authorize(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	cardID, ok := uuidParam(w, r, "cardId")
+	if !ok {
+		return
+	}
+	var req struct {
+		Amount   float64 `json:"amount" validate:"min=0.01,max=9999999999999.99,decimals=2"`
+		Channel  string  `json:"channel" validate:"required,oneof=pos online contactless atm"`
+		Merchant string  `json:"merchant" validate:"max=255"`
+		Country  string  `json:"country" validate:"max=3"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	auth, err := h.service.Authorize(r.Context(), userID, models.CardAuthorization{
+		CardID:   cardID,
+		Amount:   req.Amount,
+		Channel:  req.Channel,
+		Merchant: req.Merchant,
+		Country:  req.Country,
+	})
+	if err != nil {
+		writeError(w, r, err, "authorize card operation")
+		return
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(response)
+	json.NewEncoder(w).Encode(auth)
+}
+
+// SetPIN обрабатывает PUT /cards/{cardId}/pin (установка PIN карты).
+func (h *CardHandler) SetPIN(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	cardID, ok := uuidParam(w, r, "cardId")
+	if !ok {
+		return
+	}
+	var req struct {
+		PIN string `json:"pin" validate:"required"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if err := h.service.SetPIN(r.Context(), userID, cardID, req.PIN); err != nil {
+		writeError(w, r, err, "set card PIN")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ChangePIN обрабатывает POST /cards/{cardId}/pin/change (смена PIN с проверкой текущего).
+func (h *CardHandler) ChangePIN(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	cardID, ok := uuidParam(w, r, "cardId")
+	if !ok {
+		return
+	}
+	var req struct {
+		OldPIN string `json:"old_pin" validate:"required"`
+		NewPIN string `json:"new_pin" validate:"required"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if err := h.service.ChangePIN(r.Context(), userID, cardID, req.OldPIN, req.NewPIN); err != nil {
+		writeError(w, r, err, "change card PIN")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// VerifyPIN обрабатывает POST /cards/{cardId}/pin/verify (проверка PIN карты).
+func (h *CardHandler) VerifyPIN(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	cardID, ok := uuidParam(w, r, "cardId")
+	if !ok {
+		return
+	}
+	var req struct {
+		PIN string `json:"pin" validate:"required"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if err := h.service.VerifyPIN(r.Context(), userID, cardID, req.PIN); err != nil {
+		writeError(w, r, err, "verify card PIN")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"valid": true})
 }
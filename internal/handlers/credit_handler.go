@@ -1,10 +1,7 @@
 package handlers
 
 import (
-	"database/sql"
 	"encoding/json"
-	"errors"
-	"github.com/go-chi/chi/v5"
 	"go_project/internal/services"
 	"net/http"
 )
@@ -20,17 +17,13 @@ func NewCreditHandler(service *services.CreditService) *CreditHandler {
 // GetPaymentSchedule обрабатывает GET /credits/{creditId}/schedule (график платежей по кредиту).
 func (h *CreditHandler) GetPaymentSchedule(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value("userID").(string)
-	creditID := chi.URLParam(r, "creditId")
-	schedule, err := h.service.GetPaymentSchedule(userID, creditID)
+	creditID, ok := uuidParam(w, r, "creditId")
+	if !ok {
+		return
+	}
+	schedule, err := h.service.GetPaymentSchedule(r.Context(), userID, creditID)
 	if err != nil {
-		switch {
-		case errors.Is(err, services.ErrForbidden):
-			http.Error(w, err.Error(), http.StatusForbidden)
-		case errors.Is(err, sql.ErrNoRows):
-			http.Error(w, err.Error(), http.StatusNotFound)
-		default:
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
+		writeError(w, r, err, "get payment schedule")
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -41,26 +34,18 @@ func (h *CreditHandler) GetPaymentSchedule(w http.ResponseWriter, r *http.Reques
 func (h *CreditHandler) CreateCredit(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value("userID").(string)
 	var req struct {
-		AccountID  string  `json:"account_id"`
-		Amount     float64 `json:"amount"`
-		Interest   float64 `json:"interest_rate"`
-		TermMonths int     `json:"term_months"`
+		AccountID  string  `json:"account_id" validate:"required,uuid"`
+		Amount     float64 `json:"amount" validate:"min=1,max=10000000,decimals=2"`
+		Interest   float64 `json:"interest_rate" validate:"min=0,max=100,decimals=2"`
+		TermMonths int     `json:"term_months" validate:"min=1,max=360"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Bad request", http.StatusBadRequest)
+	if !decodeJSON(w, r, &req) {
 		return
 	}
-	credit, err := h.service.CreateCredit(userID, req.AccountID, req.Amount, req.Interest, req.TermMonths)
+	credit, err := h.service.CreateCredit(r.Context(), userID, req.AccountID, req.Amount, req.Interest, req.TermMonths)
 	if err != nil {
-		if errors.Is(err, services.ErrForbidden) {
-			http.Error(w, err.Error(), http.StatusForbidden)
-		} else if errors.Is(err, services.ErrCreditNotFound) {
-			http.Error(w, err.Error(), http.StatusNotFound)
-		} else {
-			http.Error(w, "Internal error", http.StatusInternalServerError)
-		}
+		writeError(w, r, err, "create credit")
 		return
-
 	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
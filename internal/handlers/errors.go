@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"go_project/internal/problem"
+	"go_project/internal/services"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// errorCodes сопоставляет ошибки сервисов кодам ответа. Ошибки проверяются по порядку через errors.Is,
+// поэтому более частные ошибки должны идти раньше общих.
+var errorCodes = []struct {
+	err  error
+	code problem.Code
+}{
+	{services.ErrForbidden, problem.CodeForbidden},
+	{services.ErrEmailNotVerified, problem.CodeEmailNotVerified},
+	{services.ErrStepUpRequired, problem.CodeStepUpRequired},
+
+	{services.ErrEmailInUse, problem.CodeEmailInUse},
+	{services.ErrUsernameTaken, problem.CodeUsernameTaken},
+	{services.ErrTooManyAttempts, problem.CodeTooManyAttempts},
+	{services.ErrInvalidCredentials, problem.CodeInvalidCredentials},
+	{services.ErrInvalidRefreshToken, problem.CodeInvalidRefreshToken},
+	{services.ErrTokenRevoked, problem.CodeInvalidRefreshToken},
+	{services.ErrSessionNotFound, problem.CodeSessionNotFound},
+	{services.ErrInvalidMFACode, problem.CodeInvalidMFACode},
+	{services.ErrInvalidMFAToken, problem.CodeInvalidMFAToken},
+	{services.ErrMFAAlreadyEnabled, problem.CodeMFAAlreadyEnabled},
+	{services.ErrMFANotEnabled, problem.CodeMFANotEnabled},
+	{services.ErrMFANotEnrolled, problem.CodeMFANotEnrolled},
+	{services.ErrInvalidVerificationToken, problem.CodeInvalidVerificationToken},
+	{services.ErrEmailAlreadyVerified, problem.CodeEmailAlreadyVerified},
+	{services.ErrInvalidResetToken, problem.CodeInvalidResetToken},
+	{services.ErrAPIKeyNotFound, problem.CodeAPIKeyNotFound},
+	{services.ErrTooManyAPIKeys, problem.CodeTooManyAPIKeys},
+	{services.ErrConsentNotFound, problem.CodeConsentNotFound},
+
+	{services.ErrAccountNotFound, problem.CodeAccountNotFound},
+	{services.ErrSourceAccountNotFound, problem.CodeSourceAccountNotFound},
+	{services.ErrDestinationAccountNotFound, problem.CodeDestinationAccountNotFound},
+	{services.ErrAccountFrozen, problem.CodeAccountFrozen},
+	{services.ErrDestinationAccountFrozen, problem.CodeDestinationAccountFrozen},
+	{services.ErrInvalidAmount, problem.CodeInvalidAmount},
+	{services.ErrInsufficientFunds, problem.CodeInsufficientFunds},
+	{services.ErrCreditNotFound, problem.CodeCreditNotFound},
+
+	{services.ErrCardNotFound, problem.CodeCardNotFound},
+	{services.ErrDestinationCardNotFound, problem.CodeDestinationCardNotFound},
+	{services.ErrInvalidCardNumber, problem.CodeInvalidCardNumber},
+	{services.ErrVirtualCardTransfer, problem.CodeVirtualCardTransfer},
+	{services.ErrInvalidCardType, problem.CodeInvalidCardType},
+	{services.ErrInvalidCardTTL, problem.CodeInvalidCardTTL},
+	{services.ErrInvalidAmountCap, problem.CodeInvalidAmountCap},
+	{services.ErrAmountCapExceeded, problem.CodeAmountCapExceeded},
+	{services.ErrMerchantMismatch, problem.CodeMerchantMismatch},
+	{services.ErrMerchantRequired, problem.CodeMerchantRequired},
+	{services.ErrInvalidLimits, problem.CodeInvalidLimits},
+	{services.ErrInvalidChannel, problem.CodeInvalidChannel},
+	{services.ErrChannelDisabled, problem.CodeChannelDisabled},
+	{services.ErrCardLimitExceeded, problem.CodeCardLimitExceeded},
+	{services.ErrCardExpired, problem.CodeCardExpired},
+	{services.ErrCardDestroyed, problem.CodeCardDestroyed},
+	{services.ErrCardBlocked, problem.CodeCardBlocked},
+	{services.ErrInvalidPIN, problem.CodeInvalidPIN},
+	{services.ErrPINAlreadySet, problem.CodePINAlreadySet},
+	{services.ErrPINNotSet, problem.CodePINNotSet},
+	{services.ErrIncorrectPIN, problem.CodeIncorrectPIN},
+
+	{services.ErrUserNotFound, problem.CodeUserNotFound},
+	{services.ErrInvalidReasonCode, problem.CodeInvalidReasonCode},
+	{services.ErrInvalidRole, problem.CodeInvalidRole},
+	{services.ErrAlreadyFrozen, problem.CodeAlreadyFrozen},
+	{services.ErrNotFrozen, problem.CodeNotFrozen},
+	{services.ErrEmptySearchQuery, problem.CodeEmptySearchQuery},
+
+	// Репозитории сообщают об отсутствии записи через sql.ErrNoRows
+	{sql.ErrNoRows, problem.CodeNotFound},
+}
+
+// writeError отвечает problem+json с кодом, соответствующим ошибке сервиса. Неизвестные ошибки
+// логируются вместе с описанием действия action, а клиент получает internal_error без подробностей.
+func writeError(w http.ResponseWriter, r *http.Request, err error, action string) {
+	var verr *services.ValidationError
+	if errors.As(err, &verr) {
+		writeValidationError(w, r, verr)
+		return
+	}
+	var loginErr *services.LoginError
+	if errors.As(err, &loginErr) {
+		writeLoginError(w, r, loginErr)
+		return
+	}
+	for _, ec := range errorCodes {
+		if errors.Is(err, ec.err) {
+			problem.Write(w, r, ec.code)
+			return
+		}
+	}
+	logrus.WithContext(r.Context()).Errorf("Failed to %s: %v", action, err)
+	problem.Write(w, r, problem.CodeInternal)
+}
+
+// writeValidationError отвечает 422 со списком ошибок по полям запроса.
+func writeValidationError(w http.ResponseWriter, r *http.Request, verr *services.ValidationError) {
+	fields := make([]problem.FieldError, len(verr.Errors))
+	for i, fe := range verr.Errors {
+		fields[i] = problem.FieldError(fe)
+	}
+	problem.Validation(r, fields).Write(w)
+}
+
+// writeLoginError отвечает 401 при неверных данных и 429 с Retry-After при превышении числа попыток.
+// Флаг captcha_required сообщает клиенту, что перед следующей попыткой нужно показать CAPTCHA.
+func writeLoginError(w http.ResponseWriter, r *http.Request, loginErr *services.LoginError) {
+	code := problem.CodeInvalidCredentials
+	if errors.Is(loginErr, services.ErrTooManyAttempts) {
+		code = problem.CodeTooManyAttempts
+	}
+	p := problem.New(r, code).With("captcha_required", loginErr.CaptchaRequired)
+	if code == problem.CodeTooManyAttempts {
+		retryAfter := int(math.Ceil(loginErr.RetryAfter.Seconds()))
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+		p.With("retry_after", retryAfter)
+	}
+	p.Write(w)
+}
+
+// writeBadRequest отвечает на запрос, который не удалось разобрать или в котором нет обязательных данных.
+func writeBadRequest(w http.ResponseWriter, r *http.Request) {
+	problem.Write(w, r, problem.CodeBadRequest)
+}
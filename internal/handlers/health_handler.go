@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"go_project/internal/migrations"
+	"go_project/internal/services"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// readinessTimeout ограничивает проверки /readyz, чтобы зависшая БД не задерживала ответ пробе
+const readinessTimeout = 2 * time.Second
+
+type HealthHandler struct {
+	db       *sql.DB
+	migrator *migrations.Migrator
+	keyRates *services.KeyRateProvider
+	draining atomic.Bool
+}
+
+func NewHealthHandler(db *sql.DB, migrator *migrations.Migrator, keyRates *services.KeyRateProvider) *HealthHandler {
+	return &HealthHandler{db: db, migrator: migrator, keyRates: keyRates}
+}
+
+// SetDraining переводит сервис в состояние остановки: /readyz начинает отвечать 503,
+// чтобы балансировщик перестал направлять новые запросы, пока обрабатываются текущие.
+func (h *HealthHandler) SetDraining() {
+	h.draining.Store(true)
+}
+
+// Healthz обрабатывает GET /healthz (liveness): процесс запущен и обслуживает HTTP.
+func (h *HealthHandler) Healthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// Readyz обрабатывает GET /readyz (readiness): БД доступна и её схема соответствует приложению.
+// Состояние источника ключевой ставки выводится для информации и не влияет на готовность:
+// без ЦБ недоступен только прогноз баланса.
+func (h *HealthHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+	resp := struct {
+		Status   string                 `json:"status"`
+		Database string                 `json:"database"`
+		Schema   map[string]interface{} `json:"schema"`
+		KeyRate  services.KeyRateStatus `json:"key_rate"`
+	}{
+		Status:   "ok",
+		Database: "ok",
+		Schema:   map[string]interface{}{"version": h.migrator.Latest(), "status": "ok"},
+		KeyRate:  h.keyRates.Status(),
+	}
+	code := http.StatusOK
+	if h.draining.Load() {
+		resp.Status = "shutting down"
+		code = http.StatusServiceUnavailable
+	}
+	// Текст ошибок не выводится: /readyz доступен без аутентификации, подробности пишутся в лог
+	if err := h.db.PingContext(ctx); err != nil {
+		logrus.WithContext(ctx).Warn("Readiness check: database is unavailable: ", err)
+		resp.Status, resp.Database = "unavailable", "unavailable"
+		resp.Schema["status"] = "unknown"
+		code = http.StatusServiceUnavailable
+	} else if err := h.migrator.CheckCurrent(ctx); err != nil {
+		logrus.WithContext(ctx).Warn("Readiness check: database schema is not current: ", err)
+		resp.Status = "unavailable"
+		resp.Schema["status"] = schemaStatus(err)
+		code = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, code, resp)
+}
+
+// schemaStatus сводит ошибку проверки схемы к фиксированному значению для ответа /readyz.
+func schemaStatus(err error) string {
+	switch {
+	case errors.Is(err, migrations.ErrSchemaBehind):
+		return "behind"
+	case errors.Is(err, migrations.ErrDirty):
+		return "modified"
+	default:
+		return "unknown"
+	}
+}
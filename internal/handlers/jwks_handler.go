@@ -0,0 +1,28 @@
+package handlers
+
+import (
+	"encoding/json"
+	"go_project/internal/services"
+	"net/http"
+)
+
+type JWKSHandler struct {
+	service *services.JWTKeyService
+}
+
+func NewJWKSHandler(service *services.JWTKeyService) *JWKSHandler {
+	return &JWKSHandler{service: service}
+}
+
+// JWKS обрабатывает GET /.well-known/jwks.json (открытые ключи для проверки токенов другими сервисами).
+func (h *JWKSHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	set, err := h.service.JWKS(r.Context())
+	if err != nil {
+		writeError(w, r, err, "build JWKS")
+		return
+	}
+	// Новый ключ публикуется за час до использования, поэтому кэш на 5 минут безопасен
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(set)
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"go_project/internal/models"
+	"go_project/internal/services"
+	"net/http"
+)
+
+type OAuthHandler struct {
+	service *services.OAuthService
+}
+
+func NewOAuthHandler(service *services.OAuthService) *OAuthHandler {
+	return &OAuthHandler{service: service}
+}
+
+// writeOAuthError отвечает ошибкой в формате RFC 6749, раздел 5.2.
+func writeOAuthError(w http.ResponseWriter, r *http.Request, err error, action string) {
+	var oerr *services.OAuthError
+	if !errors.As(err, &oerr) {
+		writeError(w, r, err, action)
+		return
+	}
+	status := http.StatusBadRequest
+	if oerr.Code == "invalid_client" {
+		status = http.StatusUnauthorized
+		w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, status, map[string]string{"error": oerr.Code, "error_description": oerr.Description})
+}
+
+func authorizeRequestFromQuery(r *http.Request) models.AuthorizeRequest {
+	q := r.URL.Query()
+	return models.AuthorizeRequest{
+		ResponseType:        q.Get("response_type"),
+		ClientID:            q.Get("client_id"),
+		RedirectURI:         q.Get("redirect_uri"),
+		Scope:               q.Get("scope"),
+		State:               q.Get("state"),
+		CodeChallenge:       q.Get("code_challenge"),
+		CodeChallengeMethod: q.Get("code_challenge_method"),
+	}
+}
+
+// ConsentScreen обрабатывает GET /oauth/authorize (данные для экрана согласия).
+// Параметры запроса авторизации передаются в query string, как их прислал клиент.
+func (h *OAuthHandler) ConsentScreen(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	_, _, screen, err := h.service.ValidateAuthorize(r.Context(), userID, authorizeRequestFromQuery(r))
+	if err != nil {
+		writeOAuthError(w, r, err, "validate authorization request")
+		return
+	}
+	writeJSON(w, http.StatusOK, screen)
+}
+
+// Authorize обрабатывает POST /oauth/authorize (решение пользователя на экране согласия).
+// Возвращает адрес, на который приложение банка перенаправляет пользователя.
+func (h *OAuthHandler) Authorize(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	var req struct {
+		models.AuthorizeRequest
+		Approve bool `json:"approve"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	redirectURI, err := h.service.Authorize(r.Context(), userID, req.AuthorizeRequest, req.Approve)
+	if err != nil {
+		writeOAuthError(w, r, err, "authorize OAuth client")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"redirect_uri": redirectURI})
+}
+
+// Token обрабатывает POST /oauth/token (token endpoint, application/x-www-form-urlencoded).
+// Клиент аутентифицируется через HTTP Basic или параметры client_id и client_secret.
+func (h *OAuthHandler) Token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, r, &services.OAuthError{Code: "invalid_request", Description: "malformed form body"}, "issue OAuth token")
+		return
+	}
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID = r.PostForm.Get("client_id")
+		clientSecret = r.PostForm.Get("client_secret")
+	}
+	var token *models.OAuthToken
+	var err error
+	switch r.PostForm.Get("grant_type") {
+	case models.GrantAuthorizationCode:
+		token, err = h.service.ExchangeCode(r.Context(), clientID, clientSecret, r.PostForm.Get("code"),
+			r.PostForm.Get("redirect_uri"), r.PostForm.Get("code_verifier"))
+	case models.GrantClientCredentials:
+		token, err = h.service.ClientCredentials(r.Context(), clientID, clientSecret, r.PostForm.Get("scope"))
+	default:
+		err = &services.OAuthError{Code: "unsupported_grant_type", Description: "grant_type must be authorization_code or client_credentials"}
+	}
+	if err != nil {
+		writeOAuthError(w, r, err, "issue OAuth token")
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, token)
+}
+
+// ListConsents обрабатывает GET /oauth/consents (приложения, которым пользователь дал доступ).
+func (h *OAuthHandler) ListConsents(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	consents, err := h.service.ListConsents(r.Context(), userID)
+	if err != nil {
+		writeError(w, r, err, "list OAuth consents")
+		return
+	}
+	writeJSON(w, http.StatusOK, consents)
+}
+
+// RevokeConsent обрабатывает DELETE /oauth/consents/{clientId} (отзыв доступа приложения).
+func (h *OAuthHandler) RevokeConsent(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	if err := h.service.RevokeConsent(r.Context(), userID, chi.URLParam(r, "clientId")); err != nil {
+		writeError(w, r, err, "revoke OAuth consent")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RegisterClient обрабатывает POST /admin/oauth/clients (регистрация стороннего приложения).
+// Секрет конфиденциального клиента возвращается только в этом ответе.
+func (h *OAuthHandler) RegisterClient(w http.ResponseWriter, r *http.Request) {
+	var req models.OAuthClient
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	client, secret, err := h.service.RegisterClient(r.Context(), req)
+	if err != nil {
+		writeOAuthError(w, r, err, "register OAuth client")
+		return
+	}
+	resp := struct {
+		*models.OAuthClient
+		ClientSecret string `json:"client_secret,omitempty"`
+	}{client, secret}
+	writeJSON(w, http.StatusCreated, resp)
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"go_project/internal/problem"
+	"go_project/internal/validation"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// maxBodyBytes ограничивает размер JSON-тела запроса
+const maxBodyBytes = 1 << 20
+
+// decodeJSON читает тело запроса в dst и проверяет его по тегам validate. Неизвестные поля, лишние
+// данные после объекта, неверные типы и тело больше maxBodyBytes отклоняются с кодом 400 (413 для
+// размера), нарушения правил validate — с кодом 422. При false ответ уже отправлен.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(dst)
+	if err == nil && dec.Decode(&struct{}{}) != io.EOF {
+		err = errors.New("unexpected data after JSON object")
+	}
+	if err != nil {
+		writeDecodeError(w, r, err)
+		return false
+	}
+	if fields := validation.Struct(dst); len(fields) > 0 {
+		problem.Validation(r, fields).Write(w)
+		return false
+	}
+	return true
+}
+
+func writeDecodeError(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &tooLarge):
+		problem.Write(w, r, problem.CodePayloadTooLarge)
+	case errors.As(err, &typeErr):
+		expected := jsonType(typeErr.Type)
+		problem.New(r, problem.CodeBadRequest).WithErrors([]problem.FieldError{{
+			Field:   typeErr.Field,
+			Code:    "invalid_type",
+			Message: "must be of type " + expected,
+			Params:  map[string]interface{}{"expected": expected},
+		}}).Write(w)
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json не экспортирует тип этой ошибки, имя поля есть только в тексте
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		problem.New(r, problem.CodeBadRequest).WithErrors([]problem.FieldError{{
+			Field: field, Code: "unknown_field", Message: "unknown field",
+		}}).Write(w)
+	default:
+		writeBadRequest(w, r)
+	}
+}
+
+// jsonType называет тип Go так, как он выглядит в JSON.
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Struct, reflect.Map:
+		return "object"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "integer"
+	default:
+		return "number"
+	}
+}
+
+// uuidParam возвращает параметр пути name, если это UUID; иначе отвечает 400 и возвращает false.
+func uuidParam(w http.ResponseWriter, r *http.Request, name string) (string, bool) {
+	value := chi.URLParam(r, name)
+	if !validation.IsUUID(value) {
+		problem.New(r, problem.CodeBadRequest).WithErrors([]problem.FieldError{{
+			Field: name, Code: "invalid_uuid", Message: "must be a UUID",
+		}}).Write(w)
+		return "", false
+	}
+	return value, true
+}
@@ -2,47 +2,49 @@ package handlers
 
 import (
 	"encoding/json"
-	"errors"
-	"github.com/sirupsen/logrus"
+	"go_project/internal/middleware"
 	"go_project/internal/services"
 	"net/http"
 )
 
 type TransactionHandler struct {
 	service *services.TransactionService
+	stepUp  middleware.StepUpChecker
 }
 
-func NewTransactionHandler(service *services.TransactionService) *TransactionHandler {
-	return &TransactionHandler{service: service}
+func NewTransactionHandler(service *services.TransactionService, stepUp middleware.StepUpChecker) *TransactionHandler {
+	return &TransactionHandler{service: service, stepUp: stepUp}
+}
+
+// checkLargeTransfer требует подтверждение вторым фактором для переводов от services.LargeTransferThreshold.
+func (h *TransactionHandler) checkLargeTransfer(w http.ResponseWriter, r *http.Request, userID string, amount float64) bool {
+	if amount < services.LargeTransferThreshold {
+		return true
+	}
+	if err := h.stepUp.CheckStepUp(r.Context(), userID, r.Header.Get(middleware.StepUpHeader)); err != nil {
+		middleware.WriteStepUpError(w, r, err)
+		return false
+	}
+	return true
 }
 
 // Transfer обрабатывает POST /transfer (перевод между счетами).
 func (h *TransactionHandler) Transfer(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value("userID").(string)
 	var req struct {
-		FromAccount string  `json:"from_account"`
-		ToAccount   string  `json:"to_account"`
-		Amount      float64 `json:"amount"`
+		FromAccount string  `json:"from_account" validate:"required,uuid"`
+		ToAccount   string  `json:"to_account" validate:"required,uuid"`
+		Amount      float64 `json:"amount" validate:"min=0.01,max=9999999999999.99,decimals=2"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Bad request", http.StatusBadRequest)
+	if !decodeJSON(w, r, &req) {
 		return
 	}
-	txID, err := h.service.Transfer(userID, req.FromAccount, req.ToAccount, req.Amount)
+	if !h.checkLargeTransfer(w, r, userID, req.Amount) {
+		return
+	}
+	txID, err := h.service.Transfer(r.Context(), userID, req.FromAccount, req.ToAccount, req.Amount)
 	if err != nil {
-		switch {
-		case errors.Is(err, services.ErrForbidden):
-			http.Error(w, err.Error(), http.StatusForbidden)
-		case errors.Is(err, services.ErrInsufficientFunds):
-			http.Error(w, err.Error(), http.StatusBadRequest)
-		case errors.Is(err, services.ErrSourceAccountNotFound) || errors.Is(err, services.ErrDestinationAccountNotFound):
-			http.Error(w, err.Error(), http.StatusNotFound)
-		default:
-			{
-				logrus.Error("Transfer failed: ", err)
-				http.Error(w, "Internal error", http.StatusInternalServerError)
-			}
-		}
+		writeError(w, r, err, "transfer")
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -52,13 +54,40 @@ func (h *TransactionHandler) Transfer(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// CardTransfer обрабатывает POST /transfer/card (перевод с карты на карту по номеру карты получателя).
+func (h *TransactionHandler) CardTransfer(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	var req struct {
+		FromCard     string  `json:"from_card" validate:"required,uuid"`
+		ToCardNumber string  `json:"to_card_number" validate:"required,max=23"`
+		Amount       float64 `json:"amount" validate:"min=0.01,max=9999999999999.99,decimals=2"`
+	}
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if !h.checkLargeTransfer(w, r, userID, req.Amount) {
+		return
+	}
+	txID, fee, err := h.service.TransferByCard(r.Context(), userID, req.FromCard, req.ToCardNumber, req.Amount)
+	if err != nil {
+		writeError(w, r, err, "card transfer")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"transaction_id": txID,
+		"status":         "success",
+		"amount":         req.Amount,
+		"fee":            fee,
+	})
+}
+
 // Analytics обрабатывает GET /analytics (статистика операций).
 func (h *TransactionHandler) Analytics(w http.ResponseWriter, r *http.Request) {
 	userID := r.Context().Value("userID").(string)
-	countSent, totalSent, countReceived, totalreceived, err := h.service.GetAnalytics(userID)
+	countSent, totalSent, countReceived, totalreceived, err := h.service.GetAnalytics(r.Context(), userID)
 	if err != nil {
-		logrus.Error("Failed to retrieve analytics: ", err)
-		http.Error(w, "Internal error", http.StatusInternalServerError)
+		writeError(w, r, err, "retrieve analytics")
 		return
 	}
 	response := map[string]interface{}{
@@ -74,3 +103,15 @@ func (h *TransactionHandler) Analytics(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
+
+// ListTransactions обрабатывает GET /partner/transactions (последние операции пользователя).
+func (h *TransactionHandler) ListTransactions(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value("userID").(string)
+	transactions, err := h.service.ListTransactions(r.Context(), userID)
+	if err != nil {
+		writeError(w, r, err, "list transactions")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transactions)
+}
@@ -0,0 +1,90 @@
+package keyring
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// LegacyKeyID — идентификатор, под которым используется одиночный ключ encryption_key.
+// Им помечены карты, зашифрованные до появления версионирования ключей.
+const LegacyKeyID = "default"
+
+var ErrUnknownKey = errors.New("unknown encryption key")
+
+// Keyring хранит версии ключей шифрования данных карт и идентификатор активного ключа,
+// которым шифруются новые записи. Старые ключи нужны для расшифровки до завершения ротации.
+type Keyring struct {
+	activeID string
+	keys     map[string]string
+}
+
+// New создаёт связку ключей и проверяет, что активный ключ в ней присутствует.
+func New(activeID string, keys map[string]string) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("keyring is empty")
+	}
+	if activeID == "" {
+		if len(keys) != 1 {
+			return nil, errors.New("active key id is not set")
+		}
+		for id := range keys {
+			activeID = id
+		}
+	}
+	if keys[activeID] == "" {
+		return nil, fmt.Errorf("active key %q: %w", activeID, ErrUnknownKey)
+	}
+	copied := make(map[string]string, len(keys))
+	for id, key := range keys {
+		if key == "" {
+			return nil, fmt.Errorf("key %q is empty", id)
+		}
+		copied[id] = key
+	}
+	return &Keyring{activeID: activeID, keys: copied}, nil
+}
+
+// LoadFile читает связку ключей из локального JSON-файла — упрощённой замены KMS:
+//
+//	{"active_key_id": "2025-06", "keys": {"default": "...", "2025-06": "..."}}
+func LoadFile(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		ActiveKeyID string            `json:"active_key_id"`
+		Keys        map[string]string `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse keyring file %s: %w", path, err)
+	}
+	return New(file.ActiveKeyID, file.Keys)
+}
+
+// Active возвращает идентификатор и значение активного ключа.
+func (k *Keyring) Active() (string, string) {
+	return k.activeID, k.keys[k.activeID]
+}
+
+// Key возвращает ключ по идентификатору.
+func (k *Keyring) Key(id string) (string, error) {
+	key, ok := k.keys[id]
+	if !ok {
+		return "", fmt.Errorf("key %q: %w", id, ErrUnknownKey)
+	}
+	return key, nil
+}
+
+// IDs возвращает отсортированный список идентификаторов ключей.
+func (k *Keyring) IDs() []string {
+	ids := make([]string, 0, len(k.keys))
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,76 @@
+// Package logging настраивает logrus: JSON-формат, поля запроса (request_id, user_id, trace_id)
+// из контекста записи и маскирование персональных данных и секретов в сообщениях.
+package logging
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// requestInfoKey — ключ контекста с данными запроса для логов
+const requestInfoKey = "requestInfo"
+
+// RequestInfo — данные запроса, которые попадают в каждую запись лога, сделанную с его контекстом.
+// Пользователь становится известен только после аутентификации, поэтому структура изменяемая:
+// middleware аутентификации заполняет её через SetUserID, а журнал доступа читает после ответа.
+type RequestInfo struct {
+	ID     string
+	UserID string
+}
+
+// Setup включает JSON-формат, уровень level и хуки контекста и маскирования.
+func Setup(level logrus.Level) {
+	logrus.SetFormatter(&logrus.JSONFormatter{})
+	logrus.SetLevel(level)
+	logrus.AddHook(contextHook{})
+	logrus.AddHook(maskHook{})
+}
+
+// WithRequestInfo возвращает контекст с данными запроса.
+func WithRequestInfo(ctx context.Context, info *RequestInfo) context.Context {
+	return context.WithValue(ctx, requestInfoKey, info)
+}
+
+// FromContext возвращает данные запроса или nil, если контекст не относится к HTTP-запросу.
+func FromContext(ctx context.Context) *RequestInfo {
+	info, _ := ctx.Value(requestInfoKey).(*RequestInfo)
+	return info
+}
+
+// RequestID возвращает идентификатор запроса из контекста.
+func RequestID(ctx context.Context) string {
+	if info := FromContext(ctx); info != nil {
+		return info.ID
+	}
+	return ""
+}
+
+// SetUserID запоминает аутентифицированного пользователя для журнала доступа и логов сервисов.
+func SetUserID(ctx context.Context, userID string) {
+	if info := FromContext(ctx); info != nil {
+		info.UserID = userID
+	}
+}
+
+// contextHook добавляет к записям, сделанным через logrus.WithContext, идентификаторы запроса,
+// пользователя и трассировки.
+type contextHook struct{}
+
+func (contextHook) Levels() []logrus.Level { return logrus.AllLevels }
+
+func (contextHook) Fire(entry *logrus.Entry) error {
+	if entry.Context == nil {
+		return nil
+	}
+	if info := FromContext(entry.Context); info != nil {
+		entry.Data["request_id"] = info.ID
+		if info.UserID != "" {
+			entry.Data["user_id"] = info.UserID
+		}
+	}
+	if sc := trace.SpanContextFromContext(entry.Context); sc.IsValid() {
+		entry.Data["trace_id"] = sc.TraceID().String()
+	}
+	return nil
+}
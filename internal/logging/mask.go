@@ -0,0 +1,76 @@
+package logging
+
+import (
+	"github.com/sirupsen/logrus"
+	"regexp"
+	"strings"
+)
+
+var (
+	emailPattern = regexp.MustCompile(`([A-Za-z0-9._%+-])[A-Za-z0-9._%+-]*@([A-Za-z0-9.-]+\.[A-Za-z]{2,})`)
+	// Номер карты: 13–19 цифр, возможно сгруппированных пробелами или дефисами
+	panPattern    = regexp.MustCompile(`\d(?:[ -]?\d){12,18}`)
+	jwtPattern    = regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+	apiKeyPattern = regexp.MustCompile(`kb_[A-Za-z0-9_-]{8,}`)
+	bearerPattern = regexp.MustCompile(`(?i)(Bearer|ApiKey|Basic) [A-Za-z0-9._~+/=-]+`)
+	secretParam   = regexp.MustCompile(`(?i)\b(token|refresh_token|access_token|code|code_verifier|client_secret|password)=[^&\s"]+`)
+)
+
+// Mask скрывает в строке адреса email (остаются первый символ и домен), номера карт (остаются
+// последние 4 цифры), JWT, API-ключи, заголовки авторизации и секретные параметры запросов.
+func Mask(s string) string {
+	if s == "" {
+		return s
+	}
+	// Заголовки авторизации маскируются целиком до JWT и API-ключей, иначе уже замаскированный
+	// ключ повторно попал бы под шаблон заголовка
+	s = bearerPattern.ReplaceAllString(s, "$1 [REDACTED]")
+	s = jwtPattern.ReplaceAllString(s, "[REDACTED_JWT]")
+	s = apiKeyPattern.ReplaceAllString(s, "kb_[REDACTED]")
+	s = secretParam.ReplaceAllString(s, "$1=[REDACTED]")
+	s = emailPattern.ReplaceAllString(s, "$1***@$2")
+	return maskPANs(s)
+}
+
+// maskPANs оставляет от номеров карт последние 4 цифры. Последовательности, примыкающие к буквам,
+// цифрам или дефису (части UUID, хешей, идентификаторов), номерами карт не считаются.
+func maskPANs(s string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range panPattern.FindAllStringIndex(s, -1) {
+		if loc[0] > 0 && isIDChar(s[loc[0]-1]) || loc[1] < len(s) && isIDChar(s[loc[1]]) {
+			continue
+		}
+		digits := strings.NewReplacer(" ", "", "-", "").Replace(s[loc[0]:loc[1]])
+		b.WriteString(s[last:loc[0]])
+		b.WriteString("****" + digits[len(digits)-4:])
+		last = loc[1]
+	}
+	if last == 0 {
+		return s
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+func isIDChar(c byte) bool {
+	return c == '-' || c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// maskHook маскирует сообщение и строковые поля записи перед форматированием.
+type maskHook struct{}
+
+func (maskHook) Levels() []logrus.Level { return logrus.AllLevels }
+
+func (maskHook) Fire(entry *logrus.Entry) error {
+	entry.Message = Mask(entry.Message)
+	for key, value := range entry.Data {
+		switch v := value.(type) {
+		case string:
+			entry.Data[key] = Mask(v)
+		case error:
+			entry.Data[key] = Mask(v.Error())
+		}
+	}
+	return nil
+}
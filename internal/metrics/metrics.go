@@ -0,0 +1,110 @@
+// Package metrics содержит метрики Prometheus приложения: HTTP-запросы, пул соединений с БД
+// и банковские операции. Метрики регистрируются в реестре по умолчанию и отдаются на /metrics.
+package metrics
+
+import (
+	"database/sql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "bank"
+
+// Результаты перевода (метка outcome)
+const (
+	OutcomeSuccess           = "success"
+	OutcomeInvalid           = "invalid"
+	OutcomeForbidden         = "forbidden"
+	OutcomeNotFound          = "not_found"
+	OutcomeInsufficientFunds = "insufficient_funds"
+	OutcomeDeclined          = "declined"
+	OutcomeError             = "error"
+)
+
+// Виды переводов (метка kind)
+const (
+	TransferAccount = "account"
+	TransferCard    = "card"
+)
+
+// Результаты обработки просроченного платежа (метка result)
+const (
+	OverdueAutoPaid         = "auto_paid"
+	OverduePenaltyApplied   = "penalty_applied"
+	OverdueAlreadyPenalized = "already_penalized"
+	OverdueFailed           = "failed"
+)
+
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests by method, chi route pattern and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by method, chi route pattern and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	Transfers = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transfers_total",
+		Help:      "Transfers by kind (account, card) and outcome.",
+	}, []string{"kind", "outcome"})
+
+	TransferredAmount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "transferred_amount_rubles_total",
+		Help:      "Volume of successful transfers in rubles, without card transfer fees.",
+	}, []string{"kind"})
+
+	CreditsIssued = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "credits_issued_total",
+		Help:      "Credits issued.",
+	})
+
+	CreditsIssuedAmount = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "credits_issued_amount_rubles_total",
+		Help:      "Principal of issued credits in rubles.",
+	})
+
+	OverdueInstallments = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "overdue_installments_processed_total",
+		Help:      "Overdue credit installments processed by the scheduler, by result.",
+	}, []string{"result"})
+
+	PenaltiesApplied = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "penalties_applied_total",
+		Help:      "Penalties applied to overdue installments.",
+	})
+
+	PenaltiesAmount = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "penalties_amount_rubles_total",
+		Help:      "Sum of applied penalties in rubles.",
+	})
+
+	CBRRequestDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "cbr_request_duration_seconds",
+		Help:      "Latency of key rate requests to the Central Bank web service.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	})
+
+	CBRRequestFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cbr_request_failures_total",
+		Help:      "Failed key rate requests to the Central Bank web service.",
+	})
+)
+
+// RegisterDB добавляет метрики пула соединений database/sql (открытые, занятые соединения, ожидания).
+func RegisterDB(db *sql.DB, name string) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
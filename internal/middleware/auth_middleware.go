@@ -2,47 +2,158 @@ package middleware
 
 import (
 	"context"
-	"fmt"
 	"github.com/golang-jwt/jwt/v4"
+	"github.com/sirupsen/logrus"
+	"go_project/internal/logging"
+	"go_project/internal/models"
+	"go_project/internal/problem"
+	"net"
 	"net/http"
 	"strings"
+	"time"
 )
 
+// TokenChecker проверяет, что токен доступа не отозван и относится к действующей сессии пользователя.
+type TokenChecker interface {
+	CheckAccessToken(ctx context.Context, userID, tokenID, sessionID string, version int) error
+}
+
+// TokenParser проверяет подпись, срок действия, издателя и аудиторию JWT и возвращает его claims.
+type TokenParser interface {
+	Parse(ctx context.Context, tokenString string) (jwt.MapClaims, error)
+}
+
+// APIKeyAuthenticator проверяет персональный API-ключ пользователя с учётом IP-адреса клиента.
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key, ip string) (*models.APIKey, error)
+}
+
 // JWTAuthMiddleware возвращает middleware-функцию для проверки JWT в заголовке Authorization.
-func JWTAuthMiddleware(secret string) func(http.Handler) http.Handler {
+// Если передан apiKeys, принимается также заголовок "Authorization: ApiKey <ключ>"; области доступа
+// ключа проверяются RequireScope на маршрутах, где ключи разрешены.
+func JWTAuthMiddleware(parser TokenParser, checker TokenChecker, apiKeys APIKeyAuthenticator) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			authHeader := r.Header.Get("Authorization")
+			if apiKeys != nil && strings.HasPrefix(authHeader, "ApiKey ") {
+				apiKeyAuth(w, r, next, apiKeys, strings.TrimPrefix(authHeader, "ApiKey "))
+				return
+			}
 			if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				problem.Write(w, r, problem.CodeUnauthorized)
 				return
 			}
 			tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-			token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-				// Проверяем метод подписи
-				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-					return nil, fmt.Errorf("unexpected signing method")
-				}
-				return []byte(secret), nil
-			})
-			if err != nil || !token.Valid {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return
-			}
-			claims, ok := token.Claims.(jwt.MapClaims)
-			if !ok {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			claims, err := parser.Parse(r.Context(), tokenString)
+			if err != nil || claims["typ"] != "access" {
+				problem.Write(w, r, problem.CodeUnauthorized)
 				return
 			}
 			userID, ok := claims["user_id"].(string)
 			if !ok {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				problem.Write(w, r, problem.CodeUnauthorized)
 				return
 			}
-			// Добавляем userID в контекст запроса
+			tokenID, _ := claims["jti"].(string)
+			sessionID, _ := claims["sid"].(string)
+			version, _ := claims["ver"].(float64)
+			exp, _ := claims["exp"].(float64)
+			// Токены, выпущенные до появления ролей, относятся к клиентам
+			role, _ := claims["role"].(string)
+			if role == "" {
+				role = models.RoleCustomer
+			}
+			if tokenID == "" {
+				problem.Write(w, r, problem.CodeUnauthorized)
+				return
+			}
+			// Проверяем список отозванных токенов, версию сессий пользователя и саму сессию
+			if err := checker.CheckAccessToken(r.Context(), userID, tokenID, sessionID, int(version)); err != nil {
+				logrus.WithContext(r.Context()).Debugf("Access token rejected: %v", err)
+				problem.Write(w, r, problem.CodeUnauthorized)
+				return
+			}
+			// Добавляем userID и данные токена в контекст запроса
 			ctx := r.Context()
 			ctx = context.WithValue(ctx, "userID", userID)
+			ctx = context.WithValue(ctx, "tokenID", tokenID)
+			ctx = context.WithValue(ctx, "sessionID", sessionID)
+			ctx = context.WithValue(ctx, "tokenExpiresAt", time.Unix(int64(exp), 0))
+			ctx = context.WithValue(ctx, "role", role)
+			logging.SetUserID(ctx, userID)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
+
+func apiKeyAuth(w http.ResponseWriter, r *http.Request, next http.Handler, apiKeys APIKeyAuthenticator, key string) {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	apiKey, err := apiKeys.AuthenticateAPIKey(r.Context(), key, ip)
+	if err != nil {
+		logrus.WithContext(r.Context()).Debugf("API key rejected: %v", err)
+		problem.Write(w, r, problem.CodeUnauthorized)
+		return
+	}
+	role := apiKey.Role
+	if role == "" {
+		role = models.RoleCustomer
+	}
+	ctx := r.Context()
+	ctx = context.WithValue(ctx, "userID", apiKey.UserID)
+	ctx = context.WithValue(ctx, "apiKeyID", apiKey.ID)
+	ctx = context.WithValue(ctx, "scopes", apiKey.Scopes)
+	ctx = context.WithValue(ctx, "role", role)
+	logging.SetUserID(ctx, apiKey.UserID)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// RequireRole возвращает middleware, пропускающее только пользователей с одной из указанных ролей.
+// Должно подключаться после JWTAuthMiddleware.
+func RequireRole(roles ...string) func(http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		allowed[role] = true
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role, _ := r.Context().Value("role").(string)
+			if !allowed[role] {
+				problem.Write(w, r, problem.CodeForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// StepUpHeader — заголовок, в котором передаётся токен подтверждения вторым фактором.
+const StepUpHeader = "X-Step-Up-Token"
+
+// StepUpChecker проверяет подтверждение чувствительной операции вторым фактором.
+type StepUpChecker interface {
+	CheckStepUp(ctx context.Context, userID, stepUpToken string) error
+}
+
+// RequireStepUp возвращает middleware, требующее токен подтверждения в заголовке X-Step-Up-Token
+// для пользователей с включённой двухфакторной аутентификацией.
+func RequireStepUp(checker StepUpChecker) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID := r.Context().Value("userID").(string)
+			if err := checker.CheckStepUp(r.Context(), userID, r.Header.Get(StepUpHeader)); err != nil {
+				WriteStepUpError(w, r, err)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// WriteStepUpError отвечает 403 с признаком step_up_required, чтобы клиент запросил код и повторил запрос.
+func WriteStepUpError(w http.ResponseWriter, r *http.Request, err error) {
+	logrus.WithContext(r.Context()).Debugf("Step-up verification failed: %v", err)
+	problem.New(r, problem.CodeStepUpRequired).With("step_up_required", true).Write(w)
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ParseTrustedProxies разбирает адреса доверенных прокси: подсети в нотации CIDR или отдельные IP-адреса.
+func ParseTrustedProxies(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if !strings.Contains(v, "/") {
+			addr, err := netip.ParseAddr(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", v, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", v, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// ClientIP заменяет r.RemoteAddr адресом клиента, если запрос пришёл от доверенного прокси
+// (балансировщика или ingress). X-Forwarded-For просматривается справа налево: адреса доверенных
+// прокси пропускаются, и клиентом считается первый недоверенный адрес, поэтому значения, которые
+// клиент дописал в заголовок сам, не учитываются. Без X-Forwarded-For используется X-Real-IP.
+// Запросы от остальных адресов, как и все запросы при пустом trustedProxies, не изменяются.
+func ClientIP(trustedProxies []netip.Prefix) func(http.Handler) http.Handler {
+	trusted := func(addr netip.Addr) bool {
+		addr = addr.Unmap()
+		for _, p := range trustedProxies {
+			if p.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if len(trustedProxies) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			host, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				host = r.RemoteAddr
+			}
+			peer, err := netip.ParseAddr(host)
+			if err != nil || !trusted(peer) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if client, ok := forwardedClient(r.Header, trusted); ok {
+				r.RemoteAddr = client.String()
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// forwardedClient возвращает адрес клиента из заголовков доверенного прокси.
+func forwardedClient(h http.Header, trusted func(netip.Addr) bool) (netip.Addr, bool) {
+	var hops []string
+	for _, v := range h.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	if len(hops) == 0 {
+		addr, err := netip.ParseAddr(strings.TrimSpace(h.Get("X-Real-IP")))
+		return addr.Unmap(), err == nil
+	}
+	var client netip.Addr
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			// Дальше идут значения, за которые доверенные прокси не ручаются
+			break
+		}
+		client = addr.Unmap()
+		if !trusted(client) {
+			break
+		}
+	}
+	return client, client.IsValid()
+}
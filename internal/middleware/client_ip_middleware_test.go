@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		realIP     string
+		want       string
+	}{
+		{"direct client", "203.0.113.7:5000", nil, "", "203.0.113.7:5000"},
+		{"untrusted peer ignores headers", "203.0.113.7:5000", []string{"198.51.100.1"}, "198.51.100.2", "203.0.113.7:5000"},
+		{"single proxy", "10.0.0.2:5000", []string{"198.51.100.1"}, "", "198.51.100.1"},
+		// Значения левее первого недоверенного адреса клиент мог подставить сам
+		{"spoofed prefix", "10.0.0.2:5000", []string{"1.1.1.1, 198.51.100.1"}, "", "198.51.100.1"},
+		{"proxy chain", "192.168.1.1:5000", []string{"198.51.100.1, 10.1.2.3", "10.0.0.9"}, "", "198.51.100.1"},
+		{"only proxies", "10.0.0.2:5000", []string{"10.0.0.3"}, "", "10.0.0.3"},
+		{"garbage stops the walk", "10.0.0.2:5000", []string{"198.51.100.1, not-an-ip, 10.0.0.3"}, "", "10.0.0.3"},
+		{"x-real-ip", "10.0.0.2:5000", nil, "198.51.100.2", "198.51.100.2"},
+		{"invalid x-real-ip", "10.0.0.2:5000", nil, "unknown", "10.0.0.2:5000"},
+		{"ipv4-mapped peer", "[::ffff:10.0.0.2]:5000", []string{"198.51.100.1"}, "", "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			h := ClientIP(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.RemoteAddr
+			}))
+			r := httptest.NewRequest(http.MethodPost, "/login", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", v)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+			if got != tt.want {
+				t.Fatalf("RemoteAddr = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	if _, err := ParseTrustedProxies([]string{"10.0.0.0/33"}); err == nil {
+		t.Fatal("ParseTrustedProxies accepted an invalid prefix")
+	}
+	if _, err := ParseTrustedProxies([]string{"proxy.local"}); err == nil {
+		t.Fatal("ParseTrustedProxies accepted a host name")
+	}
+}
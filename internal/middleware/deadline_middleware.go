@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"go_project/internal/problem"
+	"net/http"
+	"time"
+)
+
+// Deadlines ограничивает время обработки запроса. Для маршрутов из routeTimeouts действует свой дедлайн
+// (ключ — "МЕТОД шаблон" или только шаблон chi, например "POST /transfer" или "/analytics"),
+// для остальных — defaultTimeout; нулевой defaultTimeout означает отсутствие общего ограничения.
+// По истечении дедлайна контекст запроса отменяется, и выполняющиеся SQL-запросы и вызовы
+// внешних сервисов прерываются. Отключение клиента отменяет контекст и без дедлайна.
+func Deadlines(routes chi.Routes, defaultTimeout time.Duration, routeTimeouts map[string]time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			timeout := defaultTimeout
+			if len(routeTimeouts) > 0 {
+				// Маршрут ещё не выбран, поэтому шаблон ищется заранее по отдельному контексту маршрутизации
+				pattern := routes.Find(chi.NewRouteContext(), r.Method, r.URL.Path)
+				if t, ok := routeTimeouts[r.Method+" "+pattern]; ok {
+					timeout = t
+				} else if t, ok := routeTimeouts[pattern]; ok {
+					timeout = t
+				}
+			}
+			if timeout <= 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(ctx))
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) && ww.Status() == 0 {
+				problem.Write(w, r, problem.CodeTimeout)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/sirupsen/logrus"
+	"go_project/internal/logging"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// RequestIDHeader — заголовок с идентификатором запроса во входящем запросе и в ответе.
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID ограничивает принимаемые от клиента идентификаторы, чтобы в логи не попадал произвольный текст.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestID берёт идентификатор запроса из X-Request-ID или генерирует новый, возвращает его
+// в заголовке ответа и кладёт в контекст, откуда он попадает в каждую запись лога.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		ctx := logging.WithRequestInfo(r.Context(), &logging.RequestInfo{ID: id})
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// AccessLog пишет по записи на каждый запрос: метод, шаблон маршрута, путь, статус, размер ответа,
+// длительность и пользователя, если запрос прошёл аутентификацию. Подключается после RequestID.
+func AccessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+		route := "unmatched"
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		entry := logrus.WithContext(r.Context()).WithFields(logrus.Fields{
+			"method":      r.Method,
+			"route":       route,
+			"path":        r.URL.Path,
+			"status":      status,
+			"bytes":       ww.BytesWritten(),
+			"duration_ms": float64(time.Since(start).Microseconds()) / 1000,
+			"remote_addr": r.RemoteAddr,
+		})
+		switch {
+		case status >= http.StatusInternalServerError:
+			entry.Error("request completed")
+		case status >= http.StatusBadRequest:
+			entry.Warn("request completed")
+		default:
+			entry.Info("request completed")
+		}
+	})
+}
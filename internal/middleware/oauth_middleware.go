@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"go_project/internal/logging"
+	"go_project/internal/problem"
+	"net/http"
+	"strings"
+)
+
+// OAuthTokenChecker проверяет, что согласие пользователя, по которому выдан токен стороннего клиента,
+// не отозвано и покрывает области токена.
+type OAuthTokenChecker interface {
+	CheckOAuthToken(ctx context.Context, userID, clientID string, scopes []string) error
+}
+
+// OAuthMiddleware возвращает middleware для проверки токенов сторонних клиентов (typ=oauth).
+// В контекст добавляются clientID, scopes и, если токен выдан от имени пользователя, userID.
+func OAuthMiddleware(parser TokenParser, checker OAuthTokenChecker) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authHeader := r.Header.Get("Authorization")
+			if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
+				writeBearerError(w, r, "invalid_request", "")
+				return
+			}
+			claims, err := parser.Parse(r.Context(), strings.TrimPrefix(authHeader, "Bearer "))
+			if err != nil || claims["typ"] != "oauth" {
+				writeBearerError(w, r, "invalid_token", "")
+				return
+			}
+			clientID, _ := claims["client_id"].(string)
+			scope, _ := claims["scope"].(string)
+			userID, _ := claims["user_id"].(string)
+			if clientID == "" {
+				writeBearerError(w, r, "invalid_token", "")
+				return
+			}
+			scopes := strings.Fields(scope)
+			if err := checker.CheckOAuthToken(r.Context(), userID, clientID, scopes); err != nil {
+				logrus.WithContext(r.Context()).Debugf("OAuth token rejected: %v", err)
+				writeBearerError(w, r, "invalid_token", "")
+				return
+			}
+			ctx := context.WithValue(r.Context(), "clientID", clientID)
+			ctx = context.WithValue(ctx, "scopes", scopes)
+			if userID != "" {
+				ctx = context.WithValue(ctx, "userID", userID)
+				logging.SetUserID(ctx, userID)
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequireScope возвращает middleware, пропускающее только учётные данные с указанной областью доступа.
+// Области есть у токенов OAuth и API-ключей; токены доступа пользователя (без scopes) не ограничиваются.
+// Должно подключаться после OAuthMiddleware или JWTAuthMiddleware.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scopes, ok := r.Context().Value("scopes").([]string)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			for _, s := range scopes {
+				if s == scope {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+			writeBearerError(w, r, "insufficient_scope", scope)
+		})
+	}
+}
+
+// RequireOAuthUser пропускает только токены, выданные от имени пользователя (authorization code),
+// и отклоняет токены client_credentials.
+func RequireOAuthUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value("userID").(string); !ok {
+			writeBearerError(w, r, "insufficient_scope", "")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// writeBearerError отвечает с заголовком WWW-Authenticate по RFC 6750, раздел 3, и телом problem+json.
+func writeBearerError(w http.ResponseWriter, r *http.Request, code, scope string) {
+	value := `Bearer error="` + code + `"`
+	if scope != "" {
+		value += `, scope="` + scope + `"`
+	}
+	w.Header().Set("WWW-Authenticate", value)
+	switch code {
+	case "insufficient_scope":
+		problem.Write(w, r, problem.CodeInsufficientScope)
+	case "invalid_token":
+		problem.Write(w, r, problem.CodeInvalidToken)
+	default:
+		problem.Write(w, r, problem.CodeUnauthorized)
+	}
+}
@@ -0,0 +1,373 @@
+// Package migrations содержит версионированные миграции схемы БД, встроенные в бинарный файл,
+// и выполняет их. Файлы называются NNNN_описание.up.sql и NNNN_описание.down.sql; применённые
+// версии вместе с контрольными суммами хранятся в таблице schema_migrations.
+package migrations
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed sql/*.sql
+var files embed.FS
+
+var (
+	ErrDirty            = errors.New("applied migration differs from the embedded one")
+	ErrSchemaBehind     = errors.New("database schema is behind the application")
+	ErrUnknownVersion   = errors.New("unknown migration version")
+	ErrMissingMigration = errors.New("migration is missing a down script")
+)
+
+// Migration — одна версия схемы
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // SHA-256 скрипта up
+}
+
+// Status — состояние миграции в БД
+type Status struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt *time.Time
+	// Modified — скрипт изменён после применения (контрольные суммы не совпадают)
+	Modified bool
+}
+
+type appliedMigration struct {
+	checksum  string
+	appliedAt time.Time
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+func New(db *sql.DB) (*Migrator, error) {
+	migrations, err := load(files)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// load читает файлы миграций из каталога sql в fsys и упорядочивает их по версии.
+func load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, "sql")
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		name := entry.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			return nil, fmt.Errorf("unexpected migration file %s", name)
+		}
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		versionPart, title, ok := strings.Cut(base, "_")
+		version, err := strconv.Atoi(versionPart)
+		if !ok || err != nil || version <= 0 {
+			return nil, fmt.Errorf("migration file %s must be named NNNN_name.%s.sql", name, direction)
+		}
+		body, err := fs.ReadFile(fsys, path.Join("sql", name))
+		if err != nil {
+			return nil, err
+		}
+		m, exists := byVersion[version]
+		if !exists {
+			m = &Migration{Version: version, Name: title}
+			byVersion[version] = m
+		} else if m.Name != title {
+			return nil, fmt.Errorf("migration %d has conflicting names %s and %s", version, m.Name, title)
+		}
+		if direction == "up" {
+			m.Up = string(body)
+			sum := sha256.Sum256(body)
+			m.Checksum = hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(body)
+		}
+	}
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d has no up script", m.Version)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Latest возвращает версию схемы, которую ожидает приложение.
+func (m *Migrator) Latest() int {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Status возвращает состояние всех встроенных миграций. Схема не изменяется: метод вызывается
+// из /readyz, и без таблицы schema_migrations все миграции считаются неприменёнными.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	var exists bool
+	if err := m.db.QueryRowContext(ctx, `SELECT to_regclass('schema_migrations') IS NOT NULL`).Scan(&exists); err != nil {
+		return nil, err
+	}
+	applied := map[int]appliedMigration{}
+	if exists {
+		var err error
+		if applied, err = m.applied(ctx, m.db); err != nil {
+			return nil, err
+		}
+	}
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		st := Status{Version: mig.Version, Name: mig.Name}
+		if a, ok := applied[mig.Version]; ok {
+			appliedAt := a.appliedAt
+			st.Applied = true
+			st.AppliedAt = &appliedAt
+			st.Modified = a.checksum != mig.Checksum
+		}
+		statuses = append(statuses, st)
+	}
+	return statuses, nil
+}
+
+// CheckCurrent проверяет при запуске приложения, что все встроенные миграции применены
+// и не изменены. Более новые версии в БД допускаются: их могла применить следующая версия приложения.
+func (m *Migrator) CheckCurrent(ctx context.Context) error {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	for _, st := range statuses {
+		if !st.Applied {
+			return fmt.Errorf("%w: migration %04d_%s is not applied, run \"migrate up\"", ErrSchemaBehind, st.Version, st.Name)
+		}
+		if st.Modified {
+			return fmt.Errorf("%w: %04d_%s", ErrDirty, st.Version, st.Name)
+		}
+	}
+	return nil
+}
+
+// Up применяет все неприменённые миграции.
+func (m *Migrator) Up(ctx context.Context) error {
+	return m.To(ctx, m.Latest())
+}
+
+// Down откатывает steps последних применённых миграций.
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0 && steps > 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			if err := m.apply(ctx, conn, mig, false); err != nil {
+				return err
+			}
+			steps--
+		}
+		return nil
+	})
+}
+
+// To приводит схему к версии version: применяет недостающие миграции до неё
+// и откатывает применённые миграции новее неё.
+func (m *Migrator) To(ctx context.Context, version int) error {
+	if version != 0 && m.find(version) < 0 {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := m.verify(applied); err != nil {
+			return err
+		}
+		for i := len(m.migrations) - 1; i >= 0; i-- {
+			mig := m.migrations[i]
+			if _, ok := applied[mig.Version]; ok && mig.Version > version {
+				if err := m.apply(ctx, conn, mig, false); err != nil {
+					return err
+				}
+			}
+		}
+		for _, mig := range m.migrations {
+			if _, ok := applied[mig.Version]; !ok && mig.Version <= version {
+				if err := m.apply(ctx, conn, mig, true); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Force отмечает миграции до version включительно применёнными, а более новые — неприменёнными,
+// не выполняя их скрипты. Нужна для БД, схема которых была создана вручную.
+func (m *Migrator) Force(ctx context.Context, version int) error {
+	if version != 0 && m.find(version) < 0 {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+	return m.withLock(ctx, func(conn *sql.Conn) error {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+		if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version > $1`, version); err != nil {
+			return err
+		}
+		for _, mig := range m.migrations {
+			if mig.Version > version {
+				break
+			}
+			_, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)
+                                            ON CONFLICT (version) DO UPDATE SET name = EXCLUDED.name, checksum = EXCLUDED.checksum`,
+				mig.Version, mig.Name, mig.Checksum)
+			if err != nil {
+				return err
+			}
+		}
+		logrus.Warnf("Schema version forced to %d", version)
+		return tx.Commit()
+	})
+}
+
+// apply выполняет скрипт миграции и обновляет schema_migrations в одной транзакции.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, mig Migration, up bool) error {
+	script := mig.Up
+	if !up {
+		if mig.Down == "" {
+			return fmt.Errorf("%w: %04d_%s", ErrMissingMigration, mig.Version, mig.Name)
+		}
+		script = mig.Down
+	}
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return fmt.Errorf("migration %04d_%s: %w", mig.Version, mig.Name, err)
+	}
+	if up {
+		_, err = tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3)`,
+			mig.Version, mig.Name, mig.Checksum)
+	} else {
+		_, err = tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, mig.Version)
+	}
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if up {
+		logrus.Infof("Applied migration %04d_%s", mig.Version, mig.Name)
+	} else {
+		logrus.Infof("Rolled back migration %04d_%s", mig.Version, mig.Name)
+	}
+	return nil
+}
+
+// withLock выполняет fn на отдельном соединении под advisory-блокировкой, чтобы миграции
+// не запускались одновременно с нескольких экземпляров.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock(hashtext('schema_migrations'))`); err != nil {
+		return err
+	}
+	defer conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock(hashtext('schema_migrations'))`)
+	if err := m.ensureTable(ctx, conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+type execQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func (m *Migrator) ensureTable(ctx context.Context, db execQuerier) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+                                       version INT PRIMARY KEY,
+                                       name TEXT NOT NULL,
+                                       checksum TEXT NOT NULL,
+                                       applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+                                   )`)
+	return err
+}
+
+func (m *Migrator) applied(ctx context.Context, db execQuerier) (map[int]appliedMigration, error) {
+	rows, err := db.QueryContext(ctx, `SELECT version, checksum, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := map[int]appliedMigration{}
+	for rows.Next() {
+		var version int
+		var a appliedMigration
+		if err := rows.Scan(&version, &a.checksum, &a.appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = a
+	}
+	return applied, rows.Err()
+}
+
+// verify отказывается мигрировать, если применённая миграция была изменена после применения.
+func (m *Migrator) verify(applied map[int]appliedMigration) error {
+	for _, mig := range m.migrations {
+		if a, ok := applied[mig.Version]; ok && a.checksum != mig.Checksum {
+			return fmt.Errorf("%w: %04d_%s", ErrDirty, mig.Version, mig.Name)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) find(version int) int {
+	for i, mig := range m.migrations {
+		if mig.Version == version {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,93 @@
+package migrations
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := load(files)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no embedded migrations")
+	}
+	for i, m := range migrations {
+		name := fmt.Sprintf("%04d_%s", m.Version, m.Name)
+		// Версии идут подряд с 1: пропуск обычно означает потерянный или неверно названный файл
+		if m.Version != i+1 {
+			t.Errorf("%s: version %d, want %d", name, m.Version, i+1)
+		}
+		if strings.TrimSpace(m.Up) == "" {
+			t.Errorf("%s: empty up script", name)
+		}
+		if strings.TrimSpace(m.Down) == "" {
+			t.Errorf("%s: missing down script", name)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"sql/10_tenth.up.sql":         {Data: []byte("SELECT 10;")},
+		"sql/10_tenth.down.sql":       {Data: []byte("SELECT -10;")},
+		"sql/0002_second.up.sql":      {Data: []byte("SELECT 2;")},
+		"sql/0001_first_one.up.sql":   {Data: []byte("SELECT 1;")},
+		"sql/0001_first_one.down.sql": {Data: []byte("SELECT -1;")},
+	}
+	migrations, err := load(fsys)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	var got []string
+	for _, m := range migrations {
+		got = append(got, fmt.Sprintf("%d %s", m.Version, m.Name))
+	}
+	// Порядок числовой, а не лексикографический; подчёркивания после первого входят в название
+	if want := "1 first_one, 2 second, 10 tenth"; strings.Join(got, ", ") != want {
+		t.Fatalf("load = %q, want %q", strings.Join(got, ", "), want)
+	}
+	first := migrations[0]
+	if first.Up != "SELECT 1;" || first.Down != "SELECT -1;" {
+		t.Fatalf("scripts = %q, %q", first.Up, first.Down)
+	}
+	if sum := sha256.Sum256([]byte("SELECT 1;")); first.Checksum != hex.EncodeToString(sum[:]) {
+		t.Fatalf("checksum = %q, want SHA-256 of the up script", first.Checksum)
+	}
+	if migrations[1].Down != "" {
+		t.Fatalf("down of 0002 = %q, want empty", migrations[1].Down)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  string
+	}{
+		{"unexpected extension", []string{"0001_init.sql"}, "unexpected migration file"},
+		{"no version", []string{"init.up.sql"}, "must be named"},
+		{"non-numeric version", []string{"v1_init.up.sql"}, "must be named"},
+		{"zero version", []string{"0000_init.up.sql"}, "must be named"},
+		{"no name separator", []string{"0001.up.sql"}, "must be named"},
+		{"conflicting names", []string{"0001_init.up.sql", "0001_other.down.sql"}, "conflicting names"},
+		{"down without up", []string{"0001_init.up.sql", "0002_next.down.sql"}, "migration 2 has no up script"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := fstest.MapFS{}
+			for _, name := range tt.files {
+				fsys["sql/"+name] = &fstest.MapFile{Data: []byte("SELECT 1;")}
+			}
+			_, err := load(fsys)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("load = %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,6 @@
+DROP TABLE payment_schedules;
+DROP TABLE credits;
+DROP TABLE transactions;
+DROP TABLE cards;
+DROP TABLE accounts;
+DROP TABLE users;
@@ -0,0 +1,2 @@
+DROP TABLE card_authorizations;
+DROP TABLE card_limits;
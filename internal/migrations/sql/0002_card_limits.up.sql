@@ -0,0 +1,22 @@
+CREATE TABLE card_limits (
+                             card_id UUID PRIMARY KEY REFERENCES cards(id) ON DELETE CASCADE,
+                             daily_limit NUMERIC(15,2) NOT NULL,
+                             monthly_limit NUMERIC(15,2) NOT NULL,
+                             per_transaction_limit NUMERIC(15,2) NOT NULL,
+                             online_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+                             contactless_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+                             atm_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+                             foreign_enabled BOOLEAN NOT NULL DEFAULT FALSE
+);
+
+CREATE TABLE card_authorizations (
+                                     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                                     card_id UUID NOT NULL REFERENCES cards(id) ON DELETE CASCADE,
+                                     amount NUMERIC(15,2) NOT NULL,
+                                     channel TEXT NOT NULL,
+                                     merchant TEXT NOT NULL,
+                                     country TEXT NOT NULL,
+                                     created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX card_authorizations_card_created_idx ON card_authorizations (card_id, created_at);
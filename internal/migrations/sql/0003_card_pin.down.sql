@@ -0,0 +1,4 @@
+ALTER TABLE cards
+    DROP COLUMN status,
+    DROP COLUMN pin_attempts,
+    DROP COLUMN pin_hash;
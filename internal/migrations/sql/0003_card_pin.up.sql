@@ -0,0 +1,4 @@
+ALTER TABLE cards
+    ADD COLUMN pin_hash TEXT,
+    ADD COLUMN pin_attempts INT NOT NULL DEFAULT 0,
+    ADD COLUMN status TEXT NOT NULL DEFAULT 'active';
@@ -0,0 +1,3 @@
+DROP INDEX cards_key_id_idx;
+
+ALTER TABLE cards DROP COLUMN key_id;
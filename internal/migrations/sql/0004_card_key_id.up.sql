@@ -0,0 +1,3 @@
+ALTER TABLE cards ADD COLUMN key_id TEXT NOT NULL DEFAULT 'default';
+
+CREATE INDEX cards_key_id_idx ON cards (key_id);
@@ -0,0 +1,5 @@
+ALTER TABLE cards
+    DROP COLUMN expires_at,
+    DROP COLUMN amount_cap,
+    DROP COLUMN locked_merchant,
+    DROP COLUMN card_type;
@@ -0,0 +1,5 @@
+ALTER TABLE cards
+    ADD COLUMN card_type TEXT NOT NULL DEFAULT 'standard',
+    ADD COLUMN locked_merchant TEXT,
+    ADD COLUMN amount_cap NUMERIC(15,2),
+    ADD COLUMN expires_at TIMESTAMPTZ;
@@ -0,0 +1,3 @@
+DROP INDEX cards_pan_hmac_idx;
+
+ALTER TABLE cards DROP COLUMN pan_hmac;
@@ -0,0 +1,3 @@
+ALTER TABLE cards ADD COLUMN pan_hmac TEXT;
+
+CREATE UNIQUE INDEX cards_pan_hmac_idx ON cards (pan_hmac);
@@ -0,0 +1,4 @@
+DROP TABLE revoked_tokens;
+DROP TABLE refresh_tokens;
+
+ALTER TABLE users DROP COLUMN token_version;
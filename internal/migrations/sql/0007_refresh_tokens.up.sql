@@ -0,0 +1,18 @@
+ALTER TABLE users ADD COLUMN token_version INT NOT NULL DEFAULT 0;
+
+CREATE TABLE refresh_tokens (
+                                id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                                user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                                token_hash TEXT NOT NULL UNIQUE,
+                                expires_at TIMESTAMPTZ NOT NULL,
+                                created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                                revoked_at TIMESTAMPTZ,
+                                replaced_by UUID REFERENCES refresh_tokens(id) ON DELETE SET NULL
+);
+
+CREATE INDEX refresh_tokens_user_id_idx ON refresh_tokens (user_id);
+
+CREATE TABLE revoked_tokens (
+                                jti TEXT PRIMARY KEY,
+                                expires_at TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,7 @@
+DROP TABLE recovery_codes;
+
+ALTER TABLE users
+    DROP COLUMN totp_last_step,
+    DROP COLUMN totp_enabled,
+    DROP COLUMN totp_key_id,
+    DROP COLUMN totp_secret_encrypted;
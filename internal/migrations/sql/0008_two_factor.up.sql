@@ -0,0 +1,14 @@
+ALTER TABLE users
+    ADD COLUMN totp_secret_encrypted BYTEA,
+    ADD COLUMN totp_key_id TEXT,
+    ADD COLUMN totp_enabled BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN totp_last_step BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE recovery_codes (
+                                id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                                user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                                code_hash TEXT NOT NULL,
+                                used_at TIMESTAMPTZ
+);
+
+CREATE INDEX recovery_codes_user_id_idx ON recovery_codes (user_id);
@@ -0,0 +1,4 @@
+-- Пользователи, зарегистрированные до появления подтверждения email, считаются подтверждёнными,
+-- чтобы не потерять доступ к переводам; новые пользователи подтверждают адрес по письму
+ALTER TABLE users ADD COLUMN email_verified BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE users ALTER COLUMN email_verified SET DEFAULT FALSE;
@@ -0,0 +1,17 @@
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'customer';
+
+ALTER TABLE accounts ADD COLUMN frozen BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE admin_actions (
+                               id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                               actor_id UUID NOT NULL REFERENCES users(id),
+                               action TEXT NOT NULL,
+                               target_type TEXT NOT NULL,
+                               target_id TEXT NOT NULL,
+                               reason_code TEXT NOT NULL,
+                               comment TEXT NOT NULL DEFAULT '',
+                               amount NUMERIC(15,2),
+                               created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX admin_actions_target_idx ON admin_actions (target_id, created_at);
@@ -0,0 +1 @@
+DROP TABLE jwt_signing_keys;
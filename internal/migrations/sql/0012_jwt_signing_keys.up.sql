@@ -0,0 +1,10 @@
+CREATE TABLE jwt_signing_keys (
+                                  kid TEXT PRIMARY KEY,
+                                  algorithm TEXT NOT NULL,
+                                  private_key_encrypted BYTEA NOT NULL,
+                                  key_id TEXT NOT NULL,
+                                  public_key TEXT NOT NULL,
+                                  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                                  activates_at TIMESTAMPTZ NOT NULL,
+                                  expires_at TIMESTAMPTZ
+);
@@ -0,0 +1,3 @@
+DROP TABLE oauth_codes;
+DROP TABLE oauth_consents;
+DROP TABLE oauth_clients;
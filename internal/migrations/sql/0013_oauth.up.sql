@@ -0,0 +1,28 @@
+CREATE TABLE oauth_clients (
+                               id TEXT PRIMARY KEY,
+                               name TEXT NOT NULL,
+                               secret_hash TEXT,
+                               redirect_uris TEXT[] NOT NULL DEFAULT '{}',
+                               scopes TEXT[] NOT NULL DEFAULT '{}',
+                               grant_types TEXT[] NOT NULL DEFAULT '{}',
+                               created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE oauth_consents (
+                                user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                                client_id TEXT NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+                                scopes TEXT[] NOT NULL,
+                                granted_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                                PRIMARY KEY (user_id, client_id)
+);
+
+CREATE TABLE oauth_codes (
+                             code_hash TEXT PRIMARY KEY,
+                             client_id TEXT NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+                             user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                             redirect_uri TEXT NOT NULL,
+                             scopes TEXT[] NOT NULL,
+                             code_challenge TEXT NOT NULL,
+                             expires_at TIMESTAMPTZ NOT NULL,
+                             used_at TIMESTAMPTZ
+);
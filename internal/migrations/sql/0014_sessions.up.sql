@@ -0,0 +1,15 @@
+CREATE TABLE sessions (
+                          id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                          user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                          device_name TEXT NOT NULL,
+                          device_hash TEXT NOT NULL,
+                          ip TEXT NOT NULL DEFAULT '',
+                          user_agent TEXT NOT NULL DEFAULT '',
+                          created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                          last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                          revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX sessions_user_id_idx ON sessions (user_id, device_hash);
+
+ALTER TABLE refresh_tokens ADD COLUMN session_id UUID REFERENCES sessions(id) ON DELETE CASCADE;
@@ -0,0 +1,16 @@
+CREATE TABLE api_keys (
+                          id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+                          user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+                          name TEXT NOT NULL,
+                          prefix TEXT NOT NULL,
+                          key_hash TEXT NOT NULL UNIQUE,
+                          scopes TEXT[] NOT NULL,
+                          allowed_ips TEXT[] NOT NULL DEFAULT '{}',
+                          expires_at TIMESTAMPTZ NOT NULL,
+                          created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+                          last_used_at TIMESTAMPTZ,
+                          last_used_ip TEXT,
+                          revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX api_keys_user_id_idx ON api_keys (user_id);
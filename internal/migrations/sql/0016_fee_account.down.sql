@@ -0,0 +1,3 @@
+UPDATE transactions SET to_account = NULL WHERE to_account = '00000000-0000-4000-8000-0000000000fe';
+
+DELETE FROM users WHERE id = '00000000-0000-4000-8000-000000000001';
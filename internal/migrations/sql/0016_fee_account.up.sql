@@ -0,0 +1,7 @@
+-- Служебный пользователь банка и счёт, на который зачисляются комиссии за переводы.
+-- Пароль задан заведомо невалидным хешем, поэтому войти под этим пользователем нельзя.
+INSERT INTO users (id, email, username, password_hash, email_verified)
+VALUES ('00000000-0000-4000-8000-000000000001', 'fees@kirbank.internal', 'kirbank-fees', '!', TRUE);
+
+INSERT INTO accounts (id, user_id)
+VALUES ('00000000-0000-4000-8000-0000000000fe', '00000000-0000-4000-8000-000000000001');
@@ -13,4 +13,6 @@ type Account struct {
 	ID      string  `json:"id"`
 	UserID  string  `json:"user_id"`
 	Balance float64 `json:"balance"`
+	// С замороженного счёта нельзя списывать средства, зачисления разрешены
+	Frozen bool `json:"frozen"`
 }
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// AdminAction — запись журнала действий сотрудников банка
+type AdminAction struct {
+	ID         string    `json:"id"`
+	ActorID    string    `json:"actor_id"`
+	Action     string    `json:"action"`
+	TargetType string    `json:"target_type"`
+	TargetID   string    `json:"target_id"`
+	ReasonCode string    `json:"reason_code"`
+	Comment    string    `json:"comment,omitempty"`
+	Amount     *float64  `json:"amount,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Действия сотрудников, которые записываются в журнал
+const (
+	AdminActionFreeze        = "account_freeze"
+	AdminActionUnfreeze      = "account_unfreeze"
+	AdminActionAdjustBalance = "balance_adjustment"
+	AdminActionSetRole       = "role_change"
+)
+
+// CustomerOverview — данные клиента для сотрудников банка
+type CustomerOverview struct {
+	User     *User     `json:"user"`
+	Accounts []Account `json:"accounts"`
+}
+
+// CreditOverview — кредит вместе с графиком платежей
+type CreditOverview struct {
+	Credit   *Credit           `json:"credit"`
+	Schedule []PaymentSchedule `json:"schedule"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// APIKey — персональный ключ пользователя для доступа из скриптов. Сам ключ показывается
+// один раз при создании, в БД хранится только его SHA-256 хеш
+type APIKey struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"-"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"` // начало ключа, чтобы пользователь мог его узнать
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	AllowedIPs []string   `json:"allowed_ips"` // IP-адреса и подсети CIDR; пустой список — без ограничений
+	ExpiresAt  time.Time  `json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+	Role       string     `json:"-"` // роль владельца на момент проверки ключа
+}
@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 //	type Card struct {
 //		ID         int64     `json:"id"`
 //		AccountID  int64     `json:"account_id"`
@@ -13,6 +15,93 @@ package models
 type Card struct {
 	ID        string `json:"id"`
 	AccountID string `json:"account_id"`
+	Type      string `json:"type"`
 	// Номер карты и срок действия хранятся в БД в зашифрованном виде
 	LastFour string `json:"last_four,omitempty"`
+	Status   string `json:"status"`
+	// Ограничения виртуальных карт
+	LockedMerchant string     `json:"locked_merchant,omitempty"`
+	AmountCap      *float64   `json:"amount_cap,omitempty"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	// PIN хранится в виде HMAC от PIN-блока (ISO 9564, формат 0)
+	PINHash     string `json:"-"`
+	PINAttempts int    `json:"-"`
+	// Детерминированный HMAC номера карты для поиска без расшифровки
+	PANIndex string `json:"-"`
+}
+
+// Статусы карты
+const (
+	CardStatusActive    = "active"
+	CardStatusBlocked   = "blocked"
+	CardStatusDestroyed = "destroyed" // одноразовая карта после использования
+)
+
+// Типы карт
+const (
+	CardTypeStandard       = "standard"
+	CardTypeSingleUse      = "single_use"      // уничтожается после первой успешной авторизации
+	CardTypeMerchantLocked = "merchant_locked" // привязывается к первому списавшему продавцу
+)
+
+// IssuedCard — реквизиты выпущенной карты, которые возвращаются владельцу только при выпуске
+type IssuedCard struct {
+	ID         string     `json:"card_id"`
+	CardNumber string     `json:"card_number"`
+	Expiry     string     `json:"expiry"`
+	CVV        string     `json:"cvv"`
+	Type       string     `json:"type"`
+	AmountCap  *float64   `json:"amount_cap,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+}
+
+// CardDetails — реквизиты карты, раскрываемые владельцу после подтверждения вторым фактором
+type CardDetails struct {
+	ID         string `json:"card_id"`
+	CardNumber string `json:"card_number"`
+	Expiry     string `json:"expiry"`
+}
+
+// CardLimits — лимиты расходов и настройки каналов использования карты
+type CardLimits struct {
+	CardID              string  `json:"card_id"`
+	DailyLimit          float64 `json:"daily_limit"`
+	MonthlyLimit        float64 `json:"monthly_limit"`
+	PerTransactionLimit float64 `json:"per_transaction_limit"`
+	OnlineEnabled       bool    `json:"online_enabled"`
+	ContactlessEnabled  bool    `json:"contactless_enabled"`
+	ATMEnabled          bool    `json:"atm_enabled"`
+	ForeignEnabled      bool    `json:"foreign_enabled"`
+	// Суммы одобренных операций за скользящие окна (24 часа и 30 дней)
+	DailySpent   float64 `json:"daily_spent"`
+	MonthlySpent float64 `json:"monthly_spent"`
+}
+
+// CardAuthorization — операция по карте, прошедшая авторизацию
+type CardAuthorization struct {
+	ID        string    `json:"id"`
+	CardID    string    `json:"card_id"`
+	Amount    float64   `json:"amount"`
+	Channel   string    `json:"channel"` // pos, online, contactless, atm
+	Merchant  string    `json:"merchant"`
+	Country   string    `json:"country"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// KeyRotationProgress — ход перешифрования на активный ключ всех данных, зашифрованных ключами карт
+type KeyRotationProgress struct {
+	ActiveKeyID string         `json:"active_key_id"`
+	Total       int            `json:"total"`
+	Migrated    int            `json:"migrated"`
+	Remaining   map[string]int `json:"remaining"` // идентификатор старого ключа -> количество записей
+	Percent     float64        `json:"percent"`
+	// Tables — те же счётчики по таблицам: cards, jwt_signing_keys и users (секреты TOTP)
+	Tables map[string]KeyRotationCounts `json:"tables"`
+}
+
+// KeyRotationCounts — ход перешифрования одной таблицы
+type KeyRotationCounts struct {
+	Total     int            `json:"total"`
+	Migrated  int            `json:"migrated"`
+	Remaining map[string]int `json:"remaining"`
 }
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// SigningKey — асимметричный ключ подписи JWT. Закрытый ключ хранится в БД в зашифрованном виде.
+type SigningKey struct {
+	ID            string     `json:"kid"`
+	Algorithm     string     `json:"alg"` // RS256 или EdDSA
+	PrivateKeyPEM string     `json:"-"`
+	PublicKeyPEM  string     `json:"-"`
+	CreatedAt     time.Time  `json:"created_at"`
+	ActivatesAt   time.Time  `json:"activates_at"` // с этого момента ключом подписываются новые токены
+	ExpiresAt     *time.Time `json:"expires_at,omitempty"`
+}
+
+// JWK — открытый ключ в формате RFC 7517
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS — набор открытых ключей для /.well-known/jwks.json
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
@@ -0,0 +1,83 @@
+package models
+
+import "time"
+
+// Области доступа (scopes) OAuth2 для сторонних клиентов
+const (
+	ScopeAccountsRead     = "accounts:read"
+	ScopeTransactionsRead = "transactions:read"
+	ScopePaymentsWrite    = "payments:write"
+	ScopeRatesRead        = "rates:read" // данные без привязки к клиенту банка, для client_credentials
+)
+
+// Типы грантов OAuth2
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantClientCredentials = "client_credentials"
+)
+
+// OAuthClient — зарегистрированное стороннее приложение. Секрет хранится только в виде хеша.
+type OAuthClient struct {
+	ID           string    `json:"client_id"`
+	Name         string    `json:"name" validate:"required,max=100"`
+	SecretHash   string    `json:"-"`
+	Confidential bool      `json:"confidential"` // публичные клиенты (мобильные, SPA) не имеют секрета
+	RedirectURIs []string  `json:"redirect_uris" validate:"required,max=10"`
+	Scopes       []string  `json:"scopes" validate:"required"`
+	GrantTypes   []string  `json:"grant_types"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// OAuthConsent — согласие пользователя на доступ клиента к перечисленным областям
+type OAuthConsent struct {
+	UserID     string    `json:"-"`
+	ClientID   string    `json:"client_id"`
+	ClientName string    `json:"client_name"`
+	Scopes     []string  `json:"scopes"`
+	GrantedAt  time.Time `json:"granted_at"`
+}
+
+// OAuthCode — одноразовый код авторизации с параметрами PKCE
+type OAuthCode struct {
+	CodeHash      string
+	ClientID      string
+	UserID        string
+	RedirectURI   string
+	Scopes        []string
+	CodeChallenge string
+	ExpiresAt     time.Time
+}
+
+// AuthorizeRequest — параметры запроса авторизации (RFC 6749, раздел 4.1.1, и RFC 7636)
+type AuthorizeRequest struct {
+	ResponseType        string `json:"response_type"`
+	ClientID            string `json:"client_id"`
+	RedirectURI         string `json:"redirect_uri"`
+	Scope               string `json:"scope"`
+	State               string `json:"state"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+}
+
+// ScopeInfo — область доступа с описанием для экрана согласия
+type ScopeInfo struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// ConsentScreen — данные для экрана согласия
+type ConsentScreen struct {
+	ClientID   string      `json:"client_id"`
+	ClientName string      `json:"client_name"`
+	Scopes     []ScopeInfo `json:"scopes"`
+	// true, если пользователь уже дал согласие на все запрошенные области
+	AlreadyGranted bool `json:"already_granted"`
+}
+
+// OAuthToken — ответ token endpoint (RFC 6749, раздел 5.1)
+type OAuthToken struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// RefreshToken — долгоживущий токен обновления; в БД хранится только его SHA-256 хеш
+type RefreshToken struct {
+	ID        string     `json:"-"`
+	UserID    string     `json:"-"`
+	SessionID string     `json:"-"` // пустой для токенов, выданных до появления сессий
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"-"`
+	RevokedAt *time.Time `json:"-"`
+}
+
+// TokenPair — выдаваемые клиенту токены доступа и обновления
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"` // срок жизни токена доступа в секундах
+}
+
+// LoginResult — результат первого шага входа: пара токенов либо требование второго фактора
+type LoginResult struct {
+	*TokenPair
+	MFARequired bool   `json:"mfa_required,omitempty"`
+	MFAToken    string `json:"mfa_token,omitempty"`
+}
+
+// LoginAttempts — счётчик неудачных попыток входа для email или IP-адреса
+type LoginAttempts struct {
+	Key           string
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   *time.Time
+}
+
+// Session — сессия входа с одного устройства. Токены доступа и обновления привязаны к сессии,
+// поэтому её завершение сразу лишает устройство доступа
+type Session struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"-"`
+	DeviceName string     `json:"device_name"`
+	DeviceHash string     `json:"-"`
+	IP         string     `json:"ip"`
+	UserAgent  string     `json:"user_agent"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	RevokedAt  *time.Time `json:"-"`
+	Current    bool       `json:"current"`
+}
+
+// DeviceInfo — данные устройства, с которого выполняется вход
+type DeviceInfo struct {
+	Name      string
+	IP        string
+	UserAgent string
+}
@@ -5,4 +5,16 @@ type User struct {
 	Email        string `json:"email"`
 	Username     string `json:"username"`
 	PasswordHash string `json:"-"`
+	TOTPEnabled  bool   `json:"-"`
+	// Переводы доступны только после подтверждения email
+	EmailVerified bool   `json:"email_verified"`
+	Role          string `json:"role"`
 }
+
+// Роли пользователей
+const (
+	RoleCustomer = "customer" // клиент банка, работает только со своими счетами
+	RoleOperator = "operator" // сотрудник поддержки: поиск клиентов, просмотр и заморозка счетов
+	RoleAdmin    = "admin"    // полный доступ, включая корректировку балансов и назначение ролей
+	RoleAuditor  = "auditor"  // только чтение данных клиентов и журнала действий сотрудников
+)
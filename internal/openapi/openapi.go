@@ -0,0 +1,68 @@
+// Package openapi отдаёт описание API в формате OpenAPI 3 и страницу Swagger UI для него.
+// По этому же описанию генерируется клиент pkg/client (go generate ./pkg/client).
+package openapi
+
+import (
+	_ "embed"
+	"github.com/go-chi/chi/v5"
+	swaggerFiles "github.com/swaggo/files/v2"
+	"go_project/internal/problem"
+	"net/http"
+)
+
+//go:embed openapi.json
+var spec []byte
+
+// Spec возвращает описание API в формате JSON.
+func Spec() []byte {
+	return spec
+}
+
+// ServeSpec обрабатывает GET /openapi.json.
+func ServeSpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.Write(spec)
+}
+
+// swaggerUI подключает статические файлы Swagger UI, встроенные в сервис, а не загружаемые с CDN:
+// их версия фиксируется в go.mod вместе с остальными зависимостями
+const swaggerUI = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>KirBank API</title>
+  <link rel="stylesheet" href="/docs/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="/docs/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui"});
+  </script>
+</body>
+</html>
+`
+
+// ServeUI обрабатывает GET /docs (Swagger UI для /openapi.json).
+func ServeUI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(swaggerUI))
+}
+
+// uiAssets — файлы Swagger UI, которые отдаёт ServeUIAsset
+var uiAssets = map[string]bool{
+	"swagger-ui.css":       true,
+	"swagger-ui-bundle.js": true,
+}
+
+// ServeUIAsset обрабатывает GET /docs/{asset} (статические файлы Swagger UI).
+func ServeUIAsset(w http.ResponseWriter, r *http.Request) {
+	asset := chi.URLParam(r, "asset")
+	if !uiAssets[asset] {
+		problem.Write(w, r, problem.CodeNotFound)
+		return
+	}
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	http.ServeFileFS(w, r, swaggerFiles.FS, asset)
+}
@@ -0,0 +1,196 @@
+package problem
+
+import "net/http"
+
+// Code — стабильный машиночитаемый код ошибки. Коды не меняются при изменении текстов сообщений,
+// поэтому клиенты должны опираться на них, а не на title.
+type Code string
+
+// Общие ошибки запроса
+const (
+	CodeBadRequest        Code = "bad_request"
+	CodePayloadTooLarge   Code = "payload_too_large"
+	CodeValidationFailed  Code = "validation_failed"
+	CodeUnauthorized      Code = "unauthorized"
+	CodeInvalidToken      Code = "invalid_token"
+	CodeForbidden         Code = "forbidden"
+	CodeInsufficientScope Code = "insufficient_scope"
+	CodeNotFound          Code = "not_found"
+	CodeMethodNotAllowed  Code = "method_not_allowed"
+	CodeTimeout           Code = "timeout"
+	CodeInternal          Code = "internal_error"
+)
+
+// Аутентификация, сессии и двухфакторная аутентификация
+const (
+	CodeEmailInUse               Code = "email_in_use"
+	CodeUsernameTaken            Code = "username_taken"
+	CodeInvalidCredentials       Code = "invalid_credentials"
+	CodeTooManyAttempts          Code = "too_many_attempts"
+	CodeInvalidRefreshToken      Code = "invalid_refresh_token"
+	CodeSessionNotFound          Code = "session_not_found"
+	CodeInvalidMFACode           Code = "invalid_mfa_code"
+	CodeInvalidMFAToken          Code = "invalid_mfa_token"
+	CodeMFAAlreadyEnabled        Code = "mfa_already_enabled"
+	CodeMFANotEnabled            Code = "mfa_not_enabled"
+	CodeMFANotEnrolled           Code = "mfa_not_enrolled"
+	CodeStepUpRequired           Code = "step_up_required"
+	CodeInvalidVerificationToken Code = "invalid_verification_token"
+	CodeEmailAlreadyVerified     Code = "email_already_verified"
+	CodeEmailNotVerified         Code = "email_not_verified"
+	CodeInvalidResetToken        Code = "invalid_reset_token"
+	CodeAPIKeyNotFound           Code = "api_key_not_found"
+	CodeTooManyAPIKeys           Code = "too_many_api_keys"
+	CodeConsentNotFound          Code = "consent_not_found"
+)
+
+// Счета, переводы и кредиты
+const (
+	CodeAccountNotFound            Code = "account_not_found"
+	CodeSourceAccountNotFound      Code = "source_account_not_found"
+	CodeDestinationAccountNotFound Code = "destination_account_not_found"
+	CodeAccountFrozen              Code = "account_frozen"
+	CodeDestinationAccountFrozen   Code = "destination_account_frozen"
+	CodeInvalidAmount              Code = "invalid_amount"
+	CodeInsufficientFunds          Code = "insufficient_funds"
+	CodeCreditNotFound             Code = "credit_not_found"
+)
+
+// Карты
+const (
+	CodeCardNotFound            Code = "card_not_found"
+	CodeDestinationCardNotFound Code = "destination_card_not_found"
+	CodeInvalidCardNumber       Code = "invalid_card_number"
+	CodeVirtualCardTransfer     Code = "virtual_card_transfer"
+	CodeInvalidCardType         Code = "invalid_card_type"
+	CodeInvalidCardTTL          Code = "invalid_card_ttl"
+	CodeInvalidAmountCap        Code = "invalid_amount_cap"
+	CodeAmountCapExceeded       Code = "amount_cap_exceeded"
+	CodeMerchantMismatch        Code = "merchant_mismatch"
+	CodeMerchantRequired        Code = "merchant_required"
+	CodeInvalidLimits           Code = "invalid_limits"
+	CodeInvalidChannel          Code = "invalid_channel"
+	CodeChannelDisabled         Code = "channel_disabled"
+	CodeCardLimitExceeded       Code = "card_limit_exceeded"
+	CodeCardExpired             Code = "card_expired"
+	CodeCardDestroyed           Code = "card_destroyed"
+	CodeCardBlocked             Code = "card_blocked"
+	CodeInvalidPIN              Code = "invalid_pin"
+	CodePINAlreadySet           Code = "pin_already_set"
+	CodePINNotSet               Code = "pin_not_set"
+	CodeIncorrectPIN            Code = "incorrect_pin"
+)
+
+// Администрирование
+const (
+	CodeUserNotFound      Code = "user_not_found"
+	CodeInvalidReasonCode Code = "invalid_reason_code"
+	CodeInvalidRole       Code = "invalid_role"
+	CodeAlreadyFrozen     Code = "already_frozen"
+	CodeNotFrozen         Code = "not_frozen"
+	CodeEmptySearchQuery  Code = "empty_search_query"
+)
+
+type entry struct {
+	status  int
+	message message
+}
+
+// catalog задаёт HTTP-статус и заголовок ответа для каждого кода.
+var catalog = map[Code]entry{
+	CodeBadRequest:        {http.StatusBadRequest, message{"Bad request", "Некорректный запрос"}},
+	CodePayloadTooLarge:   {http.StatusRequestEntityTooLarge, message{"Request body is too large", "Тело запроса слишком большое"}},
+	CodeValidationFailed:  {http.StatusUnprocessableEntity, message{"Validation failed", "Ошибка проверки данных"}},
+	CodeUnauthorized:      {http.StatusUnauthorized, message{"Authentication required", "Требуется аутентификация"}},
+	CodeInvalidToken:      {http.StatusUnauthorized, message{"Access token is invalid or expired", "Токен доступа недействителен или истёк"}},
+	CodeForbidden:         {http.StatusForbidden, message{"Access denied", "Доступ запрещён"}},
+	CodeInsufficientScope: {http.StatusForbidden, message{"Token does not grant the required scope", "Токен не предоставляет нужную область доступа"}},
+	CodeNotFound:          {http.StatusNotFound, message{"Resource not found", "Ресурс не найден"}},
+	CodeMethodNotAllowed:  {http.StatusMethodNotAllowed, message{"Method not allowed", "Метод не поддерживается"}},
+	CodeTimeout:           {http.StatusGatewayTimeout, message{"Request took too long to process", "Запрос обрабатывался слишком долго"}},
+	CodeInternal:          {http.StatusInternalServerError, message{"Internal server error", "Внутренняя ошибка сервера"}},
+
+	CodeEmailInUse:               {http.StatusBadRequest, message{"Email is already in use", "Email уже используется"}},
+	CodeUsernameTaken:            {http.StatusBadRequest, message{"Username is already taken", "Имя пользователя уже занято"}},
+	CodeInvalidCredentials:       {http.StatusUnauthorized, message{"Invalid email or password", "Неверный email или пароль"}},
+	CodeTooManyAttempts:          {http.StatusTooManyRequests, message{"Too many login attempts", "Слишком много попыток входа"}},
+	CodeInvalidRefreshToken:      {http.StatusUnauthorized, message{"Refresh token is invalid or expired", "Токен обновления недействителен или истёк"}},
+	CodeSessionNotFound:          {http.StatusNotFound, message{"Session not found", "Сессия не найдена"}},
+	CodeInvalidMFACode:           {http.StatusUnauthorized, message{"Invalid two-factor code", "Неверный код двухфакторной аутентификации"}},
+	CodeInvalidMFAToken:          {http.StatusUnauthorized, message{"MFA token is invalid or expired", "Токен второго шага входа недействителен или истёк"}},
+	CodeMFAAlreadyEnabled:        {http.StatusConflict, message{"Two-factor authentication is already enabled", "Двухфакторная аутентификация уже включена"}},
+	CodeMFANotEnabled:            {http.StatusConflict, message{"Two-factor authentication is not enabled", "Двухфакторная аутентификация не включена"}},
+	CodeMFANotEnrolled:           {http.StatusConflict, message{"Two-factor enrollment has not been started", "Подключение двухфакторной аутентификации не начато"}},
+	CodeStepUpRequired:           {http.StatusForbidden, message{"Step-up verification required", "Требуется подтверждение вторым фактором"}},
+	CodeInvalidVerificationToken: {http.StatusBadRequest, message{"Verification token is invalid or expired", "Токен подтверждения недействителен или истёк"}},
+	CodeEmailAlreadyVerified:     {http.StatusConflict, message{"Email is already verified", "Email уже подтверждён"}},
+	CodeEmailNotVerified:         {http.StatusForbidden, message{"Email is not verified", "Email не подтверждён"}},
+	CodeInvalidResetToken:        {http.StatusBadRequest, message{"Password reset token is invalid or expired", "Токен сброса пароля недействителен или истёк"}},
+	CodeAPIKeyNotFound:           {http.StatusNotFound, message{"API key not found", "API-ключ не найден"}},
+	CodeTooManyAPIKeys:           {http.StatusConflict, message{"Too many API keys", "Слишком много API-ключей"}},
+	CodeConsentNotFound:          {http.StatusNotFound, message{"Consent not found", "Согласие не найдено"}},
+
+	CodeAccountNotFound:            {http.StatusNotFound, message{"Account not found", "Счёт не найден"}},
+	CodeSourceAccountNotFound:      {http.StatusNotFound, message{"Source account not found", "Счёт списания не найден"}},
+	CodeDestinationAccountNotFound: {http.StatusNotFound, message{"Destination account not found", "Счёт зачисления не найден"}},
+	CodeAccountFrozen:              {http.StatusLocked, message{"Account is frozen", "Счёт заморожен"}},
+	CodeDestinationAccountFrozen:   {http.StatusLocked, message{"Destination account is frozen", "Счёт зачисления заморожен"}},
+	CodeInvalidAmount:              {http.StatusBadRequest, message{"Invalid amount", "Некорректная сумма"}},
+	CodeInsufficientFunds:          {http.StatusBadRequest, message{"Insufficient funds", "Недостаточно средств"}},
+	CodeCreditNotFound:             {http.StatusNotFound, message{"Credit not found", "Кредит не найден"}},
+
+	CodeCardNotFound:            {http.StatusNotFound, message{"Card not found", "Карта не найдена"}},
+	CodeDestinationCardNotFound: {http.StatusNotFound, message{"Destination card not found", "Карта получателя не найдена"}},
+	CodeInvalidCardNumber:       {http.StatusBadRequest, message{"Invalid card number", "Некорректный номер карты"}},
+	CodeVirtualCardTransfer:     {http.StatusBadRequest, message{"Transfers from virtual cards are not allowed", "Переводы с виртуальных карт запрещены"}},
+	CodeInvalidCardType:         {http.StatusBadRequest, message{"Invalid virtual card type", "Некорректный тип виртуальной карты"}},
+	CodeInvalidCardTTL:          {http.StatusBadRequest, message{"Invalid virtual card lifetime", "Некорректный срок действия виртуальной карты"}},
+	CodeInvalidAmountCap:        {http.StatusBadRequest, message{"Invalid virtual card amount cap", "Некорректный лимит суммы виртуальной карты"}},
+	CodeAmountCapExceeded:       {http.StatusBadRequest, message{"Card amount cap exceeded", "Превышен лимит суммы карты"}},
+	CodeMerchantMismatch:        {http.StatusBadRequest, message{"Card is locked to another merchant", "Карта привязана к другому продавцу"}},
+	CodeMerchantRequired:        {http.StatusBadRequest, message{"Merchant is required", "Не указан продавец"}},
+	CodeInvalidLimits:           {http.StatusBadRequest, message{"Invalid card limits", "Некорректные лимиты карты"}},
+	CodeInvalidChannel:          {http.StatusBadRequest, message{"Invalid card channel", "Некорректный канал операции"}},
+	CodeChannelDisabled:         {http.StatusBadRequest, message{"Card channel is disabled", "Канал операций по карте отключён"}},
+	CodeCardLimitExceeded:       {http.StatusBadRequest, message{"Card limit exceeded", "Превышен лимит по карте"}},
+	CodeCardExpired:             {http.StatusGone, message{"Card is expired", "Срок действия карты истёк"}},
+	CodeCardDestroyed:           {http.StatusGone, message{"Card is destroyed", "Карта уничтожена"}},
+	CodeCardBlocked:             {http.StatusLocked, message{"Card is blocked", "Карта заблокирована"}},
+	CodeInvalidPIN:              {http.StatusBadRequest, message{"PIN must consist of 4 to 6 digits and must not be trivial", "PIN-код должен состоять из 4–6 цифр и не быть простым"}},
+	CodePINAlreadySet:           {http.StatusBadRequest, message{"PIN is already set", "PIN-код уже установлен"}},
+	CodePINNotSet:               {http.StatusBadRequest, message{"PIN is not set", "PIN-код не установлен"}},
+	CodeIncorrectPIN:            {http.StatusBadRequest, message{"Incorrect PIN", "Неверный PIN-код"}},
+
+	CodeUserNotFound:      {http.StatusNotFound, message{"User not found", "Пользователь не найден"}},
+	CodeInvalidReasonCode: {http.StatusBadRequest, message{"Invalid or missing reason code", "Код причины не указан или некорректен"}},
+	CodeInvalidRole:       {http.StatusBadRequest, message{"Invalid role", "Некорректная роль"}},
+	CodeAlreadyFrozen:     {http.StatusConflict, message{"Account is already frozen", "Счёт уже заморожен"}},
+	CodeNotFrozen:         {http.StatusConflict, message{"Account is not frozen", "Счёт не заморожен"}},
+	CodeEmptySearchQuery:  {http.StatusBadRequest, message{"Search query is required", "Не задан поисковый запрос"}},
+}
+
+// fieldMessages — тексты ошибок проверки полей по их кодам; {min}, {max} и {value} берутся из Params.
+var fieldMessages = map[string]message{
+	"required":               {"Field is required", "Обязательное поле"},
+	"unknown_field":          {"Unknown field", "Неизвестное поле"},
+	"invalid_type":           {"Must be of type {expected}", "Значение должно иметь тип {expected}"},
+	"invalid_uuid":           {"Must be a UUID", "Значение должно быть UUID"},
+	"invalid_value":          {"Must be one of: {allowed}", "Допустимые значения: {allowed}"},
+	"too_small":              {"Must be at least {min}", "Значение должно быть не меньше {min}"},
+	"too_large":              {"Must be at most {max}", "Значение должно быть не больше {max}"},
+	"too_many_decimals":      {"Must have at most {max} decimal places", "Допускается не более {max} знаков после запятой"},
+	"min_length":             {"Must be at least {min} characters long", "Длина должна быть не меньше {min} символов"},
+	"max_length":             {"Must be at most {max} characters long", "Длина должна быть не больше {max} символов"},
+	"too_few_items":          {"Must contain at least {min} items", "Должно содержать не меньше {min} элементов"},
+	"too_many_items":         {"Must contain at most {max} items", "Должно содержать не больше {max} элементов"},
+	"invalid_length":         {"Must be {min}-{max} characters long", "Длина должна быть от {min} до {max} символов"},
+	"out_of_range":           {"Must be between {min} and {max}", "Значение должно быть от {min} до {max}"},
+	"invalid_email":          {"Email address is invalid", "Некорректный адрес email"},
+	"too_short":              {"Must be at least {min} characters long", "Должно быть не короче {min} символов"},
+	"too_long":               {"Must be at most {max} bytes long", "Должно быть не длиннее {max} байт"},
+	"too_simple":             {"Must contain at least {min} of: lowercase letters, uppercase letters, digits, symbols", "Должно содержать не менее {min} из: строчные буквы, заглавные буквы, цифры, символы"},
+	"contains_personal_info": {"Must not contain the username or email", "Не должно содержать имя пользователя или email"},
+	"breached":               {"Password appears in a list of leaked passwords", "Пароль найден в списке утёкших паролей"},
+	"unknown_scope":          {"Unknown scope {value}", "Неизвестная область доступа {value}"},
+	"invalid_ip":             {"{value} is not an IP address or CIDR subnet", "{value} не является IP-адресом или подсетью CIDR"},
+}
@@ -0,0 +1,55 @@
+package problem
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Поддерживаемые языки сообщений об ошибках
+const (
+	LangEnglish = "en"
+	LangRussian = "ru"
+)
+
+// DefaultLanguage используется, если Accept-Language не задан или не содержит поддерживаемых языков.
+const DefaultLanguage = LangEnglish
+
+// Language выбирает язык ответа по заголовку Accept-Language с учётом весов q
+// (например, "ru-RU,ru;q=0.9,en;q=0.8" даёт "ru").
+func Language(r *http.Request) string {
+	best, bestQ := DefaultLanguage, 0.0
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		primary, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if (primary == LangRussian || primary == LangEnglish) && q > bestQ {
+			best, bestQ = primary, q
+		}
+	}
+	return best
+}
+
+// message — текст на поддерживаемых языках; {name} заменяется значением параметра name.
+type message struct {
+	en, ru string
+}
+
+func (m message) in(lang string, params map[string]interface{}) string {
+	text := m.en
+	if lang == LangRussian {
+		text = m.ru
+	}
+	for k, v := range params {
+		text = strings.ReplaceAll(text, "{"+k+"}", fmt.Sprint(v))
+	}
+	return text
+}
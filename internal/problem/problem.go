@@ -0,0 +1,121 @@
+// Package problem формирует ответы об ошибках в формате RFC 7807 (application/problem+json)
+// со стабильными машиночитаемыми кодами и сообщениями на русском или английском языке.
+package problem
+
+import (
+	"encoding/json"
+	"go_project/internal/logging"
+	"net/http"
+)
+
+// ContentType — тип содержимого ответов об ошибках
+const ContentType = "application/problem+json"
+
+// typePrefix образует URI типа проблемы из кода ошибки
+const typePrefix = "urn:kirbank:problem:"
+
+// FieldError — ошибка проверки одного поля запроса. Message заменяется переводом по Code и Params,
+// если код есть в каталоге; иначе остаётся как есть.
+type FieldError struct {
+	Field   string                 `json:"field"`
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Params  map[string]interface{} `json:"params,omitempty"`
+}
+
+// Problem — тело ответа об ошибке. Extensions добавляются в объект верхнего уровня
+// рядом со стандартными полями (например, retry_after или step_up_required).
+type Problem struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	Instance   string
+	Code       Code
+	RequestID  string
+	Errors     []FieldError
+	Extensions map[string]interface{}
+
+	lang string
+}
+
+// New создаёт описание ошибки с кодом code на языке, выбранном по Accept-Language запроса.
+func New(r *http.Request, code Code) *Problem {
+	lang := Language(r)
+	e, ok := catalog[code]
+	if !ok {
+		code, e = CodeInternal, catalog[CodeInternal]
+	}
+	return &Problem{
+		Type:      typePrefix + string(code),
+		Title:     e.message.in(lang, nil),
+		Status:    e.status,
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestID: logging.RequestID(r.Context()),
+		lang:      lang,
+	}
+}
+
+// Validation создаёт ошибку validation_failed (422) со списком ошибок по полям.
+func Validation(r *http.Request, fields []FieldError) *Problem {
+	return New(r, CodeValidationFailed).WithErrors(fields)
+}
+
+// WithErrors добавляет к ответу ошибки по полям, переводя их сообщения на язык ответа.
+func (p *Problem) WithErrors(fields []FieldError) *Problem {
+	p.Errors = make([]FieldError, len(fields))
+	for i, fe := range fields {
+		if msg, ok := fieldMessages[fe.Code]; ok {
+			fe.Message = msg.in(p.lang, fe.Params)
+		}
+		p.Errors[i] = fe
+	}
+	return p
+}
+
+// With добавляет к ответу поле-расширение.
+func (p *Problem) With(key string, value interface{}) *Problem {
+	if p.Extensions == nil {
+		p.Extensions = make(map[string]interface{})
+	}
+	p.Extensions[key] = value
+	return p
+}
+
+// Write отправляет ошибку клиенту.
+func (p *Problem) Write(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("Content-Language", p.lang)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// Write отвечает ошибкой с кодом code без дополнительных полей.
+func Write(w http.ResponseWriter, r *http.Request, code Code) {
+	New(r, code).Write(w)
+}
+
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	body := make(map[string]interface{}, len(p.Extensions)+8)
+	for k, v := range p.Extensions {
+		body[k] = v
+	}
+	body["type"] = p.Type
+	body["title"] = p.Title
+	body["status"] = p.Status
+	body["code"] = p.Code
+	if p.Detail != "" {
+		body["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		body["instance"] = p.Instance
+	}
+	if p.RequestID != "" {
+		body["request_id"] = p.RequestID
+	}
+	if len(p.Errors) > 0 {
+		body["errors"] = p.Errors
+	}
+	return json.Marshal(body)
+}
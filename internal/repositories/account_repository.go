@@ -1,6 +1,7 @@
 package repositories
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"go_project/internal/models"
@@ -14,22 +15,22 @@ func NewAccountRepository(db *sql.DB) *AccountRepository {
 	return &AccountRepository{DB: db}
 }
 
-func (r *AccountRepository) CreateAccount(userID string) (string, error) {
+func (r *AccountRepository) CreateAccount(ctx context.Context, userID string) (string, error) {
 	var accountID string
 	query := `INSERT INTO accounts (id, user_id, balance) 
               VALUES (gen_random_uuid(), $1, 0) RETURNING id`
-	err := r.DB.QueryRow(query, userID).Scan(&accountID)
+	err := conn(ctx, r.DB).QueryRowContext(ctx, query, userID).Scan(&accountID)
 	if err != nil {
 		return "", err
 	}
 	return accountID, nil
 }
 
-func (r *AccountRepository) GetByID(accountID string) (*models.Account, error) {
+func (r *AccountRepository) GetByID(ctx context.Context, accountID string) (*models.Account, error) {
 	var acc models.Account
-	row := r.DB.QueryRow(`SELECT id, user_id, accounts.balance 
+	row := conn(ctx, r.DB).QueryRowContext(ctx, `SELECT id, user_id, accounts.balance, frozen 
                                 FROM accounts where id = $1`, accountID)
-	if err := row.Scan(&acc.ID, &acc.UserID, &acc.Balance); err != nil {
+	if err := row.Scan(&acc.ID, &acc.UserID, &acc.Balance, &acc.Frozen); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, err
 		}
@@ -38,17 +39,72 @@ func (r *AccountRepository) GetByID(accountID string) (*models.Account, error) {
 	return &acc, nil
 }
 
-func (r *AccountRepository) GetBalance(accountID string) (float64, error) {
+func (r *AccountRepository) GetBalance(ctx context.Context, accountID string) (float64, error) {
 	var balance float64
 	query := `SELECT balance FROM accounts where id = $1`
-	err := r.DB.QueryRow(query, accountID).Scan(&balance)
+	err := conn(ctx, r.DB).QueryRowContext(ctx, query, accountID).Scan(&balance)
 	if err != nil {
 		return 0, err
 	}
 	return balance, nil
 }
 
-func (r *AccountRepository) AddBalance(accountID string, amount float64) error {
-	_, err := r.DB.Exec("UPDATE accounts SET balance = balance + $1 WHERE id = $2", amount, accountID)
+func (r *AccountRepository) AddBalance(ctx context.Context, accountID string, amount float64) error {
+	_, err := conn(ctx, r.DB).ExecContext(ctx, "UPDATE accounts SET balance = balance + $1 WHERE id = $2", amount, accountID)
+	return err
+}
+
+// Debit списывает сумму со счёта, только если на нём достаточно средств.
+// Возвращает false, если средств недостаточно.
+func (r *AccountRepository) Debit(ctx context.Context, accountID string, amount float64) (bool, error) {
+	res, err := conn(ctx, r.DB).ExecContext(ctx, `UPDATE accounts SET balance = balance - $1
+                               WHERE id = $2 AND balance >= $1`, amount, accountID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// Credit зачисляет сумму на счёт.
+func (r *AccountRepository) Credit(ctx context.Context, accountID string, amount float64) error {
+	_, err := conn(ctx, r.DB).ExecContext(ctx, "UPDATE accounts SET balance = balance + $1 WHERE id = $2", amount, accountID)
+	return err
+}
+
+// ListByUserID возвращает все счета пользователя.
+func (r *AccountRepository) ListByUserID(ctx context.Context, userID string) ([]models.Account, error) {
+	rows, err := conn(ctx, r.DB).QueryContext(ctx, `SELECT id, user_id, balance, frozen FROM accounts WHERE user_id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	accounts := []models.Account{}
+	for rows.Next() {
+		var acc models.Account
+		if err := rows.Scan(&acc.ID, &acc.UserID, &acc.Balance, &acc.Frozen); err != nil {
+			return nil, err
+		}
+		accounts = append(accounts, acc)
+	}
+	return accounts, rows.Err()
+}
+
+// LockByID блокирует строку счёта до конца транзакции UnitOfWork.Do.
+func (r *AccountRepository) LockByID(ctx context.Context, accountID string) (*models.Account, error) {
+	var acc models.Account
+	row := conn(ctx, r.DB).QueryRowContext(ctx, `SELECT id, user_id, balance, frozen FROM accounts WHERE id = $1 FOR UPDATE`, accountID)
+	if err := row.Scan(&acc.ID, &acc.UserID, &acc.Balance, &acc.Frozen); err != nil {
+		return nil, err
+	}
+	return &acc, nil
+}
+
+// SetFrozen замораживает или размораживает счёт.
+func (r *AccountRepository) SetFrozen(ctx context.Context, accountID string, frozen bool) error {
+	_, err := conn(ctx, r.DB).ExecContext(ctx, `UPDATE accounts SET frozen = $2 WHERE id = $1`, accountID, frozen)
 	return err
 }
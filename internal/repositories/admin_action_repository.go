@@ -0,0 +1,49 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"go_project/internal/models"
+)
+
+type AdminActionRepository struct {
+	DB *sql.DB
+}
+
+func NewAdminActionRepository(db *sql.DB) *AdminActionRepository {
+	return &AdminActionRepository{DB: db}
+}
+
+// Create записывает действие сотрудника в журнал. Чтобы запись попала в журнал только вместе
+// с самим изменением, её создают в той же транзакции UnitOfWork.Do.
+func (r *AdminActionRepository) Create(ctx context.Context, a *models.AdminAction) error {
+	return conn(ctx, r.DB).QueryRowContext(ctx, `INSERT INTO admin_actions (id, actor_id, action, target_type, target_id, reason_code, comment, amount)
+                             VALUES (gen_random_uuid(), $1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at`,
+		a.ActorID, a.Action, a.TargetType, a.TargetID, a.ReasonCode, a.Comment, a.Amount).Scan(&a.ID, &a.CreatedAt)
+}
+
+// List возвращает записи журнала, начиная с последних. Пустой targetID означает все объекты.
+func (r *AdminActionRepository) List(ctx context.Context, targetID string, limit, offset int) ([]models.AdminAction, error) {
+	rows, err := conn(ctx, r.DB).QueryContext(ctx, `SELECT id, actor_id, action, target_type, target_id, reason_code, comment, amount, created_at
+                                FROM admin_actions
+                                WHERE $1 = '' OR target_id = $1
+                                ORDER BY created_at DESC LIMIT $2 OFFSET $3`, targetID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	actions := []models.AdminAction{}
+	for rows.Next() {
+		var a models.AdminAction
+		var amount sql.NullFloat64
+		if err := rows.Scan(&a.ID, &a.ActorID, &a.Action, &a.TargetType, &a.TargetID,
+			&a.ReasonCode, &a.Comment, &amount, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		if amount.Valid {
+			a.Amount = &amount.Float64
+		}
+		actions = append(actions, a)
+	}
+	return actions, rows.Err()
+}
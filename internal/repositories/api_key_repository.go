@@ -0,0 +1,106 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"github.com/lib/pq"
+	"go_project/internal/models"
+	"time"
+)
+
+type APIKeyRepository struct {
+	DB *sql.DB
+}
+
+func NewAPIKeyRepository(db *sql.DB) *APIKeyRepository {
+	return &APIKeyRepository{DB: db}
+}
+
+func (r *APIKeyRepository) Create(ctx context.Context, k *models.APIKey) error {
+	return conn(ctx, r.DB).QueryRowContext(ctx, `INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, allowed_ips, expires_at)
+                                VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id, created_at`,
+		k.UserID, k.Name, k.Prefix, k.KeyHash, pq.Array(k.Scopes), pq.Array(k.AllowedIPs), k.ExpiresAt).
+		Scan(&k.ID, &k.CreatedAt)
+}
+
+// GetActiveByHash возвращает неотозванный ключ вместе с текущей ролью владельца или sql.ErrNoRows.
+// Срок действия проверяет вызывающий код.
+func (r *APIKeyRepository) GetActiveByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	var k models.APIKey
+	var lastUsedAt sql.NullTime
+	var lastUsedIP sql.NullString
+	err := conn(ctx, r.DB).QueryRowContext(ctx, `SELECT k.id, k.user_id, k.name, k.prefix, k.scopes, k.allowed_ips, k.expires_at,
+                                       k.created_at, k.last_used_at, k.last_used_ip, u.role
+                                FROM api_keys k JOIN users u ON u.id = k.user_id
+                                WHERE k.key_hash = $1 AND k.revoked_at IS NULL`, keyHash).
+		Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, pq.Array(&k.Scopes), pq.Array(&k.AllowedIPs), &k.ExpiresAt,
+			&k.CreatedAt, &lastUsedAt, &lastUsedIP, &k.Role)
+	if err != nil {
+		return nil, err
+	}
+	if lastUsedAt.Valid {
+		k.LastUsedAt = &lastUsedAt.Time
+	}
+	k.LastUsedIP = lastUsedIP.String
+	return &k, nil
+}
+
+// ListByUserID возвращает неотозванные ключи пользователя, включая истёкшие.
+func (r *APIKeyRepository) ListByUserID(ctx context.Context, userID string) ([]models.APIKey, error) {
+	rows, err := conn(ctx, r.DB).QueryContext(ctx, `SELECT id, name, prefix, scopes, allowed_ips, expires_at, created_at, last_used_at, last_used_ip
+                               FROM api_keys WHERE user_id = $1 AND revoked_at IS NULL
+                               ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	keys := []models.APIKey{}
+	for rows.Next() {
+		var k models.APIKey
+		var lastUsedAt sql.NullTime
+		var lastUsedIP sql.NullString
+		if err := rows.Scan(&k.ID, &k.Name, &k.Prefix, pq.Array(&k.Scopes), pq.Array(&k.AllowedIPs), &k.ExpiresAt,
+			&k.CreatedAt, &lastUsedAt, &lastUsedIP); err != nil {
+			return nil, err
+		}
+		if lastUsedAt.Valid {
+			k.LastUsedAt = &lastUsedAt.Time
+		}
+		k.UserID = userID
+		k.LastUsedIP = lastUsedIP.String
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+func (r *APIKeyRepository) CountActive(ctx context.Context, userID string, now time.Time) (int, error) {
+	var n int
+	err := conn(ctx, r.DB).QueryRowContext(ctx, `SELECT COUNT(*) FROM api_keys
+                                WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > $2`, userID, now).Scan(&n)
+	return n, err
+}
+
+// TouchLastUsed сохраняет время и IP-адрес последнего использования ключа.
+func (r *APIKeyRepository) TouchLastUsed(ctx context.Context, keyID, ip string, now time.Time) error {
+	_, err := conn(ctx, r.DB).ExecContext(ctx, `UPDATE api_keys SET last_used_at = $2, last_used_ip = $3 WHERE id = $1`, keyID, now, ip)
+	return err
+}
+
+// Revoke отзывает ключ пользователя. Возвращает false, если действующего ключа с таким id нет.
+func (r *APIKeyRepository) Revoke(ctx context.Context, userID, keyID string) (bool, error) {
+	res, err := conn(ctx, r.DB).ExecContext(ctx, `UPDATE api_keys SET revoked_at = NOW()
+                               WHERE id::text = $1 AND user_id = $2 AND revoked_at IS NULL`, keyID, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (r *APIKeyRepository) RevokeAll(ctx context.Context, userID string) error {
+	_, err := conn(ctx, r.DB).ExecContext(ctx, `UPDATE api_keys SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`, userID)
+	return err
+}
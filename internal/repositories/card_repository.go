@@ -1,6 +1,11 @@
 package repositories
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+	"go_project/internal/models"
+	"time"
+)
 
 type CardRepository struct {
 	DB *sql.DB
@@ -10,17 +15,258 @@ func NewCardRepository(db *sql.DB) *CardRepository {
 	return &CardRepository{DB: db}
 }
 
-func (r *CardRepository) CreateCard(accountID, cardNumberPlain, expiryPlain, cvvHash, encryptionKey string) (string, error) {
+func (r *CardRepository) CreateCard(ctx context.Context, card *models.Card, cardNumberPlain, expiryPlain, cvvHash, keyID, encryptionKey string) (string, error) {
 	var cardID string
-	query := `INSERT INTO cards (id, account_id, card_number_encrypted, expiry_encrypted, cvv_hash) 
+	query := `INSERT INTO cards (id, account_id, card_number_encrypted, expiry_encrypted, cvv_hash, key_id,
+                   card_type, amount_cap, expires_at, pan_hmac) 
               VALUES (gen_random_uuid(), $1, 
                       pgp_sym_encrypt($2, $5, 'cipher-algo=aes256'), 
                       pgp_sym_encrypt($3, $5, 'cipher-algo=aes256'), 
-                      $4) 
+                      $4, $6, $7, $8, $9, $10) 
               RETURNING id`
-	err := r.DB.QueryRow(query, accountID, cardNumberPlain, expiryPlain, cvvHash, encryptionKey).Scan(&cardID)
+	err := conn(ctx, r.DB).QueryRowContext(ctx, query, card.AccountID, cardNumberPlain, expiryPlain, cvvHash, encryptionKey, keyID,
+		card.Type, card.AmountCap, card.ExpiresAt, card.PANIndex).Scan(&cardID)
 	if err != nil {
 		return "", err
 	}
 	return cardID, nil
 }
+
+const cardColumns = `id, account_id, card_type, COALESCE(pin_hash, ''), pin_attempts, status,
+                     COALESCE(locked_merchant, ''), amount_cap, expires_at`
+
+func scanCard(row *sql.Row) (*models.Card, error) {
+	var c models.Card
+	var amountCap sql.NullFloat64
+	var expiresAt sql.NullTime
+	err := row.Scan(&c.ID, &c.AccountID, &c.Type, &c.PINHash, &c.PINAttempts, &c.Status,
+		&c.LockedMerchant, &amountCap, &expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	if amountCap.Valid {
+		c.AmountCap = &amountCap.Float64
+	}
+	if expiresAt.Valid {
+		c.ExpiresAt = &expiresAt.Time
+	}
+	return &c, nil
+}
+
+func (r *CardRepository) GetByID(ctx context.Context, cardID string) (*models.Card, error) {
+	return scanCard(conn(ctx, r.DB).QueryRowContext(ctx, `SELECT `+cardColumns+` FROM cards WHERE id = $1`, cardID))
+}
+
+// GetByPANIndex ищет карту по HMAC её номера.
+func (r *CardRepository) GetByPANIndex(ctx context.Context, panIndex string) (*models.Card, error) {
+	return scanCard(conn(ctx, r.DB).QueryRowContext(ctx, `SELECT `+cardColumns+` FROM cards WHERE pan_hmac = $1`, panIndex))
+}
+
+// ListWithoutPANIndex возвращает до limit идентификаторов карт, для которых ещё не рассчитан HMAC номера.
+func (r *CardRepository) ListWithoutPANIndex(ctx context.Context, limit int) ([]string, error) {
+	rows, err := conn(ctx, r.DB).QueryContext(ctx, `SELECT id FROM cards WHERE pan_hmac IS NULL ORDER BY id LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+func (r *CardRepository) SetPANIndex(ctx context.Context, cardID, panIndex string) error {
+	_, err := conn(ctx, r.DB).ExecContext(ctx, `UPDATE cards SET pan_hmac = $1 WHERE id = $2`, panIndex, cardID)
+	return err
+}
+
+// LockByID читает карту и блокирует строку до завершения транзакции UnitOfWork.Do.
+func (r *CardRepository) LockByID(ctx context.Context, cardID string) (*models.Card, error) {
+	return scanCard(conn(ctx, r.DB).QueryRowContext(ctx, `SELECT `+cardColumns+` FROM cards WHERE id = $1 FOR UPDATE`, cardID))
+}
+
+func (r *CardRepository) SetStatus(ctx context.Context, cardID, status string) error {
+	_, err := conn(ctx, r.DB).ExecContext(ctx, `UPDATE cards SET status = $1 WHERE id = $2`, status, cardID)
+	return err
+}
+
+// LockMerchant привязывает карту к продавцу, если привязка ещё не установлена.
+func (r *CardRepository) LockMerchant(ctx context.Context, cardID, merchant string) error {
+	_, err := conn(ctx, r.DB).ExecContext(ctx, `UPDATE cards SET locked_merchant = $1
+                             WHERE id = $2 AND locked_merchant IS NULL`, merchant, cardID)
+	return err
+}
+
+// GetCardNumber расшифровывает номер карты ключом, которым он был зашифрован.
+// keyFor возвращает значение ключа по идентификатору, сохранённому в строке карты.
+func (r *CardRepository) GetCardNumber(ctx context.Context, cardID string, keyFor func(keyID string) (string, error)) (string, error) {
+	var encrypted []byte
+	var keyID string
+	err := conn(ctx, r.DB).QueryRowContext(ctx, `SELECT card_number_encrypted, key_id FROM cards WHERE id = $1`, cardID).
+		Scan(&encrypted, &keyID)
+	if err != nil {
+		return "", err
+	}
+	key, err := keyFor(keyID)
+	if err != nil {
+		return "", err
+	}
+	var cardNumber string
+	if err := conn(ctx, r.DB).QueryRowContext(ctx, `SELECT pgp_sym_decrypt($1::bytea, $2)`, encrypted, key).Scan(&cardNumber); err != nil {
+		return "", err
+	}
+	return cardNumber, nil
+}
+
+// GetCardDetails расшифровывает номер и срок действия карты для показа владельцу.
+func (r *CardRepository) GetCardDetails(ctx context.Context, cardID string, keyFor func(keyID string) (string, error)) (string, string, error) {
+	var numberEncrypted, expiryEncrypted []byte
+	var keyID string
+	err := conn(ctx, r.DB).QueryRowContext(ctx, `SELECT card_number_encrypted, expiry_encrypted, key_id FROM cards WHERE id = $1`, cardID).
+		Scan(&numberEncrypted, &expiryEncrypted, &keyID)
+	if err != nil {
+		return "", "", err
+	}
+	key, err := keyFor(keyID)
+	if err != nil {
+		return "", "", err
+	}
+	var cardNumber, expiry string
+	err = conn(ctx, r.DB).QueryRowContext(ctx, `SELECT pgp_sym_decrypt($1::bytea, $3), pgp_sym_decrypt($2::bytea, $3)`,
+		numberEncrypted, expiryEncrypted, key).Scan(&cardNumber, &expiry)
+	if err != nil {
+		return "", "", err
+	}
+	return cardNumber, expiry, nil
+}
+
+// CountByKeyID возвращает количество карт, зашифрованных каждым из ключей.
+func (r *CardRepository) CountByKeyID(ctx context.Context) (map[string]int, error) {
+	return countByKeyID(ctx, conn(ctx, r.DB), `SELECT key_id, COUNT(*) FROM cards GROUP BY key_id`)
+}
+
+// countByKeyID выполняет запрос, возвращающий пары (идентификатор ключа, количество записей).
+func countByKeyID(ctx context.Context, q querier, query string) (map[string]int, error) {
+	rows, err := q.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	counts := make(map[string]int)
+	for rows.Next() {
+		var keyID string
+		var n int
+		if err := rows.Scan(&keyID, &n); err != nil {
+			return nil, err
+		}
+		counts[keyID] = n
+	}
+	return counts, rows.Err()
+}
+
+// ReencryptBatch перешифровывает до limit карт с ключа fromKeyID на ключ toKeyID одним запросом.
+// Строки, заблокированные другими транзакциями, пропускаются и будут обработаны следующим пакетом.
+func (r *CardRepository) ReencryptBatch(ctx context.Context, fromKeyID, fromKey, toKeyID, toKey string, limit int) (int, error) {
+	res, err := conn(ctx, r.DB).ExecContext(ctx, `UPDATE cards SET
+                 card_number_encrypted = pgp_sym_encrypt(pgp_sym_decrypt(card_number_encrypted, $2), $4, 'cipher-algo=aes256'),
+                 expiry_encrypted = pgp_sym_encrypt(pgp_sym_decrypt(expiry_encrypted, $2), $4, 'cipher-algo=aes256'),
+                 key_id = $3
+                 WHERE id IN (SELECT id FROM cards WHERE key_id = $1 ORDER BY id LIMIT $5 FOR UPDATE SKIP LOCKED)`,
+		fromKeyID, fromKey, toKeyID, toKey, limit)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(n), nil
+}
+
+// SetPIN сохраняет хеш нового PIN и сбрасывает счётчик неудачных попыток.
+func (r *CardRepository) SetPIN(ctx context.Context, cardID, pinHash string) error {
+	_, err := conn(ctx, r.DB).ExecContext(ctx, `UPDATE cards SET pin_hash = $1, pin_attempts = 0 WHERE id = $2`, pinHash, cardID)
+	return err
+}
+
+// RecordPINFailure увеличивает счётчик неудачных попыток ввода PIN и блокирует карту,
+// когда он достигает maxAttempts. Возвращает итоговое число попыток и статус карты.
+func (r *CardRepository) RecordPINFailure(ctx context.Context, cardID string, maxAttempts int) (int, string, error) {
+	var attempts int
+	var status string
+	err := conn(ctx, r.DB).QueryRowContext(ctx, `UPDATE cards SET pin_attempts = pin_attempts + 1,
+                 status = CASE WHEN pin_attempts + 1 >= $2 THEN $3 ELSE status END
+                 WHERE id = $1 RETURNING pin_attempts, status`,
+		cardID, maxAttempts, models.CardStatusBlocked).Scan(&attempts, &status)
+	if err != nil {
+		return 0, "", err
+	}
+	return attempts, status, nil
+}
+
+func (r *CardRepository) ResetPINAttempts(ctx context.Context, cardID string) error {
+	_, err := conn(ctx, r.DB).ExecContext(ctx, `UPDATE cards SET pin_attempts = 0 WHERE id = $1`, cardID)
+	return err
+}
+
+// GetLimits возвращает лимиты карты или sql.ErrNoRows, если они ещё не настраивались.
+func (r *CardRepository) GetLimits(ctx context.Context, cardID string) (*models.CardLimits, error) {
+	return getCardLimits(ctx, conn(ctx, r.DB), cardID)
+}
+
+func getCardLimits(ctx context.Context, q querier, cardID string) (*models.CardLimits, error) {
+	var l models.CardLimits
+	row := q.QueryRowContext(ctx, `SELECT card_id, daily_limit, monthly_limit, per_transaction_limit,
+       							online_enabled, contactless_enabled, atm_enabled, foreign_enabled
+								FROM card_limits WHERE card_id = $1`, cardID)
+	err := row.Scan(&l.CardID, &l.DailyLimit, &l.MonthlyLimit, &l.PerTransactionLimit,
+		&l.OnlineEnabled, &l.ContactlessEnabled, &l.ATMEnabled, &l.ForeignEnabled)
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+func (r *CardRepository) UpsertLimits(ctx context.Context, l *models.CardLimits) error {
+	_, err := conn(ctx, r.DB).ExecContext(ctx, `INSERT INTO card_limits (card_id, daily_limit, monthly_limit, per_transaction_limit,
+                         online_enabled, contactless_enabled, atm_enabled, foreign_enabled)
+						 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+						 ON CONFLICT (card_id) DO UPDATE SET
+						     daily_limit = EXCLUDED.daily_limit,
+						     monthly_limit = EXCLUDED.monthly_limit,
+						     per_transaction_limit = EXCLUDED.per_transaction_limit,
+						     online_enabled = EXCLUDED.online_enabled,
+						     contactless_enabled = EXCLUDED.contactless_enabled,
+						     atm_enabled = EXCLUDED.atm_enabled,
+						     foreign_enabled = EXCLUDED.foreign_enabled`,
+		l.CardID, l.DailyLimit, l.MonthlyLimit, l.PerTransactionLimit,
+		l.OnlineEnabled, l.ContactlessEnabled, l.ATMEnabled, l.ForeignEnabled)
+	return err
+}
+
+// SumAuthorizedSince возвращает сумму авторизованных по карте операций начиная с момента since.
+func (r *CardRepository) SumAuthorizedSince(ctx context.Context, cardID string, since time.Time) (float64, error) {
+	return sumCardAuthorizations(ctx, conn(ctx, r.DB), cardID, since)
+}
+
+func sumCardAuthorizations(ctx context.Context, q querier, cardID string, since time.Time) (float64, error) {
+	var total float64
+	err := q.QueryRowContext(ctx, `SELECT COALESCE(SUM(amount), 0) FROM card_authorizations
+                              WHERE card_id = $1 AND created_at >= $2`, cardID, since).Scan(&total)
+	if err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+func (r *CardRepository) CreateAuthorization(ctx context.Context, a *models.CardAuthorization) error {
+	return conn(ctx, r.DB).QueryRowContext(ctx, `INSERT INTO card_authorizations (id, card_id, amount, channel, merchant, country)
+							  VALUES (gen_random_uuid(), $1, $2, $3, $4, $5)
+							  RETURNING id, created_at`,
+		a.CardID, a.Amount, a.Channel, a.Merchant, a.Country).Scan(&a.ID, &a.CreatedAt)
+}
@@ -1,6 +1,7 @@
 package repositories
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"go_project/internal/models"
@@ -15,9 +16,9 @@ func NewCreditRepository(db *sql.DB) *CreditRepository {
 	return &CreditRepository{DB: db}
 }
 
-func (r *CreditRepository) GetById(creditID string) (*models.Credit, error) {
+func (r *CreditRepository) GetById(ctx context.Context, creditID string) (*models.Credit, error) {
 	var c models.Credit
-	row := r.DB.QueryRow(`SELECT id, account_id, amount, interest_rate, term_months, start_date 
+	row := conn(ctx, r.DB).QueryRowContext(ctx, `SELECT id, account_id, amount, interest_rate, term_months, start_date 
 								FROM credits WHERE id = $1`, creditID)
 	var start time.Time
 	if err := row.Scan(&c.ID, &c.AccountID, &c.Amount, &c.InterestRate, &c.TermMonths, &start); err != nil {
@@ -30,12 +31,12 @@ func (r *CreditRepository) GetById(creditID string) (*models.Credit, error) {
 	return &c, nil
 }
 
-func (r *CreditRepository) CreateCredit(accountID string, amount float64, interest float64, term int) (string, error) {
+func (r *CreditRepository) CreateCredit(ctx context.Context, accountID string, amount float64, interest float64, term int) (string, error) {
 	var creditID string
 	query := `INSERT INTO credits (id, account_id, amount, interest_rate, term_months, start_date) 
               VALUES (gen_random_uuid(), $1, $2, $3, $4, now()) 
               RETURNING id`
-	err := r.DB.QueryRow(query, accountID, amount, interest, term).Scan(&creditID)
+	err := conn(ctx, r.DB).QueryRowContext(ctx, query, accountID, amount, interest, term).Scan(&creditID)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,91 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"go_project/internal/models"
+	"time"
+)
+
+// LoginAttemptRepository хранит счётчики неудачных попыток входа в БД,
+// чтобы ограничения действовали одинаково на всех экземплярах сервиса.
+type LoginAttemptRepository struct {
+	DB *sql.DB
+}
+
+func NewLoginAttemptRepository(db *sql.DB) *LoginAttemptRepository {
+	return &LoginAttemptRepository{DB: db}
+}
+
+// Get возвращает состояние счётчика по ключу (email:<адрес> или ip:<адрес>).
+// Если попыток не было, возвращается sql.ErrNoRows.
+func (r *LoginAttemptRepository) Get(ctx context.Context, key string) (*models.LoginAttempts, error) {
+	var a models.LoginAttempts
+	var lockedUntil sql.NullTime
+	err := conn(ctx, r.DB).QueryRowContext(ctx, `SELECT key, failures, last_failure_at, locked_until FROM login_attempts WHERE key = $1`, key).
+		Scan(&a.Key, &a.Failures, &a.LastFailureAt, &lockedUntil)
+	if err != nil {
+		return nil, err
+	}
+	if lockedUntil.Valid {
+		a.LockedUntil = &lockedUntil.Time
+	}
+	return &a, nil
+}
+
+// RecordAttempt атомарно учитывает попытку входа до проверки пароля или кода: параллельные запросы
+// получают разные значения счётчика. Если последняя попытка была раньше resetBefore, счёт начинается
+// заново, а истёкшая блокировка снимается. Возвращает состояние с учётом этой попытки и время
+// предыдущей попытки (нулевое, если записи не было).
+func (r *LoginAttemptRepository) RecordAttempt(ctx context.Context, key string, now, resetBefore time.Time) (*models.LoginAttempts, time.Time, error) {
+	var a models.LoginAttempts
+	var lockedUntil, previousAt sql.NullTime
+	err := conn(ctx, r.DB).QueryRowContext(ctx, `WITH previous AS (
+                                    SELECT last_failure_at FROM login_attempts WHERE key = $1 FOR UPDATE
+                                )
+                                INSERT INTO login_attempts (key, failures, last_failure_at) VALUES ($1, 1, $2)
+                                ON CONFLICT (key) DO UPDATE SET
+                                    failures = CASE WHEN login_attempts.last_failure_at < $3 THEN 1
+                                               ELSE login_attempts.failures + 1 END,
+                                    locked_until = CASE WHEN login_attempts.last_failure_at < $3 THEN NULL
+                                                   ELSE login_attempts.locked_until END,
+                                    last_failure_at = $2
+                                RETURNING key, failures, last_failure_at, locked_until,
+                                          (SELECT last_failure_at FROM previous)`, key, now, resetBefore).
+		Scan(&a.Key, &a.Failures, &a.LastFailureAt, &lockedUntil, &previousAt)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if lockedUntil.Valid {
+		a.LockedUntil = &lockedUntil.Time
+	}
+	return &a, previousAt.Time, nil
+}
+
+// Release отменяет учёт попытки, которая не оказалась неудачной: уменьшает счётчик и возвращает
+// время предыдущей попытки, полученное от RecordAttempt (нулевое время оставляет текущее).
+func (r *LoginAttemptRepository) Release(ctx context.Context, key string, previousAt time.Time) error {
+	_, err := conn(ctx, r.DB).ExecContext(ctx, `UPDATE login_attempts
+                               SET failures = GREATEST(failures - 1, 0), last_failure_at = COALESCE($2, last_failure_at)
+                               WHERE key = $1`, key, sql.NullTime{Time: previousAt, Valid: !previousAt.IsZero()})
+	return err
+}
+
+// Lock запрещает вход по ключу до указанного момента.
+func (r *LoginAttemptRepository) Lock(ctx context.Context, key string, until time.Time) error {
+	_, err := conn(ctx, r.DB).ExecContext(ctx, `UPDATE login_attempts SET locked_until = $2 WHERE key = $1`, key, until)
+	return err
+}
+
+// Reset сбрасывает счётчик после успешного входа.
+func (r *LoginAttemptRepository) Reset(ctx context.Context, key string) error {
+	_, err := conn(ctx, r.DB).ExecContext(ctx, `DELETE FROM login_attempts WHERE key = $1`, key)
+	return err
+}
+
+// DeleteStale удаляет счётчики без неудач после before и без действующей блокировки.
+func (r *LoginAttemptRepository) DeleteStale(ctx context.Context, before time.Time) error {
+	_, err := conn(ctx, r.DB).ExecContext(ctx, `DELETE FROM login_attempts
+                               WHERE last_failure_at < $1 AND (locked_until IS NULL OR locked_until < $1)`, before)
+	return err
+}
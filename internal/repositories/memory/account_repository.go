@@ -0,0 +1,100 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"go_project/internal/models"
+	"sort"
+)
+
+type account struct {
+	models.Account
+	seq int64
+}
+
+type AccountRepository struct {
+	s *Store
+}
+
+func NewAccountRepository(s *Store) *AccountRepository {
+	return &AccountRepository{s: s}
+}
+
+func (r *AccountRepository) CreateAccount(ctx context.Context, userID string) (string, error) {
+	defer r.s.lock(ctx)()
+	if r.s.data.users[userID] == nil {
+		return "", ErrForeignKey
+	}
+	id := newID()
+	r.s.data.accounts[id] = &account{Account: models.Account{ID: id, UserID: userID}, seq: r.s.data.next()}
+	return id, nil
+}
+
+func (r *AccountRepository) GetByID(ctx context.Context, accountID string) (*models.Account, error) {
+	defer r.s.lock(ctx)()
+	acc, ok := r.s.data.accounts[accountID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	copied := acc.Account
+	return &copied, nil
+}
+
+func (r *AccountRepository) GetBalance(ctx context.Context, accountID string) (float64, error) {
+	acc, err := r.GetByID(ctx, accountID)
+	if err != nil {
+		return 0, err
+	}
+	return acc.Balance, nil
+}
+
+func (r *AccountRepository) ListByUserID(ctx context.Context, userID string) ([]models.Account, error) {
+	defer r.s.lock(ctx)()
+	var rows []*account
+	for _, acc := range r.s.data.accounts {
+		if acc.UserID == userID {
+			rows = append(rows, acc)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].seq < rows[j].seq })
+	accounts := []models.Account{}
+	for _, acc := range rows {
+		accounts = append(accounts, acc.Account)
+	}
+	return accounts, nil
+}
+
+func (r *AccountRepository) AddBalance(ctx context.Context, accountID string, amount float64) error {
+	return r.Credit(ctx, accountID, amount)
+}
+
+func (r *AccountRepository) Debit(ctx context.Context, accountID string, amount float64) (bool, error) {
+	defer r.s.lock(ctx)()
+	acc, ok := r.s.data.accounts[accountID]
+	if !ok || acc.Balance < money(amount) {
+		return false, nil
+	}
+	acc.Balance = money(acc.Balance - amount)
+	return true, nil
+}
+
+func (r *AccountRepository) Credit(ctx context.Context, accountID string, amount float64) error {
+	defer r.s.lock(ctx)()
+	if acc, ok := r.s.data.accounts[accountID]; ok {
+		acc.Balance = money(acc.Balance + amount)
+	}
+	return nil
+}
+
+// LockByID читает счёт; внутри Do данные и так заблокированы целиком.
+func (r *AccountRepository) LockByID(ctx context.Context, accountID string) (*models.Account, error) {
+	return r.GetByID(ctx, accountID)
+}
+
+func (r *AccountRepository) SetFrozen(ctx context.Context, accountID string, frozen bool) error {
+	defer r.s.lock(ctx)()
+	if acc, ok := r.s.data.accounts[accountID]; ok {
+		acc.Frozen = frozen
+	}
+	return nil
+}
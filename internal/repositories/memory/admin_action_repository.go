@@ -0,0 +1,52 @@
+package memory
+
+import (
+	"context"
+	"go_project/internal/models"
+	"time"
+)
+
+type adminAction struct {
+	models.AdminAction
+}
+
+type AdminActionRepository struct {
+	s *Store
+}
+
+func NewAdminActionRepository(s *Store) *AdminActionRepository {
+	return &AdminActionRepository{s: s}
+}
+
+func (r *AdminActionRepository) Create(ctx context.Context, a *models.AdminAction) error {
+	defer r.s.lock(ctx)()
+	if r.s.data.users[a.ActorID] == nil {
+		return ErrForeignKey
+	}
+	a.ID, a.CreatedAt = newID(), time.Now()
+	row := *a
+	if row.Amount != nil {
+		amount := money(*row.Amount)
+		row.Amount = &amount
+	}
+	r.s.data.adminActions = append(r.s.data.adminActions, adminAction{AdminAction: row})
+	return nil
+}
+
+func (r *AdminActionRepository) List(ctx context.Context, targetID string, limit, offset int) ([]models.AdminAction, error) {
+	defer r.s.lock(ctx)()
+	actions := []models.AdminAction{}
+	// Записи добавляются в порядке создания, поэтому последние идут с конца
+	for i := len(r.s.data.adminActions) - 1; i >= 0 && len(actions) < limit; i-- {
+		a := r.s.data.adminActions[i]
+		if targetID != "" && a.TargetID != targetID {
+			continue
+		}
+		if offset > 0 {
+			offset--
+			continue
+		}
+		actions = append(actions, a.AdminAction)
+	}
+	return actions, nil
+}
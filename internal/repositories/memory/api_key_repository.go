@@ -0,0 +1,115 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"go_project/internal/models"
+	"sort"
+	"time"
+)
+
+type apiKey struct {
+	models.APIKey
+	revoked bool
+}
+
+type APIKeyRepository struct {
+	s *Store
+}
+
+func NewAPIKeyRepository(s *Store) *APIKeyRepository {
+	return &APIKeyRepository{s: s}
+}
+
+func (r *APIKeyRepository) Create(ctx context.Context, k *models.APIKey) error {
+	defer r.s.lock(ctx)()
+	if r.s.data.users[k.UserID] == nil {
+		return ErrForeignKey
+	}
+	for _, existing := range r.s.data.apiKeys {
+		if existing.KeyHash == k.KeyHash {
+			return ErrDuplicate
+		}
+	}
+	k.ID, k.CreatedAt = newID(), time.Now()
+	row := *k
+	row.LastUsedAt, row.LastUsedIP, row.Role = nil, "", ""
+	if row.Scopes == nil {
+		row.Scopes = []string{}
+	}
+	if row.AllowedIPs == nil {
+		row.AllowedIPs = []string{}
+	}
+	r.s.data.apiKeys[k.ID] = &apiKey{APIKey: row}
+	return nil
+}
+
+func (r *APIKeyRepository) GetActiveByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	defer r.s.lock(ctx)()
+	for _, k := range r.s.data.apiKeys {
+		if k.KeyHash == keyHash && !k.revoked {
+			owner, ok := r.s.data.users[k.UserID]
+			if !ok {
+				break
+			}
+			found := k.APIKey
+			found.KeyHash = ""
+			found.Role = owner.Role
+			return &found, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *APIKeyRepository) ListByUserID(ctx context.Context, userID string) ([]models.APIKey, error) {
+	defer r.s.lock(ctx)()
+	keys := []models.APIKey{}
+	for _, k := range r.s.data.apiKeys {
+		if k.UserID == userID && !k.revoked {
+			listed := k.APIKey
+			listed.KeyHash = ""
+			keys = append(keys, listed)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.After(keys[j].CreatedAt) })
+	return keys, nil
+}
+
+func (r *APIKeyRepository) CountActive(ctx context.Context, userID string, now time.Time) (int, error) {
+	defer r.s.lock(ctx)()
+	n := 0
+	for _, k := range r.s.data.apiKeys {
+		if k.UserID == userID && !k.revoked && k.ExpiresAt.After(now) {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (r *APIKeyRepository) TouchLastUsed(ctx context.Context, keyID, ip string, now time.Time) error {
+	defer r.s.lock(ctx)()
+	if k, ok := r.s.data.apiKeys[keyID]; ok {
+		k.LastUsedAt, k.LastUsedIP = timePtr(now), ip
+	}
+	return nil
+}
+
+func (r *APIKeyRepository) Revoke(ctx context.Context, userID, keyID string) (bool, error) {
+	defer r.s.lock(ctx)()
+	k, ok := r.s.data.apiKeys[keyID]
+	if !ok || k.UserID != userID || k.revoked {
+		return false, nil
+	}
+	k.revoked = true
+	return true, nil
+}
+
+func (r *APIKeyRepository) RevokeAll(ctx context.Context, userID string) error {
+	defer r.s.lock(ctx)()
+	for _, k := range r.s.data.apiKeys {
+		if k.UserID == userID {
+			k.revoked = true
+		}
+	}
+	return nil
+}
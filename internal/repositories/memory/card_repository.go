@@ -0,0 +1,254 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"go_project/internal/models"
+	"sort"
+	"time"
+)
+
+type card struct {
+	models.Card
+	cardNumber  string
+	expiry      string
+	cvvHash     string
+	keyID       string
+	key         string
+	hasPANIndex bool
+}
+
+type cardLimits struct {
+	models.CardLimits
+}
+
+type cardAuthorization struct {
+	models.CardAuthorization
+}
+
+type CardRepository struct {
+	s *Store
+}
+
+func NewCardRepository(s *Store) *CardRepository {
+	return &CardRepository{s: s}
+}
+
+func (r *CardRepository) CreateCard(ctx context.Context, c *models.Card, cardNumberPlain, expiryPlain, cvvHash, keyID, encryptionKey string) (string, error) {
+	defer r.s.lock(ctx)()
+	if r.s.data.accounts[c.AccountID] == nil {
+		return "", ErrForeignKey
+	}
+	if r.panIndexTaken(c.PANIndex) {
+		return "", ErrDuplicate
+	}
+	row := &card{
+		Card: models.Card{
+			ID:        newID(),
+			AccountID: c.AccountID,
+			Type:      c.Type,
+			Status:    models.CardStatusActive,
+			AmountCap: c.AmountCap,
+			ExpiresAt: c.ExpiresAt,
+			PANIndex:  c.PANIndex,
+		},
+		cardNumber:  cardNumberPlain,
+		expiry:      expiryPlain,
+		cvvHash:     cvvHash,
+		keyID:       keyID,
+		key:         encryptionKey,
+		hasPANIndex: true,
+	}
+	r.s.data.cards[row.ID] = row
+	return row.ID, nil
+}
+
+// get возвращает карту в том виде, в каком её читает основная реализация (без индекса номера).
+func (r *CardRepository) get(match func(c *card) bool) (*models.Card, error) {
+	for _, c := range r.s.data.cards {
+		if match(c) {
+			copied := c.Card
+			copied.PANIndex = ""
+			return &copied, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *CardRepository) GetByID(ctx context.Context, cardID string) (*models.Card, error) {
+	defer r.s.lock(ctx)()
+	return r.get(func(c *card) bool { return c.ID == cardID })
+}
+
+func (r *CardRepository) GetByPANIndex(ctx context.Context, panIndex string) (*models.Card, error) {
+	defer r.s.lock(ctx)()
+	return r.get(func(c *card) bool { return c.hasPANIndex && c.PANIndex == panIndex })
+}
+
+func (r *CardRepository) ListWithoutPANIndex(ctx context.Context, limit int) ([]string, error) {
+	defer r.s.lock(ctx)()
+	var ids []string
+	for _, c := range r.s.data.cards {
+		if !c.hasPANIndex {
+			ids = append(ids, c.ID)
+		}
+	}
+	sort.Strings(ids)
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	return ids, nil
+}
+
+func (r *CardRepository) SetPANIndex(ctx context.Context, cardID, panIndex string) error {
+	defer r.s.lock(ctx)()
+	if r.panIndexTaken(panIndex) {
+		return ErrDuplicate
+	}
+	if c, ok := r.s.data.cards[cardID]; ok {
+		c.PANIndex, c.hasPANIndex = panIndex, true
+	}
+	return nil
+}
+
+// panIndexTaken повторяет уникальный индекс cards_pan_hmac_idx.
+func (r *CardRepository) panIndexTaken(panIndex string) bool {
+	for _, c := range r.s.data.cards {
+		if c.hasPANIndex && c.PANIndex == panIndex {
+			return true
+		}
+	}
+	return false
+}
+
+// LockByID читает карту; внутри Do данные и так заблокированы целиком.
+func (r *CardRepository) LockByID(ctx context.Context, cardID string) (*models.Card, error) {
+	return r.GetByID(ctx, cardID)
+}
+
+func (r *CardRepository) SetStatus(ctx context.Context, cardID, status string) error {
+	defer r.s.lock(ctx)()
+	if c, ok := r.s.data.cards[cardID]; ok {
+		c.Status = status
+	}
+	return nil
+}
+
+func (r *CardRepository) LockMerchant(ctx context.Context, cardID, merchant string) error {
+	defer r.s.lock(ctx)()
+	if c, ok := r.s.data.cards[cardID]; ok && c.LockedMerchant == "" {
+		c.LockedMerchant = merchant
+	}
+	return nil
+}
+
+func (r *CardRepository) GetCardNumber(ctx context.Context, cardID string, keyFor func(keyID string) (string, error)) (string, error) {
+	number, _, err := r.GetCardDetails(ctx, cardID, keyFor)
+	return number, err
+}
+
+func (r *CardRepository) GetCardDetails(ctx context.Context, cardID string, keyFor func(keyID string) (string, error)) (string, string, error) {
+	defer r.s.lock(ctx)()
+	c, ok := r.s.data.cards[cardID]
+	if !ok {
+		return "", "", sql.ErrNoRows
+	}
+	if err := decrypt(keyFor, c.keyID, c.key); err != nil {
+		return "", "", err
+	}
+	return c.cardNumber, c.expiry, nil
+}
+
+func (r *CardRepository) CountByKeyID(ctx context.Context) (map[string]int, error) {
+	defer r.s.lock(ctx)()
+	counts := make(map[string]int)
+	for _, c := range r.s.data.cards {
+		counts[c.keyID]++
+	}
+	return counts, nil
+}
+
+func (r *CardRepository) ReencryptBatch(ctx context.Context, fromKeyID, fromKey, toKeyID, toKey string, limit int) (int, error) {
+	defer r.s.lock(ctx)()
+	fields := make(map[string]encryptedField, len(r.s.data.cards))
+	for id, c := range r.s.data.cards {
+		fields[id] = encryptedField{keyID: &c.keyID, key: &c.key}
+	}
+	return reencrypt(fields, fromKeyID, fromKey, toKeyID, toKey, limit)
+}
+
+func (r *CardRepository) SetPIN(ctx context.Context, cardID, pinHash string) error {
+	defer r.s.lock(ctx)()
+	if c, ok := r.s.data.cards[cardID]; ok {
+		c.PINHash, c.PINAttempts = pinHash, 0
+	}
+	return nil
+}
+
+func (r *CardRepository) RecordPINFailure(ctx context.Context, cardID string, maxAttempts int) (int, string, error) {
+	defer r.s.lock(ctx)()
+	c, ok := r.s.data.cards[cardID]
+	if !ok {
+		return 0, "", sql.ErrNoRows
+	}
+	c.PINAttempts++
+	if c.PINAttempts >= maxAttempts {
+		c.Status = models.CardStatusBlocked
+	}
+	return c.PINAttempts, c.Status, nil
+}
+
+func (r *CardRepository) ResetPINAttempts(ctx context.Context, cardID string) error {
+	defer r.s.lock(ctx)()
+	if c, ok := r.s.data.cards[cardID]; ok {
+		c.PINAttempts = 0
+	}
+	return nil
+}
+
+func (r *CardRepository) GetLimits(ctx context.Context, cardID string) (*models.CardLimits, error) {
+	defer r.s.lock(ctx)()
+	l, ok := r.s.data.cardLimits[cardID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	copied := l.CardLimits
+	return &copied, nil
+}
+
+func (r *CardRepository) UpsertLimits(ctx context.Context, l *models.CardLimits) error {
+	defer r.s.lock(ctx)()
+	if r.s.data.cards[l.CardID] == nil {
+		return ErrForeignKey
+	}
+	row := *l
+	row.DailyLimit = money(row.DailyLimit)
+	row.MonthlyLimit = money(row.MonthlyLimit)
+	row.PerTransactionLimit = money(row.PerTransactionLimit)
+	row.DailySpent, row.MonthlySpent = 0, 0
+	r.s.data.cardLimits[l.CardID] = &cardLimits{CardLimits: row}
+	return nil
+}
+
+func (r *CardRepository) SumAuthorizedSince(ctx context.Context, cardID string, since time.Time) (float64, error) {
+	defer r.s.lock(ctx)()
+	var total float64
+	for _, a := range r.s.data.cardAuthorizations {
+		if a.CardID == cardID && !a.CreatedAt.Before(since) {
+			total += a.Amount
+		}
+	}
+	return money(total), nil
+}
+
+func (r *CardRepository) CreateAuthorization(ctx context.Context, a *models.CardAuthorization) error {
+	defer r.s.lock(ctx)()
+	if r.s.data.cards[a.CardID] == nil {
+		return ErrForeignKey
+	}
+	a.ID, a.CreatedAt = newID(), time.Now()
+	row := *a
+	row.Amount = money(row.Amount)
+	r.s.data.cardAuthorizations = append(r.s.data.cardAuthorizations, cardAuthorization{CardAuthorization: row})
+	return nil
+}
@@ -0,0 +1,47 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"go_project/internal/models"
+	"time"
+)
+
+type credit struct {
+	models.Credit
+}
+
+type CreditRepository struct {
+	s *Store
+}
+
+func NewCreditRepository(s *Store) *CreditRepository {
+	return &CreditRepository{s: s}
+}
+
+func (r *CreditRepository) GetById(ctx context.Context, creditID string) (*models.Credit, error) {
+	defer r.s.lock(ctx)()
+	c, ok := r.s.data.credits[creditID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	copied := c.Credit
+	return &copied, nil
+}
+
+func (r *CreditRepository) CreateCredit(ctx context.Context, accountID string, amount float64, interest float64, term int) (string, error) {
+	defer r.s.lock(ctx)()
+	if r.s.data.accounts[accountID] == nil {
+		return "", ErrForeignKey
+	}
+	id := newID()
+	r.s.data.credits[id] = &credit{Credit: models.Credit{
+		ID:           id,
+		AccountID:    accountID,
+		Amount:       money(amount),
+		InterestRate: money(interest),
+		TermMonths:   term,
+		StartDate:    date(time.Now()),
+	}}
+	return id, nil
+}
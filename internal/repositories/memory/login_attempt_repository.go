@@ -0,0 +1,89 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"go_project/internal/models"
+	"time"
+)
+
+type loginAttempts struct {
+	models.LoginAttempts
+}
+
+type LoginAttemptRepository struct {
+	s *Store
+}
+
+func NewLoginAttemptRepository(s *Store) *LoginAttemptRepository {
+	return &LoginAttemptRepository{s: s}
+}
+
+func (r *LoginAttemptRepository) Get(ctx context.Context, key string) (*models.LoginAttempts, error) {
+	defer r.s.lock(ctx)()
+	a, ok := r.s.data.loginAttempts[key]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	copied := a.LoginAttempts
+	return &copied, nil
+}
+
+func (r *LoginAttemptRepository) RecordAttempt(ctx context.Context, key string, now, resetBefore time.Time) (*models.LoginAttempts, time.Time, error) {
+	defer r.s.lock(ctx)()
+	a, ok := r.s.data.loginAttempts[key]
+	var previousAt time.Time
+	switch {
+	case !ok:
+		a = &loginAttempts{LoginAttempts: models.LoginAttempts{Key: key, Failures: 1}}
+		r.s.data.loginAttempts[key] = a
+	case a.LastFailureAt.Before(resetBefore):
+		previousAt = a.LastFailureAt
+		a.Failures, a.LockedUntil = 1, nil
+	default:
+		previousAt = a.LastFailureAt
+		a.Failures++
+	}
+	a.LastFailureAt = now
+	copied := a.LoginAttempts
+	return &copied, previousAt, nil
+}
+
+func (r *LoginAttemptRepository) Release(ctx context.Context, key string, previousAt time.Time) error {
+	defer r.s.lock(ctx)()
+	a, ok := r.s.data.loginAttempts[key]
+	if !ok {
+		return nil
+	}
+	if a.Failures > 0 {
+		a.Failures--
+	}
+	if !previousAt.IsZero() {
+		a.LastFailureAt = previousAt
+	}
+	return nil
+}
+
+func (r *LoginAttemptRepository) Lock(ctx context.Context, key string, until time.Time) error {
+	defer r.s.lock(ctx)()
+	if a, ok := r.s.data.loginAttempts[key]; ok {
+		a.LockedUntil = timePtr(until)
+	}
+	return nil
+}
+
+func (r *LoginAttemptRepository) Reset(ctx context.Context, key string) error {
+	defer r.s.lock(ctx)()
+	delete(r.s.data.loginAttempts, key)
+	return nil
+}
+
+func (r *LoginAttemptRepository) DeleteStale(ctx context.Context, before time.Time) error {
+	defer r.s.lock(ctx)()
+	for key, a := range r.s.data.loginAttempts {
+		if a.LastFailureAt.Before(before) && (a.LockedUntil == nil || a.LockedUntil.Before(before)) {
+			delete(r.s.data.loginAttempts, key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package memory
+
+import (
+	"go_project/internal/repositories/repotest"
+	"testing"
+)
+
+func TestContract(t *testing.T) {
+	repotest.Run(t, func(t *testing.T) *repotest.Backend {
+		s := NewStore()
+		return &repotest.Backend{
+			UnitOfWork:       s,
+			Accounts:         NewAccountRepository(s),
+			Transactions:     NewTransactionRepository(s),
+			Credits:          NewCreditRepository(s),
+			PaymentSchedules: NewPaymentScheduleRepository(s),
+			Users:            NewUserRepository(s),
+			Tokens:           NewTokenRepository(s),
+			Sessions:         NewSessionRepository(s),
+			RecoveryCodes:    NewRecoveryCodeRepository(s),
+			LoginAttempts:    NewLoginAttemptRepository(s),
+			Cards:            NewCardRepository(s),
+			SigningKeys:      NewSigningKeyRepository(s),
+			AdminActions:     NewAdminActionRepository(s),
+			APIKeys:          NewAPIKeyRepository(s),
+			OAuth:            NewOAuthRepository(s),
+		}
+	})
+}
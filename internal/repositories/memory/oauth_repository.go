@@ -0,0 +1,173 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"go_project/internal/models"
+	"sort"
+	"time"
+)
+
+type oauthClient struct {
+	models.OAuthClient
+}
+
+type consentKey struct {
+	userID   string
+	clientID string
+}
+
+type oauthConsent struct {
+	scopes    []string
+	grantedAt time.Time
+}
+
+type oauthCode struct {
+	models.OAuthCode
+	used bool
+}
+
+type OAuthRepository struct {
+	s *Store
+}
+
+func NewOAuthRepository(s *Store) *OAuthRepository {
+	return &OAuthRepository{s: s}
+}
+
+// orEmpty заменяет nil пустым срезом, как столбцы TEXT[] NOT NULL DEFAULT '{}'.
+func orEmpty(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return append([]string(nil), values...)
+}
+
+func (r *OAuthRepository) CreateClient(ctx context.Context, c *models.OAuthClient) error {
+	defer r.s.lock(ctx)()
+	if _, ok := r.s.data.oauthClients[c.ID]; ok {
+		return ErrDuplicate
+	}
+	c.CreatedAt = time.Now()
+	row := *c
+	row.Confidential = row.SecretHash != ""
+	row.RedirectURIs, row.Scopes, row.GrantTypes = orEmpty(c.RedirectURIs), orEmpty(c.Scopes), orEmpty(c.GrantTypes)
+	r.s.data.oauthClients[c.ID] = &oauthClient{OAuthClient: row}
+	return nil
+}
+
+func (r *OAuthRepository) GetClient(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	defer r.s.lock(ctx)()
+	c, ok := r.s.data.oauthClients[clientID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	copied := c.OAuthClient
+	return &copied, nil
+}
+
+func (r *OAuthRepository) consent(key consentKey, c *oauthConsent) models.OAuthConsent {
+	return models.OAuthConsent{
+		UserID:     key.userID,
+		ClientID:   key.clientID,
+		ClientName: r.s.data.oauthClients[key.clientID].Name,
+		Scopes:     c.scopes,
+		GrantedAt:  c.grantedAt,
+	}
+}
+
+func (r *OAuthRepository) GetConsent(ctx context.Context, userID, clientID string) (*models.OAuthConsent, error) {
+	defer r.s.lock(ctx)()
+	key := consentKey{userID, clientID}
+	c, ok := r.s.data.oauthConsents[key]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	consent := r.consent(key, c)
+	return &consent, nil
+}
+
+func (r *OAuthRepository) SaveConsent(ctx context.Context, userID, clientID string, scopes []string) error {
+	defer r.s.lock(ctx)()
+	if r.s.data.users[userID] == nil || r.s.data.oauthClients[clientID] == nil {
+		return ErrForeignKey
+	}
+	key := consentKey{userID, clientID}
+	var merged []string
+	if c, ok := r.s.data.oauthConsents[key]; ok {
+		merged = append(merged, c.scopes...)
+	}
+	for _, scope := range scopes {
+		if !contains(merged, scope) {
+			merged = append(merged, scope)
+		}
+	}
+	r.s.data.oauthConsents[key] = &oauthConsent{scopes: orEmpty(merged), grantedAt: time.Now()}
+	return nil
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *OAuthRepository) ListConsents(ctx context.Context, userID string) ([]models.OAuthConsent, error) {
+	defer r.s.lock(ctx)()
+	consents := []models.OAuthConsent{}
+	for key, c := range r.s.data.oauthConsents {
+		if key.userID == userID {
+			consents = append(consents, r.consent(key, c))
+		}
+	}
+	sort.Slice(consents, func(i, j int) bool { return consents[i].GrantedAt.After(consents[j].GrantedAt) })
+	return consents, nil
+}
+
+func (r *OAuthRepository) DeleteConsent(ctx context.Context, userID, clientID string) (bool, error) {
+	defer r.s.lock(ctx)()
+	key := consentKey{userID, clientID}
+	if _, ok := r.s.data.oauthConsents[key]; !ok {
+		return false, nil
+	}
+	delete(r.s.data.oauthConsents, key)
+	return true, nil
+}
+
+func (r *OAuthRepository) CreateCode(ctx context.Context, c *models.OAuthCode) error {
+	defer r.s.lock(ctx)()
+	if r.s.data.users[c.UserID] == nil || r.s.data.oauthClients[c.ClientID] == nil {
+		return ErrForeignKey
+	}
+	if _, ok := r.s.data.oauthCodes[c.CodeHash]; ok {
+		return ErrDuplicate
+	}
+	row := *c
+	row.Scopes = orEmpty(c.Scopes)
+	r.s.data.oauthCodes[c.CodeHash] = &oauthCode{OAuthCode: row}
+	return nil
+}
+
+func (r *OAuthRepository) UseCode(ctx context.Context, codeHash string, now time.Time) (*models.OAuthCode, error) {
+	defer r.s.lock(ctx)()
+	c, ok := r.s.data.oauthCodes[codeHash]
+	if !ok || c.used || !c.ExpiresAt.After(now) {
+		return nil, sql.ErrNoRows
+	}
+	c.used = true
+	copied := c.OAuthCode
+	return &copied, nil
+}
+
+func (r *OAuthRepository) DeleteExpiredCodes(ctx context.Context, now time.Time) error {
+	defer r.s.lock(ctx)()
+	for hash, c := range r.s.data.oauthCodes {
+		if c.ExpiresAt.Before(now) {
+			delete(r.s.data.oauthCodes, hash)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package memory
+
+import (
+	"context"
+	"go_project/internal/models"
+	"sort"
+	"time"
+)
+
+type schedule struct {
+	models.PaymentSchedule
+	seq int64
+}
+
+type PaymentScheduleRepository struct {
+	s *Store
+}
+
+func NewPaymentScheduleRepository(s *Store) *PaymentScheduleRepository {
+	return &PaymentScheduleRepository{s: s}
+}
+
+func (r *PaymentScheduleRepository) Create(ctx context.Context, ps *models.PaymentSchedule) error {
+	defer r.s.lock(ctx)()
+	if r.s.data.credits[ps.CreditID] == nil {
+		return ErrForeignKey
+	}
+	ps.ID = newID()
+	r.s.data.schedules[ps.ID] = &schedule{
+		PaymentSchedule: models.PaymentSchedule{
+			ID:       ps.ID,
+			CreditID: ps.CreditID,
+			DueDate:  date(ps.DueDate),
+			Amount:   money(ps.Amount),
+		},
+		seq: r.s.data.next(),
+	}
+	return nil
+}
+
+// list возвращает платежи, удовлетворяющие match, в порядке сроков.
+func (r *PaymentScheduleRepository) list(match func(ps *schedule) bool) []models.PaymentSchedule {
+	var rows []*schedule
+	for _, ps := range r.s.data.schedules {
+		if match(ps) {
+			rows = append(rows, ps)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if !rows[i].DueDate.Equal(rows[j].DueDate) {
+			return rows[i].DueDate.Before(rows[j].DueDate)
+		}
+		return rows[i].seq < rows[j].seq
+	})
+	schedules := []models.PaymentSchedule{}
+	for _, ps := range rows {
+		schedules = append(schedules, ps.PaymentSchedule)
+	}
+	return schedules
+}
+
+func (r *PaymentScheduleRepository) GetByCreditID(ctx context.Context, creditID string) ([]models.PaymentSchedule, error) {
+	defer r.s.lock(ctx)()
+	return r.list(func(ps *schedule) bool { return ps.CreditID == creditID }), nil
+}
+
+func (r *PaymentScheduleRepository) ListOverdue(ctx context.Context, currentTime time.Time) ([]models.PaymentSchedule, error) {
+	defer r.s.lock(ctx)()
+	return r.list(func(ps *schedule) bool { return !ps.Paid && ps.DueDate.Before(currentTime) }), nil
+}
+
+func (r *PaymentScheduleRepository) MarkAsPaid(ctx context.Context, scheduleID string, paidDate time.Time) error {
+	defer r.s.lock(ctx)()
+	if ps, ok := r.s.data.schedules[scheduleID]; ok {
+		ps.Paid = true
+		ps.PaidDate = timePtr(date(paidDate))
+	}
+	return nil
+}
+
+func (r *PaymentScheduleRepository) ApplyPenalty(ctx context.Context, scheduleID string, penaltyAmount float64) error {
+	defer r.s.lock(ctx)()
+	if ps, ok := r.s.data.schedules[scheduleID]; ok {
+		ps.Penalty = money(ps.Penalty + penaltyAmount)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package memory
+
+import (
+	"context"
+)
+
+type recoveryCode struct {
+	userID   string
+	codeHash string
+	used     bool
+}
+
+type RecoveryCodeRepository struct {
+	s *Store
+}
+
+func NewRecoveryCodeRepository(s *Store) *RecoveryCodeRepository {
+	return &RecoveryCodeRepository{s: s}
+}
+
+func (r *RecoveryCodeRepository) ReplaceCodes(ctx context.Context, userID string, codeHashes []string) error {
+	defer r.s.lock(ctx)()
+	if r.s.data.users[userID] == nil && len(codeHashes) > 0 {
+		return ErrForeignKey
+	}
+	r.deleteCodes(userID)
+	for _, hash := range codeHashes {
+		r.s.data.recoveryCodes = append(r.s.data.recoveryCodes, recoveryCode{userID: userID, codeHash: hash})
+	}
+	return nil
+}
+
+func (r *RecoveryCodeRepository) UseCode(ctx context.Context, userID, codeHash string) (bool, error) {
+	defer r.s.lock(ctx)()
+	for i := range r.s.data.recoveryCodes {
+		c := &r.s.data.recoveryCodes[i]
+		if c.userID == userID && c.codeHash == codeHash && !c.used {
+			c.used = true
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r *RecoveryCodeRepository) DeleteCodes(ctx context.Context, userID string) error {
+	defer r.s.lock(ctx)()
+	r.deleteCodes(userID)
+	return nil
+}
+
+func (r *RecoveryCodeRepository) deleteCodes(userID string) {
+	kept := r.s.data.recoveryCodes[:0:0]
+	for _, c := range r.s.data.recoveryCodes {
+		if c.userID != userID {
+			kept = append(kept, c)
+		}
+	}
+	r.s.data.recoveryCodes = kept
+}
@@ -0,0 +1,117 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"go_project/internal/models"
+	"sort"
+	"time"
+)
+
+type session struct {
+	models.Session
+}
+
+type SessionRepository struct {
+	s *Store
+}
+
+func NewSessionRepository(s *Store) *SessionRepository {
+	return &SessionRepository{s: s}
+}
+
+func (r *SessionRepository) Create(ctx context.Context, s *models.Session) error {
+	defer r.s.lock(ctx)()
+	if r.s.data.users[s.UserID] == nil {
+		return ErrForeignKey
+	}
+	now := time.Now()
+	s.ID, s.CreatedAt, s.LastSeenAt = newID(), now, now
+	row := *s
+	row.RevokedAt, row.Current = nil, false
+	r.s.data.sessions[s.ID] = &session{Session: row}
+	return nil
+}
+
+func (r *SessionRepository) GetByID(ctx context.Context, sessionID string) (*models.Session, error) {
+	defer r.s.lock(ctx)()
+	s, ok := r.s.data.sessions[sessionID]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	copied := s.Session
+	return &copied, nil
+}
+
+func (r *SessionRepository) KnownDevice(ctx context.Context, userID, deviceHash string) (known bool, hasSessions bool, err error) {
+	defer r.s.lock(ctx)()
+	for _, s := range r.s.data.sessions {
+		if s.UserID == userID {
+			hasSessions = true
+			known = known || s.DeviceHash == deviceHash
+		}
+	}
+	return known, hasSessions, nil
+}
+
+func (r *SessionRepository) ListActive(ctx context.Context, userID string) ([]models.Session, error) {
+	defer r.s.lock(ctx)()
+	sessions := []models.Session{}
+	for _, s := range r.s.data.sessions {
+		if s.UserID == userID && s.RevokedAt == nil {
+			listed := s.Session
+			listed.DeviceHash = ""
+			sessions = append(sessions, listed)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool { return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt) })
+	return sessions, nil
+}
+
+func (r *SessionRepository) Touch(ctx context.Context, sessionID, ip string, now time.Time) error {
+	defer r.s.lock(ctx)()
+	if s, ok := r.s.data.sessions[sessionID]; ok {
+		s.LastSeenAt = now
+		if ip != "" {
+			s.IP = ip
+		}
+	}
+	return nil
+}
+
+func (r *SessionRepository) Revoke(ctx context.Context, userID, sessionID string) (bool, error) {
+	defer r.s.lock(ctx)()
+	s, ok := r.s.data.sessions[sessionID]
+	if !ok || s.UserID != userID || s.RevokedAt != nil {
+		return false, nil
+	}
+	s.RevokedAt = timePtr(time.Now())
+	return true, nil
+}
+
+func (r *SessionRepository) RevokeAll(ctx context.Context, userID string) error {
+	defer r.s.lock(ctx)()
+	now := time.Now()
+	for _, s := range r.s.data.sessions {
+		if s.UserID == userID && s.RevokedAt == nil {
+			s.RevokedAt = timePtr(now)
+		}
+	}
+	return nil
+}
+
+// DeleteStale удаляет устаревшие сессии вместе с их токенами обновления (ON DELETE CASCADE).
+func (r *SessionRepository) DeleteStale(ctx context.Context, before time.Time) error {
+	defer r.s.lock(ctx)()
+	for id, s := range r.s.data.sessions {
+		if s.LastSeenAt.Before(before) {
+			delete(r.s.data.sessions, id)
+			for tokenID, t := range r.s.data.refreshTokens {
+				if t.SessionID == id {
+					delete(r.s.data.refreshTokens, tokenID)
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package memory
+
+import (
+	"context"
+	"go_project/internal/models"
+	"sort"
+	"time"
+)
+
+type signingKey struct {
+	models.SigningKey
+	keyID string
+	key   string
+}
+
+type SigningKeyRepository struct {
+	s *Store
+}
+
+func NewSigningKeyRepository(s *Store) *SigningKeyRepository {
+	return &SigningKeyRepository{s: s}
+}
+
+func (r *SigningKeyRepository) ListValid(ctx context.Context, now time.Time, keyFor func(keyID string) (string, error)) ([]models.SigningKey, error) {
+	defer r.s.lock(ctx)()
+	var valid []*signingKey
+	for _, k := range r.s.data.signingKeys {
+		if k.ExpiresAt == nil || k.ExpiresAt.After(now) {
+			valid = append(valid, k)
+		}
+	}
+	sort.Slice(valid, func(i, j int) bool { return valid[i].ActivatesAt.After(valid[j].ActivatesAt) })
+	keys := make([]models.SigningKey, 0, len(valid))
+	for _, k := range valid {
+		if err := decrypt(keyFor, k.keyID, k.key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k.SigningKey)
+	}
+	return keys, nil
+}
+
+// Rotate добавляет ключ, если после rotateBefore никто не выпустил другой. Блокировка Store
+// заменяет рекомендательную блокировку основной реализации, поэтому false возвращается
+// только при наличии свежего ключа.
+func (r *SigningKeyRepository) Rotate(ctx context.Context, key *models.SigningKey, keyID, encryptionKey string, retireAt time.Time, rotateBefore time.Time) (bool, error) {
+	defer r.s.lock(ctx)()
+	for _, k := range r.s.data.signingKeys {
+		if !k.CreatedAt.Before(rotateBefore) {
+			return false, nil
+		}
+	}
+	if _, ok := r.s.data.signingKeys[key.ID]; ok {
+		return false, ErrDuplicate
+	}
+	for _, k := range r.s.data.signingKeys {
+		if k.ExpiresAt == nil {
+			k.ExpiresAt = timePtr(retireAt)
+		}
+	}
+	row := *key
+	row.ExpiresAt = nil
+	r.s.data.signingKeys[key.ID] = &signingKey{SigningKey: row, keyID: keyID, key: encryptionKey}
+	return true, nil
+}
+
+func (r *SigningKeyRepository) CountByKeyID(ctx context.Context) (map[string]int, error) {
+	defer r.s.lock(ctx)()
+	counts := make(map[string]int)
+	for _, k := range r.s.data.signingKeys {
+		counts[k.keyID]++
+	}
+	return counts, nil
+}
+
+func (r *SigningKeyRepository) ReencryptBatch(ctx context.Context, fromKeyID, fromKey, toKeyID, toKey string, limit int) (int, error) {
+	defer r.s.lock(ctx)()
+	fields := make(map[string]encryptedField, len(r.s.data.signingKeys))
+	for id, k := range r.s.data.signingKeys {
+		fields[id] = encryptedField{keyID: &k.keyID, key: &k.key}
+	}
+	return reencrypt(fields, fromKeyID, fromKey, toKeyID, toKey, limit)
+}
+
+func (r *SigningKeyRepository) DeleteExpired(ctx context.Context, now time.Time) error {
+	defer r.s.lock(ctx)()
+	for id, k := range r.s.data.signingKeys {
+		if k.ExpiresAt != nil && k.ExpiresAt.Before(now) {
+			delete(r.s.data.signingKeys, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+// Package memory реализует интерфейсы пакета repositories в памяти процесса. Реализация нужна
+// для модульных тестов сервисов без PostgreSQL и проходит тот же набор тестов repotest, что и
+// основная, поэтому ведёт себя так же: отсутствующие записи возвращают sql.ErrNoRows, суммы
+// округляются до копеек, как в столбцах NUMERIC(15,2), а даты — до дня, как в столбцах DATE.
+//
+// Все репозитории одного Store работают с общими данными. Store сам является UnitOfWork:
+// внутри Do данные заблокированы для других горутин, а при ошибке возвращаются к состоянию
+// на момент начала транзакции. Шифрование не моделируется: значения хранятся открытыми вместе
+// с ключом, а при чтении проверяется, что keyFor возвращает тот же ключ.
+package memory
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"go_project/internal/repositories"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrDuplicate  = errors.New("memory: duplicate key")
+	ErrForeignKey = errors.New("memory: referenced row does not exist")
+	ErrWrongKey   = errors.New("memory: wrong encryption key")
+)
+
+// Store хранит данные всех репозиториев.
+type Store struct {
+	mu   sync.Mutex
+	data *tables
+}
+
+func NewStore() *Store {
+	return &Store{data: newTables()}
+}
+
+var (
+	_ repositories.UnitOfWork       = (*Store)(nil)
+	_ repositories.Accounts         = (*AccountRepository)(nil)
+	_ repositories.Transactions     = (*TransactionRepository)(nil)
+	_ repositories.Credits          = (*CreditRepository)(nil)
+	_ repositories.PaymentSchedules = (*PaymentScheduleRepository)(nil)
+	_ repositories.Users            = (*UserRepository)(nil)
+	_ repositories.Tokens           = (*TokenRepository)(nil)
+	_ repositories.Sessions         = (*SessionRepository)(nil)
+	_ repositories.RecoveryCodes    = (*RecoveryCodeRepository)(nil)
+	_ repositories.LoginAttempts    = (*LoginAttemptRepository)(nil)
+	_ repositories.Cards            = (*CardRepository)(nil)
+	_ repositories.SigningKeys      = (*SigningKeyRepository)(nil)
+	_ repositories.AdminActions     = (*AdminActionRepository)(nil)
+	_ repositories.APIKeys          = (*APIKeyRepository)(nil)
+	_ repositories.OAuth            = (*OAuthRepository)(nil)
+)
+
+// txKey помечает контекст транзакции конкретного Store
+type txKey struct {
+	s *Store
+}
+
+// Do выполняет fn под блокировкой Store и откатывает её изменения, если fn вернула ошибку.
+// Вызов с контекстом уже открытой транзакции продолжает её.
+func (s *Store) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if s.inTx(ctx) {
+		return fn(ctx)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	saved := s.data.clone()
+	if err := fn(context.WithValue(ctx, txKey{s}, true)); err != nil {
+		s.data = saved
+		return err
+	}
+	return nil
+}
+
+func (s *Store) inTx(ctx context.Context) bool {
+	return ctx.Value(txKey{s}) != nil
+}
+
+// lock блокирует Store на время одного вызова репозитория, если он выполняется вне Do.
+func (s *Store) lock(ctx context.Context) func() {
+	if s.inTx(ctx) {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+type tables struct {
+	users              map[string]*user
+	accounts           map[string]*account
+	transactions       []transaction
+	credits            map[string]*credit
+	schedules          map[string]*schedule
+	refreshTokens      map[string]*refreshToken
+	revokedTokens      map[string]time.Time
+	sessions           map[string]*session
+	recoveryCodes      []recoveryCode
+	loginAttempts      map[string]*loginAttempts
+	cards              map[string]*card
+	cardLimits         map[string]*cardLimits
+	cardAuthorizations []cardAuthorization
+	signingKeys        map[string]*signingKey
+	adminActions       []adminAction
+	apiKeys            map[string]*apiKey
+	oauthClients       map[string]*oauthClient
+	oauthConsents      map[consentKey]*oauthConsent
+	oauthCodes         map[string]*oauthCode
+	// seq упорядочивает записи, созданные в одну и ту же наносекунду
+	seq int64
+}
+
+func newTables() *tables {
+	return &tables{
+		users:         make(map[string]*user),
+		accounts:      make(map[string]*account),
+		credits:       make(map[string]*credit),
+		schedules:     make(map[string]*schedule),
+		refreshTokens: make(map[string]*refreshToken),
+		revokedTokens: make(map[string]time.Time),
+		sessions:      make(map[string]*session),
+		loginAttempts: make(map[string]*loginAttempts),
+		cards:         make(map[string]*card),
+		cardLimits:    make(map[string]*cardLimits),
+		signingKeys:   make(map[string]*signingKey),
+		apiKeys:       make(map[string]*apiKey),
+		oauthClients:  make(map[string]*oauthClient),
+		oauthConsents: make(map[consentKey]*oauthConsent),
+		oauthCodes:    make(map[string]*oauthCode),
+	}
+}
+
+// clone копирует таблицы для отката транзакции. Строки копируются по значению; срезы и указатели
+// внутри строк не изменяются на месте, а только заменяются, поэтому их можно разделять.
+func (t *tables) clone() *tables {
+	return &tables{
+		users:              cloneMap(t.users),
+		accounts:           cloneMap(t.accounts),
+		transactions:       append([]transaction(nil), t.transactions...),
+		credits:            cloneMap(t.credits),
+		schedules:          cloneMap(t.schedules),
+		refreshTokens:      cloneMap(t.refreshTokens),
+		revokedTokens:      cloneValues(t.revokedTokens),
+		sessions:           cloneMap(t.sessions),
+		recoveryCodes:      append([]recoveryCode(nil), t.recoveryCodes...),
+		loginAttempts:      cloneMap(t.loginAttempts),
+		cards:              cloneMap(t.cards),
+		cardLimits:         cloneMap(t.cardLimits),
+		cardAuthorizations: append([]cardAuthorization(nil), t.cardAuthorizations...),
+		signingKeys:        cloneMap(t.signingKeys),
+		adminActions:       append([]adminAction(nil), t.adminActions...),
+		apiKeys:            cloneMap(t.apiKeys),
+		oauthClients:       cloneMap(t.oauthClients),
+		oauthConsents:      cloneMap(t.oauthConsents),
+		oauthCodes:         cloneMap(t.oauthCodes),
+		seq:                t.seq,
+	}
+}
+
+func cloneMap[K comparable, V any](m map[K]*V) map[K]*V {
+	copied := make(map[K]*V, len(m))
+	for k, v := range m {
+		row := *v
+		copied[k] = &row
+	}
+	return copied
+}
+
+func cloneValues[K comparable, V any](m map[K]V) map[K]V {
+	copied := make(map[K]V, len(m))
+	for k, v := range m {
+		copied[k] = v
+	}
+	return copied
+}
+
+// next возвращает следующий номер для упорядочивания записей.
+func (t *tables) next() int64 {
+	t.seq++
+	return t.seq
+}
+
+// newID генерирует UUID версии 4, как gen_random_uuid() в PostgreSQL.
+func newID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// money округляет сумму до копеек, как столбцы NUMERIC(15,2).
+func money(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// date отбрасывает время, как столбцы DATE.
+func date(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// timePtr возвращает указатель на копию t.
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+// decrypt проверяет, что keyFor возвращает ключ, которым было «зашифровано» значение.
+func decrypt(keyFor func(keyID string) (string, error), keyID, key string) error {
+	got, err := keyFor(keyID)
+	if err != nil {
+		return err
+	}
+	if got != key {
+		return ErrWrongKey
+	}
+	return nil
+}
+
+// encryptedField — идентификатор ключа и ключ, которым «зашифровано» значение записи
+type encryptedField struct {
+	keyID *string
+	key   *string
+}
+
+// reencrypt переводит на ключ toKeyID до limit записей с ключом fromKeyID в порядке идентификаторов,
+// как ReencryptBatch основной реализации. Если ключ fromKey не подходит, записи не изменяются.
+func reencrypt(fields map[string]encryptedField, fromKeyID, fromKey, toKeyID, toKey string, limit int) (int, error) {
+	var ids []string
+	for id, f := range fields {
+		if *f.keyID == fromKeyID {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	if len(ids) > limit {
+		ids = ids[:limit]
+	}
+	for _, id := range ids {
+		if *fields[id].key != fromKey {
+			return 0, ErrWrongKey
+		}
+	}
+	for _, id := range ids {
+		*fields[id].keyID, *fields[id].key = toKeyID, toKey
+	}
+	return len(ids), nil
+}
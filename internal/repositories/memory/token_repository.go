@@ -0,0 +1,129 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"go_project/internal/models"
+	"time"
+)
+
+type refreshToken struct {
+	models.RefreshToken
+	replacedBy string
+}
+
+type TokenRepository struct {
+	s *Store
+}
+
+func NewTokenRepository(s *Store) *TokenRepository {
+	return &TokenRepository{s: s}
+}
+
+func (r *TokenRepository) CreateRefreshToken(ctx context.Context, userID, sessionID, tokenHash string, expiresAt time.Time) (string, error) {
+	defer r.s.lock(ctx)()
+	if r.s.data.users[userID] == nil || (sessionID != "" && r.s.data.sessions[sessionID] == nil) {
+		return "", ErrForeignKey
+	}
+	if r.byHash(tokenHash) != nil {
+		return "", ErrDuplicate
+	}
+	id := newID()
+	r.s.data.refreshTokens[id] = &refreshToken{RefreshToken: models.RefreshToken{
+		ID:        id,
+		UserID:    userID,
+		SessionID: sessionID,
+		TokenHash: tokenHash,
+		ExpiresAt: expiresAt,
+	}}
+	return id, nil
+}
+
+func (r *TokenRepository) byHash(tokenHash string) *refreshToken {
+	for _, t := range r.s.data.refreshTokens {
+		if t.TokenHash == tokenHash {
+			return t
+		}
+	}
+	return nil
+}
+
+func (r *TokenRepository) GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	defer r.s.lock(ctx)()
+	t := r.byHash(tokenHash)
+	if t == nil {
+		return nil, sql.ErrNoRows
+	}
+	copied := t.RefreshToken
+	return &copied, nil
+}
+
+func (r *TokenRepository) RevokeRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	defer r.s.lock(ctx)()
+	t := r.byHash(tokenHash)
+	if t == nil || t.RevokedAt != nil {
+		return nil, sql.ErrNoRows
+	}
+	t.RevokedAt = timePtr(time.Now())
+	copied := t.RefreshToken
+	copied.RevokedAt = nil
+	return &copied, nil
+}
+
+func (r *TokenRepository) MarkReplaced(ctx context.Context, tokenID, replacedByID string) error {
+	defer r.s.lock(ctx)()
+	if t, ok := r.s.data.refreshTokens[tokenID]; ok {
+		t.replacedBy = replacedByID
+	}
+	return nil
+}
+
+// revokeWhere отзывает действующие токены, удовлетворяющие match.
+func (r *TokenRepository) revokeWhere(ctx context.Context, match func(t *refreshToken) bool) {
+	defer r.s.lock(ctx)()
+	now := time.Now()
+	for _, t := range r.s.data.refreshTokens {
+		if t.RevokedAt == nil && match(t) {
+			t.RevokedAt = timePtr(now)
+		}
+	}
+}
+
+func (r *TokenRepository) RevokeAllRefreshTokens(ctx context.Context, userID string) error {
+	r.revokeWhere(ctx, func(t *refreshToken) bool { return t.UserID == userID })
+	return nil
+}
+
+func (r *TokenRepository) RevokeSessionRefreshTokens(ctx context.Context, sessionID string) error {
+	r.revokeWhere(ctx, func(t *refreshToken) bool { return t.SessionID == sessionID })
+	return nil
+}
+
+func (r *TokenRepository) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	defer r.s.lock(ctx)()
+	if _, ok := r.s.data.revokedTokens[jti]; !ok {
+		r.s.data.revokedTokens[jti] = expiresAt
+	}
+	return nil
+}
+
+func (r *TokenRepository) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	defer r.s.lock(ctx)()
+	_, ok := r.s.data.revokedTokens[jti]
+	return ok, nil
+}
+
+func (r *TokenRepository) DeleteExpired(ctx context.Context, now time.Time) error {
+	defer r.s.lock(ctx)()
+	for jti, expiresAt := range r.s.data.revokedTokens {
+		if expiresAt.Before(now) {
+			delete(r.s.data.revokedTokens, jti)
+		}
+	}
+	for id, t := range r.s.data.refreshTokens {
+		if t.ExpiresAt.Before(now) {
+			delete(r.s.data.refreshTokens, id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package memory
+
+import (
+	"context"
+	"go_project/internal/models"
+	"sort"
+	"time"
+)
+
+type transaction struct {
+	models.Transaction
+	seq int64
+}
+
+type TransactionRepository struct {
+	s *Store
+}
+
+func NewTransactionRepository(s *Store) *TransactionRepository {
+	return &TransactionRepository{s: s}
+}
+
+func (r *TransactionRepository) Create(ctx context.Context, t *models.Transaction) error {
+	defer r.s.lock(ctx)()
+	for _, id := range []string{t.FromAccountID, t.ToAccountID} {
+		if id != "" && r.s.data.accounts[id] == nil {
+			return ErrForeignKey
+		}
+	}
+	t.ID = newID()
+	t.Timestamp = time.Now()
+	row := *t
+	row.Amount = money(row.Amount)
+	r.s.data.transactions = append(r.s.data.transactions, transaction{Transaction: row, seq: r.s.data.next()})
+	return nil
+}
+
+// ownedBy сообщает, принадлежит ли счёт пользователю.
+func (r *TransactionRepository) ownedBy(accountID, userID string) bool {
+	acc, ok := r.s.data.accounts[accountID]
+	return ok && acc.UserID == userID
+}
+
+func (r *TransactionRepository) GetUserStats(ctx context.Context, userID string) (totalSent float64, countSent int, totalReceived float64, countReceived int, err error) {
+	defer r.s.lock(ctx)()
+	for _, t := range r.s.data.transactions {
+		if r.ownedBy(t.FromAccountID, userID) {
+			totalSent += t.Amount
+			countSent++
+		}
+		if r.ownedBy(t.ToAccountID, userID) {
+			totalReceived += t.Amount
+			countReceived++
+		}
+	}
+	return money(totalSent), countSent, money(totalReceived), countReceived, nil
+}
+
+func (r *TransactionRepository) ListByUserID(ctx context.Context, userID string, limit int) ([]models.Transaction, error) {
+	defer r.s.lock(ctx)()
+	var rows []transaction
+	for _, t := range r.s.data.transactions {
+		if r.ownedBy(t.FromAccountID, userID) || r.ownedBy(t.ToAccountID, userID) {
+			rows = append(rows, t)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].seq > rows[j].seq })
+	transactions := []models.Transaction{}
+	for _, t := range rows {
+		if len(transactions) == limit {
+			break
+		}
+		t.HMAC = ""
+		transactions = append(transactions, t.Transaction)
+	}
+	return transactions, nil
+}
@@ -0,0 +1,199 @@
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"go_project/internal/models"
+	"sort"
+	"strings"
+)
+
+type user struct {
+	models.User
+	tokenVersion int
+	totpSecret   string
+	totpKeyID    string
+	totpKey      string
+	totpLastStep int64
+}
+
+type UserRepository struct {
+	s *Store
+}
+
+func NewUserRepository(s *Store) *UserRepository {
+	return &UserRepository{s: s}
+}
+
+func (r *UserRepository) CreateUser(ctx context.Context, email, username, passwordHash string) (string, error) {
+	defer r.s.lock(ctx)()
+	for _, u := range r.s.data.users {
+		if u.Email == email || u.Username == username {
+			return "", ErrDuplicate
+		}
+	}
+	id := newID()
+	r.s.data.users[id] = &user{User: models.User{
+		ID:           id,
+		Email:        email,
+		Username:     username,
+		PasswordHash: passwordHash,
+		Role:         models.RoleCustomer,
+	}}
+	return id, nil
+}
+
+// find возвращает копию первого пользователя, удовлетворяющего match, или sql.ErrNoRows.
+func (r *UserRepository) find(ctx context.Context, match func(u *user) bool) (*models.User, error) {
+	defer r.s.lock(ctx)()
+	for _, u := range r.s.data.users {
+		if match(u) {
+			copied := u.User
+			return &copied, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.find(ctx, func(u *user) bool { return u.Email == email })
+}
+
+func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	return r.find(ctx, func(u *user) bool { return u.Username == username })
+}
+
+func (r *UserRepository) GetByID(ctx context.Context, userID string) (*models.User, error) {
+	return r.find(ctx, func(u *user) bool { return u.ID == userID })
+}
+
+func (r *UserRepository) GetTokenVersion(ctx context.Context, userID string) (int, error) {
+	defer r.s.lock(ctx)()
+	u, ok := r.s.data.users[userID]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	return u.tokenVersion, nil
+}
+
+func (r *UserRepository) IncrementTokenVersion(ctx context.Context, userID string) error {
+	defer r.s.lock(ctx)()
+	if u, ok := r.s.data.users[userID]; ok {
+		u.tokenVersion++
+	}
+	return nil
+}
+
+func (r *UserRepository) SetTOTPSecret(ctx context.Context, userID, secret, keyID, encryptionKey string) error {
+	defer r.s.lock(ctx)()
+	if u, ok := r.s.data.users[userID]; ok {
+		u.totpSecret, u.totpKeyID, u.totpKey = secret, keyID, encryptionKey
+	}
+	return nil
+}
+
+func (r *UserRepository) GetTOTPSecret(ctx context.Context, userID string, keyFor func(keyID string) (string, error)) (string, string, int64, error) {
+	defer r.s.lock(ctx)()
+	u, ok := r.s.data.users[userID]
+	if !ok || u.totpKeyID == "" {
+		return "", "", 0, sql.ErrNoRows
+	}
+	if err := decrypt(keyFor, u.totpKeyID, u.totpKey); err != nil {
+		return "", "", 0, err
+	}
+	return u.totpSecret, u.totpKeyID, u.totpLastStep, nil
+}
+
+func (r *UserRepository) CountTOTPByKeyID(ctx context.Context) (map[string]int, error) {
+	defer r.s.lock(ctx)()
+	counts := make(map[string]int)
+	for _, u := range r.s.data.users {
+		if u.totpKeyID != "" {
+			counts[u.totpKeyID]++
+		}
+	}
+	return counts, nil
+}
+
+func (r *UserRepository) ReencryptTOTPBatch(ctx context.Context, fromKeyID, fromKey, toKeyID, toKey string, limit int) (int, error) {
+	defer r.s.lock(ctx)()
+	fields := make(map[string]encryptedField)
+	for id, u := range r.s.data.users {
+		if u.totpKeyID != "" {
+			fields[id] = encryptedField{keyID: &u.totpKeyID, key: &u.totpKey}
+		}
+	}
+	return reencrypt(fields, fromKeyID, fromKey, toKeyID, toKey, limit)
+}
+
+func (r *UserRepository) UseTOTPStep(ctx context.Context, userID string, step int64) (bool, error) {
+	defer r.s.lock(ctx)()
+	u, ok := r.s.data.users[userID]
+	if !ok || u.totpLastStep >= step {
+		return false, nil
+	}
+	u.totpLastStep = step
+	return true, nil
+}
+
+func (r *UserRepository) SetTOTPEnabled(ctx context.Context, userID string, enabled bool) error {
+	defer r.s.lock(ctx)()
+	u, ok := r.s.data.users[userID]
+	if !ok {
+		return nil
+	}
+	u.TOTPEnabled = enabled
+	if !enabled {
+		u.totpSecret, u.totpKeyID, u.totpKey, u.totpLastStep = "", "", "", 0
+	}
+	return nil
+}
+
+func (r *UserRepository) SetEmailVerified(ctx context.Context, userID, email string) (bool, error) {
+	defer r.s.lock(ctx)()
+	u, ok := r.s.data.users[userID]
+	if !ok || u.Email != email {
+		return false, nil
+	}
+	u.EmailVerified = true
+	return true, nil
+}
+
+func (r *UserRepository) UpdatePassword(ctx context.Context, userID, oldPasswordHash, newPasswordHash string) (bool, error) {
+	defer r.s.lock(ctx)()
+	u, ok := r.s.data.users[userID]
+	if !ok || u.PasswordHash != oldPasswordHash {
+		return false, nil
+	}
+	u.PasswordHash = newPasswordHash
+	return true, nil
+}
+
+func (r *UserRepository) Search(ctx context.Context, query string, limit int) ([]models.User, error) {
+	defer r.s.lock(ctx)()
+	q := strings.ToLower(query)
+	users := []models.User{}
+	for _, u := range r.s.data.users {
+		if u.ID == query || strings.Contains(strings.ToLower(u.Email), q) || strings.Contains(strings.ToLower(u.Username), q) {
+			found := u.User
+			found.PasswordHash = ""
+			users = append(users, found)
+		}
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].Email < users[j].Email })
+	if len(users) > limit {
+		users = users[:limit]
+	}
+	return users, nil
+}
+
+func (r *UserRepository) SetRole(ctx context.Context, userID, role string) error {
+	defer r.s.lock(ctx)()
+	u, ok := r.s.data.users[userID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	u.Role = role
+	u.tokenVersion++
+	return nil
+}
@@ -0,0 +1,124 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"github.com/lib/pq"
+	"go_project/internal/models"
+	"time"
+)
+
+type OAuthRepository struct {
+	DB *sql.DB
+}
+
+func NewOAuthRepository(db *sql.DB) *OAuthRepository {
+	return &OAuthRepository{DB: db}
+}
+
+func (r *OAuthRepository) CreateClient(ctx context.Context, c *models.OAuthClient) error {
+	var secretHash sql.NullString
+	if c.SecretHash != "" {
+		secretHash = sql.NullString{String: c.SecretHash, Valid: true}
+	}
+	return conn(ctx, r.DB).QueryRowContext(ctx, `INSERT INTO oauth_clients (id, name, secret_hash, redirect_uris, scopes, grant_types)
+                                VALUES ($1, $2, $3, $4, $5, $6) RETURNING created_at`,
+		c.ID, c.Name, secretHash, pq.Array(c.RedirectURIs), pq.Array(c.Scopes), pq.Array(c.GrantTypes)).
+		Scan(&c.CreatedAt)
+}
+
+func (r *OAuthRepository) GetClient(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	var c models.OAuthClient
+	var secretHash sql.NullString
+	err := conn(ctx, r.DB).QueryRowContext(ctx, `SELECT id, name, secret_hash, redirect_uris, scopes, grant_types, created_at
+                                FROM oauth_clients WHERE id = $1`, clientID).
+		Scan(&c.ID, &c.Name, &secretHash, pq.Array(&c.RedirectURIs), pq.Array(&c.Scopes), pq.Array(&c.GrantTypes), &c.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	c.SecretHash = secretHash.String
+	c.Confidential = secretHash.Valid
+	return &c, nil
+}
+
+// GetConsent возвращает согласие пользователя для клиента или sql.ErrNoRows.
+func (r *OAuthRepository) GetConsent(ctx context.Context, userID, clientID string) (*models.OAuthConsent, error) {
+	var c models.OAuthConsent
+	err := conn(ctx, r.DB).QueryRowContext(ctx, `SELECT oc.user_id, oc.client_id, cl.name, oc.scopes, oc.granted_at
+                                FROM oauth_consents oc JOIN oauth_clients cl ON cl.id = oc.client_id
+                                WHERE oc.user_id = $1 AND oc.client_id = $2`, userID, clientID).
+		Scan(&c.UserID, &c.ClientID, &c.ClientName, pq.Array(&c.Scopes), &c.GrantedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// SaveConsent сохраняет согласие, объединяя новые области с ранее разрешёнными.
+func (r *OAuthRepository) SaveConsent(ctx context.Context, userID, clientID string, scopes []string) error {
+	_, err := conn(ctx, r.DB).ExecContext(ctx, `INSERT INTO oauth_consents (user_id, client_id, scopes) VALUES ($1, $2, $3)
+                               ON CONFLICT (user_id, client_id) DO UPDATE SET
+                                   scopes = ARRAY(SELECT DISTINCT unnest(oauth_consents.scopes || EXCLUDED.scopes)),
+                                   granted_at = NOW()`,
+		userID, clientID, pq.Array(scopes))
+	return err
+}
+
+func (r *OAuthRepository) ListConsents(ctx context.Context, userID string) ([]models.OAuthConsent, error) {
+	rows, err := conn(ctx, r.DB).QueryContext(ctx, `SELECT oc.user_id, oc.client_id, cl.name, oc.scopes, oc.granted_at
+                                FROM oauth_consents oc JOIN oauth_clients cl ON cl.id = oc.client_id
+                                WHERE oc.user_id = $1 ORDER BY oc.granted_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	consents := []models.OAuthConsent{}
+	for rows.Next() {
+		var c models.OAuthConsent
+		if err := rows.Scan(&c.UserID, &c.ClientID, &c.ClientName, pq.Array(&c.Scopes), &c.GrantedAt); err != nil {
+			return nil, err
+		}
+		consents = append(consents, c)
+	}
+	return consents, rows.Err()
+}
+
+// DeleteConsent отзывает согласие. Возвращает false, если согласия не было.
+func (r *OAuthRepository) DeleteConsent(ctx context.Context, userID, clientID string) (bool, error) {
+	res, err := conn(ctx, r.DB).ExecContext(ctx, `DELETE FROM oauth_consents WHERE user_id = $1 AND client_id = $2`, userID, clientID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (r *OAuthRepository) CreateCode(ctx context.Context, c *models.OAuthCode) error {
+	_, err := conn(ctx, r.DB).ExecContext(ctx, `INSERT INTO oauth_codes (code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, expires_at)
+                               VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		c.CodeHash, c.ClientID, c.UserID, c.RedirectURI, pq.Array(c.Scopes), c.CodeChallenge, c.ExpiresAt)
+	return err
+}
+
+// UseCode атомарно помечает код использованным и возвращает его. Если код не найден,
+// истёк или уже использован, возвращается sql.ErrNoRows.
+func (r *OAuthRepository) UseCode(ctx context.Context, codeHash string, now time.Time) (*models.OAuthCode, error) {
+	var c models.OAuthCode
+	err := conn(ctx, r.DB).QueryRowContext(ctx, `UPDATE oauth_codes SET used_at = $2
+                                WHERE code_hash = $1 AND used_at IS NULL AND expires_at > $2
+                                RETURNING code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, expires_at`,
+		codeHash, now).
+		Scan(&c.CodeHash, &c.ClientID, &c.UserID, &c.RedirectURI, pq.Array(&c.Scopes), &c.CodeChallenge, &c.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *OAuthRepository) DeleteExpiredCodes(ctx context.Context, now time.Time) error {
+	_, err := conn(ctx, r.DB).ExecContext(ctx, `DELETE FROM oauth_codes WHERE expires_at < $1`, now)
+	return err
+}
@@ -1,6 +1,7 @@
 package repositories
 
 import (
+	"context"
 	"database/sql"
 	"go_project/internal/models"
 	"time"
@@ -14,61 +15,62 @@ func NewPaymentScheduleRepository(db *sql.DB) *PaymentScheduleRepository {
 	return &PaymentScheduleRepository{DB: db}
 }
 
-func (r *PaymentScheduleRepository) GetByCreditID(creditID string) ([]models.PaymentSchedule, error) {
-	rows, err := r.DB.Query(`SELECT id, credit_id, due_date, amount, is_paid, penalty 
-								    FROM payment_schedules`, creditID)
+// Create добавляет платёж в график и заполняет его идентификатор.
+func (r *PaymentScheduleRepository) Create(ctx context.Context, ps *models.PaymentSchedule) error {
+	return conn(ctx, r.DB).QueryRowContext(ctx, `INSERT INTO payment_schedules (id, credit_id, due_date, amount, is_paid, penalty)
+							    VALUES (gen_random_uuid(), $1, $2, $3, FALSE, 0) RETURNING id`,
+		ps.CreditID, ps.DueDate, ps.Amount).Scan(&ps.ID)
+}
+
+const scheduleColumns = `id, credit_id, due_date, amount, is_paid, paid_date, penalty`
+
+// GetByCreditID возвращает график платежей по кредиту в порядке сроков.
+func (r *PaymentScheduleRepository) GetByCreditID(ctx context.Context, creditID string) ([]models.PaymentSchedule, error) {
+	rows, err := conn(ctx, r.DB).QueryContext(ctx, `SELECT `+scheduleColumns+`
+								    FROM payment_schedules WHERE credit_id = $1 ORDER BY due_date`, creditID)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-	var schedules []models.PaymentSchedule
-	for rows.Next() {
-		var ps models.PaymentSchedule
-		var paidDate sql.NullTime
-		if err := rows.Scan(&ps.ID, &ps.CreditID, &ps.DueDate, &ps.Amount, &paidDate, &ps.Penalty); err != nil {
-			return nil, err
-		}
-		if paidDate.Valid {
-			pd := paidDate.Time
-			ps.PaidDate = &pd
-		}
-		schedules = append(schedules, ps)
-	}
-	return schedules, nil
+	return scanSchedules(rows)
 }
 
-func (r *PaymentScheduleRepository) ListOverdue(currentTime time.Time) ([]models.PaymentSchedule, error) {
-	rows, err := r.DB.Query(`SELECT id, credit_id, due_date, amount, is_paid, penalty 
+// ListOverdue возвращает неоплаченные платежи со сроком раньше currentTime.
+func (r *PaymentScheduleRepository) ListOverdue(ctx context.Context, currentTime time.Time) ([]models.PaymentSchedule, error) {
+	rows, err := conn(ctx, r.DB).QueryContext(ctx, `SELECT `+scheduleColumns+`
 									FROM payment_schedules 
-									WHERE is_paid = false AND due_date < ?`, currentTime)
+									WHERE is_paid = false AND due_date < $1 ORDER BY due_date`, currentTime)
 	if err != nil {
 		return nil, err
 	}
+	return scanSchedules(rows)
+}
+
+func scanSchedules(rows *sql.Rows) ([]models.PaymentSchedule, error) {
 	defer rows.Close()
-	var overdue []models.PaymentSchedule
+	schedules := []models.PaymentSchedule{}
 	for rows.Next() {
 		var ps models.PaymentSchedule
 		var paidDate sql.NullTime
-		if err := rows.Scan(&ps.ID, &ps.CreditID, &ps.DueDate, &ps.Amount, &paidDate, &ps.Penalty); err != nil {
+		if err := rows.Scan(&ps.ID, &ps.CreditID, &ps.DueDate, &ps.Amount, &ps.Paid, &paidDate, &ps.Penalty); err != nil {
 			return nil, err
 		}
 		if paidDate.Valid {
 			pd := paidDate.Time
 			ps.PaidDate = &pd
 		}
-		overdue = append(overdue, ps)
+		schedules = append(schedules, ps)
 	}
-	return overdue, nil
+	return schedules, rows.Err()
 }
 
-func (r *PaymentScheduleRepository) MarkAsPaid(scheduleID string, paidDate time.Time) error {
-	_, err := r.DB.Exec(`UPDATE payment_schedules SET is_paid = true, paid_date = $1 
+func (r *PaymentScheduleRepository) MarkAsPaid(ctx context.Context, scheduleID string, paidDate time.Time) error {
+	_, err := conn(ctx, r.DB).ExecContext(ctx, `UPDATE payment_schedules SET is_paid = true, paid_date = $1 
 								WHERE id = $2`, paidDate, scheduleID)
 	return err
 }
 
-func (r *PaymentScheduleRepository) ApplyPenalty(scheduleID string, penaltyAmount float64) error {
-	_, err := r.DB.Exec(`UPDATE payment_schedules SET penalty = penalty + $1 
+func (r *PaymentScheduleRepository) ApplyPenalty(ctx context.Context, scheduleID string, penaltyAmount float64) error {
+	_, err := conn(ctx, r.DB).ExecContext(ctx, `UPDATE payment_schedules SET penalty = penalty + $1 
 								WHERE id = $2`, penaltyAmount, scheduleID)
 	return err
 }
@@ -0,0 +1,59 @@
+package repositories_test
+
+import (
+	"context"
+	"database/sql"
+	_ "github.com/lib/pq"
+	"go_project/internal/migrations"
+	"go_project/internal/repositories"
+	"go_project/internal/repositories/repotest"
+	"os"
+	"testing"
+)
+
+// TestContract проверяет репозитории на настоящей базе. База из TEST_DATABASE_URL очищается
+// перед каждым подтестом, поэтому указывать в ней рабочую базу нельзя.
+func TestContract(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	migrator, err := migrations.New(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := migrator.Up(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	repotest.Run(t, func(t *testing.T) *repotest.Backend {
+		_, err := db.Exec(`TRUNCATE users, accounts, cards, transactions, credits, payment_schedules, card_limits,
+                           card_authorizations, refresh_tokens, revoked_tokens, recovery_codes, admin_actions,
+                           login_attempts, jwt_signing_keys, oauth_clients, oauth_consents, oauth_codes, sessions, api_keys CASCADE`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &repotest.Backend{
+			UnitOfWork:       repositories.NewTxManager(db),
+			Accounts:         repositories.NewAccountRepository(db),
+			Transactions:     repositories.NewTransactionRepository(db),
+			Credits:          repositories.NewCreditRepository(db),
+			PaymentSchedules: repositories.NewPaymentScheduleRepository(db),
+			Users:            repositories.NewUserRepository(db),
+			Tokens:           repositories.NewTokenRepository(db),
+			Sessions:         repositories.NewSessionRepository(db),
+			RecoveryCodes:    repositories.NewRecoveryCodeRepository(db),
+			LoginAttempts:    repositories.NewLoginAttemptRepository(db),
+			Cards:            repositories.NewCardRepository(db),
+			SigningKeys:      repositories.NewSigningKeyRepository(db),
+			AdminActions:     repositories.NewAdminActionRepository(db),
+			APIKeys:          repositories.NewAPIKeyRepository(db),
+			OAuth:            repositories.NewOAuthRepository(db),
+		}
+	})
+}
@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+)
+
+type RecoveryCodeRepository struct {
+	DB *sql.DB
+}
+
+func NewRecoveryCodeRepository(db *sql.DB) *RecoveryCodeRepository {
+	return &RecoveryCodeRepository{DB: db}
+}
+
+// ReplaceCodes заменяет все резервные коды пользователя новыми хешами.
+func (r *RecoveryCodeRepository) ReplaceCodes(ctx context.Context, userID string, codeHashes []string) error {
+	return NewTxManager(r.DB).Do(ctx, func(ctx context.Context) error {
+		q := conn(ctx, r.DB)
+		if _, err := q.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+			return err
+		}
+		for _, hash := range codeHashes {
+			_, err := q.ExecContext(ctx, `INSERT INTO recovery_codes (id, user_id, code_hash)
+                                 VALUES (gen_random_uuid(), $1, $2)`, userID, hash)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// UseCode помечает неиспользованный резервный код использованным.
+// Возвращает false, если такого кода нет или он уже был использован.
+func (r *RecoveryCodeRepository) UseCode(ctx context.Context, userID, codeHash string) (bool, error) {
+	res, err := conn(ctx, r.DB).ExecContext(ctx, `UPDATE recovery_codes SET used_at = NOW()
+                                 WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (r *RecoveryCodeRepository) DeleteCodes(ctx context.Context, userID string) error {
+	_, err := conn(ctx, r.DB).ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID)
+	return err
+}
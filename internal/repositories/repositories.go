@@ -0,0 +1,204 @@
+// Package repositories описывает хранилища данных сервиса и содержит их реализацию поверх PostgreSQL.
+//
+// Сервисы зависят от интерфейсов этого файла, а не от конкретных типов, поэтому в тестах вместо
+// PostgreSQL можно использовать реализацию в памяти (пакет memory). Поведение обеих реализаций
+// проверяется общим набором тестов из пакета repotest.
+//
+// Несколько вызовов репозиториев объединяются в одну транзакцию через UnitOfWork: методы,
+// вызванные с контекстом, который передан в функцию Do, выполняются в её транзакции.
+package repositories
+
+import (
+	"context"
+	"go_project/internal/models"
+	"time"
+)
+
+// UnitOfWork выполняет fn в транзакции: изменения фиксируются, если fn вернула nil, и откатываются
+// при ошибке. Вложенный вызов Do с контекстом транзакции продолжает внешнюю транзакцию.
+type UnitOfWork interface {
+	Do(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Accounts хранит счета клиентов.
+type Accounts interface {
+	CreateAccount(ctx context.Context, userID string) (string, error)
+	GetByID(ctx context.Context, accountID string) (*models.Account, error)
+	GetBalance(ctx context.Context, accountID string) (float64, error)
+	ListByUserID(ctx context.Context, userID string) ([]models.Account, error)
+	AddBalance(ctx context.Context, accountID string, amount float64) error
+	// Debit списывает сумму, только если на счёте достаточно средств; иначе возвращает false.
+	Debit(ctx context.Context, accountID string, amount float64) (bool, error)
+	Credit(ctx context.Context, accountID string, amount float64) error
+	// LockByID читает счёт и блокирует его до конца транзакции.
+	LockByID(ctx context.Context, accountID string) (*models.Account, error)
+	SetFrozen(ctx context.Context, accountID string, frozen bool) error
+}
+
+// Transactions хранит историю переводов между счетами.
+type Transactions interface {
+	Create(ctx context.Context, t *models.Transaction) error
+	GetUserStats(ctx context.Context, userID string) (totalSent float64, countSent int, totalReceived float64, countReceived int, err error)
+	ListByUserID(ctx context.Context, userID string, limit int) ([]models.Transaction, error)
+}
+
+// Credits хранит выданные кредиты.
+type Credits interface {
+	GetById(ctx context.Context, creditID string) (*models.Credit, error)
+	CreateCredit(ctx context.Context, accountID string, amount float64, interest float64, term int) (string, error)
+}
+
+// PaymentSchedules хранит графики платежей по кредитам.
+type PaymentSchedules interface {
+	Create(ctx context.Context, ps *models.PaymentSchedule) error
+	GetByCreditID(ctx context.Context, creditID string) ([]models.PaymentSchedule, error)
+	ListOverdue(ctx context.Context, currentTime time.Time) ([]models.PaymentSchedule, error)
+	MarkAsPaid(ctx context.Context, scheduleID string, paidDate time.Time) error
+	ApplyPenalty(ctx context.Context, scheduleID string, penaltyAmount float64) error
+}
+
+// Users хранит учётные записи клиентов и сотрудников.
+type Users interface {
+	CreateUser(ctx context.Context, email, username, passwordHash string) (string, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+	GetByUsername(ctx context.Context, username string) (*models.User, error)
+	GetByID(ctx context.Context, userID string) (*models.User, error)
+	GetTokenVersion(ctx context.Context, userID string) (int, error)
+	IncrementTokenVersion(ctx context.Context, userID string) error
+	SetTOTPSecret(ctx context.Context, userID, secret, keyID, encryptionKey string) error
+	GetTOTPSecret(ctx context.Context, userID string, keyFor func(keyID string) (string, error)) (string, string, int64, error)
+	UseTOTPStep(ctx context.Context, userID string, step int64) (bool, error)
+	SetTOTPEnabled(ctx context.Context, userID string, enabled bool) error
+	CountTOTPByKeyID(ctx context.Context) (map[string]int, error)
+	ReencryptTOTPBatch(ctx context.Context, fromKeyID, fromKey, toKeyID, toKey string, limit int) (int, error)
+	SetEmailVerified(ctx context.Context, userID, email string) (bool, error)
+	UpdatePassword(ctx context.Context, userID, oldPasswordHash, newPasswordHash string) (bool, error)
+	Search(ctx context.Context, query string, limit int) ([]models.User, error)
+	SetRole(ctx context.Context, userID, role string) error
+}
+
+// Tokens хранит токены обновления и отозванные токены доступа.
+type Tokens interface {
+	CreateRefreshToken(ctx context.Context, userID, sessionID, tokenHash string, expiresAt time.Time) (string, error)
+	GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	RevokeRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	MarkReplaced(ctx context.Context, tokenID, replacedByID string) error
+	RevokeAllRefreshTokens(ctx context.Context, userID string) error
+	RevokeSessionRefreshTokens(ctx context.Context, sessionID string) error
+	RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error
+	IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error)
+	DeleteExpired(ctx context.Context, now time.Time) error
+}
+
+// Sessions хранит сессии пользователей на устройствах.
+type Sessions interface {
+	Create(ctx context.Context, s *models.Session) error
+	GetByID(ctx context.Context, sessionID string) (*models.Session, error)
+	KnownDevice(ctx context.Context, userID, deviceHash string) (known bool, hasSessions bool, err error)
+	ListActive(ctx context.Context, userID string) ([]models.Session, error)
+	Touch(ctx context.Context, sessionID, ip string, now time.Time) error
+	Revoke(ctx context.Context, userID, sessionID string) (bool, error)
+	RevokeAll(ctx context.Context, userID string) error
+	DeleteStale(ctx context.Context, before time.Time) error
+}
+
+// RecoveryCodes хранит хеши резервных кодов двухфакторной аутентификации.
+type RecoveryCodes interface {
+	ReplaceCodes(ctx context.Context, userID string, codeHashes []string) error
+	UseCode(ctx context.Context, userID, codeHash string) (bool, error)
+	DeleteCodes(ctx context.Context, userID string) error
+}
+
+// LoginAttempts хранит счётчики неудачных попыток входа.
+type LoginAttempts interface {
+	Get(ctx context.Context, key string) (*models.LoginAttempts, error)
+	// RecordAttempt учитывает попытку до проверки пароля; Release отменяет учёт, если попытка
+	// не была неудачной.
+	RecordAttempt(ctx context.Context, key string, now, resetBefore time.Time) (*models.LoginAttempts, time.Time, error)
+	Release(ctx context.Context, key string, previousAt time.Time) error
+	Lock(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+	DeleteStale(ctx context.Context, before time.Time) error
+}
+
+// Cards хранит карты, их лимиты и авторизации.
+type Cards interface {
+	CreateCard(ctx context.Context, card *models.Card, cardNumberPlain, expiryPlain, cvvHash, keyID, encryptionKey string) (string, error)
+	GetByID(ctx context.Context, cardID string) (*models.Card, error)
+	GetByPANIndex(ctx context.Context, panIndex string) (*models.Card, error)
+	ListWithoutPANIndex(ctx context.Context, limit int) ([]string, error)
+	SetPANIndex(ctx context.Context, cardID, panIndex string) error
+	// LockByID читает карту и блокирует её до конца транзакции.
+	LockByID(ctx context.Context, cardID string) (*models.Card, error)
+	SetStatus(ctx context.Context, cardID, status string) error
+	LockMerchant(ctx context.Context, cardID, merchant string) error
+	GetCardNumber(ctx context.Context, cardID string, keyFor func(keyID string) (string, error)) (string, error)
+	GetCardDetails(ctx context.Context, cardID string, keyFor func(keyID string) (string, error)) (string, string, error)
+	CountByKeyID(ctx context.Context) (map[string]int, error)
+	ReencryptBatch(ctx context.Context, fromKeyID, fromKey, toKeyID, toKey string, limit int) (int, error)
+	SetPIN(ctx context.Context, cardID, pinHash string) error
+	RecordPINFailure(ctx context.Context, cardID string, maxAttempts int) (int, string, error)
+	ResetPINAttempts(ctx context.Context, cardID string) error
+	GetLimits(ctx context.Context, cardID string) (*models.CardLimits, error)
+	UpsertLimits(ctx context.Context, l *models.CardLimits) error
+	SumAuthorizedSince(ctx context.Context, cardID string, since time.Time) (float64, error)
+	CreateAuthorization(ctx context.Context, a *models.CardAuthorization) error
+}
+
+// SigningKeys хранит ключи подписи JWT.
+type SigningKeys interface {
+	ListValid(ctx context.Context, now time.Time, keyFor func(keyID string) (string, error)) ([]models.SigningKey, error)
+	Rotate(ctx context.Context, key *models.SigningKey, keyID, encryptionKey string, retireAt time.Time, rotateBefore time.Time) (bool, error)
+	CountByKeyID(ctx context.Context) (map[string]int, error)
+	ReencryptBatch(ctx context.Context, fromKeyID, fromKey, toKeyID, toKey string, limit int) (int, error)
+	DeleteExpired(ctx context.Context, now time.Time) error
+}
+
+// AdminActions хранит журнал действий сотрудников.
+type AdminActions interface {
+	Create(ctx context.Context, a *models.AdminAction) error
+	List(ctx context.Context, targetID string, limit, offset int) ([]models.AdminAction, error)
+}
+
+// APIKeys хранит персональные API-ключи.
+type APIKeys interface {
+	Create(ctx context.Context, k *models.APIKey) error
+	GetActiveByHash(ctx context.Context, keyHash string) (*models.APIKey, error)
+	ListByUserID(ctx context.Context, userID string) ([]models.APIKey, error)
+	CountActive(ctx context.Context, userID string, now time.Time) (int, error)
+	TouchLastUsed(ctx context.Context, keyID, ip string, now time.Time) error
+	Revoke(ctx context.Context, userID, keyID string) (bool, error)
+	// RevokeAll отзывает все действующие ключи пользователя.
+	RevokeAll(ctx context.Context, userID string) error
+}
+
+// OAuth хранит клиентов OAuth2, согласия пользователей и коды авторизации.
+type OAuth interface {
+	CreateClient(ctx context.Context, c *models.OAuthClient) error
+	GetClient(ctx context.Context, clientID string) (*models.OAuthClient, error)
+	GetConsent(ctx context.Context, userID, clientID string) (*models.OAuthConsent, error)
+	SaveConsent(ctx context.Context, userID, clientID string, scopes []string) error
+	ListConsents(ctx context.Context, userID string) ([]models.OAuthConsent, error)
+	DeleteConsent(ctx context.Context, userID, clientID string) (bool, error)
+	CreateCode(ctx context.Context, c *models.OAuthCode) error
+	UseCode(ctx context.Context, codeHash string, now time.Time) (*models.OAuthCode, error)
+	DeleteExpiredCodes(ctx context.Context, now time.Time) error
+}
+
+var (
+	_ UnitOfWork       = (*TxManager)(nil)
+	_ Accounts         = (*AccountRepository)(nil)
+	_ Transactions     = (*TransactionRepository)(nil)
+	_ Credits          = (*CreditRepository)(nil)
+	_ PaymentSchedules = (*PaymentScheduleRepository)(nil)
+	_ Users            = (*UserRepository)(nil)
+	_ Tokens           = (*TokenRepository)(nil)
+	_ Sessions         = (*SessionRepository)(nil)
+	_ RecoveryCodes    = (*RecoveryCodeRepository)(nil)
+	_ LoginAttempts    = (*LoginAttemptRepository)(nil)
+	_ Cards            = (*CardRepository)(nil)
+	_ SigningKeys      = (*SigningKeyRepository)(nil)
+	_ AdminActions     = (*AdminActionRepository)(nil)
+	_ APIKeys          = (*APIKeyRepository)(nil)
+	_ OAuth            = (*OAuthRepository)(nil)
+)
@@ -0,0 +1,1067 @@
+// Package repotest содержит общий набор тестов для реализаций интерфейсов пакета repositories.
+// Один и тот же набор запускается для PostgreSQL и для реализации в памяти, поэтому сервисы,
+// протестированные на памяти, ведут себя так же на настоящей базе.
+package repotest
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"go_project/internal/models"
+	"go_project/internal/repositories"
+	"sort"
+	"testing"
+	"time"
+)
+
+// Backend — набор репозиториев одной реализации, работающих с общими данными.
+type Backend struct {
+	UnitOfWork       repositories.UnitOfWork
+	Accounts         repositories.Accounts
+	Transactions     repositories.Transactions
+	Credits          repositories.Credits
+	PaymentSchedules repositories.PaymentSchedules
+	Users            repositories.Users
+	Tokens           repositories.Tokens
+	Sessions         repositories.Sessions
+	RecoveryCodes    repositories.RecoveryCodes
+	LoginAttempts    repositories.LoginAttempts
+	Cards            repositories.Cards
+	SigningKeys      repositories.SigningKeys
+	AdminActions     repositories.AdminActions
+	APIKeys          repositories.APIKeys
+	OAuth            repositories.OAuth
+}
+
+// Run запускает набор тестов. newBackend вызывается в каждом подтесте и должен возвращать
+// хранилище без данных, оставшихся от предыдущих подтестов.
+func Run(t *testing.T, newBackend func(t *testing.T) *Backend) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, b *Backend)
+	}{
+		{"UnitOfWork", testUnitOfWork},
+		{"Accounts", testAccounts},
+		{"Transactions", testTransactions},
+		{"Credits", testCredits},
+		{"PaymentSchedules", testPaymentSchedules},
+		{"Users", testUsers},
+		{"TOTP", testTOTP},
+		{"Tokens", testTokens},
+		{"Sessions", testSessions},
+		{"RecoveryCodes", testRecoveryCodes},
+		{"LoginAttempts", testLoginAttempts},
+		{"Cards", testCards},
+		{"CardPIN", testCardPIN},
+		{"CardLimits", testCardLimits},
+		{"SigningKeys", testSigningKeys},
+		{"AdminActions", testAdminActions},
+		{"APIKeys", testAPIKeys},
+		{"OAuth", testOAuth},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newBackend(t))
+		})
+	}
+}
+
+var errRollback = errors.New("rollback")
+
+// missingID — корректный UUID, которого нет ни в одной таблице.
+const missingID = "00000000-0000-4000-8000-000000000000"
+
+func random() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func wantNoRows(t *testing.T, err error) {
+	t.Helper()
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("err = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func sameSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a, b = append([]string(nil), a...), append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func keyFor(key string) func(string) (string, error) {
+	return func(string) (string, error) { return key, nil }
+}
+
+func createUser(t *testing.T, b *Backend) string {
+	t.Helper()
+	name := "user-" + random()
+	id, err := b.Users.CreateUser(context.Background(), name+"@example.com", name, "hash")
+	must(t, err)
+	return id
+}
+
+func createAccount(t *testing.T, b *Backend, balance float64) string {
+	t.Helper()
+	ctx := context.Background()
+	id, err := b.Accounts.CreateAccount(ctx, createUser(t, b))
+	must(t, err)
+	if balance != 0 {
+		must(t, b.Accounts.AddBalance(ctx, id, balance))
+	}
+	return id
+}
+
+func balance(t *testing.T, b *Backend, accountID string) float64 {
+	t.Helper()
+	got, err := b.Accounts.GetBalance(context.Background(), accountID)
+	must(t, err)
+	return got
+}
+
+func createCard(t *testing.T, b *Backend, keyID, key string) string {
+	t.Helper()
+	card := &models.Card{AccountID: createAccount(t, b, 0), Type: models.CardTypeStandard, PANIndex: random()}
+	id, err := b.Cards.CreateCard(context.Background(), card, "4000001234567899", "12/30", "cvv-hash", keyID, key)
+	must(t, err)
+	return id
+}
+
+func testUnitOfWork(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	from, to := createAccount(t, b, 100), createAccount(t, b, 0)
+	transfer := func(ctx context.Context) error {
+		if ok, err := b.Accounts.Debit(ctx, from, 30); err != nil || !ok {
+			t.Fatalf("Debit = %v, %v", ok, err)
+		}
+		return b.Accounts.Credit(ctx, to, 30)
+	}
+
+	err := b.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		must(t, transfer(ctx))
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("Do err = %v, want %v", err, errRollback)
+	}
+	if got := balance(t, b, from); got != 100 {
+		t.Fatalf("balance after rollback = %v, want 100", got)
+	}
+
+	// Вложенный вызов продолжает внешнюю транзакцию и откатывается вместе с ней
+	err = b.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		must(t, b.UnitOfWork.Do(ctx, transfer))
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("Do err = %v, want %v", err, errRollback)
+	}
+	if got := balance(t, b, to); got != 0 {
+		t.Fatalf("balance after nested rollback = %v, want 0", got)
+	}
+
+	must(t, b.UnitOfWork.Do(ctx, transfer))
+	if got, want := []float64{balance(t, b, from), balance(t, b, to)}, []float64{70, 30}; got[0] != want[0] || got[1] != want[1] {
+		t.Fatalf("balances after commit = %v, want %v", got, want)
+	}
+}
+
+func testAccounts(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	userID := createUser(t, b)
+	first, err := b.Accounts.CreateAccount(ctx, userID)
+	must(t, err)
+	second, err := b.Accounts.CreateAccount(ctx, userID)
+	must(t, err)
+
+	acc, err := b.Accounts.GetByID(ctx, first)
+	must(t, err)
+	if acc.UserID != userID || acc.Balance != 0 || acc.Frozen {
+		t.Fatalf("new account = %+v", acc)
+	}
+	_, err = b.Accounts.GetByID(ctx, missingID)
+	wantNoRows(t, err)
+	_, err = b.Accounts.CreateAccount(ctx, missingID)
+	if err == nil {
+		t.Fatal("CreateAccount for missing user succeeded")
+	}
+
+	must(t, b.Accounts.AddBalance(ctx, first, 100.126))
+	if got := balance(t, b, first); got != 100.13 {
+		t.Fatalf("balance = %v, want 100.13", got)
+	}
+	must(t, b.Accounts.SetFrozen(ctx, first, true))
+	must(t, b.Accounts.Credit(ctx, second, 50))
+	ok, err := b.Accounts.Debit(ctx, second, 80)
+	must(t, err)
+	if ok || balance(t, b, second) != 50 {
+		t.Fatalf("Debit over balance = %v, balance %v", ok, balance(t, b, second))
+	}
+	ok, err = b.Accounts.Debit(ctx, second, 50)
+	must(t, err)
+	if !ok || balance(t, b, second) != 0 {
+		t.Fatalf("Debit of whole balance = %v, balance %v", ok, balance(t, b, second))
+	}
+
+	accounts, err := b.Accounts.ListByUserID(ctx, userID)
+	must(t, err)
+	if len(accounts) != 2 {
+		t.Fatalf("ListByUserID = %+v, want 2 accounts", accounts)
+	}
+	for _, a := range accounts {
+		if a.ID == first && !a.Frozen {
+			t.Fatalf("account %s is not frozen", a.ID)
+		}
+	}
+	must(t, b.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		locked, err := b.Accounts.LockByID(ctx, first)
+		if err == nil && !locked.Frozen {
+			t.Fatalf("LockByID = %+v", locked)
+		}
+		return err
+	}))
+	empty, err := b.Accounts.ListByUserID(ctx, createUser(t, b))
+	must(t, err)
+	if empty == nil || len(empty) != 0 {
+		t.Fatalf("ListByUserID without accounts = %#v, want empty slice", empty)
+	}
+}
+
+func testTransactions(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	userID := createUser(t, b)
+	own, err := b.Accounts.CreateAccount(ctx, userID)
+	must(t, err)
+	other := createAccount(t, b, 0)
+
+	for _, tx := range []*models.Transaction{
+		{ToAccountID: own, Amount: 500, HMAC: "h1"},
+		{FromAccountID: own, ToAccountID: other, Amount: 120.5, HMAC: "h2"},
+		{FromAccountID: other, ToAccountID: own, Amount: 20, HMAC: "h3"},
+	} {
+		must(t, b.Transactions.Create(ctx, tx))
+		if tx.ID == "" || tx.Timestamp.IsZero() {
+			t.Fatalf("Create did not fill id and timestamp: %+v", tx)
+		}
+	}
+
+	sent, countSent, received, countReceived, err := b.Transactions.GetUserStats(ctx, userID)
+	must(t, err)
+	if sent != 120.5 || countSent != 1 || received != 520 || countReceived != 2 {
+		t.Fatalf("GetUserStats = %v, %d, %v, %d", sent, countSent, received, countReceived)
+	}
+
+	list, err := b.Transactions.ListByUserID(ctx, userID, 2)
+	must(t, err)
+	if len(list) != 2 || list[0].Amount != 20 || list[1].Amount != 120.5 {
+		t.Fatalf("ListByUserID = %+v, want the two latest, newest first", list)
+	}
+	all, err := b.Transactions.ListByUserID(ctx, userID, 10)
+	must(t, err)
+	if len(all) != 3 || all[2].FromAccountID != "" || all[2].ToAccountID != own {
+		t.Fatalf("deposit = %+v, want empty source account", all)
+	}
+}
+
+func testCredits(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	accountID := createAccount(t, b, 0)
+	id, err := b.Credits.CreateCredit(ctx, accountID, 10000, 12.5, 6)
+	must(t, err)
+	credit, err := b.Credits.GetById(ctx, id)
+	must(t, err)
+	if credit.AccountID != accountID || credit.Amount != 10000 || credit.InterestRate != 12.5 || credit.TermMonths != 6 {
+		t.Fatalf("GetById = %+v", credit)
+	}
+	if y, m, d := credit.StartDate.Date(); y != time.Now().Year() || m != time.Now().Month() || d != time.Now().Day() {
+		t.Fatalf("start date = %v, want today", credit.StartDate)
+	}
+	_, err = b.Credits.GetById(ctx, missingID)
+	wantNoRows(t, err)
+}
+
+func testPaymentSchedules(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	accountID := createAccount(t, b, 0)
+	creditID, err := b.Credits.CreateCredit(ctx, accountID, 3000, 10, 3)
+	must(t, err)
+	otherID, err := b.Credits.CreateCredit(ctx, accountID, 1000, 10, 1)
+	must(t, err)
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	dates := []time.Time{today.AddDate(0, 1, 0), today.AddDate(0, -2, 0), today.AddDate(0, -1, 0)}
+	for _, due := range dates {
+		must(t, b.PaymentSchedules.Create(ctx, &models.PaymentSchedule{CreditID: creditID, DueDate: due, Amount: 1050}))
+	}
+	must(t, b.PaymentSchedules.Create(ctx, &models.PaymentSchedule{CreditID: otherID, DueDate: today.AddDate(0, 0, -3), Amount: 1010}))
+
+	schedule, err := b.PaymentSchedules.GetByCreditID(ctx, creditID)
+	must(t, err)
+	if len(schedule) != 3 {
+		t.Fatalf("GetByCreditID = %+v, want only payments of the credit", schedule)
+	}
+	for i := 1; i < len(schedule); i++ {
+		if !schedule[i-1].DueDate.Before(schedule[i].DueDate) {
+			t.Fatalf("GetByCreditID is not ordered by due date: %+v", schedule)
+		}
+	}
+	first := schedule[0]
+	if first.ID == "" || first.CreditID != creditID || first.Paid || first.PaidDate != nil || first.Penalty != 0 {
+		t.Fatalf("payment = %+v", first)
+	}
+
+	overdue := func() []models.PaymentSchedule {
+		all, err := b.PaymentSchedules.ListOverdue(ctx, time.Now())
+		must(t, err)
+		var own []models.PaymentSchedule
+		for _, p := range all {
+			if p.CreditID == creditID {
+				own = append(own, p)
+			}
+		}
+		return own
+	}
+	if got := overdue(); len(got) != 2 || got[0].ID != first.ID {
+		t.Fatalf("ListOverdue = %+v, want two past payments, oldest first", got)
+	}
+
+	must(t, b.PaymentSchedules.MarkAsPaid(ctx, first.ID, time.Now()))
+	must(t, b.PaymentSchedules.ApplyPenalty(ctx, schedule[1].ID, 15.5))
+	must(t, b.PaymentSchedules.ApplyPenalty(ctx, schedule[1].ID, 15.5))
+	if got := overdue(); len(got) != 1 || got[0].ID != schedule[1].ID || got[0].Penalty != 31 {
+		t.Fatalf("ListOverdue after payment = %+v", got)
+	}
+	schedule, err = b.PaymentSchedules.GetByCreditID(ctx, creditID)
+	must(t, err)
+	if !schedule[0].Paid || schedule[0].PaidDate == nil {
+		t.Fatalf("paid payment = %+v", schedule[0])
+	}
+	empty, err := b.PaymentSchedules.GetByCreditID(ctx, missingID)
+	must(t, err)
+	if empty == nil || len(empty) != 0 {
+		t.Fatalf("GetByCreditID of missing credit = %#v, want empty slice", empty)
+	}
+}
+
+func testUsers(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	name := "user-" + random()
+	email := name + "@example.com"
+	id, err := b.Users.CreateUser(ctx, email, name, "old")
+	must(t, err)
+	if _, err := b.Users.CreateUser(ctx, email, name+"-2", "hash"); err == nil {
+		t.Fatal("CreateUser with duplicate email succeeded")
+	}
+	if _, err := b.Users.CreateUser(ctx, "other-"+email, name, "hash"); err == nil {
+		t.Fatal("CreateUser with duplicate username succeeded")
+	}
+
+	u, err := b.Users.GetByEmail(ctx, email)
+	must(t, err)
+	if u.ID != id || u.Username != name || u.PasswordHash != "old" || u.Role != models.RoleCustomer || u.EmailVerified || u.TOTPEnabled {
+		t.Fatalf("GetByEmail = %+v", u)
+	}
+	if u, err := b.Users.GetByUsername(ctx, name); err != nil || u.ID != id {
+		t.Fatalf("GetByUsername = %+v, %v", u, err)
+	}
+	_, err = b.Users.GetByID(ctx, missingID)
+	wantNoRows(t, err)
+
+	if ok, err := b.Users.SetEmailVerified(ctx, id, "changed@example.com"); err != nil || ok {
+		t.Fatalf("SetEmailVerified with stale email = %v, %v", ok, err)
+	}
+	if ok, err := b.Users.SetEmailVerified(ctx, id, email); err != nil || !ok {
+		t.Fatalf("SetEmailVerified = %v, %v", ok, err)
+	}
+	if ok, err := b.Users.UpdatePassword(ctx, id, "wrong", "new"); err != nil || ok {
+		t.Fatalf("UpdatePassword with stale hash = %v, %v", ok, err)
+	}
+	if ok, err := b.Users.UpdatePassword(ctx, id, "old", "new"); err != nil || !ok {
+		t.Fatalf("UpdatePassword = %v, %v", ok, err)
+	}
+
+	must(t, b.Users.IncrementTokenVersion(ctx, id))
+	must(t, b.Users.SetRole(ctx, id, models.RoleOperator))
+	wantNoRows(t, b.Users.SetRole(ctx, missingID, models.RoleAdmin))
+	// Смена роли отзывает выданные токены
+	if v, err := b.Users.GetTokenVersion(ctx, id); err != nil || v != 2 {
+		t.Fatalf("GetTokenVersion = %d, %v, want 2", v, err)
+	}
+	u, err = b.Users.GetByID(ctx, id)
+	must(t, err)
+	if !u.EmailVerified || u.PasswordHash != "new" || u.Role != models.RoleOperator {
+		t.Fatalf("GetByID = %+v", u)
+	}
+
+	found, err := b.Users.Search(ctx, name[5:11], 10)
+	must(t, err)
+	if len(found) != 1 || found[0].ID != id {
+		t.Fatalf("Search = %+v", found)
+	}
+	// Спецсимволы шаблона LIKE в строке поиска сравниваются буквально
+	for _, pattern := range []string{"%", "_", `\`} {
+		wildcard, err := b.Users.Search(ctx, pattern, 10)
+		must(t, err)
+		if len(wildcard) != 0 {
+			t.Fatalf("Search(%q) = %+v, want no matches", pattern, wildcard)
+		}
+	}
+	none, err := b.Users.Search(ctx, "no-such-user-"+random(), 10)
+	must(t, err)
+	if none == nil || len(none) != 0 {
+		t.Fatalf("Search without matches = %#v, want empty slice", none)
+	}
+}
+
+func testTOTP(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	id := createUser(t, b)
+	_, _, _, err := b.Users.GetTOTPSecret(ctx, id, keyFor("key-1"))
+	wantNoRows(t, err)
+
+	must(t, b.Users.SetTOTPSecret(ctx, id, "SECRET", "k1", "key-1"))
+	secret, keyID, step, err := b.Users.GetTOTPSecret(ctx, id, func(keyID string) (string, error) {
+		if keyID != "k1" {
+			t.Fatalf("keyFor(%q), want k1", keyID)
+		}
+		return "key-1", nil
+	})
+	must(t, err)
+	if secret != "SECRET" || keyID != "k1" || step != 0 {
+		t.Fatalf("GetTOTPSecret = %q, %q, %d", secret, keyID, step)
+	}
+	if _, _, _, err := b.Users.GetTOTPSecret(ctx, id, keyFor("key-2")); err == nil {
+		t.Fatal("GetTOTPSecret with wrong key succeeded")
+	}
+
+	for _, tc := range []struct {
+		step int64
+		want bool
+	}{{100, true}, {100, false}, {99, false}, {101, true}} {
+		if ok, err := b.Users.UseTOTPStep(ctx, id, tc.step); err != nil || ok != tc.want {
+			t.Fatalf("UseTOTPStep(%d) = %v, %v, want %v", tc.step, ok, err, tc.want)
+		}
+	}
+	must(t, b.Users.SetTOTPEnabled(ctx, id, true))
+	if u, err := b.Users.GetByID(ctx, id); err != nil || !u.TOTPEnabled {
+		t.Fatalf("GetByID after enabling TOTP = %+v, %v", u, err)
+	}
+	must(t, b.Users.SetTOTPEnabled(ctx, id, false))
+	_, _, _, err = b.Users.GetTOTPSecret(ctx, id, keyFor("key-1"))
+	wantNoRows(t, err)
+
+	oldKeyID, newKeyID := "old-"+random(), "new-"+random()
+	must(t, b.Users.SetTOTPSecret(ctx, id, "ROTATED", oldKeyID, "old-key"))
+	counts, err := b.Users.CountTOTPByKeyID(ctx)
+	must(t, err)
+	if counts[oldKeyID] != 1 {
+		t.Fatalf("CountTOTPByKeyID = %v, want 1 secret for %s", counts, oldKeyID)
+	}
+	if _, err := b.Users.ReencryptTOTPBatch(ctx, oldKeyID, "wrong-key", newKeyID, "new-key", 10); err == nil {
+		t.Fatal("ReencryptTOTPBatch with wrong key succeeded")
+	}
+	if n, err := b.Users.ReencryptTOTPBatch(ctx, oldKeyID, "old-key", newKeyID, "new-key", 10); err != nil || n != 1 {
+		t.Fatalf("ReencryptTOTPBatch = %d, %v, want 1", n, err)
+	}
+	counts, err = b.Users.CountTOTPByKeyID(ctx)
+	must(t, err)
+	if counts[oldKeyID] != 0 || counts[newKeyID] != 1 {
+		t.Fatalf("CountTOTPByKeyID after rotation = %v", counts)
+	}
+	if secret, keyID, _, err := b.Users.GetTOTPSecret(ctx, id, keyFor("new-key")); err != nil || secret != "ROTATED" || keyID != newKeyID {
+		t.Fatalf("GetTOTPSecret after rotation = %q, %q, %v", secret, keyID, err)
+	}
+}
+
+func testTokens(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	userID := createUser(t, b)
+	session := &models.Session{UserID: userID, DeviceName: "phone", DeviceHash: "d1"}
+	must(t, b.Sessions.Create(ctx, session))
+	expires := time.Now().Add(time.Hour)
+
+	hashes := []string{random(), random(), random()}
+	first, err := b.Tokens.CreateRefreshToken(ctx, userID, session.ID, hashes[0], expires)
+	must(t, err)
+	second, err := b.Tokens.CreateRefreshToken(ctx, userID, session.ID, hashes[1], expires)
+	must(t, err)
+	_, err = b.Tokens.CreateRefreshToken(ctx, userID, session.ID, hashes[2], time.Now().Add(-time.Hour))
+	must(t, err)
+
+	tok, err := b.Tokens.GetRefreshToken(ctx, hashes[0])
+	must(t, err)
+	if tok.ID != first || tok.UserID != userID || tok.SessionID != session.ID || tok.RevokedAt != nil {
+		t.Fatalf("GetRefreshToken = %+v", tok)
+	}
+	revoked, err := b.Tokens.RevokeRefreshToken(ctx, hashes[0])
+	must(t, err)
+	if revoked.ID != first {
+		t.Fatalf("RevokeRefreshToken = %+v", revoked)
+	}
+	// Один токен нельзя обменять дважды
+	_, err = b.Tokens.RevokeRefreshToken(ctx, hashes[0])
+	wantNoRows(t, err)
+	must(t, b.Tokens.MarkReplaced(ctx, first, second))
+	if tok, err := b.Tokens.GetRefreshToken(ctx, hashes[0]); err != nil || tok.RevokedAt == nil {
+		t.Fatalf("revoked token = %+v, %v", tok, err)
+	}
+
+	must(t, b.Tokens.RevokeSessionRefreshTokens(ctx, session.ID))
+	_, err = b.Tokens.RevokeRefreshToken(ctx, hashes[1])
+	wantNoRows(t, err)
+
+	latest := random()
+	_, err = b.Tokens.CreateRefreshToken(ctx, userID, session.ID, latest, expires)
+	must(t, err)
+	must(t, b.Tokens.RevokeAllRefreshTokens(ctx, userID))
+	_, err = b.Tokens.RevokeRefreshToken(ctx, latest)
+	wantNoRows(t, err)
+
+	jti := random()
+	if ok, err := b.Tokens.IsAccessTokenRevoked(ctx, jti); err != nil || ok {
+		t.Fatalf("IsAccessTokenRevoked before revoke = %v, %v", ok, err)
+	}
+	must(t, b.Tokens.RevokeAccessToken(ctx, jti, expires))
+	must(t, b.Tokens.RevokeAccessToken(ctx, jti, expires))
+	if ok, err := b.Tokens.IsAccessTokenRevoked(ctx, jti); err != nil || !ok {
+		t.Fatalf("IsAccessTokenRevoked = %v, %v", ok, err)
+	}
+
+	must(t, b.Tokens.DeleteExpired(ctx, time.Now()))
+	_, err = b.Tokens.GetRefreshToken(ctx, hashes[2])
+	wantNoRows(t, err)
+	if _, err := b.Tokens.GetRefreshToken(ctx, hashes[1]); err != nil {
+		t.Fatalf("DeleteExpired removed a valid token: %v", err)
+	}
+}
+
+func testSessions(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	userID := createUser(t, b)
+	if known, has, err := b.Sessions.KnownDevice(ctx, userID, "d1"); err != nil || known || has {
+		t.Fatalf("KnownDevice without sessions = %v, %v, %v", known, has, err)
+	}
+
+	phone := &models.Session{UserID: userID, DeviceName: "phone", DeviceHash: "d1", IP: "10.0.0.1", UserAgent: "app"}
+	laptop := &models.Session{UserID: userID, DeviceName: "laptop", DeviceHash: "d2"}
+	must(t, b.Sessions.Create(ctx, phone))
+	must(t, b.Sessions.Create(ctx, laptop))
+	if phone.ID == "" || phone.CreatedAt.IsZero() || phone.LastSeenAt.IsZero() {
+		t.Fatalf("Create did not fill the session: %+v", phone)
+	}
+	if known, has, err := b.Sessions.KnownDevice(ctx, userID, "d3"); err != nil || known || !has {
+		t.Fatalf("KnownDevice for new device = %v, %v, %v", known, has, err)
+	}
+
+	must(t, b.Sessions.Touch(ctx, phone.ID, "", time.Now().Add(time.Minute)))
+	active, err := b.Sessions.ListActive(ctx, userID)
+	must(t, err)
+	if len(active) != 2 || active[0].ID != phone.ID || active[0].IP != "10.0.0.1" {
+		t.Fatalf("ListActive = %+v, want recently seen first with kept IP", active)
+	}
+	must(t, b.Sessions.Touch(ctx, phone.ID, "10.0.0.2", time.Now().Add(2*time.Minute)))
+	if s, err := b.Sessions.GetByID(ctx, phone.ID); err != nil || s.IP != "10.0.0.2" || s.DeviceName != "phone" {
+		t.Fatalf("GetByID after Touch = %+v, %v", s, err)
+	}
+
+	if ok, err := b.Sessions.Revoke(ctx, createUser(t, b), phone.ID); err != nil || ok {
+		t.Fatalf("Revoke by another user = %v, %v", ok, err)
+	}
+	if ok, err := b.Sessions.Revoke(ctx, userID, phone.ID); err != nil || !ok {
+		t.Fatalf("Revoke = %v, %v", ok, err)
+	}
+	if ok, err := b.Sessions.Revoke(ctx, userID, phone.ID); err != nil || ok {
+		t.Fatalf("second Revoke = %v, %v", ok, err)
+	}
+	if s, err := b.Sessions.GetByID(ctx, phone.ID); err != nil || s.RevokedAt == nil {
+		t.Fatalf("GetByID of revoked session = %+v, %v", s, err)
+	}
+	// Завершённая сессия по-прежнему делает устройство известным
+	if known, _, err := b.Sessions.KnownDevice(ctx, userID, "d1"); err != nil || !known {
+		t.Fatalf("KnownDevice after revoke = %v, %v", known, err)
+	}
+
+	must(t, b.Sessions.RevokeAll(ctx, userID))
+	active, err = b.Sessions.ListActive(ctx, userID)
+	must(t, err)
+	if active == nil || len(active) != 0 {
+		t.Fatalf("ListActive after RevokeAll = %#v, want empty slice", active)
+	}
+	must(t, b.Sessions.DeleteStale(ctx, time.Now().Add(time.Hour)))
+	_, err = b.Sessions.GetByID(ctx, laptop.ID)
+	wantNoRows(t, err)
+}
+
+func testRecoveryCodes(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	userID := createUser(t, b)
+	use := func(code string, want bool) {
+		t.Helper()
+		if ok, err := b.RecoveryCodes.UseCode(ctx, userID, code); err != nil || ok != want {
+			t.Fatalf("UseCode(%q) = %v, %v, want %v", code, ok, err, want)
+		}
+	}
+
+	must(t, b.RecoveryCodes.ReplaceCodes(ctx, userID, []string{"a", "b"}))
+	use("a", true)
+	use("a", false)
+	if ok, err := b.RecoveryCodes.UseCode(ctx, createUser(t, b), "b"); err != nil || ok {
+		t.Fatalf("UseCode of another user = %v, %v", ok, err)
+	}
+
+	must(t, b.RecoveryCodes.ReplaceCodes(ctx, userID, []string{"c"}))
+	use("b", false)
+	must(t, b.RecoveryCodes.DeleteCodes(ctx, userID))
+	use("c", false)
+}
+
+func testLoginAttempts(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	key := "email:" + random()
+	_, err := b.LoginAttempts.Get(ctx, key)
+	wantNoRows(t, err)
+
+	// Время усекается до микросекунд, как в столбцах TIMESTAMPTZ
+	now := time.Now().Truncate(time.Microsecond)
+	for want := 1; want <= 3; want++ {
+		a, previousAt, err := b.LoginAttempts.RecordAttempt(ctx, key, now.Add(time.Duration(want)*time.Second), now.Add(-time.Minute))
+		must(t, err)
+		if a.Failures != want || (want == 1) != previousAt.IsZero() {
+			t.Fatalf("RecordAttempt = %+v, previous %v, want %d failures", a, previousAt, want)
+		}
+	}
+	must(t, b.LoginAttempts.Lock(ctx, key, now.Add(time.Hour)))
+	a, err := b.LoginAttempts.Get(ctx, key)
+	must(t, err)
+	if a.Key != key || a.Failures != 3 || a.LockedUntil == nil || a.LastFailureAt.IsZero() {
+		t.Fatalf("Get = %+v", a)
+	}
+
+	// Отменённая попытка не учитывается, а время последней попытки возвращается к предыдущему
+	a, previousAt, err := b.LoginAttempts.RecordAttempt(ctx, key, now.Add(time.Minute), now.Add(-time.Minute))
+	must(t, err)
+	if a.Failures != 4 || a.LockedUntil == nil || !previousAt.Equal(now.Add(3*time.Second)) {
+		t.Fatalf("RecordAttempt = %+v, previous %v", a, previousAt)
+	}
+	must(t, b.LoginAttempts.Release(ctx, key, previousAt))
+	if a, err := b.LoginAttempts.Get(ctx, key); err != nil || a.Failures != 3 || !a.LastFailureAt.Equal(previousAt) {
+		t.Fatalf("Get after Release = %+v, %v", a, err)
+	}
+
+	// Попытка после окна сбрасывает счётчик и снимает блокировку
+	later := now.Add(2 * time.Hour)
+	if a, _, err := b.LoginAttempts.RecordAttempt(ctx, key, later, later.Add(-time.Minute)); err != nil || a.Failures != 1 || a.LockedUntil != nil {
+		t.Fatalf("RecordAttempt after window = %+v, %v, want 1 failure without lock", a, err)
+	}
+
+	must(t, b.LoginAttempts.Reset(ctx, key))
+	_, err = b.LoginAttempts.Get(ctx, key)
+	wantNoRows(t, err)
+
+	locked := "ip:" + random()
+	_, _, err = b.LoginAttempts.RecordAttempt(ctx, locked, now, now)
+	must(t, err)
+	must(t, b.LoginAttempts.Lock(ctx, locked, now.Add(3*time.Hour)))
+	must(t, b.LoginAttempts.DeleteStale(ctx, now.Add(time.Hour)))
+	if _, err := b.LoginAttempts.Get(ctx, locked); err != nil {
+		t.Fatalf("DeleteStale removed an active lock: %v", err)
+	}
+	must(t, b.LoginAttempts.DeleteStale(ctx, now.Add(4*time.Hour)))
+	_, err = b.LoginAttempts.Get(ctx, locked)
+	wantNoRows(t, err)
+}
+
+func testCards(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	accountID := createAccount(t, b, 0)
+	limit := 500.0
+	card := &models.Card{AccountID: accountID, Type: models.CardTypeMerchantLocked, AmountCap: &limit, PANIndex: random()}
+	id, err := b.Cards.CreateCard(ctx, card, "4000001234567899", "12/30", "cvv-hash", "k1", "key-1")
+	must(t, err)
+	if _, err := b.Cards.CreateCard(ctx, &models.Card{AccountID: missingID, Type: models.CardTypeStandard}, "4000001234567899", "12/30", "cvv", "k1", "key-1"); err == nil {
+		t.Fatal("CreateCard for missing account succeeded")
+	}
+
+	got, err := b.Cards.GetByID(ctx, id)
+	must(t, err)
+	if got.AccountID != accountID || got.Type != models.CardTypeMerchantLocked || got.Status != models.CardStatusActive ||
+		got.AmountCap == nil || *got.AmountCap != 500 || got.PINAttempts != 0 {
+		t.Fatalf("GetByID = %+v", got)
+	}
+	if byIndex, err := b.Cards.GetByPANIndex(ctx, card.PANIndex); err != nil || byIndex.ID != id {
+		t.Fatalf("GetByPANIndex = %+v, %v", byIndex, err)
+	}
+	_, err = b.Cards.GetByPANIndex(ctx, random())
+	wantNoRows(t, err)
+	duplicate := &models.Card{AccountID: accountID, Type: models.CardTypeStandard, PANIndex: card.PANIndex}
+	if _, err := b.Cards.CreateCard(ctx, duplicate, "4000001234567899", "12/30", "cvv", "k1", "key-1"); err == nil {
+		t.Fatal("CreateCard with a taken PAN index succeeded")
+	}
+	_, err = b.Cards.GetByID(ctx, missingID)
+	wantNoRows(t, err)
+
+	number, expiry, err := b.Cards.GetCardDetails(ctx, id, keyFor("key-1"))
+	must(t, err)
+	if number != "4000001234567899" || expiry != "12/30" {
+		t.Fatalf("GetCardDetails = %q, %q", number, expiry)
+	}
+	if _, err := b.Cards.GetCardNumber(ctx, id, keyFor("key-2")); err == nil {
+		t.Fatal("GetCardNumber with wrong key succeeded")
+	}
+
+	must(t, b.UnitOfWork.Do(ctx, func(ctx context.Context) error {
+		if _, err := b.Cards.LockByID(ctx, id); err != nil {
+			return err
+		}
+		return b.Cards.LockMerchant(ctx, id, "shop-1")
+	}))
+	// Продавец привязывается только один раз
+	must(t, b.Cards.LockMerchant(ctx, id, "shop-2"))
+	must(t, b.Cards.SetStatus(ctx, id, models.CardStatusDestroyed))
+	if got, err := b.Cards.GetByID(ctx, id); err != nil || got.LockedMerchant != "shop-1" || got.Status != models.CardStatusDestroyed {
+		t.Fatalf("GetByID after updates = %+v, %v", got, err)
+	}
+
+	// Идентификаторы ключей уникальны, чтобы не задеть карты других подтестов
+	oldKeyID, newKeyID := "old-"+random(), "new-"+random()
+	first, second := createCard(t, b, oldKeyID, "old-key"), createCard(t, b, oldKeyID, "old-key")
+	counts, err := b.Cards.CountByKeyID(ctx)
+	must(t, err)
+	if counts[oldKeyID] != 2 {
+		t.Fatalf("CountByKeyID = %v, want 2 cards for %s", counts, oldKeyID)
+	}
+	if _, err := b.Cards.ReencryptBatch(ctx, oldKeyID, "wrong-key", newKeyID, "new-key", 10); err == nil {
+		t.Fatal("ReencryptBatch with wrong key succeeded")
+	}
+	if n, err := b.Cards.ReencryptBatch(ctx, oldKeyID, "old-key", newKeyID, "new-key", 1); err != nil || n != 1 {
+		t.Fatalf("ReencryptBatch = %d, %v, want 1", n, err)
+	}
+	if n, err := b.Cards.ReencryptBatch(ctx, oldKeyID, "old-key", newKeyID, "new-key", 10); err != nil || n != 1 {
+		t.Fatalf("second ReencryptBatch = %d, %v, want 1", n, err)
+	}
+	counts, err = b.Cards.CountByKeyID(ctx)
+	must(t, err)
+	if counts[oldKeyID] != 0 || counts[newKeyID] != 2 {
+		t.Fatalf("CountByKeyID after rotation = %v", counts)
+	}
+	for _, cardID := range []string{first, second} {
+		if number, err := b.Cards.GetCardNumber(ctx, cardID, keyFor("new-key")); err != nil || number != "4000001234567899" {
+			t.Fatalf("GetCardNumber after rotation = %q, %v", number, err)
+		}
+	}
+}
+
+func testCardPIN(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	id := createCard(t, b, "k1", "key-1")
+	must(t, b.Cards.SetPIN(ctx, id, "pin-hash"))
+
+	for want := 1; want <= 2; want++ {
+		attempts, status, err := b.Cards.RecordPINFailure(ctx, id, 3)
+		must(t, err)
+		if attempts != want || status != models.CardStatusActive {
+			t.Fatalf("RecordPINFailure = %d, %q, want %d, active", attempts, status, want)
+		}
+	}
+	must(t, b.Cards.ResetPINAttempts(ctx, id))
+	for want := 1; want <= 3; want++ {
+		attempts, status, err := b.Cards.RecordPINFailure(ctx, id, 3)
+		must(t, err)
+		if want == 3 && (attempts != 3 || status != models.CardStatusBlocked) {
+			t.Fatalf("RecordPINFailure at limit = %d, %q, want blocked", attempts, status)
+		}
+	}
+	got, err := b.Cards.GetByID(ctx, id)
+	must(t, err)
+	if got.PINHash != "pin-hash" || got.PINAttempts != 3 || got.Status != models.CardStatusBlocked {
+		t.Fatalf("GetByID = %+v", got)
+	}
+	_, _, err = b.Cards.RecordPINFailure(ctx, missingID, 3)
+	wantNoRows(t, err)
+}
+
+func testCardLimits(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	id := createCard(t, b, "k1", "key-1")
+	_, err := b.Cards.GetLimits(ctx, id)
+	wantNoRows(t, err)
+
+	limits := &models.CardLimits{CardID: id, DailyLimit: 1000, MonthlyLimit: 5000, PerTransactionLimit: 300, OnlineEnabled: true}
+	must(t, b.Cards.UpsertLimits(ctx, limits))
+	limits.ATMEnabled, limits.DailyLimit = true, 2000
+	must(t, b.Cards.UpsertLimits(ctx, limits))
+	got, err := b.Cards.GetLimits(ctx, id)
+	must(t, err)
+	if got.DailyLimit != 2000 || got.MonthlyLimit != 5000 || got.PerTransactionLimit != 300 ||
+		!got.OnlineEnabled || !got.ATMEnabled || got.ContactlessEnabled || got.ForeignEnabled {
+		t.Fatalf("GetLimits = %+v", got)
+	}
+
+	since := time.Now().Add(-time.Second)
+	for _, amount := range []float64{100, 250.25} {
+		auth := &models.CardAuthorization{CardID: id, Amount: amount, Channel: "online", Merchant: "shop", Country: "RU"}
+		must(t, b.Cards.CreateAuthorization(ctx, auth))
+		if auth.ID == "" || auth.CreatedAt.IsZero() {
+			t.Fatalf("CreateAuthorization did not fill the record: %+v", auth)
+		}
+	}
+	if sum, err := b.Cards.SumAuthorizedSince(ctx, id, since); err != nil || sum != 350.25 {
+		t.Fatalf("SumAuthorizedSince = %v, %v, want 350.25", sum, err)
+	}
+	if sum, err := b.Cards.SumAuthorizedSince(ctx, id, time.Now().Add(time.Hour)); err != nil || sum != 0 {
+		t.Fatalf("SumAuthorizedSince in the future = %v, %v, want 0", sum, err)
+	}
+}
+
+func testSigningKeys(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	now := time.Now()
+	first := &models.SigningKey{ID: "kid-" + random(), Algorithm: "EdDSA", PrivateKeyPEM: "private-1", PublicKeyPEM: "public-1",
+		CreatedAt: now.Add(-time.Hour), ActivatesAt: now.Add(-time.Hour)}
+	if ok, err := b.SigningKeys.Rotate(ctx, first, "k1", "key-1", now, now.Add(-time.Hour)); err != nil || !ok {
+		t.Fatalf("Rotate = %v, %v", ok, err)
+	}
+	second := &models.SigningKey{ID: "kid-" + random(), Algorithm: "EdDSA", PrivateKeyPEM: "private-2", PublicKeyPEM: "public-2",
+		CreatedAt: now, ActivatesAt: now.Add(time.Minute)}
+	retireAt := now.Add(24 * time.Hour)
+	if ok, err := b.SigningKeys.Rotate(ctx, second, "k1", "key-1", retireAt, now.Add(-time.Minute)); err != nil || !ok {
+		t.Fatalf("second Rotate = %v, %v", ok, err)
+	}
+	// Другой экземпляр уже выпустил ключ после rotateBefore
+	third := &models.SigningKey{ID: "kid-" + random(), Algorithm: "EdDSA", PrivateKeyPEM: "p", PublicKeyPEM: "p", CreatedAt: now, ActivatesAt: now}
+	if ok, err := b.SigningKeys.Rotate(ctx, third, "k1", "key-1", retireAt, now.Add(-time.Minute)); err != nil || ok {
+		t.Fatalf("concurrent Rotate = %v, %v, want false", ok, err)
+	}
+
+	keys, err := b.SigningKeys.ListValid(ctx, now, keyFor("key-1"))
+	must(t, err)
+	if len(keys) != 2 || keys[0].ID != second.ID || keys[1].ID != first.ID {
+		t.Fatalf("ListValid = %+v, want newest first", keys)
+	}
+	if keys[0].PrivateKeyPEM != "private-2" || keys[0].ExpiresAt != nil || keys[1].ExpiresAt == nil {
+		t.Fatalf("ListValid = %+v", keys)
+	}
+	if _, err := b.SigningKeys.ListValid(ctx, now, keyFor("key-2")); err == nil {
+		t.Fatal("ListValid with wrong key succeeded")
+	}
+
+	must(t, b.SigningKeys.DeleteExpired(ctx, retireAt.Add(time.Second)))
+	keys, err = b.SigningKeys.ListValid(ctx, now, keyFor("key-1"))
+	must(t, err)
+	if len(keys) != 1 || keys[0].ID != second.ID {
+		t.Fatalf("ListValid after DeleteExpired = %+v", keys)
+	}
+
+	rotated := &models.SigningKey{ID: "kid-" + random(), Algorithm: "EdDSA", PrivateKeyPEM: "private-3", PublicKeyPEM: "public-3",
+		CreatedAt: now, ActivatesAt: now}
+	oldKeyID, newKeyID := "old-"+random(), "new-"+random()
+	if ok, err := b.SigningKeys.Rotate(ctx, rotated, oldKeyID, "old-key", now.Add(48*time.Hour), now.Add(time.Minute)); err != nil || !ok {
+		t.Fatalf("Rotate under old key = %v, %v", ok, err)
+	}
+	counts, err := b.SigningKeys.CountByKeyID(ctx)
+	must(t, err)
+	if counts[oldKeyID] != 1 {
+		t.Fatalf("CountByKeyID = %v, want 1 key for %s", counts, oldKeyID)
+	}
+	if _, err := b.SigningKeys.ReencryptBatch(ctx, oldKeyID, "wrong-key", newKeyID, "new-key", 10); err == nil {
+		t.Fatal("ReencryptBatch with wrong key succeeded")
+	}
+	if n, err := b.SigningKeys.ReencryptBatch(ctx, oldKeyID, "old-key", newKeyID, "new-key", 10); err != nil || n != 1 {
+		t.Fatalf("ReencryptBatch = %d, %v, want 1", n, err)
+	}
+	counts, err = b.SigningKeys.CountByKeyID(ctx)
+	must(t, err)
+	if counts[oldKeyID] != 0 || counts[newKeyID] != 1 {
+		t.Fatalf("CountByKeyID after rotation = %v", counts)
+	}
+}
+
+func testAdminActions(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	actorID := createUser(t, b)
+	target := createAccount(t, b, 0)
+	amount := 10.5
+	for _, action := range []string{models.AdminActionFreeze, models.AdminActionAdjustBalance, models.AdminActionUnfreeze} {
+		a := &models.AdminAction{ActorID: actorID, Action: action, TargetType: "account", TargetID: target, ReasonCode: "fraud"}
+		if action == models.AdminActionAdjustBalance {
+			a.Amount = &amount
+		}
+		must(t, b.AdminActions.Create(ctx, a))
+		if a.ID == "" || a.CreatedAt.IsZero() {
+			t.Fatalf("Create did not fill the record: %+v", a)
+		}
+	}
+	if err := b.AdminActions.Create(ctx, &models.AdminAction{ActorID: missingID, Action: "x", TargetType: "account", TargetID: target, ReasonCode: "x"}); err == nil {
+		t.Fatal("Create with missing actor succeeded")
+	}
+
+	actions, err := b.AdminActions.List(ctx, target, 2, 0)
+	must(t, err)
+	if len(actions) != 2 || actions[0].Action != models.AdminActionUnfreeze || actions[1].Amount == nil {
+		t.Fatalf("List = %+v, want newest first", actions)
+	}
+	actions, err = b.AdminActions.List(ctx, target, 10, 2)
+	must(t, err)
+	if len(actions) != 1 || actions[0].Action != models.AdminActionFreeze || actions[0].ActorID != actorID {
+		t.Fatalf("List with offset = %+v", actions)
+	}
+	actions, err = b.AdminActions.List(ctx, missingID, 10, 0)
+	must(t, err)
+	if actions == nil || len(actions) != 0 {
+		t.Fatalf("List of another target = %#v, want empty slice", actions)
+	}
+}
+
+func testAPIKeys(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	userID := createUser(t, b)
+	must(t, b.Users.SetRole(ctx, userID, models.RoleOperator))
+	now := time.Now()
+	key := &models.APIKey{UserID: userID, Name: "ci", Prefix: "bk_123", KeyHash: random(),
+		Scopes: []string{models.ScopeAccountsRead}, ExpiresAt: now.Add(time.Hour)}
+	must(t, b.APIKeys.Create(ctx, key))
+	if key.ID == "" || key.CreatedAt.IsZero() {
+		t.Fatalf("Create did not fill the key: %+v", key)
+	}
+	expired := &models.APIKey{UserID: userID, Name: "old", Prefix: "bk_456", KeyHash: random(),
+		Scopes: []string{models.ScopeAccountsRead}, AllowedIPs: []string{"10.0.0.0/8"}, ExpiresAt: now.Add(-time.Hour)}
+	must(t, b.APIKeys.Create(ctx, expired))
+	if err := b.APIKeys.Create(ctx, &models.APIKey{UserID: userID, KeyHash: key.KeyHash, Scopes: []string{}, ExpiresAt: now}); err == nil {
+		t.Fatal("Create with duplicate hash succeeded")
+	}
+
+	got, err := b.APIKeys.GetActiveByHash(ctx, key.KeyHash)
+	must(t, err)
+	if got.ID != key.ID || got.Role != models.RoleOperator || !sameSet(got.Scopes, key.Scopes) || len(got.AllowedIPs) != 0 {
+		t.Fatalf("GetActiveByHash = %+v", got)
+	}
+	if n, err := b.APIKeys.CountActive(ctx, userID, now); err != nil || n != 1 {
+		t.Fatalf("CountActive = %d, %v, want 1", n, err)
+	}
+
+	must(t, b.APIKeys.TouchLastUsed(ctx, key.ID, "10.0.0.1", now))
+	keys, err := b.APIKeys.ListByUserID(ctx, userID)
+	must(t, err)
+	if len(keys) != 2 || keys[0].ID != expired.ID || !sameSet(keys[0].AllowedIPs, expired.AllowedIPs) {
+		t.Fatalf("ListByUserID = %+v, want newest first", keys)
+	}
+	if keys[1].LastUsedAt == nil || keys[1].LastUsedIP != "10.0.0.1" {
+		t.Fatalf("key after TouchLastUsed = %+v", keys[1])
+	}
+
+	if ok, err := b.APIKeys.Revoke(ctx, createUser(t, b), key.ID); err != nil || ok {
+		t.Fatalf("Revoke by another user = %v, %v", ok, err)
+	}
+	if ok, err := b.APIKeys.Revoke(ctx, userID, key.ID); err != nil || !ok {
+		t.Fatalf("Revoke = %v, %v", ok, err)
+	}
+	_, err = b.APIKeys.GetActiveByHash(ctx, key.KeyHash)
+	wantNoRows(t, err)
+	if n, err := b.APIKeys.CountActive(ctx, userID, now); err != nil || n != 0 {
+		t.Fatalf("CountActive after revoke = %d, %v, want 0", n, err)
+	}
+
+	// RevokeAll затрагивает только ключи указанного пользователя
+	otherID := createUser(t, b)
+	other := &models.APIKey{UserID: otherID, Name: "other", Prefix: "bk_789", KeyHash: random(),
+		Scopes: []string{models.ScopeAccountsRead}, ExpiresAt: now.Add(time.Hour)}
+	must(t, b.APIKeys.Create(ctx, other))
+	second := &models.APIKey{UserID: userID, Name: "ci-2", Prefix: "bk_012", KeyHash: random(),
+		Scopes: []string{models.ScopeAccountsRead}, ExpiresAt: now.Add(time.Hour)}
+	must(t, b.APIKeys.Create(ctx, second))
+	must(t, b.APIKeys.RevokeAll(ctx, userID))
+	_, err = b.APIKeys.GetActiveByHash(ctx, second.KeyHash)
+	wantNoRows(t, err)
+	if _, err := b.APIKeys.GetActiveByHash(ctx, other.KeyHash); err != nil {
+		t.Fatalf("GetActiveByHash of another user's key = %v", err)
+	}
+}
+
+func testOAuth(t *testing.T, b *Backend) {
+	ctx := context.Background()
+	userID := createUser(t, b)
+	client := &models.OAuthClient{ID: "client-" + random(), Name: "Budget app", SecretHash: "secret",
+		RedirectURIs: []string{"https://app.example.com/cb"}, Scopes: []string{models.ScopeAccountsRead, models.ScopeTransactionsRead},
+		GrantTypes: []string{models.GrantAuthorizationCode}}
+	must(t, b.OAuth.CreateClient(ctx, client))
+	if err := b.OAuth.CreateClient(ctx, client); err == nil {
+		t.Fatal("CreateClient with duplicate id succeeded")
+	}
+	public := &models.OAuthClient{ID: "client-" + random(), Name: "Mobile", RedirectURIs: []string{"app://cb"},
+		Scopes: []string{models.ScopeAccountsRead}, GrantTypes: []string{models.GrantAuthorizationCode}}
+	must(t, b.OAuth.CreateClient(ctx, public))
+
+	got, err := b.OAuth.GetClient(ctx, client.ID)
+	must(t, err)
+	if !got.Confidential || got.SecretHash != "secret" || !sameSet(got.Scopes, client.Scopes) || !sameSet(got.GrantTypes, client.GrantTypes) {
+		t.Fatalf("GetClient = %+v", got)
+	}
+	if got, err := b.OAuth.GetClient(ctx, public.ID); err != nil || got.Confidential || got.SecretHash != "" {
+		t.Fatalf("GetClient of public client = %+v, %v", got, err)
+	}
+	_, err = b.OAuth.GetClient(ctx, "missing-"+random())
+	wantNoRows(t, err)
+
+	_, err = b.OAuth.GetConsent(ctx, userID, client.ID)
+	wantNoRows(t, err)
+	must(t, b.OAuth.SaveConsent(ctx, userID, client.ID, []string{models.ScopeAccountsRead}))
+	must(t, b.OAuth.SaveConsent(ctx, userID, client.ID, []string{models.ScopeAccountsRead, models.ScopeTransactionsRead}))
+	consent, err := b.OAuth.GetConsent(ctx, userID, client.ID)
+	must(t, err)
+	if consent.ClientName != "Budget app" || !sameSet(consent.Scopes, client.Scopes) {
+		t.Fatalf("GetConsent = %+v, want merged scopes", consent)
+	}
+	must(t, b.OAuth.SaveConsent(ctx, userID, public.ID, []string{models.ScopeAccountsRead}))
+	consents, err := b.OAuth.ListConsents(ctx, userID)
+	must(t, err)
+	if len(consents) != 2 {
+		t.Fatalf("ListConsents = %+v", consents)
+	}
+	if ok, err := b.OAuth.DeleteConsent(ctx, userID, public.ID); err != nil || !ok {
+		t.Fatalf("DeleteConsent = %v, %v", ok, err)
+	}
+	if ok, err := b.OAuth.DeleteConsent(ctx, userID, public.ID); err != nil || ok {
+		t.Fatalf("second DeleteConsent = %v, %v", ok, err)
+	}
+
+	now := time.Now()
+	code := &models.OAuthCode{CodeHash: random(), ClientID: client.ID, UserID: userID, RedirectURI: "https://app.example.com/cb",
+		Scopes: []string{models.ScopeAccountsRead}, CodeChallenge: "challenge", ExpiresAt: now.Add(time.Minute)}
+	must(t, b.OAuth.CreateCode(ctx, code))
+	expired := *code
+	expired.CodeHash, expired.ExpiresAt = random(), now.Add(-time.Minute)
+	must(t, b.OAuth.CreateCode(ctx, &expired))
+
+	used, err := b.OAuth.UseCode(ctx, code.CodeHash, now)
+	must(t, err)
+	if used.UserID != userID || used.ClientID != client.ID || used.CodeChallenge != "challenge" || !sameSet(used.Scopes, code.Scopes) {
+		t.Fatalf("UseCode = %+v", used)
+	}
+	// Код одноразовый
+	_, err = b.OAuth.UseCode(ctx, code.CodeHash, now)
+	wantNoRows(t, err)
+	_, err = b.OAuth.UseCode(ctx, expired.CodeHash, now)
+	wantNoRows(t, err)
+	must(t, b.OAuth.DeleteExpiredCodes(ctx, now))
+}
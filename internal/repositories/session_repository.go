@@ -0,0 +1,99 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"go_project/internal/models"
+	"time"
+)
+
+type SessionRepository struct {
+	DB *sql.DB
+}
+
+func NewSessionRepository(db *sql.DB) *SessionRepository {
+	return &SessionRepository{DB: db}
+}
+
+func (r *SessionRepository) Create(ctx context.Context, s *models.Session) error {
+	return conn(ctx, r.DB).QueryRowContext(ctx, `INSERT INTO sessions (user_id, device_name, device_hash, ip, user_agent)
+                                VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at, last_seen_at`,
+		s.UserID, s.DeviceName, s.DeviceHash, s.IP, s.UserAgent).
+		Scan(&s.ID, &s.CreatedAt, &s.LastSeenAt)
+}
+
+// GetByID возвращает сессию, в том числе завершённую, или sql.ErrNoRows.
+func (r *SessionRepository) GetByID(ctx context.Context, sessionID string) (*models.Session, error) {
+	var s models.Session
+	var revokedAt sql.NullTime
+	err := conn(ctx, r.DB).QueryRowContext(ctx, `SELECT id, user_id, device_name, device_hash, ip, user_agent, created_at, last_seen_at, revoked_at
+                                FROM sessions WHERE id = $1`, sessionID).
+		Scan(&s.ID, &s.UserID, &s.DeviceName, &s.DeviceHash, &s.IP, &s.UserAgent, &s.CreatedAt, &s.LastSeenAt, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+	if revokedAt.Valid {
+		s.RevokedAt = &revokedAt.Time
+	}
+	return &s, nil
+}
+
+// KnownDevice сообщает, входил ли пользователь раньше с устройства deviceHash и был ли у него хоть один вход.
+func (r *SessionRepository) KnownDevice(ctx context.Context, userID, deviceHash string) (known bool, hasSessions bool, err error) {
+	err = conn(ctx, r.DB).QueryRowContext(ctx, `SELECT COALESCE(BOOL_OR(device_hash = $2), FALSE), COUNT(*) > 0
+                               FROM sessions WHERE user_id = $1`, userID, deviceHash).Scan(&known, &hasSessions)
+	return
+}
+
+// ListActive возвращает незавершённые сессии пользователя, недавно активные первыми.
+func (r *SessionRepository) ListActive(ctx context.Context, userID string) ([]models.Session, error) {
+	rows, err := conn(ctx, r.DB).QueryContext(ctx, `SELECT id, user_id, device_name, ip, user_agent, created_at, last_seen_at
+                               FROM sessions WHERE user_id = $1 AND revoked_at IS NULL
+                               ORDER BY last_seen_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	sessions := []models.Session{}
+	for rows.Next() {
+		var s models.Session
+		if err := rows.Scan(&s.ID, &s.UserID, &s.DeviceName, &s.IP, &s.UserAgent, &s.CreatedAt, &s.LastSeenAt); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// Touch обновляет время последней активности и IP-адрес сессии.
+func (r *SessionRepository) Touch(ctx context.Context, sessionID, ip string, now time.Time) error {
+	_, err := conn(ctx, r.DB).ExecContext(ctx, `UPDATE sessions SET last_seen_at = $2, ip = CASE WHEN $3 = '' THEN ip ELSE $3 END
+                               WHERE id = $1`, sessionID, now, ip)
+	return err
+}
+
+// Revoke завершает сессию пользователя. Возвращает false, если активной сессии с таким id нет.
+func (r *SessionRepository) Revoke(ctx context.Context, userID, sessionID string) (bool, error) {
+	res, err := conn(ctx, r.DB).ExecContext(ctx, `UPDATE sessions SET revoked_at = NOW()
+                               WHERE id::text = $1 AND user_id = $2 AND revoked_at IS NULL`, sessionID, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (r *SessionRepository) RevokeAll(ctx context.Context, userID string) error {
+	_, err := conn(ctx, r.DB).ExecContext(ctx, `UPDATE sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`, userID)
+	return err
+}
+
+// DeleteStale удаляет сессии, неактивные с момента before. Запись о завершённой сессии
+// сохраняется столько же, чтобы устройство не считалось новым при следующем входе.
+func (r *SessionRepository) DeleteStale(ctx context.Context, before time.Time) error {
+	_, err := conn(ctx, r.DB).ExecContext(ctx, `DELETE FROM sessions WHERE last_seen_at < $1`, before)
+	return err
+}
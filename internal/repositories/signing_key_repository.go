@@ -0,0 +1,129 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"go_project/internal/models"
+	"time"
+)
+
+type SigningKeyRepository struct {
+	DB *sql.DB
+}
+
+func NewSigningKeyRepository(db *sql.DB) *SigningKeyRepository {
+	return &SigningKeyRepository{DB: db}
+}
+
+// ListValid возвращает ключи, срок публикации которых не истёк, от новых к старым.
+// Закрытые ключи расшифровываются ключом, которым были зашифрованы.
+func (r *SigningKeyRepository) ListValid(ctx context.Context, now time.Time, keyFor func(keyID string) (string, error)) ([]models.SigningKey, error) {
+	rows, err := conn(ctx, r.DB).QueryContext(ctx, `SELECT kid, algorithm, private_key_encrypted, key_id, public_key, created_at, activates_at, expires_at
+                                FROM jwt_signing_keys
+                                WHERE expires_at IS NULL OR expires_at > $1
+                                ORDER BY activates_at DESC`, now)
+	if err != nil {
+		return nil, err
+	}
+	type encryptedKey struct {
+		key       models.SigningKey
+		encrypted []byte
+		keyID     string
+	}
+	var encrypted []encryptedKey
+	for rows.Next() {
+		var k encryptedKey
+		var expiresAt sql.NullTime
+		if err := rows.Scan(&k.key.ID, &k.key.Algorithm, &k.encrypted, &k.keyID, &k.key.PublicKeyPEM,
+			&k.key.CreatedAt, &k.key.ActivatesAt, &expiresAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if expiresAt.Valid {
+			k.key.ExpiresAt = &expiresAt.Time
+		}
+		encrypted = append(encrypted, k)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	keys := make([]models.SigningKey, 0, len(encrypted))
+	for _, k := range encrypted {
+		key, err := keyFor(k.keyID)
+		if err != nil {
+			return nil, err
+		}
+		if err := conn(ctx, r.DB).QueryRowContext(ctx, `SELECT pgp_sym_decrypt($1::bytea, $2)`, k.encrypted, key).Scan(&k.key.PrivateKeyPEM); err != nil {
+			return nil, err
+		}
+		keys = append(keys, k.key)
+	}
+	return keys, nil
+}
+
+// Rotate добавляет новый ключ подписи и назначает срок публикации всем предыдущим ключам.
+// Рекомендательная блокировка не даёт нескольким экземплярам сервиса выпустить ключи одновременно;
+// если блокировку получить не удалось, возвращается false.
+func (r *SigningKeyRepository) Rotate(ctx context.Context, key *models.SigningKey, keyID, encryptionKey string, retireAt time.Time, rotateBefore time.Time) (bool, error) {
+	var rotated bool
+	err := NewTxManager(r.DB).Do(ctx, func(ctx context.Context) error {
+		q := conn(ctx, r.DB)
+		var locked bool
+		if err := q.QueryRowContext(ctx, `SELECT pg_try_advisory_xact_lock(hashtext('jwt_signing_keys'))`).Scan(&locked); err != nil {
+			return err
+		}
+		if !locked {
+			return nil
+		}
+		// Другой экземпляр мог уже выпустить ключ, пока мы ждали
+		var fresh bool
+		err := q.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM jwt_signing_keys WHERE created_at >= $1)`, rotateBefore).Scan(&fresh)
+		if err != nil {
+			return err
+		}
+		if fresh {
+			return nil
+		}
+		if _, err := q.ExecContext(ctx, `UPDATE jwt_signing_keys SET expires_at = $1 WHERE expires_at IS NULL`, retireAt); err != nil {
+			return err
+		}
+		_, err = q.ExecContext(ctx, `INSERT INTO jwt_signing_keys (kid, algorithm, private_key_encrypted, key_id, public_key, created_at, activates_at)
+                           VALUES ($1, $2, pgp_sym_encrypt($3, $4, 'cipher-algo=aes256'), $5, $6, $7, $8)`,
+			key.ID, key.Algorithm, key.PrivateKeyPEM, encryptionKey, keyID, key.PublicKeyPEM, key.CreatedAt, key.ActivatesAt)
+		if err != nil {
+			return err
+		}
+		rotated = true
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+	return rotated, nil
+}
+
+// CountByKeyID возвращает количество ключей подписи, зашифрованных каждым из ключей шифрования.
+func (r *SigningKeyRepository) CountByKeyID(ctx context.Context) (map[string]int, error) {
+	return countByKeyID(ctx, conn(ctx, r.DB), `SELECT key_id, COUNT(*) FROM jwt_signing_keys GROUP BY key_id`)
+}
+
+// ReencryptBatch перешифровывает до limit закрытых ключей с ключа fromKeyID на ключ toKeyID одним запросом.
+func (r *SigningKeyRepository) ReencryptBatch(ctx context.Context, fromKeyID, fromKey, toKeyID, toKey string, limit int) (int, error) {
+	res, err := conn(ctx, r.DB).ExecContext(ctx, `UPDATE jwt_signing_keys SET
+                 private_key_encrypted = pgp_sym_encrypt(pgp_sym_decrypt(private_key_encrypted, $2), $4, 'cipher-algo=aes256'),
+                 key_id = $3
+                 WHERE kid IN (SELECT kid FROM jwt_signing_keys WHERE key_id = $1 ORDER BY kid LIMIT $5 FOR UPDATE SKIP LOCKED)`,
+		fromKeyID, fromKey, toKeyID, toKey, limit)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// DeleteExpired удаляет ключи, срок публикации которых истёк.
+func (r *SigningKeyRepository) DeleteExpired(ctx context.Context, now time.Time) error {
+	_, err := conn(ctx, r.DB).ExecContext(ctx, `DELETE FROM jwt_signing_keys WHERE expires_at < $1`, now)
+	return err
+}
@@ -0,0 +1,99 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"go_project/internal/models"
+	"time"
+)
+
+type TokenRepository struct {
+	DB *sql.DB
+}
+
+func NewTokenRepository(db *sql.DB) *TokenRepository {
+	return &TokenRepository{DB: db}
+}
+
+func (r *TokenRepository) CreateRefreshToken(ctx context.Context, userID, sessionID, tokenHash string, expiresAt time.Time) (string, error) {
+	var id string
+	err := conn(ctx, r.DB).QueryRowContext(ctx, `INSERT INTO refresh_tokens (id, user_id, session_id, token_hash, expires_at)
+                                VALUES (gen_random_uuid(), $1, $2, $3, $4) RETURNING id`,
+		userID, sessionID, tokenHash, expiresAt).Scan(&id)
+	if err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+func (r *TokenRepository) GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	var t models.RefreshToken
+	var revokedAt sql.NullTime
+	row := conn(ctx, r.DB).QueryRowContext(ctx, `SELECT id, user_id, COALESCE(session_id::text, ''), token_hash, expires_at, revoked_at
+                                FROM refresh_tokens WHERE token_hash = $1`, tokenHash)
+	if err := row.Scan(&t.ID, &t.UserID, &t.SessionID, &t.TokenHash, &t.ExpiresAt, &revokedAt); err != nil {
+		return nil, err
+	}
+	if revokedAt.Valid {
+		t.RevokedAt = &revokedAt.Time
+	}
+	return &t, nil
+}
+
+// RevokeRefreshToken атомарно отзывает действующий токен обновления и возвращает его.
+// Если токен не найден или уже отозван, возвращается sql.ErrNoRows, поэтому один токен
+// нельзя обменять дважды даже при параллельных запросах.
+func (r *TokenRepository) RevokeRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	var t models.RefreshToken
+	row := conn(ctx, r.DB).QueryRowContext(ctx, `UPDATE refresh_tokens SET revoked_at = NOW()
+                                WHERE token_hash = $1 AND revoked_at IS NULL
+                                RETURNING id, user_id, COALESCE(session_id::text, ''), token_hash, expires_at`, tokenHash)
+	if err := row.Scan(&t.ID, &t.UserID, &t.SessionID, &t.TokenHash, &t.ExpiresAt); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// MarkReplaced связывает отозванный при ротации токен с выданным вместо него.
+func (r *TokenRepository) MarkReplaced(ctx context.Context, tokenID, replacedByID string) error {
+	_, err := conn(ctx, r.DB).ExecContext(ctx, `UPDATE refresh_tokens SET replaced_by = $1 WHERE id = $2`, replacedByID, tokenID)
+	return err
+}
+
+func (r *TokenRepository) RevokeAllRefreshTokens(ctx context.Context, userID string) error {
+	_, err := conn(ctx, r.DB).ExecContext(ctx, `UPDATE refresh_tokens SET revoked_at = NOW()
+                               WHERE user_id = $1 AND revoked_at IS NULL`, userID)
+	return err
+}
+
+// RevokeSessionRefreshTokens отзывает токены обновления, выданные в рамках сессии.
+func (r *TokenRepository) RevokeSessionRefreshTokens(ctx context.Context, sessionID string) error {
+	_, err := conn(ctx, r.DB).ExecContext(ctx, `UPDATE refresh_tokens SET revoked_at = NOW()
+                               WHERE session_id = $1 AND revoked_at IS NULL`, sessionID)
+	return err
+}
+
+// RevokeAccessToken добавляет идентификатор токена доступа в список отозванных до истечения его срока.
+func (r *TokenRepository) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	_, err := conn(ctx, r.DB).ExecContext(ctx, `INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, $2)
+                               ON CONFLICT (jti) DO NOTHING`, jti, expiresAt)
+	return err
+}
+
+func (r *TokenRepository) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	var revoked bool
+	err := conn(ctx, r.DB).QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM revoked_tokens WHERE jti = $1)`, jti).Scan(&revoked)
+	if err != nil {
+		return false, err
+	}
+	return revoked, nil
+}
+
+// DeleteExpired удаляет истёкшие токены обновления и записи об отзыве истёкших токенов доступа.
+func (r *TokenRepository) DeleteExpired(ctx context.Context, now time.Time) error {
+	if _, err := conn(ctx, r.DB).ExecContext(ctx, `DELETE FROM revoked_tokens WHERE expires_at < $1`, now); err != nil {
+		return err
+	}
+	_, err := conn(ctx, r.DB).ExecContext(ctx, `DELETE FROM refresh_tokens WHERE expires_at < $1`, now)
+	return err
+}
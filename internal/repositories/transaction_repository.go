@@ -1,6 +1,10 @@
 package repositories
 
-import "database/sql"
+import (
+	"context"
+	"database/sql"
+	"go_project/internal/models"
+)
 
 type TransactionRepository struct {
 	DB *sql.DB
@@ -10,17 +14,54 @@ func NewTransactionRepository(db *sql.DB) *TransactionRepository {
 	return &TransactionRepository{DB: db}
 }
 
-func (r *TransactionRepository) GetUserStats(userID string) (totalSent float64, countSent int, totalReceived float64, countReceived int, err error) {
+// Create сохраняет запись о переводе и заполняет её идентификатор и время.
+// Пустой идентификатор счёта сохраняется как NULL (зачисление или списание без второй стороны).
+func (r *TransactionRepository) Create(ctx context.Context, t *models.Transaction) error {
+	return conn(ctx, r.DB).QueryRowContext(ctx, `INSERT INTO transactions (id, from_account, to_account, amount, hmac)
+                             VALUES (gen_random_uuid(), $1, $2, $3, $4) RETURNING id, timestamp`,
+		nullableID(t.FromAccountID), nullableID(t.ToAccountID), t.Amount, t.HMAC).Scan(&t.ID, &t.Timestamp)
+}
+
+func nullableID(id string) interface{} {
+	if id == "" {
+		return nil
+	}
+	return id
+}
+
+func (r *TransactionRepository) GetUserStats(ctx context.Context, userID string) (totalSent float64, countSent int, totalReceived float64, countReceived int, err error) {
 	query := `
 	SELECT 
 	COALESCE((SELECT SUM(amount) FROM transactions t JOIN accounts a ON t.from_account = a.id WHERE a.user_id = $1), 0), 
 	(SELECT count(*) FROM transactions t JOIN accounts a ON t.from_account = a.id WHERE a.user_id = $1), 
 	COALESCE((SELECT SUM(amount) FROM transactions t JOIN accounts b ON t.to_account = b.id WHERE b.user_id = $1), 0),
     (SELECT COUNT(*) FROM transactions t JOIN accounts b ON t.to_account = b.id WHERE b.user_id = $1)`
-	row := r.DB.QueryRow(query, userID)
+	row := conn(ctx, r.DB).QueryRowContext(ctx, query, userID)
 	err = row.Scan(&totalSent, &countSent, &totalReceived, &countReceived)
 	if err != nil {
 		return 0, 0, 0, 0, err
 	}
 	return
 }
+
+// ListByUserID возвращает последние операции по счетам пользователя, новые первыми.
+func (r *TransactionRepository) ListByUserID(ctx context.Context, userID string, limit int) ([]models.Transaction, error) {
+	rows, err := conn(ctx, r.DB).QueryContext(ctx, `SELECT t.id, COALESCE(t.from_account::text, ''), COALESCE(t.to_account::text, ''), t.amount, t.timestamp
+                               FROM transactions t
+                               WHERE t.from_account IN (SELECT id FROM accounts WHERE user_id = $1)
+                                  OR t.to_account IN (SELECT id FROM accounts WHERE user_id = $1)
+                               ORDER BY t.timestamp DESC LIMIT $2`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	transactions := []models.Transaction{}
+	for rows.Next() {
+		var t models.Transaction
+		if err := rows.Scan(&t.ID, &t.FromAccountID, &t.ToAccountID, &t.Amount, &t.Timestamp); err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, t)
+	}
+	return transactions, rows.Err()
+}
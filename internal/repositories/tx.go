@@ -0,0 +1,50 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+)
+
+// txKey — ключ контекста, под которым TxManager передаёт открытую транзакцию
+type txKey struct{}
+
+// TxManager — UnitOfWork поверх транзакций PostgreSQL.
+type TxManager struct {
+	DB *sql.DB
+}
+
+func NewTxManager(db *sql.DB) *TxManager {
+	return &TxManager{DB: db}
+}
+
+// Do открывает транзакцию и передаёт её в fn через контекст. Если контекст уже содержит
+// транзакцию, fn выполняется в ней, а фиксирует её внешний вызов Do.
+func (m *TxManager) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// querier — общий набор методов *sql.DB и *sql.Tx
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// conn возвращает транзакцию из контекста, если запрос выполняется внутри TxManager.Do, иначе db.
+func conn(ctx context.Context, db *sql.DB) querier {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return tx
+	}
+	return db
+}
@@ -1,9 +1,11 @@
 package repositories
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"go_project/internal/models"
+	"strings"
 )
 
 type UserRepository struct {
@@ -14,22 +16,22 @@ func NewUserRepository(db *sql.DB) *UserRepository {
 	return &UserRepository{DB: db}
 }
 
-func (r *UserRepository) CreateUser(email, username, passwordHash string) (string, error) {
+func (r *UserRepository) CreateUser(ctx context.Context, email, username, passwordHash string) (string, error) {
 	var newID string
 	query := `INSERT INTO users (id, email, username, password_hash) 
 	VALUES (gen_random_uuid(), $1, $2, $3) RETURNING id`
-	err := r.DB.QueryRow(query, email, username, passwordHash).Scan(&newID)
+	err := conn(ctx, r.DB).QueryRowContext(ctx, query, email, username, passwordHash).Scan(&newID)
 	if err != nil {
 		return "", err
 	}
 	return newID, nil
 }
 
-func (r *UserRepository) GetByEmail(email string) (*models.User, error) {
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*models.User, error) {
 	var u models.User
-	row := r.DB.QueryRow(`SELECT id, email, username, password_hash 
+	row := conn(ctx, r.DB).QueryRowContext(ctx, `SELECT id, email, username, password_hash, totp_enabled, email_verified, role 
 	FROM users WHERE email=$1`, email)
-	if err := row.Scan(&u.ID, &u.Email, &u.Username, &u.PasswordHash); err != nil {
+	if err := row.Scan(&u.ID, &u.Email, &u.Username, &u.PasswordHash, &u.TOTPEnabled, &u.EmailVerified, &u.Role); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, sql.ErrNoRows
 		}
@@ -38,11 +40,11 @@ func (r *UserRepository) GetByEmail(email string) (*models.User, error) {
 	return &u, nil
 }
 
-func (r *UserRepository) GetByUsername(username string) (*models.User, error) {
+func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
 	var u models.User
-	row := r.DB.QueryRow(`SELECT id, email, username, password_hash 
+	row := conn(ctx, r.DB).QueryRowContext(ctx, `SELECT id, email, username, password_hash, totp_enabled, email_verified, role 
 	FROM users WHERE username=$1`, username)
-	if err := row.Scan(&u.ID, &u.Email, &u.Username, &u.PasswordHash); err != nil {
+	if err := row.Scan(&u.ID, &u.Email, &u.Username, &u.PasswordHash, &u.TOTPEnabled, &u.EmailVerified, &u.Role); err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, sql.ErrNoRows
 		}
@@ -50,3 +52,178 @@ func (r *UserRepository) GetByUsername(username string) (*models.User, error) {
 	}
 	return &u, nil
 }
+
+func (r *UserRepository) GetByID(ctx context.Context, userID string) (*models.User, error) {
+	var u models.User
+	row := conn(ctx, r.DB).QueryRowContext(ctx, `SELECT id, email, username, password_hash, totp_enabled, email_verified, role 
+	FROM users WHERE id=$1`, userID)
+	if err := row.Scan(&u.ID, &u.Email, &u.Username, &u.PasswordHash, &u.TOTPEnabled, &u.EmailVerified, &u.Role); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// GetTokenVersion возвращает версию сессий пользователя. Токены доступа с другой версией недействительны.
+func (r *UserRepository) GetTokenVersion(ctx context.Context, userID string) (int, error) {
+	var version int
+	err := conn(ctx, r.DB).QueryRowContext(ctx, `SELECT token_version FROM users WHERE id = $1`, userID).Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// IncrementTokenVersion инвалидирует все выданные пользователю токены доступа.
+func (r *UserRepository) IncrementTokenVersion(ctx context.Context, userID string) error {
+	_, err := conn(ctx, r.DB).ExecContext(ctx, `UPDATE users SET token_version = token_version + 1 WHERE id = $1`, userID)
+	return err
+}
+
+// SetTOTPSecret сохраняет зашифрованный секрет TOTP. Двухфакторная аутентификация включается
+// отдельно после подтверждения кодом, поэтому флаг totp_enabled здесь не меняется.
+func (r *UserRepository) SetTOTPSecret(ctx context.Context, userID, secret, keyID, encryptionKey string) error {
+	_, err := conn(ctx, r.DB).ExecContext(ctx, `UPDATE users SET totp_secret_encrypted = pgp_sym_encrypt($2, $4, 'cipher-algo=aes256'),
+                 totp_key_id = $3 WHERE id = $1`, userID, secret, keyID, encryptionKey)
+	return err
+}
+
+// GetTOTPSecret расшифровывает секрет TOTP пользователя и возвращает его вместе с идентификатором ключа
+// и номером последнего принятого интервала. Если секрет не задан, возвращается sql.ErrNoRows.
+func (r *UserRepository) GetTOTPSecret(ctx context.Context, userID string, keyFor func(keyID string) (string, error)) (string, string, int64, error) {
+	var encrypted []byte
+	var keyID sql.NullString
+	var lastStep int64
+	err := conn(ctx, r.DB).QueryRowContext(ctx, `SELECT totp_secret_encrypted, totp_key_id, totp_last_step FROM users WHERE id = $1`, userID).
+		Scan(&encrypted, &keyID, &lastStep)
+	if err != nil {
+		return "", "", 0, err
+	}
+	if encrypted == nil || !keyID.Valid {
+		return "", "", 0, sql.ErrNoRows
+	}
+	key, err := keyFor(keyID.String)
+	if err != nil {
+		return "", "", 0, err
+	}
+	var secret string
+	if err := conn(ctx, r.DB).QueryRowContext(ctx, `SELECT pgp_sym_decrypt($1::bytea, $2)`, encrypted, key).Scan(&secret); err != nil {
+		return "", "", 0, err
+	}
+	return secret, keyID.String, lastStep, nil
+}
+
+// CountTOTPByKeyID возвращает количество секретов TOTP, зашифрованных каждым из ключей.
+func (r *UserRepository) CountTOTPByKeyID(ctx context.Context) (map[string]int, error) {
+	return countByKeyID(ctx, conn(ctx, r.DB), `SELECT totp_key_id, COUNT(*) FROM users WHERE totp_key_id IS NOT NULL GROUP BY totp_key_id`)
+}
+
+// ReencryptTOTPBatch перешифровывает до limit секретов TOTP с ключа fromKeyID на ключ toKeyID одним запросом.
+func (r *UserRepository) ReencryptTOTPBatch(ctx context.Context, fromKeyID, fromKey, toKeyID, toKey string, limit int) (int, error) {
+	res, err := conn(ctx, r.DB).ExecContext(ctx, `UPDATE users SET
+                 totp_secret_encrypted = pgp_sym_encrypt(pgp_sym_decrypt(totp_secret_encrypted, $2), $4, 'cipher-algo=aes256'),
+                 totp_key_id = $3
+                 WHERE id IN (SELECT id FROM users WHERE totp_key_id = $1 ORDER BY id LIMIT $5 FOR UPDATE SKIP LOCKED)`,
+		fromKeyID, fromKey, toKeyID, toKey, limit)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// UseTOTPStep запоминает интервал принятого кода. Возвращает false, если код этого или более
+// позднего интервала уже использовался, что защищает от повторного предъявления кода.
+func (r *UserRepository) UseTOTPStep(ctx context.Context, userID string, step int64) (bool, error) {
+	res, err := conn(ctx, r.DB).ExecContext(ctx, `UPDATE users SET totp_last_step = $2 WHERE id = $1 AND totp_last_step < $2`, userID, step)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+func (r *UserRepository) SetTOTPEnabled(ctx context.Context, userID string, enabled bool) error {
+	var err error
+	if enabled {
+		_, err = conn(ctx, r.DB).ExecContext(ctx, `UPDATE users SET totp_enabled = TRUE WHERE id = $1`, userID)
+	} else {
+		_, err = conn(ctx, r.DB).ExecContext(ctx, `UPDATE users SET totp_enabled = FALSE, totp_secret_encrypted = NULL,
+                 totp_key_id = NULL, totp_last_step = 0 WHERE id = $1`, userID)
+	}
+	return err
+}
+
+// SetEmailVerified отмечает адрес подтверждённым, если он не изменился с момента выдачи токена.
+func (r *UserRepository) SetEmailVerified(ctx context.Context, userID, email string) (bool, error) {
+	res, err := conn(ctx, r.DB).ExecContext(ctx, `UPDATE users SET email_verified = TRUE WHERE id = $1 AND email = $2`, userID, email)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// UpdatePassword заменяет хеш пароля, если он не изменился с момента проверки токена сброса.
+// Возвращает false, если пароль уже был изменён, поэтому токен сброса нельзя использовать дважды.
+func (r *UserRepository) UpdatePassword(ctx context.Context, userID, oldPasswordHash, newPasswordHash string) (bool, error) {
+	res, err := conn(ctx, r.DB).ExecContext(ctx, `UPDATE users SET password_hash = $3 WHERE id = $1 AND password_hash = $2`,
+		userID, oldPasswordHash, newPasswordHash)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// likeEscaper экранирует спецсимволы шаблона LIKE, чтобы строка поиска сравнивалась буквально.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Search ищет пользователей по идентификатору, email или имени пользователя (без учёта регистра).
+func (r *UserRepository) Search(ctx context.Context, query string, limit int) ([]models.User, error) {
+	rows, err := conn(ctx, r.DB).QueryContext(ctx, `SELECT id, email, username, totp_enabled, email_verified, role
+                                FROM users
+                                WHERE id::text = $1
+                                   OR email ILIKE '%' || $3 || '%' ESCAPE '\'
+                                   OR username ILIKE '%' || $3 || '%' ESCAPE '\'
+                                ORDER BY email LIMIT $2`, query, limit, likeEscaper.Replace(query))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	users := []models.User{}
+	for rows.Next() {
+		var u models.User
+		if err := rows.Scan(&u.ID, &u.Email, &u.Username, &u.TOTPEnabled, &u.EmailVerified, &u.Role); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+// SetRole назначает пользователю роль и инвалидирует выданные ему токены доступа,
+// чтобы новая роль применилась при следующем обновлении токенов. Если пользователя нет,
+// возвращается sql.ErrNoRows.
+func (r *UserRepository) SetRole(ctx context.Context, userID, role string) error {
+	res, err := conn(ctx, r.DB).ExecContext(ctx, `UPDATE users SET role = $2, token_version = token_version + 1 WHERE id = $1`, userID, role)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
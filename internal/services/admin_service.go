@@ -0,0 +1,260 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"go_project/internal/models"
+	"go_project/internal/repositories"
+	"strings"
+)
+
+// AdminService — операции сотрудников банка над данными любых клиентов.
+// Все изменения записываются в журнал admin_actions вместе с кодом причины.
+type AdminService struct {
+	uow             repositories.UnitOfWork
+	userRepo        repositories.Users
+	accountRepo     repositories.Accounts
+	creditRepo      repositories.Credits
+	scheduleRepo    repositories.PaymentSchedules
+	adminActionRepo repositories.AdminActions
+	transactions    *TransactionService
+}
+
+func NewAdminService(uow repositories.UnitOfWork, userRepo repositories.Users, accountRepo repositories.Accounts, creditRepo repositories.Credits, scheduleRepo repositories.PaymentSchedules, adminActionRepo repositories.AdminActions, transactions *TransactionService) *AdminService {
+	return &AdminService{
+		uow:             uow,
+		userRepo:        userRepo,
+		accountRepo:     accountRepo,
+		creditRepo:      creditRepo,
+		scheduleRepo:    scheduleRepo,
+		adminActionRepo: adminActionRepo,
+		transactions:    transactions,
+	}
+}
+
+// ReasonCodes — допустимые коды причин для действий сотрудников
+var ReasonCodes = map[string]bool{
+	"customer_request":  true, // обращение клиента
+	"fraud_suspected":   true, // подозрение на мошенничество
+	"court_order":       true, // решение суда или требование госоргана
+	"compliance_review": true, // проверка службой комплаенса
+	"chargeback":        true, // возврат по спорной операции
+	"error_correction":  true, // исправление ошибки банка
+	"goodwill":          true, // компенсация клиенту
+	"access_management": true, // изменение прав сотрудника
+}
+
+const (
+	userSearchLimit     = 50
+	adminActionsMaxPage = 200
+)
+
+var (
+	ErrInvalidReasonCode = errors.New("invalid or missing reason code")
+	ErrInvalidRole       = errors.New("invalid role")
+	ErrUserNotFound      = errors.New("user not found")
+	ErrAlreadyFrozen     = errors.New("account is already frozen")
+	ErrNotFrozen         = errors.New("account is not frozen")
+	ErrEmptySearchQuery  = errors.New("search query is required")
+)
+
+var validRoles = map[string]bool{
+	models.RoleCustomer: true,
+	models.RoleOperator: true,
+	models.RoleAdmin:    true,
+	models.RoleAuditor:  true,
+}
+
+// SearchUsers ищет клиентов по идентификатору, email или имени пользователя.
+func (s *AdminService) SearchUsers(ctx context.Context, query string) ([]models.User, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, ErrEmptySearchQuery
+	}
+	return s.userRepo.Search(ctx, query, userSearchLimit)
+}
+
+// GetCustomer возвращает данные клиента и список его счетов.
+func (s *AdminService) GetCustomer(ctx context.Context, userID string) (*models.CustomerOverview, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	accounts, err := s.accountRepo.ListByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.CustomerOverview{User: user, Accounts: accounts}, nil
+}
+
+// GetAccount возвращает любой счёт без проверки владельца.
+func (s *AdminService) GetAccount(ctx context.Context, accountID string) (*models.Account, error) {
+	acc, err := s.accountRepo.GetByID(ctx, accountID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrAccountNotFound
+		}
+		return nil, err
+	}
+	return acc, nil
+}
+
+// GetCredit возвращает любой кредит вместе с графиком платежей.
+func (s *AdminService) GetCredit(ctx context.Context, creditID string) (*models.CreditOverview, error) {
+	credit, err := s.creditRepo.GetById(ctx, creditID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrCreditNotFound
+		}
+		return nil, err
+	}
+	schedule, err := s.scheduleRepo.GetByCreditID(ctx, creditID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.CreditOverview{Credit: credit, Schedule: schedule}, nil
+}
+
+// SetAccountFrozen замораживает или размораживает счёт. Списания с замороженного счёта запрещены.
+func (s *AdminService) SetAccountFrozen(ctx context.Context, actorID, accountID string, frozen bool, reasonCode, comment string) (*models.AdminAction, error) {
+	if !ReasonCodes[reasonCode] {
+		return nil, ErrInvalidReasonCode
+	}
+	action := &models.AdminAction{
+		ActorID:    actorID,
+		Action:     models.AdminActionFreeze,
+		TargetType: "account",
+		TargetID:   accountID,
+		ReasonCode: reasonCode,
+		Comment:    comment,
+	}
+	if !frozen {
+		action.Action = models.AdminActionUnfreeze
+	}
+	err := s.withAccountTx(ctx, accountID, func(ctx context.Context, acc *models.Account) error {
+		if acc.Frozen == frozen {
+			if frozen {
+				return ErrAlreadyFrozen
+			}
+			return ErrNotFrozen
+		}
+		if err := s.accountRepo.SetFrozen(ctx, accountID, frozen); err != nil {
+			return err
+		}
+		return s.adminActionRepo.Create(ctx, action)
+	})
+	if err != nil {
+		return nil, err
+	}
+	logrus.WithContext(ctx).Warnf("Account %s %s by %s (reason: %s)", accountID, action.Action, actorID, reasonCode)
+	return action, nil
+}
+
+// AdjustBalance зачисляет (amount > 0) или списывает (amount < 0) средства со счёта.
+// Корректировка сохраняется как операция без второй стороны и записывается в журнал.
+func (s *AdminService) AdjustBalance(ctx context.Context, actorID, accountID string, amount float64, reasonCode, comment string) (*models.AdminAction, error) {
+	if !ReasonCodes[reasonCode] {
+		return nil, ErrInvalidReasonCode
+	}
+	if amount == 0 {
+		return nil, ErrInvalidAmount
+	}
+	action := &models.AdminAction{
+		ActorID:    actorID,
+		Action:     models.AdminActionAdjustBalance,
+		TargetType: "account",
+		TargetID:   accountID,
+		ReasonCode: reasonCode,
+		Comment:    comment,
+		Amount:     &amount,
+	}
+	err := s.withAccountTx(ctx, accountID, func(ctx context.Context, acc *models.Account) error {
+		if amount > 0 {
+			if err := s.accountRepo.Credit(ctx, accountID, amount); err != nil {
+				return err
+			}
+			if _, err := s.transactions.recordTransfer(ctx, "", accountID, amount); err != nil {
+				return err
+			}
+		} else {
+			ok, err := s.accountRepo.Debit(ctx, accountID, -amount)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return ErrInsufficientFunds
+			}
+			if _, err := s.transactions.recordTransfer(ctx, accountID, "", -amount); err != nil {
+				return err
+			}
+		}
+		return s.adminActionRepo.Create(ctx, action)
+	})
+	if err != nil {
+		return nil, err
+	}
+	logrus.WithContext(ctx).Warnf("Balance of account %s adjusted by %.2f by %s (reason: %s)", accountID, amount, actorID, reasonCode)
+	return action, nil
+}
+
+// SetRole назначает пользователю роль. Выданные ему токены доступа перестают приниматься.
+func (s *AdminService) SetRole(ctx context.Context, actorID, userID, role, reasonCode, comment string) (*models.AdminAction, error) {
+	if !validRoles[role] {
+		return nil, ErrInvalidRole
+	}
+	if !ReasonCodes[reasonCode] {
+		return nil, ErrInvalidReasonCode
+	}
+	action := &models.AdminAction{
+		ActorID:    actorID,
+		Action:     models.AdminActionSetRole,
+		TargetType: "user",
+		TargetID:   userID,
+		ReasonCode: reasonCode,
+		Comment:    role + ": " + comment,
+	}
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.SetRole(ctx, userID, role); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrUserNotFound
+			}
+			return err
+		}
+		return s.adminActionRepo.Create(ctx, action)
+	})
+	if err != nil {
+		return nil, err
+	}
+	logrus.WithContext(ctx).Warnf("User %s was granted role %s by %s", userID, role, actorID)
+	return action, nil
+}
+
+// ListActions возвращает журнал действий сотрудников, при необходимости по одному объекту.
+func (s *AdminService) ListActions(ctx context.Context, targetID string, limit, offset int) ([]models.AdminAction, error) {
+	if limit <= 0 || limit > adminActionsMaxPage {
+		limit = adminActionsMaxPage
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	return s.adminActionRepo.List(ctx, targetID, limit, offset)
+}
+
+// withAccountTx выполняет fn в транзакции с заблокированной строкой счёта.
+func (s *AdminService) withAccountTx(ctx context.Context, accountID string, fn func(ctx context.Context, acc *models.Account) error) error {
+	return s.uow.Do(ctx, func(ctx context.Context) error {
+		acc, err := s.accountRepo.LockByID(ctx, accountID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return ErrAccountNotFound
+			}
+			return err
+		}
+		return fn(ctx, acc)
+	})
+}
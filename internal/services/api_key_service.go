@@ -0,0 +1,172 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"go_project/internal/models"
+	"go_project/internal/repositories"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	// apiKeyPrefix отличает ключи банка от других секретов (например, при поиске утечек в репозиториях)
+	apiKeyPrefix        = "kb_"
+	apiKeyDisplayLength = 11 // kb_ и первые 8 символов ключа
+	defaultAPIKeyDays   = 90
+	maxAPIKeyDays       = 365
+	maxAPIKeysPerUser   = 10
+	apiKeyTouchInterval = time.Minute
+)
+
+// apiKeyScopes — области доступа, которые можно выдать персональному ключу
+var apiKeyScopes = map[string]bool{
+	models.ScopeAccountsRead:     true,
+	models.ScopeTransactionsRead: true,
+	models.ScopePaymentsWrite:    true,
+}
+
+var (
+	ErrInvalidAPIKey  = errors.New("invalid api key")
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrTooManyAPIKeys = errors.New("too many api keys")
+)
+
+type APIKeyService struct {
+	repo repositories.APIKeys
+}
+
+func NewAPIKeyService(repo repositories.APIKeys) *APIKeyService {
+	return &APIKeyService{repo: repo}
+}
+
+// CreateKey создаёт ключ с областями scopes, доступный только с адресов allowedIPs (если список не пуст).
+// Нулевой expiresInDays означает срок по умолчанию — 90 дней. Ключ возвращается один раз.
+func (s *APIKeyService) CreateKey(ctx context.Context, userID, name string, scopes, allowedIPs []string, expiresInDays int) (*models.APIKey, string, error) {
+	verr := &ValidationError{}
+	name = strings.TrimSpace(name)
+	if name == "" || len([]rune(name)) > 100 {
+		verr.addWithParams("name", "invalid_length", "name must be 1-100 characters long", map[string]interface{}{"min": 1, "max": 100})
+	}
+	if len(scopes) == 0 {
+		verr.add("scopes", "required", "at least one scope is required")
+	}
+	for _, scope := range scopes {
+		if !apiKeyScopes[scope] {
+			verr.addWithParams("scopes", "unknown_scope", "unknown scope "+scope, map[string]interface{}{"value": scope})
+		}
+	}
+	for _, ip := range allowedIPs {
+		if _, _, err := net.ParseCIDR(ip); err != nil && net.ParseIP(ip) == nil {
+			verr.addWithParams("allowed_ips", "invalid_ip", ip+" is not an IP address or CIDR subnet", map[string]interface{}{"value": ip})
+		}
+	}
+	if expiresInDays == 0 {
+		expiresInDays = defaultAPIKeyDays
+	}
+	if expiresInDays < 0 || expiresInDays > maxAPIKeyDays {
+		verr.addWithParams("expires_in_days", "out_of_range", "expiry must be between 1 and 365 days",
+			map[string]interface{}{"min": 1, "max": maxAPIKeyDays})
+	}
+	if err := verr.errOrNil(); err != nil {
+		return nil, "", err
+	}
+	now := time.Now()
+	count, err := s.repo.CountActive(ctx, userID, now)
+	if err != nil {
+		return nil, "", err
+	}
+	if count >= maxAPIKeysPerUser {
+		return nil, "", ErrTooManyAPIKeys
+	}
+	secret, err := randomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	key := apiKeyPrefix + secret
+	if allowedIPs == nil {
+		allowedIPs = []string{}
+	}
+	apiKey := &models.APIKey{
+		UserID:     userID,
+		Name:       name,
+		Prefix:     key[:apiKeyDisplayLength],
+		KeyHash:    hashToken(key),
+		Scopes:     scopes,
+		AllowedIPs: allowedIPs,
+		ExpiresAt:  now.AddDate(0, 0, expiresInDays),
+	}
+	if err := s.repo.Create(ctx, apiKey); err != nil {
+		return nil, "", err
+	}
+	logrus.WithContext(ctx).Infof("User %s created API key %s (%s) with scopes %v", userID, apiKey.ID, apiKey.Prefix, scopes)
+	return apiKey, key, nil
+}
+
+func (s *APIKeyService) ListKeys(ctx context.Context, userID string) ([]models.APIKey, error) {
+	return s.repo.ListByUserID(ctx, userID)
+}
+
+func (s *APIKeyService) RevokeKey(ctx context.Context, userID, keyID string) error {
+	ok, err := s.repo.Revoke(ctx, userID, keyID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrAPIKeyNotFound
+	}
+	logrus.WithContext(ctx).Infof("User %s revoked API key %s", userID, keyID)
+	return nil
+}
+
+// AuthenticateAPIKey проверяет ключ из заголовка Authorization: ApiKey: он не отозван, не истёк
+// и используется с разрешённого адреса. Время последнего использования обновляется не чаще раза в минуту.
+func (s *APIKeyService) AuthenticateAPIKey(ctx context.Context, key, ip string) (*models.APIKey, error) {
+	if !strings.HasPrefix(key, apiKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+	apiKey, err := s.repo.GetActiveByHash(ctx, hashToken(key))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if !now.Before(apiKey.ExpiresAt) {
+		return nil, ErrInvalidAPIKey
+	}
+	if !ipAllowed(apiKey.AllowedIPs, ip) {
+		logrus.WithContext(ctx).Warnf("API key %s used from disallowed address %s", apiKey.ID, ip)
+		return nil, ErrInvalidAPIKey
+	}
+	if apiKey.LastUsedAt == nil || now.Sub(*apiKey.LastUsedAt) >= apiKeyTouchInterval || apiKey.LastUsedIP != ip {
+		if err := s.repo.TouchLastUsed(ctx, apiKey.ID, ip, now); err != nil {
+			logrus.WithContext(ctx).Error("Failed to update API key usage: ", err)
+		}
+	}
+	return apiKey, nil
+}
+
+func ipAllowed(allowed []string, ip string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, entry := range allowed {
+		if _, subnet, err := net.ParseCIDR(entry); err == nil {
+			if subnet.Contains(addr) {
+				return true
+			}
+		} else if allowedIP := net.ParseIP(entry); allowedIP != nil && allowedIP.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
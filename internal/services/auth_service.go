@@ -1,36 +1,93 @@
 package services
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
-	"fmt"
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/sirupsen/logrus"
+	"go_project/internal/keyring"
 	"go_project/internal/models"
 	"go_project/internal/repositories"
-	"go_project/internal/utils"
 	"golang.org/x/crypto/bcrypt"
+	"strings"
 	"time"
 )
 
+// Сроки жизни токенов по умолчанию
+const (
+	DefaultAccessTokenTTL  = 15 * time.Minute
+	DefaultRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// Mailer отправляет письма по шаблонам из internal/utils/templates (реализация — utils.Mailer).
+type Mailer interface {
+	SendTemplateEmail(ctx context.Context, to, subject, name string, data interface{}) error
+}
+
 type AuthService struct {
-	userRepo  *repositories.UserRepository
-	jwtSecret string
+	userRepo         repositories.Users
+	tokenRepo        repositories.Tokens
+	recoveryCodeRepo repositories.RecoveryCodes
+	loginAttemptRepo repositories.LoginAttempts
+	sessionRepo      repositories.Sessions
+	apiKeyRepo       repositories.APIKeys
+	keys             *keyring.Keyring
+	jwtKeys          *JWTKeyService
+	passwords        *PasswordPolicy
+	mailer           Mailer
+	publicURL        string
+	accessTokenTTL   time.Duration
+	refreshTokenTTL  time.Duration
 }
 
-func NewAuthService(userRepo *repositories.UserRepository, jwtSecret string) *AuthService {
-	return &AuthService{userRepo: userRepo, jwtSecret: jwtSecret}
+func NewAuthService(userRepo repositories.Users, tokenRepo repositories.Tokens, recoveryCodeRepo repositories.RecoveryCodes, loginAttemptRepo repositories.LoginAttempts, sessionRepo repositories.Sessions, apiKeyRepo repositories.APIKeys, keys *keyring.Keyring, jwtKeys *JWTKeyService, passwords *PasswordPolicy, mailer Mailer, publicURL string, accessTokenTTL, refreshTokenTTL time.Duration) *AuthService {
+	if accessTokenTTL <= 0 {
+		accessTokenTTL = DefaultAccessTokenTTL
+	}
+	if refreshTokenTTL <= 0 {
+		refreshTokenTTL = DefaultRefreshTokenTTL
+	}
+	return &AuthService{
+		userRepo:         userRepo,
+		tokenRepo:        tokenRepo,
+		recoveryCodeRepo: recoveryCodeRepo,
+		loginAttemptRepo: loginAttemptRepo,
+		sessionRepo:      sessionRepo,
+		apiKeyRepo:       apiKeyRepo,
+		keys:             keys,
+		jwtKeys:          jwtKeys,
+		passwords:        passwords,
+		mailer:           mailer,
+		publicURL:        strings.TrimRight(publicURL, "/"),
+		accessTokenTTL:   accessTokenTTL,
+		refreshTokenTTL:  refreshTokenTTL,
+	}
 }
 
 var (
-	ErrEmailInUse    = errors.New("email is already in use")
-	ErrUsernameTaken = errors.New("username is already taken")
+	ErrEmailInUse          = errors.New("email is already in use")
+	ErrUsernameTaken       = errors.New("username is already taken")
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrTokenRevoked        = errors.New("token has been revoked")
 )
 
-func (s *AuthService) RegisterUser(email, username, password string) (*models.User, error) {
-	if user, _ := s.userRepo.GetByEmail(email); user != nil {
+// RegisterUser создаёт пользователя. Некорректные email, имя пользователя или пароль,
+// не соответствующий политике паролей, возвращаются одной ошибкой ValidationError.
+func (s *AuthService) RegisterUser(ctx context.Context, email, username, password string) (*models.User, error) {
+	email = strings.TrimSpace(email)
+	username = strings.TrimSpace(username)
+	if err := s.validateRegistration(email, username, password); err != nil {
+		return nil, err
+	}
+	if user, _ := s.userRepo.GetByEmail(ctx, email); user != nil {
 		return nil, ErrEmailInUse
 	}
-	if user, _ := s.userRepo.GetByUsername(username); user != nil {
+	if user, _ := s.userRepo.GetByUsername(ctx, username); user != nil {
 		return nil, ErrUsernameTaken
 	}
 
@@ -39,40 +96,312 @@ func (s *AuthService) RegisterUser(email, username, password string) (*models.Us
 		return nil, err
 	}
 	passwordHash := string(hash)
-	userID, err := s.userRepo.CreateUser(email, username, passwordHash)
+	userID, err := s.userRepo.CreateUser(ctx, email, username, passwordHash)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &models.User{ID: userID, Email: email, Username: username}
+	// Приветственное письмо содержит ссылку для подтверждения адреса
+	if err := s.sendVerificationEmail(ctx, user, "Welcome to Bank", "welcome.html"); err != nil {
+		logrus.WithContext(ctx).Errorf("Failed to send welcome email to %s: %v", email, err)
+	}
+	logrus.WithContext(ctx).Infof("Registered new user %s (email: %s)", username, email)
+	return user, nil
+}
+
+// LoginUser проверяет email и пароль. Если у пользователя включена двухфакторная аутентификация,
+// вместо токенов возвращается короткоживущий mfa_token для второго шага входа.
+// Попытки учитываются по email и IP-адресу клиента (см. beginLoginAttempt).
+func (s *AuthService) LoginUser(ctx context.Context, email, password string, device models.DeviceInfo) (*models.LoginResult, error) {
+	emailKey, ipKey := emailAttemptKey(email), ""
+	if device.IP != "" {
+		ipKey = ipAttemptKey(device.IP)
+	}
+	attempts, err := s.beginLoginAttempt(ctx, emailKey, ipKey)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		s.cancelLoginAttempt(ctx, attempts)
+		return nil, err
+	}
+	if err != nil || bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		if err := s.loginFailed(ctx, attempts, user); err != nil {
+			return nil, err
+		}
+		return nil, &LoginError{Err: ErrInvalidCredentials, CaptchaRequired: loginCaptchaRequired(attempts)}
+	}
+	s.loginSucceeded(ctx, attempts)
+	if user.TOTPEnabled {
+		mfaToken, err := s.signToken(ctx, jwt.MapClaims{
+			"user_id": user.ID,
+			"typ":     tokenTypeMFA,
+			"exp":     time.Now().Add(mfaTokenTTL).Unix(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		logrus.WithContext(ctx).Infof("User %s passed password check, second factor required", user.Username)
+		return &models.LoginResult{MFARequired: true, MFAToken: mfaToken}, nil
+	}
+	tokens, err := s.issueTokens(ctx, user, device)
 	if err != nil {
 		return nil, err
 	}
+	logrus.WithContext(ctx).Infof("User %s logged in (email: %s)", user.Username, email)
+	return &models.LoginResult{TokenPair: tokens}, nil
+}
 
-	welcomeBody := "Welcome, " + username + "! Thank you for registering."
-	err = utils.SendEmail(email, "Welcome to Bank", welcomeBody)
+// RefreshTokens обменивает токен обновления на новую пару токенов. Использованный токен
+// отзывается; повторное предъявление уже отозванного токена считается признаком кражи,
+// и тогда отзываются все сессии пользователя.
+func (s *AuthService) RefreshTokens(ctx context.Context, refreshToken string, device models.DeviceInfo) (*models.TokenPair, error) {
+	hash := hashToken(refreshToken)
+	old, err := s.tokenRepo.RevokeRefreshToken(ctx, hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		if reused, _ := s.tokenRepo.GetRefreshToken(ctx, hash); reused != nil {
+			logrus.WithContext(ctx).Warnf("Reuse of revoked refresh token detected for user %s, revoking all sessions", reused.UserID)
+			if err := s.LogoutAll(ctx, reused.UserID); err != nil {
+				return nil, err
+			}
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().After(old.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+	sessionID := old.SessionID
+	if sessionID == "" {
+		// Токен выдан до появления сессий: заводим сессию для устройства, с которого он предъявлен
+		user, err := s.userRepo.GetByID(ctx, old.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if sessionID, err = s.startSession(ctx, user, device); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := s.checkSession(ctx, old.UserID, sessionID); err != nil {
+			if errors.Is(err, ErrTokenRevoked) {
+				return nil, ErrInvalidRefreshToken
+			}
+			return nil, err
+		}
+		if err := s.sessionRepo.Touch(ctx, sessionID, device.IP, time.Now()); err != nil {
+			logrus.WithContext(ctx).Error("Failed to update session activity: ", err)
+		}
+	}
+	tokens, newID, err := s.issueTokenPair(ctx, old.UserID, sessionID)
 	if err != nil {
-		logrus.Errorf("Failed to send welcome email to %s: %v", email, err)
+		return nil, err
+	}
+	if err := s.tokenRepo.MarkReplaced(ctx, old.ID, newID); err != nil {
+		logrus.WithContext(ctx).Error("Failed to link rotated refresh token: ", err)
 	}
-	logrus.Infof("Registered new user %s (email: %s)", username, email)
+	return tokens, nil
+}
 
-	user := &models.User{ID: userID, Email: email, Username: username}
-	return user, nil
+// Logout отзывает текущий токен доступа и, если он передан, токен обновления пользователя,
+// а также завершает сессию, к которой привязан токен.
+func (s *AuthService) Logout(ctx context.Context, userID, tokenID, sessionID string, tokenExpiresAt time.Time, refreshToken string) error {
+	if err := s.tokenRepo.RevokeAccessToken(ctx, tokenID, tokenExpiresAt); err != nil {
+		return err
+	}
+	if sessionID != "" {
+		if err := s.RevokeSession(ctx, userID, sessionID); err != nil && !errors.Is(err, ErrSessionNotFound) {
+			return err
+		}
+	}
+	if refreshToken != "" {
+		hash := hashToken(refreshToken)
+		if t, err := s.tokenRepo.GetRefreshToken(ctx, hash); err == nil && t.UserID == userID {
+			if _, err := s.tokenRepo.RevokeRefreshToken(ctx, hash); err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+		}
+	}
+	logrus.WithContext(ctx).Infof("User %s logged out", userID)
+	return nil
+}
+
+// LogoutAll завершает все сессии пользователя: отзывает сессии, токены обновления и личные API-ключи
+// и повышает версию сессий, из-за чего все ранее выданные токены доступа перестают приниматься.
+func (s *AuthService) LogoutAll(ctx context.Context, userID string) error {
+	if err := s.tokenRepo.RevokeAllRefreshTokens(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.sessionRepo.RevokeAll(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.apiKeyRepo.RevokeAll(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.userRepo.IncrementTokenVersion(ctx, userID); err != nil {
+		return err
+	}
+	logrus.WithContext(ctx).Infof("All sessions of user %s were revoked", userID)
+	return nil
+}
+
+// CheckAccessToken проверяет, что токен доступа не отозван, выдан в текущей версии сессий пользователя
+// и его сессия не завершена. Токены без sid выданы до появления сессий и проверяются без неё.
+func (s *AuthService) CheckAccessToken(ctx context.Context, userID, tokenID, sessionID string, version int) error {
+	revoked, err := s.tokenRepo.IsAccessTokenRevoked(ctx, tokenID)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return ErrTokenRevoked
+	}
+	current, err := s.userRepo.GetTokenVersion(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if current != version {
+		return ErrTokenRevoked
+	}
+	if sessionID != "" {
+		return s.checkSession(ctx, userID, sessionID)
+	}
+	return nil
+}
+
+// RunTokenCleanup раз в час удаляет истёкшие токены, устаревшие счётчики попыток входа
+// и давно неактивные сессии, пока не отменён ctx.
+func (s *AuthService) RunTokenCleanup(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := s.tokenRepo.DeleteExpired(ctx, time.Now()); err != nil {
+			logrus.WithContext(ctx).Error("Failed to delete expired tokens: ", err)
+		}
+		if err := s.loginAttemptRepo.DeleteStale(ctx, time.Now().Add(-loginFailureWindow)); err != nil {
+			logrus.WithContext(ctx).Error("Failed to delete stale login attempts: ", err)
+		}
+		if err := s.sessionRepo.DeleteStale(ctx, time.Now().Add(-s.refreshTokenTTL)); err != nil {
+			logrus.WithContext(ctx).Error("Failed to delete stale sessions: ", err)
+		}
+	}
 }
 
-func (s *AuthService) LoginUser(email, password string) (string, error) {
-	user, err := s.userRepo.GetByEmail(email)
+// issueTokens начинает новую сессию для устройства device и выпускает для неё пару токенов.
+func (s *AuthService) issueTokens(ctx context.Context, user *models.User, device models.DeviceInfo) (*models.TokenPair, error) {
+	sessionID, err := s.startSession(ctx, user, device)
+	if err != nil {
+		return nil, err
+	}
+	tokens, _, err := s.issueTokenPair(ctx, user.ID, sessionID)
+	return tokens, err
+}
+
+// issueTokenPair выпускает короткоживущий токен доступа и новый токен обновления в рамках сессии.
+// Возвращает также идентификатор сохранённого токена обновления.
+func (s *AuthService) issueTokenPair(ctx context.Context, userID, sessionID string) (*models.TokenPair, string, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	version, err := s.userRepo.GetTokenVersion(ctx, userID)
 	if err != nil {
-		return "", fmt.Errorf("invalid credentials")
+		return nil, "", err
 	}
-	err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
+	tokenID, err := randomToken(16)
 	if err != nil {
-		return "", fmt.Errorf("invalid credentials")
+		return nil, "", err
 	}
-	claims := jwt.MapClaims{
-		"user_id": user.ID,
-		"exp":     time.Now().Add(24 * time.Hour).Unix(),
+	now := time.Now()
+	accessToken, err := s.signToken(ctx, jwt.MapClaims{
+		"user_id": userID,
+		"typ":     tokenTypeAccess,
+		"jti":     tokenID,
+		"sid":     sessionID,
+		"ver":     version,
+		"role":    user.Role,
+		"iat":     now.Unix(),
+		"exp":     now.Add(s.accessTokenTTL).Unix(),
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+	refreshID, err := s.tokenRepo.CreateRefreshToken(ctx, userID, sessionID, hashToken(refreshToken), now.Add(s.refreshTokenTTL))
+	if err != nil {
+		return nil, "", err
+	}
+	return &models.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.accessTokenTTL.Seconds()),
+	}, refreshID, nil
+}
+
+// Типы выпускаемых JWT (claim typ). Middleware принимает только токены доступа.
+const (
+	tokenTypeAccess = "access"
+	tokenTypeMFA    = "mfa"
+	tokenTypeStepUp = "step_up"
+	tokenTypeVerify = "email_verify"
+	tokenTypeReset  = "password_reset"
+)
+
+// signToken подписывает токен текущим ключом; sub и iat заполняются из user_id и текущего времени.
+func (s *AuthService) signToken(ctx context.Context, claims jwt.MapClaims) (string, error) {
+	if userID, ok := claims["user_id"]; ok {
+		claims["sub"] = userID
+	}
+	if _, ok := claims["iat"]; !ok {
+		claims["iat"] = time.Now().Unix()
 	}
-	tokenObj := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	token, err := tokenObj.SignedString([]byte(s.jwtSecret))
+	return s.jwtKeys.Sign(ctx, claims)
+}
+
+// parseToken проверяет подпись и срок действия токена и его тип, возвращая идентификатор пользователя.
+func (s *AuthService) parseToken(ctx context.Context, tokenString, tokenType string) (string, error) {
+	userID, _, err := s.parseTokenClaims(ctx, tokenString, tokenType)
+	return userID, err
+}
+
+// parseTokenClaims работает как parseToken, но дополнительно возвращает все claims токена.
+func (s *AuthService) parseTokenClaims(ctx context.Context, tokenString, tokenType string) (string, jwt.MapClaims, error) {
+	claims, err := s.jwtKeys.Parse(ctx, tokenString)
 	if err != nil {
+		return "", nil, err
+	}
+	if claims["typ"] != tokenType {
+		return "", nil, errors.New("invalid token type")
+	}
+	userID, ok := claims["user_id"].(string)
+	if !ok || userID == "" {
+		return "", nil, errors.New("invalid token subject")
+	}
+	return userID, claims, nil
+}
+
+// randomToken возвращает n случайных байт в виде base64url-строки.
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
 		return "", err
 	}
-	logrus.Infof("User %s logged in (email: %s)", user.Username, email)
-	return token, nil
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken возвращает SHA-256 хеш токена; токены высокой энтропии не требуют медленного хеширования.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"go_project/internal/keyring"
+	"go_project/internal/models"
+	"go_project/internal/repositories/memory"
+	"golang.org/x/crypto/bcrypt"
+	"testing"
+	"time"
+)
+
+const testPassword = "Correct-Horse-42"
+
+var testDevice = models.DeviceInfo{Name: "test", IP: "10.0.0.1", UserAgent: "go-test"}
+
+type authFixture struct {
+	service  *AuthService
+	users    *memory.UserRepository
+	apiKeys  *memory.APIKeyRepository
+	attempts *memory.LoginAttemptRepository
+	mailer   *testMailer
+	userID   string
+}
+
+// testMailer передаёт в канал имена шаблонов отправленных писем вместо отправки по SMTP.
+type testMailer struct {
+	sent chan string
+}
+
+func (m *testMailer) SendTemplateEmail(ctx context.Context, to, subject, name string, data interface{}) error {
+	m.sent <- name
+	return nil
+}
+
+// waitEmail ждёт письмо по шаблону name: часть писем отправляется из фоновых горутин.
+func (m *testMailer) waitEmail(t *testing.T, name string) {
+	t.Helper()
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case sent := <-m.sent:
+			if sent == name {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("email %s was not sent", name)
+		}
+	}
+}
+
+// newAuthFixture создаёт сервис поверх хранилища в памяти и пользователя с паролем testPassword.
+// Пользователь заводится напрямую в хранилище: RegisterUser отправляет приветственное письмо.
+func newAuthFixture(t *testing.T) *authFixture {
+	t.Helper()
+	store := memory.NewStore()
+	keys, err := keyring.New("k1", map[string]string{"k1": "encryption-key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwtKeys, err := NewJWTKeyService(memory.NewSigningKeyRepository(store), keys, JWTAlgorithmHS256, "jwt-secret", time.Time{}, "bank", "bank-api", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &authFixture{users: memory.NewUserRepository(store), apiKeys: memory.NewAPIKeyRepository(store),
+		attempts: memory.NewLoginAttemptRepository(store), mailer: &testMailer{sent: make(chan string, 100)}}
+	f.service = NewAuthService(f.users, memory.NewTokenRepository(store), memory.NewRecoveryCodeRepository(store),
+		f.attempts, memory.NewSessionRepository(store), f.apiKeys, keys, jwtKeys, NewPasswordPolicy(0, 0, nil), f.mailer, "https://bank.example.com", 0, 0)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.userID, err = f.users.CreateUser(context.Background(), "alice@example.com", "alice", string(hash)); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func (f *authFixture) login(t *testing.T) *models.TokenPair {
+	t.Helper()
+	result, err := f.service.LoginUser(context.Background(), "alice@example.com", testPassword, testDevice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.MFARequired || result.TokenPair == nil {
+		t.Fatalf("LoginUser = %+v, want a token pair", result)
+	}
+	return result.TokenPair
+}
+
+// accessClaims возвращает идентификатор, сессию и версию токена доступа.
+func (f *authFixture) accessClaims(t *testing.T, token string) (jti, sid string, version int) {
+	t.Helper()
+	_, claims, err := f.service.parseTokenClaims(context.Background(), token, tokenTypeAccess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return claims["jti"].(string), claims["sid"].(string), int(claims["ver"].(float64))
+}
+
+func (f *authFixture) checkAccess(t *testing.T, token string) error {
+	t.Helper()
+	jti, sid, version := f.accessClaims(t, token)
+	return f.service.CheckAccessToken(context.Background(), f.userID, jti, sid, version)
+}
+
+func TestRegisterUserRejectsTakenEmail(t *testing.T) {
+	f := newAuthFixture(t)
+	_, err := f.service.RegisterUser(context.Background(), "alice@example.com", "alice2", testPassword)
+	if !errors.Is(err, ErrEmailInUse) {
+		t.Fatalf("RegisterUser err = %v, want %v", err, ErrEmailInUse)
+	}
+	_, err = f.service.RegisterUser(context.Background(), "alice2@example.com", "alice", testPassword)
+	if !errors.Is(err, ErrUsernameTaken) {
+		t.Fatalf("RegisterUser err = %v, want %v", err, ErrUsernameTaken)
+	}
+}
+
+func TestLoginUser(t *testing.T) {
+	f := newAuthFixture(t)
+	_, err := f.service.LoginUser(context.Background(), "alice@example.com", "wrong-password", testDevice)
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("LoginUser with wrong password err = %v, want %v", err, ErrInvalidCredentials)
+	}
+
+	tokens := f.login(t)
+	if err := f.checkAccess(t, tokens.AccessToken); err != nil {
+		t.Fatalf("CheckAccessToken = %v", err)
+	}
+	sessions, err := f.service.ListSessions(context.Background(), f.userID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].IP != testDevice.IP {
+		t.Fatalf("sessions = %+v, want the login session", sessions)
+	}
+}
+
+func TestRefreshTokensRotation(t *testing.T) {
+	f := newAuthFixture(t)
+	first := f.login(t)
+	second, err := f.service.RefreshTokens(context.Background(), first.RefreshToken, testDevice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("refresh token was not rotated")
+	}
+	_, firstSession, _ := f.accessClaims(t, first.AccessToken)
+	if _, sid, _ := f.accessClaims(t, second.AccessToken); sid != firstSession {
+		t.Fatalf("refreshed token session = %s, want %s", sid, firstSession)
+	}
+
+	// Повторное предъявление использованного токена завершает все сессии пользователя
+	if _, err := f.service.RefreshTokens(context.Background(), first.RefreshToken, testDevice); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("reused refresh token err = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+	if _, err := f.service.RefreshTokens(context.Background(), second.RefreshToken, testDevice); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("refresh after reuse err = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+	if err := f.checkAccess(t, second.AccessToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("CheckAccessToken after reuse = %v, want %v", err, ErrTokenRevoked)
+	}
+}
+
+func TestLogout(t *testing.T) {
+	f := newAuthFixture(t)
+	tokens := f.login(t)
+	other := f.login(t)
+	jti, sid, _ := f.accessClaims(t, tokens.AccessToken)
+	if err := f.service.Logout(context.Background(), f.userID, jti, sid, time.Now().Add(time.Hour), tokens.RefreshToken); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.checkAccess(t, tokens.AccessToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("CheckAccessToken after logout = %v, want %v", err, ErrTokenRevoked)
+	}
+	// Другие сессии пользователя продолжают работать
+	if err := f.checkAccess(t, other.AccessToken); err != nil {
+		t.Fatalf("CheckAccessToken of another session = %v", err)
+	}
+	if _, err := f.service.RefreshTokens(context.Background(), tokens.RefreshToken, testDevice); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("RefreshTokens after logout err = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+}
+
+func TestLogoutAll(t *testing.T) {
+	f := newAuthFixture(t)
+	tokens := f.login(t)
+	key := &models.APIKey{UserID: f.userID, Name: "ci", Prefix: "bk_123", KeyHash: "key-hash",
+		Scopes: []string{models.ScopeAccountsRead}, ExpiresAt: time.Now().Add(time.Hour)}
+	if err := f.apiKeys.Create(context.Background(), key); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.service.LogoutAll(context.Background(), f.userID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := f.checkAccess(t, tokens.AccessToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Fatalf("CheckAccessToken after logout = %v, want %v", err, ErrTokenRevoked)
+	}
+	if _, err := f.service.RefreshTokens(context.Background(), tokens.RefreshToken, testDevice); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("RefreshTokens after logout err = %v, want %v", err, ErrInvalidRefreshToken)
+	}
+	// Личные API-ключи отзываются вместе с сессиями
+	if _, err := f.apiKeys.GetActiveByHash(context.Background(), key.KeyHash); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("GetActiveByHash after logout err = %v, want %v", err, sql.ErrNoRows)
+	}
+}
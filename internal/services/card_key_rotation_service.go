@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"github.com/sirupsen/logrus"
+	"go_project/internal/keyring"
+	"go_project/internal/models"
+	"go_project/internal/repositories"
+	"time"
+)
+
+const (
+	// reencryptBatchSize — сколько записей перешифровывается одним запросом
+	reencryptBatchSize = 100
+	// reencryptInterval — период запуска фонового перешифрования
+	reencryptInterval = time.Hour
+)
+
+// encryptedTable — таблица с данными, зашифрованными ключами из связки ключей карт
+type encryptedTable struct {
+	name      string
+	count     func(ctx context.Context) (map[string]int, error)
+	reencrypt func(ctx context.Context, fromKeyID, fromKey, toKeyID, toKey string, limit int) (int, error)
+}
+
+// CardKeyRotationService перешифровывает со старых ключей на активный всё, что зашифровано ключами
+// карт: номера и сроки действия карт, закрытые ключи подписи JWT и секреты TOTP. Старый ключ можно
+// удалить только после перевода всех трёх таблиц, иначе перестанут расшифровываться ключи подписи
+// и секреты второго фактора. Состояние ротации хранится в самих строках (key_id), поэтому задача
+// возобновляется с того же места после перезапуска и может выполняться на нескольких экземплярах.
+type CardKeyRotationService struct {
+	tables []encryptedTable
+	keys   *keyring.Keyring
+}
+
+func NewCardKeyRotationService(cardRepo repositories.Cards, signingKeyRepo repositories.SigningKeys, userRepo repositories.Users, keys *keyring.Keyring) *CardKeyRotationService {
+	return &CardKeyRotationService{
+		tables: []encryptedTable{
+			{"cards", cardRepo.CountByKeyID, cardRepo.ReencryptBatch},
+			{"jwt_signing_keys", signingKeyRepo.CountByKeyID, signingKeyRepo.ReencryptBatch},
+			{"users", userRepo.CountTOTPByKeyID, userRepo.ReencryptTOTPBatch},
+		},
+		keys: keys,
+	}
+}
+
+// Progress возвращает текущий ход ротации: сколько записей уже зашифровано активным ключом
+// и сколько осталось на каждом из старых ключей, всего и по таблицам.
+func (s *CardKeyRotationService) Progress(ctx context.Context) (*models.KeyRotationProgress, error) {
+	activeID, _ := s.keys.Active()
+	progress := &models.KeyRotationProgress{
+		ActiveKeyID: activeID,
+		Remaining:   make(map[string]int),
+		Tables:      make(map[string]models.KeyRotationCounts, len(s.tables)),
+	}
+	for _, table := range s.tables {
+		counts, err := table.count(ctx)
+		if err != nil {
+			return nil, err
+		}
+		tp := models.KeyRotationCounts{Remaining: make(map[string]int)}
+		for keyID, n := range counts {
+			tp.Total += n
+			if keyID == activeID {
+				tp.Migrated += n
+			} else {
+				tp.Remaining[keyID] = n
+				progress.Remaining[keyID] += n
+			}
+		}
+		progress.Total += tp.Total
+		progress.Migrated += tp.Migrated
+		progress.Tables[table.name] = tp
+	}
+	progress.Percent = 100
+	if progress.Total > 0 {
+		progress.Percent = float64(progress.Migrated) * 100 / float64(progress.Total)
+	}
+	return progress, nil
+}
+
+// Reencrypt переводит на активный ключ все записи, зашифрованные старыми ключами,
+// пакетами по reencryptBatchSize и возвращает количество перешифрованных записей.
+func (s *CardKeyRotationService) Reencrypt(ctx context.Context) (int, error) {
+	progress, err := s.Progress(ctx)
+	if err != nil {
+		return 0, err
+	}
+	activeID, activeKey := s.keys.Active()
+	migrated := 0
+	for _, table := range s.tables {
+		for keyID := range progress.Tables[table.name].Remaining {
+			oldKey, err := s.keys.Key(keyID)
+			if err != nil {
+				logrus.WithContext(ctx).Errorf("Cannot re-encrypt %s from key %s: %v", table.name, keyID, err)
+				continue
+			}
+			for {
+				n, err := table.reencrypt(ctx, keyID, oldKey, activeID, activeKey, reencryptBatchSize)
+				if err != nil {
+					return migrated, err
+				}
+				migrated += n
+				if n < reencryptBatchSize {
+					break
+				}
+				logrus.WithContext(ctx).Infof("Card key rotation: %d records moved from key %s to %s", migrated, keyID, activeID)
+			}
+		}
+	}
+	if migrated > 0 {
+		if progress, err := s.Progress(ctx); err == nil {
+			logrus.WithContext(ctx).Infof("Card key rotation: %d of %d records use key %s (%.1f%%)",
+				progress.Migrated, progress.Total, progress.ActiveKeyID, progress.Percent)
+		}
+	}
+	return migrated, nil
+}
+
+// RunReencryption перешифровывает данные сразу и далее каждый час, пока не отменён ctx.
+func (s *CardKeyRotationService) RunReencryption(ctx context.Context) {
+	ticker := time.NewTicker(reencryptInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.Reencrypt(ctx); err != nil {
+			logrus.WithContext(ctx).Error("Card key rotation failed: ", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
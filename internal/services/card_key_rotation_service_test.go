@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"go_project/internal/keyring"
+	"go_project/internal/repositories/memory"
+	"testing"
+	"time"
+)
+
+func TestCardKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	store := memory.NewStore()
+	users, accounts := memory.NewUserRepository(store), memory.NewAccountRepository(store)
+	cardRepo, signingKeys := memory.NewCardRepository(store), memory.NewSigningKeyRepository(store)
+	oldKeys, err := keyring.New("k1", map[string]string{"k1": "key-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKeys, err := keyring.New("k2", map[string]string{"k1": "key-1", "k2": "key-2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Связка без старого ключа: после ротации все данные должны расшифровываться только k2
+	onlyNew, err := keyring.New("k2", map[string]string{"k2": "key-2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userID, err := users.CreateUser(ctx, "alice@example.com", "alice", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	accountID, err := accounts.CreateAccount(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cards := NewCardService(store, cardRepo, accounts, oldKeys, "pin-key", "pan-index-key")
+	issued := make(map[string]string)
+	for i := 0; i < 3; i++ {
+		card, err := cards.CreateCard(ctx, userID, accountID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		issued[card.ID] = card.CardNumber
+	}
+	if err := users.SetTOTPSecret(ctx, userID, "SECRET", "k1", "key-1"); err != nil {
+		t.Fatal(err)
+	}
+	jwtKeys, err := NewJWTKeyService(signingKeys, oldKeys, JWTAlgorithmEdDSA, "", time.Time{}, "bank", "bank-api", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := jwtKeys.Init(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	rotation := NewCardKeyRotationService(cardRepo, signingKeys, users, newKeys)
+	progress, err := rotation.Progress(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.Total != 5 || progress.Migrated != 0 || progress.Remaining["k1"] != 5 {
+		t.Fatalf("Progress before rotation = %+v, want 5 records on k1", progress)
+	}
+	if n, err := rotation.Reencrypt(ctx); err != nil || n != 5 {
+		t.Fatalf("Reencrypt = %d, %v, want 5", n, err)
+	}
+
+	for cardID, number := range issued {
+		if got, err := cardRepo.GetCardNumber(ctx, cardID, onlyNew.Key); err != nil || got != number {
+			t.Fatalf("GetCardNumber(%s) under k2 = %q, %v, want %q", cardID, got, err, number)
+		}
+	}
+	if secret, keyID, _, err := users.GetTOTPSecret(ctx, userID, onlyNew.Key); err != nil || secret != "SECRET" || keyID != "k2" {
+		t.Fatalf("GetTOTPSecret under k2 = %q, %q, %v", secret, keyID, err)
+	}
+	if keys, err := signingKeys.ListValid(ctx, time.Now(), onlyNew.Key); err != nil || len(keys) != 1 {
+		t.Fatalf("ListValid under k2 = %d keys, %v, want 1", len(keys), err)
+	}
+
+	progress, err = rotation.Progress(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if progress.Total != 5 || progress.Migrated != 5 || len(progress.Remaining) != 0 || progress.Percent != 100 {
+		t.Fatalf("Progress after rotation = %+v, want nothing remaining", progress)
+	}
+	for name, table := range progress.Tables {
+		if len(table.Remaining) != 0 || table.Migrated != table.Total {
+			t.Fatalf("Progress of %s = %+v, want nothing remaining", name, table)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/sirupsen/logrus"
+	"go_project/internal/models"
+	"strings"
+)
+
+// maxPINAttempts — число неверных вводов PIN подряд, после которого карта блокируется
+const maxPINAttempts = 3
+
+var (
+	ErrInvalidPIN    = errors.New("PIN must consist of 4 to 6 digits and must not be trivial")
+	ErrPINAlreadySet = errors.New("PIN is already set")
+	ErrPINNotSet     = errors.New("PIN is not set")
+	ErrIncorrectPIN  = errors.New("incorrect PIN")
+	ErrCardBlocked   = errors.New("card is blocked")
+	ErrInvalidPAN    = errors.New("card number is not suitable for a PIN block")
+)
+
+// SetPIN устанавливает PIN карты, если он ещё не был задан.
+func (s *CardService) SetPIN(ctx context.Context, userID, cardID, pin string) error {
+	if !isValidPIN(pin) {
+		return ErrInvalidPIN
+	}
+	if _, err := s.OwnedCard(ctx, userID, cardID); err != nil {
+		return err
+	}
+	pan, err := s.cardRepo.GetCardNumber(ctx, cardID, s.keys.Key)
+	if err != nil {
+		return err
+	}
+	pinHash, err := s.hashPIN(pin, pan)
+	if err != nil {
+		return err
+	}
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		card, err := s.cardRepo.LockByID(ctx, cardID)
+		if err != nil {
+			return err
+		}
+		if card.Status == models.CardStatusBlocked {
+			return ErrCardBlocked
+		}
+		if card.PINHash != "" {
+			return ErrPINAlreadySet
+		}
+		return s.cardRepo.SetPIN(ctx, cardID, pinHash)
+	})
+	if err != nil {
+		return err
+	}
+	logrus.WithContext(ctx).Infof("PIN was set for card %s", cardID)
+	return nil
+}
+
+// ChangePIN меняет PIN карты после проверки текущего PIN. Проверка и замена выполняются
+// в одной транзакции под блокировкой карты, чтобы параллельный ввод PIN не вклинился между ними.
+func (s *CardService) ChangePIN(ctx context.Context, userID, cardID, oldPIN, newPIN string) error {
+	if !isValidPIN(newPIN) {
+		return ErrInvalidPIN
+	}
+	if _, err := s.OwnedCard(ctx, userID, cardID); err != nil {
+		return err
+	}
+	pan, err := s.cardRepo.GetCardNumber(ctx, cardID, s.keys.Key)
+	if err != nil {
+		return err
+	}
+	oldHash, err := s.hashPIN(oldPIN, pan)
+	if err != nil {
+		return err
+	}
+	newHash, err := s.hashPIN(newPIN, pan)
+	if err != nil {
+		return err
+	}
+	var check pinCheck
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		card, err := s.cardRepo.LockByID(ctx, cardID)
+		if err != nil {
+			return err
+		}
+		if check, err = s.checkPIN(ctx, card, oldHash); err != nil || !check.matched {
+			// Транзакция фиксируется и при неверном PIN, чтобы счётчик неудачных попыток сохранился
+			return err
+		}
+		return s.cardRepo.SetPIN(ctx, cardID, newHash)
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.pinCheckError(ctx, cardID, check); err != nil {
+		return err
+	}
+	logrus.WithContext(ctx).Infof("PIN was changed for card %s", cardID)
+	return nil
+}
+
+// VerifyPIN проверяет PIN карты. После maxPINAttempts неверных попыток подряд карта блокируется.
+func (s *CardService) VerifyPIN(ctx context.Context, userID, cardID, pin string) error {
+	if _, err := s.OwnedCard(ctx, userID, cardID); err != nil {
+		return err
+	}
+	pan, err := s.cardRepo.GetCardNumber(ctx, cardID, s.keys.Key)
+	if err != nil {
+		return err
+	}
+	pinHash, err := s.hashPIN(pin, pan)
+	if err != nil {
+		return err
+	}
+	var check pinCheck
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		card, err := s.cardRepo.LockByID(ctx, cardID)
+		if err != nil {
+			return err
+		}
+		// Транзакция фиксируется и при неверном PIN, чтобы счётчик неудачных попыток сохранился
+		check, err = s.checkPIN(ctx, card, pinHash)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return s.pinCheckError(ctx, cardID, check)
+}
+
+// pinCheck — результат сверки PIN
+type pinCheck struct {
+	matched  bool
+	attempts int
+	status   string
+}
+
+// checkPIN сверяет хеш PIN с картой, заблокированной в текущей транзакции: при совпадении
+// сбрасывает счётчик неудачных попыток, иначе увеличивает его и блокирует карту на maxPINAttempts.
+func (s *CardService) checkPIN(ctx context.Context, card *models.Card, pinHash string) (pinCheck, error) {
+	if card.Status == models.CardStatusBlocked {
+		return pinCheck{}, ErrCardBlocked
+	}
+	if card.PINHash == "" {
+		return pinCheck{}, ErrPINNotSet
+	}
+	if hmac.Equal([]byte(pinHash), []byte(card.PINHash)) {
+		if card.PINAttempts > 0 {
+			return pinCheck{matched: true}, s.cardRepo.ResetPINAttempts(ctx, card.ID)
+		}
+		return pinCheck{matched: true}, nil
+	}
+	attempts, status, err := s.cardRepo.RecordPINFailure(ctx, card.ID, maxPINAttempts)
+	return pinCheck{attempts: attempts, status: status}, err
+}
+
+// pinCheckError возвращает ошибку для неудачной сверки PIN и nil при совпадении.
+func (s *CardService) pinCheckError(ctx context.Context, cardID string, check pinCheck) error {
+	if check.matched {
+		return nil
+	}
+	if check.status == models.CardStatusBlocked {
+		logrus.WithContext(ctx).Warnf("Card %s was blocked after %d incorrect PIN attempts", cardID, check.attempts)
+		return ErrCardBlocked
+	}
+	return ErrIncorrectPIN
+}
+
+// hashPIN вычисляет HMAC-SHA256 на ключе PIN от PIN-блока. Ключ хранится отдельно
+// от ключа шифрования карт, а привязка к PAN исключает одинаковые хеши одинаковых PIN.
+func (s *CardService) hashPIN(pin, pan string) (string, error) {
+	block, err := pinBlock(pin, pan)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, []byte(s.pinKey))
+	mac.Write(block)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// pinBlock формирует PIN-блок ISO 9564 формата 0: PIN-поле XOR PAN-поле.
+func pinBlock(pin, pan string) ([]byte, error) {
+	if len(pin) > 12 {
+		return nil, ErrInvalidPIN
+	}
+	// PAN-поле — 12 правых цифр номера без контрольной цифры
+	if len(pan) < 13 {
+		return nil, ErrInvalidPAN
+	}
+	digits := pan[:len(pan)-1]
+	pinField := fmt.Sprintf("0%X%s", len(pin), pin)
+	pinField += strings.Repeat("F", 16-len(pinField))
+	block, err := hex.DecodeString(pinField)
+	if err != nil {
+		return nil, ErrInvalidPIN
+	}
+	panBytes, err := hex.DecodeString("0000" + digits[len(digits)-12:])
+	if err != nil {
+		return nil, ErrInvalidPAN
+	}
+	for i := range block {
+		block[i] ^= panBytes[i]
+	}
+	return block, nil
+}
+
+// isValidPIN проверяет формат PIN и отсекает тривиальные комбинации (1111, 1234, 4321).
+func isValidPIN(pin string) bool {
+	if len(pin) < 4 || len(pin) > 6 {
+		return false
+	}
+	for _, c := range pin {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	same, asc, desc := true, true, true
+	for i := 1; i < len(pin); i++ {
+		d := int(pin[i]) - int(pin[i-1])
+		same = same && d == 0
+		asc = asc && d == 1
+		desc = desc && d == -1
+	}
+	return !same && !asc && !desc
+}
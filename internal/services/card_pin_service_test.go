@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"go_project/internal/models"
+	"testing"
+)
+
+// newPINCard выпускает карту пользователю фикстуры и задаёт ей PIN 2580.
+func newPINCard(t *testing.T) (*transferFixture, string) {
+	t.Helper()
+	f := newTransferFixture(t, 100)
+	card, err := f.cards.CreateCard(context.Background(), f.userID, f.from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.cards.SetPIN(context.Background(), f.userID, card.ID, "2580"); err != nil {
+		t.Fatal(err)
+	}
+	return f, card.ID
+}
+
+func (f *transferFixture) pinState(t *testing.T, cardID string) (int, string) {
+	t.Helper()
+	card, err := f.cards.cardRepo.GetByID(context.Background(), cardID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return card.PINAttempts, card.Status
+}
+
+func TestSetPIN(t *testing.T) {
+	f, cardID := newPINCard(t)
+	if err := f.cards.SetPIN(context.Background(), f.userID, cardID, "1357"); !errors.Is(err, ErrPINAlreadySet) {
+		t.Fatalf("second SetPIN err = %v, want %v", err, ErrPINAlreadySet)
+	}
+	card, err := f.cards.CreateCard(context.Background(), f.userID, f.from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, pin := range []string{"123", "1234567", "12a4", "1111", "1234", "98765"} {
+		if err := f.cards.SetPIN(context.Background(), f.userID, card.ID, pin); !errors.Is(err, ErrInvalidPIN) {
+			t.Fatalf("SetPIN(%q) err = %v, want %v", pin, err, ErrInvalidPIN)
+		}
+	}
+	if err := f.cards.VerifyPIN(context.Background(), f.userID, card.ID, "2580"); !errors.Is(err, ErrPINNotSet) {
+		t.Fatalf("VerifyPIN without PIN err = %v, want %v", err, ErrPINNotSet)
+	}
+}
+
+func TestVerifyPIN(t *testing.T) {
+	ctx := context.Background()
+	f, cardID := newPINCard(t)
+	if err := f.cards.VerifyPIN(ctx, f.userID, cardID, "0000"); !errors.Is(err, ErrIncorrectPIN) {
+		t.Fatalf("VerifyPIN err = %v, want %v", err, ErrIncorrectPIN)
+	}
+	if attempts, _ := f.pinState(t, cardID); attempts != 1 {
+		t.Fatalf("PIN attempts = %d, want 1", attempts)
+	}
+	if err := f.cards.VerifyPIN(ctx, f.userID, cardID, "2580"); err != nil {
+		t.Fatal(err)
+	}
+	if attempts, _ := f.pinState(t, cardID); attempts != 0 {
+		t.Fatalf("PIN attempts after correct PIN = %d, want 0", attempts)
+	}
+
+	for i := 1; i < maxPINAttempts; i++ {
+		if err := f.cards.VerifyPIN(ctx, f.userID, cardID, "0000"); !errors.Is(err, ErrIncorrectPIN) {
+			t.Fatalf("attempt %d: VerifyPIN err = %v, want %v", i, err, ErrIncorrectPIN)
+		}
+	}
+	if err := f.cards.VerifyPIN(ctx, f.userID, cardID, "0000"); !errors.Is(err, ErrCardBlocked) {
+		t.Fatalf("VerifyPIN err = %v, want %v", err, ErrCardBlocked)
+	}
+	if attempts, status := f.pinState(t, cardID); attempts != maxPINAttempts || status != models.CardStatusBlocked {
+		t.Fatalf("PIN state = %d, %s, want %d, %s", attempts, status, maxPINAttempts, models.CardStatusBlocked)
+	}
+	// Заблокированная карта не принимает и верный PIN
+	if err := f.cards.VerifyPIN(ctx, f.userID, cardID, "2580"); !errors.Is(err, ErrCardBlocked) {
+		t.Fatalf("VerifyPIN on blocked card err = %v, want %v", err, ErrCardBlocked)
+	}
+}
+
+func TestChangePIN(t *testing.T) {
+	ctx := context.Background()
+	f, cardID := newPINCard(t)
+	if err := f.cards.ChangePIN(ctx, f.userID, cardID, "2580", "1111"); !errors.Is(err, ErrInvalidPIN) {
+		t.Fatalf("ChangePIN to trivial PIN err = %v, want %v", err, ErrInvalidPIN)
+	}
+	if err := f.cards.ChangePIN(ctx, f.userID, cardID, "0000", "1357"); !errors.Is(err, ErrIncorrectPIN) {
+		t.Fatalf("ChangePIN err = %v, want %v", err, ErrIncorrectPIN)
+	}
+	if attempts, _ := f.pinState(t, cardID); attempts != 1 {
+		t.Fatalf("PIN attempts = %d, want 1", attempts)
+	}
+	if err := f.cards.VerifyPIN(ctx, f.userID, cardID, "2580"); err != nil {
+		t.Fatalf("PIN changed after a wrong old PIN: %v", err)
+	}
+
+	if err := f.cards.ChangePIN(ctx, f.userID, cardID, "2580", "1357"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.cards.VerifyPIN(ctx, f.userID, cardID, "1357"); err != nil {
+		t.Fatalf("VerifyPIN with new PIN: %v", err)
+	}
+	if err := f.cards.VerifyPIN(ctx, f.userID, cardID, "2580"); !errors.Is(err, ErrIncorrectPIN) {
+		t.Fatalf("VerifyPIN with old PIN err = %v, want %v", err, ErrIncorrectPIN)
+	}
+}
+
+func TestPINBlockRejectsShortPAN(t *testing.T) {
+	if _, err := pinBlock("2580", "400000123456"); !errors.Is(err, ErrInvalidPAN) {
+		t.Fatalf("pinBlock err = %v, want %v", err, ErrInvalidPAN)
+	}
+	if _, err := pinBlock("2580", "4000001234567899"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,57 +1,421 @@
 package services
 
 import (
+	"context"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/sirupsen/logrus"
+	"go_project/internal/keyring"
+	"go_project/internal/models"
 	"go_project/internal/repositories"
 	"golang.org/x/crypto/bcrypt"
 	"math/big"
+	"strings"
 	"time"
 )
 
 type CardService struct {
-	cardRepo    *repositories.CardRepository
-	accountRepo *repositories.AccountRepository
-	encKey      string
+	uow         repositories.UnitOfWork
+	cardRepo    repositories.Cards
+	accountRepo repositories.Accounts
+	keys        *keyring.Keyring
+	pinKey      string
+	panIndexKey string
 }
 
-func NewCardService(cardRepo *repositories.CardRepository, accountRepo *repositories.AccountRepository, encryptionKey string) *CardService {
-	return &CardService{cardRepo, accountRepo, encryptionKey}
+func NewCardService(uow repositories.UnitOfWork, cardRepo repositories.Cards, accountRepo repositories.Accounts, keys *keyring.Keyring, pinKey, panIndexKey string) *CardService {
+	return &CardService{uow, cardRepo, accountRepo, keys, pinKey, panIndexKey}
 }
 
 // CreateCard Генерирует новую карту для указанного счета и возвращает её реквизиты (номер, срок и CVV)
-func (s *CardService) CreateCard(userID, accountID string) (string, string, string, error) {
-	acc, err := s.accountRepo.GetByID(accountID)
+func (s *CardService) CreateCard(ctx context.Context, userID, accountID string) (*models.IssuedCard, error) {
+	return s.issueCard(ctx, userID, models.Card{AccountID: accountID, Type: models.CardTypeStandard})
+}
+
+// maxVirtualCardTTL — максимальный срок жизни виртуальной карты
+const maxVirtualCardTTL = 30 * 24 * time.Hour
+
+var (
+	ErrInvalidCardType   = errors.New("invalid virtual card type")
+	ErrInvalidCardTTL    = errors.New("invalid virtual card TTL")
+	ErrInvalidAmountCap  = errors.New("invalid virtual card amount cap")
+	ErrCardExpired       = errors.New("card is expired")
+	ErrCardDestroyed     = errors.New("card is destroyed")
+	ErrMerchantMismatch  = errors.New("card is locked to another merchant")
+	ErrAmountCapExceeded = errors.New("card amount cap exceeded")
+)
+
+// CreateVirtualCard выпускает одноразовую карту или карту, привязываемую к первому продавцу.
+// amountCap ограничивает общую сумму операций по карте, ttl — срок её жизни (0 — без ограничений).
+func (s *CardService) CreateVirtualCard(ctx context.Context, userID, accountID, cardType string, amountCap float64, ttl time.Duration) (*models.IssuedCard, error) {
+	if cardType != models.CardTypeSingleUse && cardType != models.CardTypeMerchantLocked {
+		return nil, ErrInvalidCardType
+	}
+	if ttl < 0 || ttl > maxVirtualCardTTL {
+		return nil, ErrInvalidCardTTL
+	}
+	if amountCap < 0 {
+		return nil, ErrInvalidAmountCap
+	}
+	card := models.Card{AccountID: accountID, Type: cardType}
+	if amountCap > 0 {
+		card.AmountCap = &amountCap
+	}
+	if ttl > 0 {
+		expiresAt := time.Now().Add(ttl)
+		card.ExpiresAt = &expiresAt
+	}
+	return s.issueCard(ctx, userID, card)
+}
+
+// issueCard генерирует реквизиты и сохраняет карту с заданными типом и ограничениями.
+func (s *CardService) issueCard(ctx context.Context, userID string, card models.Card) (*models.IssuedCard, error) {
+	acc, err := s.accountRepo.GetByID(ctx, card.AccountID)
 	if err != nil {
-		return "", "", "", ErrAccountNotFound
+		return nil, ErrAccountNotFound
 	}
 	if acc.UserID != userID {
-		return "", "", "", ErrForbidden
+		return nil, ErrForbidden
 	}
 	// Генерируем 16-значный номер карты
 	max := new(big.Int).Exp(big.NewInt(10), big.NewInt(16), nil)
 	randNum, _ := rand.Int(rand.Reader, max)
 	cardNumber := fmt.Sprintf("%016d", randNum)
-	// Генерируем срок действия (MM/YY) через 3 года от текущей даты
-	now := time.Now()
-	month := int(now.Month())
-	year := (now.Year() + 3) % 100
-	expiry := fmt.Sprintf("%02d/%02d", month, year)
+	// Генерируем срок действия (MM/YY) через 3 года от текущей даты,
+	// для виртуальной карты с ограниченным сроком жизни — по дате её истечения
+	expiryDate := time.Now().AddDate(3, 0, 0)
+	if card.ExpiresAt != nil {
+		expiryDate = *card.ExpiresAt
+	}
+	expiry := fmt.Sprintf("%02d/%02d", int(expiryDate.Month()), expiryDate.Year()%100)
 	// Генерируем случайный CVV (3 цифры)
 	randCVV, _ := rand.Int(rand.Reader, big.NewInt(1000))
 	cvv := fmt.Sprintf("%03d", randCVV.Int64())
 	// Хешируем CVV
 	cvvHashBytes, err := bcrypt.GenerateFromPassword([]byte(cvv), bcrypt.DefaultCost)
 	if err != nil {
-		return "", "", "", err
+		return nil, err
 	}
 	cvvHash := string(cvvHashBytes)
-	// Сохраняем карту в базе
-	cardID, err := s.cardRepo.CreateCard(accountID, cardNumber, expiry, cvvHash, s.encKey)
+	// Сохраняем карту в базе, шифруя данные активным ключом
+	card.PANIndex = s.panIndex(cardNumber)
+	keyID, key := s.keys.Active()
+	cardID, err := s.cardRepo.CreateCard(ctx, &card, cardNumber, expiry, cvvHash, keyID, key)
+	if err != nil {
+		return nil, err
+	}
+	logrus.WithContext(ctx).Infof("Generated new %s card %s for account %s", card.Type, cardID, card.AccountID)
+	return &models.IssuedCard{
+		ID:         cardID,
+		CardNumber: cardNumber,
+		Expiry:     expiry,
+		CVV:        cvv,
+		Type:       card.Type,
+		AmountCap:  card.AmountCap,
+		ExpiresAt:  card.ExpiresAt,
+	}, nil
+}
+
+// panIndex вычисляет детерминированный HMAC номера карты, по которому карта ищется
+// без расшифровки всех строк таблицы.
+func (s *CardService) panIndex(pan string) string {
+	mac := hmac.New(sha256.New, []byte(s.panIndexKey))
+	mac.Write([]byte("pan|" + pan))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ActiveCardByPAN находит активную карту по её номеру через HMAC-индекс.
+func (s *CardService) ActiveCardByPAN(ctx context.Context, pan string) (*models.Card, error) {
+	card, err := s.cardRepo.GetByPANIndex(ctx, s.panIndex(pan))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrCardNotFound
+		}
+		return nil, err
+	}
+	if card.Status != models.CardStatusActive {
+		return nil, ErrCardNotFound
+	}
+	return card, nil
+}
+
+// BackfillPANIndex рассчитывает HMAC номера для карт, выпущенных до появления индекса.
+func (s *CardService) BackfillPANIndex(ctx context.Context) (int, error) {
+	done := 0
+	for {
+		ids, err := s.cardRepo.ListWithoutPANIndex(ctx, reencryptBatchSize)
+		if err != nil {
+			return done, err
+		}
+		if len(ids) == 0 {
+			return done, nil
+		}
+		for _, id := range ids {
+			pan, err := s.cardRepo.GetCardNumber(ctx, id, s.keys.Key)
+			if err != nil {
+				return done, err
+			}
+			if err := s.cardRepo.SetPANIndex(ctx, id, s.panIndex(pan)); err != nil {
+				return done, err
+			}
+			done++
+		}
+		logrus.WithContext(ctx).Infof("PAN index backfill: %d cards processed", done)
+	}
+}
+
+// Каналы использования карты
+const (
+	ChannelPOS         = "pos"
+	ChannelOnline      = "online"
+	ChannelContactless = "contactless"
+	ChannelATM         = "atm"
+)
+
+// homeCountry — страна выпуска карт; операции в других странах считаются зарубежными
+const homeCountry = "RU"
+
+// DefaultCardLimits применяются к картам, для которых владелец ещё не настраивал лимиты.
+var DefaultCardLimits = models.CardLimits{
+	DailyLimit:          100000,
+	MonthlyLimit:        1000000,
+	PerTransactionLimit: 50000,
+	OnlineEnabled:       true,
+	ContactlessEnabled:  true,
+	ATMEnabled:          true,
+	ForeignEnabled:      false,
+}
+
+var (
+	ErrCardNotFound      = errors.New("card not found")
+	ErrInvalidLimits     = errors.New("invalid card limits")
+	ErrInvalidChannel    = errors.New("invalid card channel")
+	ErrMerchantRequired  = errors.New("merchant is required")
+	ErrChannelDisabled   = errors.New("card channel is disabled")
+	ErrCardLimitExceeded = errors.New("card limit exceeded")
+)
+
+// OwnedCard возвращает карту, если она принадлежит пользователю.
+func (s *CardService) OwnedCard(ctx context.Context, userID, cardID string) (*models.Card, error) {
+	card, err := s.cardRepo.GetByID(ctx, cardID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrCardNotFound
+		}
+		return nil, err
+	}
+	acc, err := s.accountRepo.GetByID(ctx, card.AccountID)
+	if err != nil {
+		return nil, ErrAccountNotFound
+	}
+	if acc.UserID != userID {
+		return nil, ErrForbidden
+	}
+	return card, nil
+}
+
+// RevealCard возвращает полный номер и срок действия карты её владельцу.
+// CVV не хранится в открытом виде и не раскрывается.
+func (s *CardService) RevealCard(ctx context.Context, userID, cardID string) (*models.CardDetails, error) {
+	card, err := s.OwnedCard(ctx, userID, cardID)
+	if err != nil {
+		return nil, err
+	}
+	if card.Status == models.CardStatusDestroyed {
+		return nil, ErrCardDestroyed
+	}
+	cardNumber, expiry, err := s.cardRepo.GetCardDetails(ctx, cardID, s.keys.Key)
+	if err != nil {
+		return nil, err
+	}
+	logrus.WithContext(ctx).Infof("Card %s details were revealed to its owner", cardID)
+	return &models.CardDetails{ID: cardID, CardNumber: cardNumber, Expiry: expiry}, nil
+}
+
+// GetLimits возвращает лимиты карты и суммы расходов за текущие скользящие окна.
+func (s *CardService) GetLimits(ctx context.Context, userID, cardID string) (*models.CardLimits, error) {
+	if _, err := s.OwnedCard(ctx, userID, cardID); err != nil {
+		return nil, err
+	}
+	limits, err := s.cardRepo.GetLimits(ctx, cardID)
+	if errors.Is(err, sql.ErrNoRows) {
+		defaults := DefaultCardLimits
+		limits, err = &defaults, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	limits.CardID = cardID
+	now := time.Now()
+	if limits.DailySpent, err = s.cardRepo.SumAuthorizedSince(ctx, cardID, now.Add(-24*time.Hour)); err != nil {
+		return nil, err
+	}
+	if limits.MonthlySpent, err = s.cardRepo.SumAuthorizedSince(ctx, cardID, now.AddDate(0, 0, -30)); err != nil {
+		return nil, err
+	}
+	return limits, nil
+}
+
+// UpdateLimits сохраняет новые лимиты и настройки каналов карты.
+func (s *CardService) UpdateLimits(ctx context.Context, userID string, limits models.CardLimits) (*models.CardLimits, error) {
+	if limits.PerTransactionLimit <= 0 || limits.DailyLimit <= 0 || limits.MonthlyLimit <= 0 ||
+		limits.PerTransactionLimit > limits.DailyLimit || limits.DailyLimit > limits.MonthlyLimit {
+		return nil, ErrInvalidLimits
+	}
+	if _, err := s.OwnedCard(ctx, userID, limits.CardID); err != nil {
+		return nil, err
+	}
+	if err := s.cardRepo.UpsertLimits(ctx, &limits); err != nil {
+		return nil, err
+	}
+	logrus.WithContext(ctx).Infof("Limits of card %s were updated", limits.CardID)
+	return s.GetLimits(ctx, userID, limits.CardID)
+}
+
+// Authorize проводит авторизацию операции по карте: проверяет канал и лимиты
+// и списывает сумму со счёта карты в одной транзакции.
+func (s *CardService) Authorize(ctx context.Context, userID string, auth models.CardAuthorization) (*models.CardAuthorization, error) {
+	if auth.Amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	if strings.TrimSpace(auth.Merchant) == "" {
+		return nil, ErrMerchantRequired
+	}
+	if _, err := s.OwnedCard(ctx, userID, auth.CardID); err != nil {
+		return nil, err
+	}
+	err := s.uow.Do(ctx, func(ctx context.Context) error {
+		return s.AuthorizeInTx(ctx, &auth)
+	})
+	if err != nil {
+		return nil, err
+	}
+	logrus.WithContext(ctx).Infof("Card %s authorized %.2f via %s at %s", auth.CardID, auth.Amount, auth.Channel, auth.Merchant)
+	return &auth, nil
+}
+
+// AuthorizeInTx выполняет проверки и списание; вызывается внутри UnitOfWork.Do, чтобы
+// другие сервисы могли провести авторизацию в одной транзакции со своими изменениями.
+// Строка карты блокируется, поэтому параллельные авторизации по одной карте
+// видят согласованные суммы расходов за скользящие окна.
+func (s *CardService) AuthorizeInTx(ctx context.Context, auth *models.CardAuthorization) error {
+	card, err := s.cardRepo.LockByID(ctx, auth.CardID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrCardNotFound
+		}
+		return err
+	}
+	switch card.Status {
+	case models.CardStatusActive:
+	case models.CardStatusDestroyed:
+		return ErrCardDestroyed
+	default:
+		return ErrCardBlocked
+	}
+	now := time.Now()
+	if card.ExpiresAt != nil && now.After(*card.ExpiresAt) {
+		return ErrCardExpired
+	}
+	acc, err := s.accountRepo.LockByID(ctx, card.AccountID)
 	if err != nil {
-		return "", "", "", err
+		return err
+	}
+	if acc.Frozen {
+		return ErrAccountFrozen
+	}
+	if card.Type == models.CardTypeMerchantLocked && card.LockedMerchant != "" &&
+		!strings.EqualFold(card.LockedMerchant, auth.Merchant) {
+		return ErrMerchantMismatch
+	}
+	limits, err := s.cardRepo.GetLimits(ctx, card.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		defaults := DefaultCardLimits
+		limits, err = &defaults, nil
+	}
+	if err != nil {
+		return err
+	}
+	if auth.Country == "" {
+		auth.Country = homeCountry
+	}
+	auth.Country = strings.ToUpper(auth.Country)
+	if err := checkChannel(limits, auth); err != nil {
+		return err
+	}
+	if auth.Amount > limits.PerTransactionLimit {
+		return ErrCardLimitExceeded
+	}
+	if card.AmountCap != nil {
+		total, err := s.cardRepo.SumAuthorizedSince(ctx, card.ID, time.Time{})
+		if err != nil {
+			return err
+		}
+		if total+auth.Amount > *card.AmountCap {
+			return ErrAmountCapExceeded
+		}
+	}
+	daily, err := s.cardRepo.SumAuthorizedSince(ctx, card.ID, now.Add(-24*time.Hour))
+	if err != nil {
+		return err
+	}
+	if daily+auth.Amount > limits.DailyLimit {
+		return ErrCardLimitExceeded
+	}
+	monthly, err := s.cardRepo.SumAuthorizedSince(ctx, card.ID, now.AddDate(0, 0, -30))
+	if err != nil {
+		return err
+	}
+	if monthly+auth.Amount > limits.MonthlyLimit {
+		return ErrCardLimitExceeded
+	}
+	ok, err := s.accountRepo.Debit(ctx, card.AccountID, auth.Amount)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInsufficientFunds
+	}
+	if err := s.cardRepo.CreateAuthorization(ctx, auth); err != nil {
+		return err
+	}
+	// Правила виртуальных карт применяются только после успешной авторизации
+	switch card.Type {
+	case models.CardTypeSingleUse:
+		return s.cardRepo.SetStatus(ctx, card.ID, models.CardStatusDestroyed)
+	case models.CardTypeMerchantLocked:
+		if card.LockedMerchant == "" {
+			return s.cardRepo.LockMerchant(ctx, card.ID, auth.Merchant)
+		}
+	}
+	return nil
+}
+
+func checkChannel(limits *models.CardLimits, auth *models.CardAuthorization) error {
+	switch auth.Channel {
+	case ChannelPOS:
+	case ChannelOnline:
+		if !limits.OnlineEnabled {
+			return ErrChannelDisabled
+		}
+	case ChannelContactless:
+		if !limits.ContactlessEnabled {
+			return ErrChannelDisabled
+		}
+	case ChannelATM:
+		if !limits.ATMEnabled {
+			return ErrChannelDisabled
+		}
+	default:
+		return ErrInvalidChannel
+	}
+	if auth.Country != homeCountry && !limits.ForeignEnabled {
+		return ErrChannelDisabled
 	}
-	logrus.Infof("Generated new card %s for account %s", cardID, accountID)
-	return cardNumber, expiry, cvv, nil
+	return nil
 }
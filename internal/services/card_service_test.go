@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"go_project/internal/models"
+	"go_project/internal/repositories"
+	"testing"
+	"time"
+)
+
+// pastSpending добавляет к расходам по карте сумму amount, потраченную десять дней назад:
+// хранилище в памяти проставляет авторизациям текущее время.
+type pastSpending struct {
+	repositories.Cards
+	amount float64
+}
+
+func (c pastSpending) SumAuthorizedSince(ctx context.Context, cardID string, since time.Time) (float64, error) {
+	total, err := c.Cards.SumAuthorizedSince(ctx, cardID, since)
+	if since.Before(time.Now().AddDate(0, 0, -10)) {
+		total += c.amount
+	}
+	return total, err
+}
+
+func TestAuthorizeRejected(t *testing.T) {
+	limits := models.CardLimits{PerTransactionLimit: 100, DailyLimit: 150, MonthlyLimit: 300,
+		OnlineEnabled: true, ContactlessEnabled: true, ATMEnabled: true}
+	tests := []struct {
+		name string
+		// prepare настраивает карту и возвращает её идентификатор
+		prepare func(t *testing.T, f *transferFixture, cardID string) string
+		auth    models.CardAuthorization
+		want    error
+	}{
+		{"per-transaction limit", nil,
+			models.CardAuthorization{Amount: 100.01, Channel: ChannelPOS}, ErrCardLimitExceeded},
+		{"daily limit", func(t *testing.T, f *transferFixture, cardID string) string {
+			auth := models.CardAuthorization{CardID: cardID, Amount: 90, Channel: ChannelPOS, Merchant: "shop"}
+			if _, err := f.cards.Authorize(context.Background(), f.userID, auth); err != nil {
+				t.Fatal(err)
+			}
+			return cardID
+		}, models.CardAuthorization{Amount: 70, Channel: ChannelPOS}, ErrCardLimitExceeded},
+		{"monthly limit", func(t *testing.T, f *transferFixture, cardID string) string {
+			f.cards.cardRepo = pastSpending{f.cards.cardRepo, 250}
+			return cardID
+		}, models.CardAuthorization{Amount: 60, Channel: ChannelPOS}, ErrCardLimitExceeded},
+		{"disabled channel", func(t *testing.T, f *transferFixture, cardID string) string {
+			disabled := limits
+			disabled.CardID, disabled.OnlineEnabled = cardID, false
+			if _, err := f.cards.UpdateLimits(context.Background(), f.userID, disabled); err != nil {
+				t.Fatal(err)
+			}
+			return cardID
+		}, models.CardAuthorization{Amount: 10, Channel: ChannelOnline}, ErrChannelDisabled},
+		{"foreign country", nil,
+			models.CardAuthorization{Amount: 10, Channel: ChannelPOS, Country: "de"}, ErrChannelDisabled},
+		{"blocked card", func(t *testing.T, f *transferFixture, cardID string) string {
+			if err := f.cards.cardRepo.SetStatus(context.Background(), cardID, models.CardStatusBlocked); err != nil {
+				t.Fatal(err)
+			}
+			return cardID
+		}, models.CardAuthorization{Amount: 10, Channel: ChannelPOS}, ErrCardBlocked},
+		{"frozen account", func(t *testing.T, f *transferFixture, cardID string) string {
+			if err := f.accounts.SetFrozen(context.Background(), f.from, true); err != nil {
+				t.Fatal(err)
+			}
+			return cardID
+		}, models.CardAuthorization{Amount: 10, Channel: ChannelPOS}, ErrAccountFrozen},
+		{"expired card", func(t *testing.T, f *transferFixture, cardID string) string {
+			card, err := f.cards.CreateVirtualCard(context.Background(), f.userID, f.from, models.CardTypeMerchantLocked, 0, time.Nanosecond)
+			if err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(time.Millisecond)
+			return card.ID
+		}, models.CardAuthorization{Amount: 10, Channel: ChannelPOS}, ErrCardExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newTransferFixture(t, 1000)
+			card, err := f.cards.CreateCard(ctx, f.userID, f.from)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cardLimits := limits
+			cardLimits.CardID = card.ID
+			if _, err := f.cards.UpdateLimits(ctx, f.userID, cardLimits); err != nil {
+				t.Fatal(err)
+			}
+			auth := tt.auth
+			auth.CardID, auth.Merchant = card.ID, "shop"
+			if tt.prepare != nil {
+				auth.CardID = tt.prepare(t, f, card.ID)
+			}
+			before := f.balance(t, f.from)
+			if _, err := f.cards.Authorize(ctx, f.userID, auth); !errors.Is(err, tt.want) {
+				t.Fatalf("Authorize err = %v, want %v", err, tt.want)
+			}
+			if after := f.balance(t, f.from); after != before {
+				t.Fatalf("balance = %v, want unchanged %v", after, before)
+			}
+		})
+	}
+}
+
+func TestVirtualCardAuthorization(t *testing.T) {
+	ctx := context.Background()
+	authorize := func(f *transferFixture, cardID, merchant string, amount float64) error {
+		_, err := f.cards.Authorize(ctx, f.userID, models.CardAuthorization{CardID: cardID, Amount: amount, Channel: ChannelOnline, Merchant: merchant})
+		return err
+	}
+	issue := func(t *testing.T, f *transferFixture, cardType string, amountCap float64, ttl time.Duration) string {
+		t.Helper()
+		card, err := f.cards.CreateVirtualCard(ctx, f.userID, f.from, cardType, amountCap, ttl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return card.ID
+	}
+
+	t.Run("single use", func(t *testing.T) {
+		f := newTransferFixture(t, 1000)
+		cardID := issue(t, f, models.CardTypeSingleUse, 0, 0)
+		if err := authorize(f, cardID, "shop", 10); err != nil {
+			t.Fatal(err)
+		}
+		if err := authorize(f, cardID, "shop", 10); !errors.Is(err, ErrCardDestroyed) {
+			t.Fatalf("second Authorize err = %v, want %v", err, ErrCardDestroyed)
+		}
+		if balance := f.balance(t, f.from); balance != 990 {
+			t.Fatalf("balance = %v, want 990", balance)
+		}
+	})
+
+	t.Run("merchant lock", func(t *testing.T) {
+		f := newTransferFixture(t, 1000)
+		cardID := issue(t, f, models.CardTypeMerchantLocked, 0, 0)
+		if err := authorize(f, cardID, "shop", 10); err != nil {
+			t.Fatal(err)
+		}
+		if err := authorize(f, cardID, "other-shop", 10); !errors.Is(err, ErrMerchantMismatch) {
+			t.Fatalf("Authorize at another merchant err = %v, want %v", err, ErrMerchantMismatch)
+		}
+		// Продавец сравнивается без учёта регистра
+		if err := authorize(f, cardID, "SHOP", 10); err != nil {
+			t.Fatal(err)
+		}
+		if balance := f.balance(t, f.from); balance != 980 {
+			t.Fatalf("balance = %v, want 980", balance)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		f := newTransferFixture(t, 1000)
+		cardID := issue(t, f, models.CardTypeSingleUse, 0, time.Nanosecond)
+		time.Sleep(time.Millisecond)
+		if err := authorize(f, cardID, "shop", 10); !errors.Is(err, ErrCardExpired) {
+			t.Fatalf("Authorize err = %v, want %v", err, ErrCardExpired)
+		}
+		if balance := f.balance(t, f.from); balance != 1000 {
+			t.Fatalf("balance = %v, want unchanged", balance)
+		}
+	})
+
+	t.Run("amount cap", func(t *testing.T) {
+		f := newTransferFixture(t, 1000)
+		cardID := issue(t, f, models.CardTypeMerchantLocked, 50, 0)
+		if err := authorize(f, cardID, "shop", 50.01); !errors.Is(err, ErrAmountCapExceeded) {
+			t.Fatalf("Authorize over the cap err = %v, want %v", err, ErrAmountCapExceeded)
+		}
+		if err := authorize(f, cardID, "shop", 30); err != nil {
+			t.Fatal(err)
+		}
+		// Лимит считается по всем операциям карты, а не по одной
+		if err := authorize(f, cardID, "shop", 20.01); !errors.Is(err, ErrAmountCapExceeded) {
+			t.Fatalf("Authorize over the remaining cap err = %v, want %v", err, ErrAmountCapExceeded)
+		}
+		if balance := f.balance(t, f.from); balance != 970 {
+			t.Fatalf("balance = %v, want 970", balance)
+		}
+	})
+}
@@ -1,9 +1,11 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/sirupsen/logrus"
+	"go_project/internal/metrics"
 	"go_project/internal/models"
 	"go_project/internal/repositories"
 	"math"
@@ -11,13 +13,14 @@ import (
 )
 
 type CreditService struct {
-	creditRepo   *repositories.CreditRepository
-	accountRepo  *repositories.AccountRepository
-	scheduleRepo *repositories.PaymentScheduleRepository
+	uow          repositories.UnitOfWork
+	creditRepo   repositories.Credits
+	accountRepo  repositories.Accounts
+	scheduleRepo repositories.PaymentSchedules
 }
 
-func NewCreditService(creditRepo *repositories.CreditRepository, accountRepo *repositories.AccountRepository, scheduleRepo *repositories.PaymentScheduleRepository) *CreditService {
-	return &CreditService{creditRepo: creditRepo, accountRepo: accountRepo, scheduleRepo: scheduleRepo}
+func NewCreditService(uow repositories.UnitOfWork, creditRepo repositories.Credits, accountRepo repositories.Accounts, scheduleRepo repositories.PaymentSchedules) *CreditService {
+	return &CreditService{uow: uow, creditRepo: creditRepo, accountRepo: accountRepo, scheduleRepo: scheduleRepo}
 }
 
 var (
@@ -25,19 +28,19 @@ var (
 )
 
 // GetPaymentSchedule возвращает график платежей по кредиту после проверки прав доступа.
-func (s *CreditService) GetPaymentSchedule(userID, creditID string) ([]models.PaymentSchedule, error) {
-	cred, err := s.creditRepo.GetById(creditID)
+func (s *CreditService) GetPaymentSchedule(ctx context.Context, userID, creditID string) ([]models.PaymentSchedule, error) {
+	cred, err := s.creditRepo.GetById(ctx, creditID)
 	if err != nil {
 		return nil, err
 	}
-	acc, err := s.accountRepo.GetByID(cred.AccountID)
+	acc, err := s.accountRepo.GetByID(ctx, cred.AccountID)
 	if err != nil {
 		return nil, err
 	}
 	if acc.UserID != userID {
 		return nil, ErrForbidden
 	}
-	payment, err := s.scheduleRepo.GetByCreditID(creditID)
+	payment, err := s.scheduleRepo.GetByCreditID(ctx, creditID)
 	if err != nil {
 		return nil, err
 	}
@@ -45,88 +48,107 @@ func (s *CreditService) GetPaymentSchedule(userID, creditID string) ([]models.Pa
 }
 
 // ProcessOverduePayments обрабатывает просроченные платежи: автосписание или начисление штрафов.
-func (s *CreditService) ProcessOverduePayments() {
-	overdueList, err := s.scheduleRepo.ListOverdue(time.Now())
+func (s *CreditService) ProcessOverduePayments(ctx context.Context) {
+	overdueList, err := s.scheduleRepo.ListOverdue(ctx, time.Now())
 	if err != nil {
-		logrus.Error("Failed to list overdue payments", err)
+		logrus.WithContext(ctx).Error("Failed to list overdue payments", err)
 		return
 	}
 	for _, ps := range overdueList {
-		cred, err := s.creditRepo.GetById(ps.CreditID)
+		cred, err := s.creditRepo.GetById(ctx, ps.CreditID)
 		if err != nil {
-			logrus.Error("Credit not found for payment ", ps.ID)
+			logrus.WithContext(ctx).Error("Credit not found for payment ", ps.ID)
 			continue
 		}
-		acc, err := s.accountRepo.GetByID(cred.AccountID)
+		acc, err := s.accountRepo.GetByID(ctx, cred.AccountID)
 		if err != nil {
-			logrus.Error("Account not found for credit")
+			logrus.WithContext(ctx).Error("Account not found for credit ", cred.ID)
+			continue
 		}
 		if !ps.Paid {
-			if acc.Balance >= ps.Amount {
-				// Автосписание платежа
-				_, err := s.accountRepo.DB.Exec(`UPDATE accounts SET balance = balance - $1 
-   														WHERE id=$2`, ps.Amount, acc.ID)
-				if err != nil {
-					logrus.Error("Failed to deduct payment for schedule ", ps.ID, ": ", err)
-					continue
-				}
-				err = s.scheduleRepo.MarkAsPaid(ps.ID, time.Now())
-				if err != nil {
-					logrus.Error("Failed to mark payment as paid ", ps.ID, ": ", err)
+			// Автосписание платежа: списание и отметка об оплате выполняются в одной транзакции
+			paid := false
+			err := s.uow.Do(ctx, func(ctx context.Context) error {
+				ok, err := s.accountRepo.Debit(ctx, acc.ID, ps.Amount)
+				if err != nil || !ok {
+					return err
 				}
-				logrus.Infof("Auto-paid credit %s installment %.2f from account %s", cred.ID, ps.Amount, acc.ID)
+				paid = true
+				return s.scheduleRepo.MarkAsPaid(ctx, ps.ID, time.Now())
+			})
+			if err != nil {
+				logrus.WithContext(ctx).Error("Failed to deduct payment for schedule ", ps.ID, ": ", err)
+				metrics.OverdueInstallments.WithLabelValues(metrics.OverdueFailed).Inc()
+				continue
+			}
+			if paid {
+				metrics.OverdueInstallments.WithLabelValues(metrics.OverdueAutoPaid).Inc()
+				logrus.WithContext(ctx).Infof("Auto-paid credit %s installment %.2f from account %s", cred.ID, ps.Amount, acc.ID)
 			} else {
 				if ps.Penalty == 0 {
 					penaltyAmount := ps.Amount * 0.01
-					err = s.scheduleRepo.ApplyPenalty(ps.ID, penaltyAmount)
+					err = s.scheduleRepo.ApplyPenalty(ctx, ps.ID, penaltyAmount)
 					if err != nil {
-						logrus.Error("Failed to apply penalty for payment ", ps.ID, ": ", err)
+						logrus.WithContext(ctx).Error("Failed to apply penalty for payment ", ps.ID, ": ", err)
+						metrics.OverdueInstallments.WithLabelValues(metrics.OverdueFailed).Inc()
 					} else {
-						logrus.Warnf("Applied penalty %.2f for overdue payment %s", penaltyAmount, ps.ID)
+						logrus.WithContext(ctx).Warnf("Applied penalty %.2f for overdue payment %s", penaltyAmount, ps.ID)
+						metrics.OverdueInstallments.WithLabelValues(metrics.OverduePenaltyApplied).Inc()
+						metrics.PenaltiesApplied.Inc()
+						metrics.PenaltiesAmount.Add(penaltyAmount)
 					}
 				} else {
-					logrus.Warnf("Payment %s still overdue; penalty already applied", ps.ID)
+					logrus.WithContext(ctx).Warnf("Payment %s still overdue; penalty already applied", ps.ID)
+					metrics.OverdueInstallments.WithLabelValues(metrics.OverdueAlreadyPenalized).Inc()
 				}
 			}
 		}
 	}
 }
 
-// StartOverduePayments запускает фоновой планировщик проверки просроченных платежей каждые 12 часов.
-func (s *CreditService) StartOverduePayments() {
-	go func() {
-		ticker := time.NewTicker(12 * time.Hour)
-		for {
-			<-ticker.C
-			s.ProcessOverduePayments()
+// RunOverduePayments проверяет просроченные платежи каждые 12 часов, пока не отменён ctx.
+func (s *CreditService) RunOverduePayments(ctx context.Context) {
+	ticker := time.NewTicker(12 * time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.ProcessOverduePayments(ctx)
 		}
-	}()
+	}
 }
 
-func (s *CreditService) CreateCredit(userID string, accountID string, amount float64, interest float64, termMonths int) (*models.Credit, error) {
-	acc, err := s.accountRepo.GetByID(accountID)
+func (s *CreditService) CreateCredit(ctx context.Context, userID string, accountID string, amount float64, interest float64, termMonths int) (credit *models.Credit, err error) {
+	ctx, span := tracer.Start(ctx, "CreditService.CreateCredit")
+	defer func() { endSpan(span, err) }()
+	acc, err := s.accountRepo.GetByID(ctx, accountID)
 	if err != nil {
 		return nil, ErrAccountNotFound
 	}
 	if acc.UserID != userID {
 		return nil, ErrForbidden
 	}
-	// Создать кредит
-	creditID, err := s.creditRepo.CreateCredit(accountID, amount, interest, termMonths)
-	if err != nil {
-		return nil, err
-	}
-	// Обновляем баланс счёта — добавляем сумму кредита
-	err = s.accountRepo.AddBalance(accountID, amount)
-	if err != nil {
-		return nil, err
-	}
-	// Создать график платежей (аннуитетный)
-	err = s.createPaymentSchedule(creditID, amount, interest, termMonths)
+	// Кредит, зачисление суммы и график платежей создаются в одной транзакции
+	var creditID string
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		var err error
+		creditID, err = s.creditRepo.CreateCredit(ctx, accountID, amount, interest, termMonths)
+		if err != nil {
+			return err
+		}
+		// Обновляем баланс счёта — добавляем сумму кредита
+		if err := s.accountRepo.AddBalance(ctx, accountID, amount); err != nil {
+			return err
+		}
+		// Создать график платежей (аннуитетный)
+		return s.createPaymentSchedule(ctx, creditID, amount, interest, termMonths)
+	})
 	if err != nil {
 		return nil, err
 	}
-	credit := &models.Credit{
+	credit = &models.Credit{
 		ID:           creditID,
 		AccountID:    accountID,
 		Amount:       amount,
@@ -134,10 +156,12 @@ func (s *CreditService) CreateCredit(userID string, accountID string, amount flo
 		TermMonths:   termMonths,
 		StartDate:    time.Now(),
 	}
+	metrics.CreditsIssued.Inc()
+	metrics.CreditsIssuedAmount.Add(amount)
 	return credit, nil
 }
 
-func (s *CreditService) createPaymentSchedule(creditID string, principal float64, annualInterest float64, months int) error {
+func (s *CreditService) createPaymentSchedule(ctx context.Context, creditID string, principal float64, annualInterest float64, months int) error {
 	monthlyRate := annualInterest / 1200
 	annuity := principal * (monthlyRate * math.Pow(1+monthlyRate, float64(months))) / (math.Pow(1+monthlyRate, float64(months)) - 1)
 	if math.IsNaN(annuity) || annuity <= 0 {
@@ -145,24 +169,12 @@ func (s *CreditService) createPaymentSchedule(creditID string, principal float64
 	}
 
 	paymentDate := time.Now().AddDate(0, 1, 0)
-	tx, err := s.creditRepo.DB.Begin()
-	if err != nil {
-		return err
-	}
-	defer func() {
-		if err != nil {
-			tx.Rollback()
-		}
-	}()
-
 	for i := 0; i < months; i++ {
-		_, err = tx.Exec(`INSERT INTO payment_schedules (id, credit_id, due_date, amount, is_paid, penalty) 
-							    VALUES (gen_random_uuid(), $1, $2, $3, FALSE, 0)`, creditID, paymentDate, annuity)
-		if err != nil {
+		ps := &models.PaymentSchedule{CreditID: creditID, DueDate: paymentDate, Amount: annuity}
+		if err := s.scheduleRepo.Create(ctx, ps); err != nil {
 			return err
 		}
 		paymentDate = paymentDate.AddDate(0, 1, 0)
 	}
-	err = tx.Commit()
-	return err
+	return nil
 }
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"go_project/internal/models"
+	"go_project/internal/repositories/memory"
+	"math"
+	"testing"
+	"time"
+)
+
+type creditFixture struct {
+	service   *CreditService
+	accounts  *memory.AccountRepository
+	credits   *memory.CreditRepository
+	schedules *memory.PaymentScheduleRepository
+	userID    string
+	accountID string
+}
+
+func newCreditFixture(t *testing.T, balance float64) *creditFixture {
+	t.Helper()
+	ctx := context.Background()
+	store := memory.NewStore()
+	f := &creditFixture{
+		accounts:  memory.NewAccountRepository(store),
+		credits:   memory.NewCreditRepository(store),
+		schedules: memory.NewPaymentScheduleRepository(store),
+	}
+	f.service = NewCreditService(store, f.credits, f.accounts, f.schedules)
+
+	var err error
+	if f.userID, err = memory.NewUserRepository(store).CreateUser(ctx, "alice@example.com", "alice", "hash"); err != nil {
+		t.Fatal(err)
+	}
+	if f.accountID, err = f.accounts.CreateAccount(ctx, f.userID); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.accounts.AddBalance(ctx, f.accountID, balance); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// overduePayment создаёт кредит с одним платежом, просроченным на неделю.
+func (f *creditFixture) overduePayment(t *testing.T, amount float64) string {
+	t.Helper()
+	ctx := context.Background()
+	creditID, err := f.credits.CreateCredit(ctx, f.accountID, amount, 12, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps := &models.PaymentSchedule{CreditID: creditID, DueDate: time.Now().AddDate(0, 0, -7), Amount: amount}
+	if err := f.schedules.Create(ctx, ps); err != nil {
+		t.Fatal(err)
+	}
+	return creditID
+}
+
+func (f *creditFixture) payment(t *testing.T, creditID string) models.PaymentSchedule {
+	t.Helper()
+	schedule, err := f.schedules.GetByCreditID(context.Background(), creditID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schedule) != 1 {
+		t.Fatalf("schedule = %+v, want one payment", schedule)
+	}
+	return schedule[0]
+}
+
+func (f *creditFixture) balance(t *testing.T) float64 {
+	t.Helper()
+	balance, err := f.accounts.GetBalance(context.Background(), f.accountID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return balance
+}
+
+func TestCreateCredit(t *testing.T) {
+	f := newCreditFixture(t, 0)
+	credit, err := f.service.CreateCredit(context.Background(), f.userID, f.accountID, 12000, 12, 12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := f.balance(t); got != 12000 {
+		t.Fatalf("balance = %v, want the credit amount", got)
+	}
+
+	schedule, err := f.service.GetPaymentSchedule(context.Background(), f.userID, credit.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(schedule) != 12 {
+		t.Fatalf("schedule has %d payments, want 12", len(schedule))
+	}
+	// Аннуитет 12000 под 12% годовых на 12 месяцев — 1066.19 в месяц
+	var total float64
+	for i, ps := range schedule {
+		if ps.Amount != 1066.19 {
+			t.Fatalf("payment %d = %v, want 1066.19", i, ps.Amount)
+		}
+		if i > 0 && !ps.DueDate.After(schedule[i-1].DueDate) {
+			t.Fatalf("payments are not monthly: %+v", schedule)
+		}
+		total += ps.Amount
+	}
+	if math.Abs(total-12794.28) > 0.01 {
+		t.Fatalf("total = %v, want 12794.28", total)
+	}
+}
+
+func TestCreateCreditRejected(t *testing.T) {
+	f := newCreditFixture(t, 0)
+	if _, err := f.service.CreateCredit(context.Background(), "another-user", f.accountID, 1000, 12, 12); !errors.Is(err, ErrForbidden) {
+		t.Fatalf("CreateCredit for foreign account err = %v, want %v", err, ErrForbidden)
+	}
+	// При нулевой ставке аннуитет не рассчитывается, и вместе с графиком откатывается зачисление суммы
+	if _, err := f.service.CreateCredit(context.Background(), f.userID, f.accountID, 1000, 0, 12); err == nil {
+		t.Fatal("CreateCredit with zero interest succeeded")
+	}
+	if got := f.balance(t); got != 0 {
+		t.Fatalf("balance = %v, want the credit rolled back", got)
+	}
+}
+
+func TestProcessOverduePayments(t *testing.T) {
+	t.Run("auto-pay", func(t *testing.T) {
+		f := newCreditFixture(t, 500)
+		creditID := f.overduePayment(t, 200)
+		f.service.ProcessOverduePayments(context.Background())
+		if ps := f.payment(t, creditID); !ps.Paid || ps.PaidDate == nil || ps.Penalty != 0 {
+			t.Fatalf("payment = %+v, want paid without penalty", ps)
+		}
+		if got := f.balance(t); got != 300 {
+			t.Fatalf("balance = %v, want 300", got)
+		}
+	})
+
+	t.Run("penalty", func(t *testing.T) {
+		f := newCreditFixture(t, 50)
+		creditID := f.overduePayment(t, 200)
+		// Штраф начисляется один раз, сколько бы раз ни запускалась обработка
+		f.service.ProcessOverduePayments(context.Background())
+		f.service.ProcessOverduePayments(context.Background())
+		if ps := f.payment(t, creditID); ps.Paid || ps.Penalty != 2 {
+			t.Fatalf("payment = %+v, want unpaid with a 2.00 penalty", ps)
+		}
+		if got := f.balance(t); got != 50 {
+			t.Fatalf("balance = %v, want unchanged", got)
+		}
+	})
+}
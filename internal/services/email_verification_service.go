@@ -0,0 +1,176 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/sirupsen/logrus"
+	"go_project/internal/models"
+	"golang.org/x/crypto/bcrypt"
+	"net/url"
+	"time"
+)
+
+const (
+	// verifyTokenTTL — срок действия ссылки для подтверждения email
+	verifyTokenTTL = 24 * time.Hour
+	// resetTokenTTL — срок действия ссылки для сброса пароля
+	resetTokenTTL = time.Hour
+)
+
+var (
+	ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+	ErrEmailAlreadyVerified     = errors.New("email is already verified")
+	ErrInvalidResetToken        = errors.New("invalid or expired password reset token")
+	ErrEmailNotVerified         = errors.New("email is not verified")
+)
+
+// emailLinkData — данные для шаблонов писем со ссылкой
+type emailLinkData struct {
+	Username string
+	Link     string
+	ValidFor string
+}
+
+// VerifyEmail подтверждает адрес по токену из письма. Токен привязан к адресу, на который
+// был отправлен, поэтому после смены email старые ссылки перестают действовать.
+func (s *AuthService) VerifyEmail(ctx context.Context, token string) error {
+	userID, claims, err := s.parseTokenClaims(ctx, token, tokenTypeVerify)
+	if err != nil {
+		return ErrInvalidVerificationToken
+	}
+	email, _ := claims["email"].(string)
+	ok, err := s.userRepo.SetEmailVerified(ctx, userID, email)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidVerificationToken
+	}
+	logrus.WithContext(ctx).Infof("User %s verified email", userID)
+	return nil
+}
+
+// ResendVerification повторно отправляет письмо со ссылкой для подтверждения email.
+func (s *AuthService) ResendVerification(ctx context.Context, userID string) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerified {
+		return ErrEmailAlreadyVerified
+	}
+	return s.sendVerificationEmail(ctx, user, "Confirm your email", "verify_email.html")
+}
+
+// RequestPasswordReset отправляет письмо со ссылкой для сброса пароля. Чтобы по ответу нельзя было
+// определить, зарегистрирован ли адрес, ошибки поиска пользователя не возвращаются, а письмо
+// отправляется в фоне.
+func (s *AuthService) RequestPasswordReset(ctx context.Context, email string) {
+	user, err := s.userRepo.GetByEmail(ctx, email)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			logrus.WithContext(ctx).Error("Failed to look up user for password reset: ", err)
+		}
+		return
+	}
+	// Письмо отправляется после ответа клиенту, поэтому завершение запроса не должно его прерывать
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		token, err := s.signToken(ctx, jwt.MapClaims{
+			"user_id": user.ID,
+			"typ":     tokenTypeReset,
+			"pwd":     passwordFingerprint(user.PasswordHash),
+			"exp":     time.Now().Add(resetTokenTTL).Unix(),
+		})
+		if err != nil {
+			logrus.WithContext(ctx).Error("Failed to sign password reset token: ", err)
+			return
+		}
+		data := emailLinkData{Username: user.Username, Link: s.emailLink("/password/reset", token), ValidFor: "1 час"}
+		if err := s.mailer.SendTemplateEmail(ctx, user.Email, "Password reset", "password_reset.html", data); err != nil {
+			logrus.WithContext(ctx).Errorf("Failed to send password reset email to %s: %v", user.Email, err)
+			return
+		}
+		logrus.WithContext(ctx).Infof("Password reset requested for user %s", user.ID)
+	}()
+}
+
+// ResetPassword задаёт новый пароль по токену из письма и завершает все сессии пользователя.
+// Токен содержит отпечаток текущего хеша пароля, поэтому после смены пароля он становится недействительным.
+func (s *AuthService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	userID, claims, err := s.parseTokenClaims(ctx, token, tokenTypeReset)
+	if err != nil {
+		return ErrInvalidResetToken
+	}
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrInvalidResetToken
+	}
+	if err != nil {
+		return err
+	}
+	if fingerprint, _ := claims["pwd"].(string); fingerprint != passwordFingerprint(user.PasswordHash) {
+		return ErrInvalidResetToken
+	}
+	if err := s.validateNewPassword(newPassword, user.Username, user.Email); err != nil {
+		return err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	ok, err := s.userRepo.UpdatePassword(ctx, userID, user.PasswordHash, string(hash))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidResetToken
+	}
+	if err := s.LogoutAll(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.mailer.SendTemplateEmail(ctx, user.Email, "Your password was changed", "password_changed.html", user); err != nil {
+		logrus.WithContext(ctx).Errorf("Failed to send password change notice to %s: %v", user.Email, err)
+	}
+	logrus.WithContext(ctx).Infof("User %s reset password", userID)
+	return nil
+}
+
+// IsEmailVerified сообщает, подтвердил ли пользователь адрес электронной почты.
+func (s *AuthService) IsEmailVerified(ctx context.Context, userID string) (bool, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return user.EmailVerified, nil
+}
+
+// sendVerificationEmail отправляет письмо по шаблону template со ссылкой для подтверждения адреса.
+func (s *AuthService) sendVerificationEmail(ctx context.Context, user *models.User, subject, template string) error {
+	token, err := s.signToken(ctx, jwt.MapClaims{
+		"user_id": user.ID,
+		"typ":     tokenTypeVerify,
+		"email":   user.Email,
+		"exp":     time.Now().Add(verifyTokenTTL).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+	data := emailLinkData{Username: user.Username, Link: s.emailLink("/verify-email", token), ValidFor: "24 часа"}
+	return s.mailer.SendTemplateEmail(ctx, user.Email, subject, template, data)
+}
+
+// emailLink формирует ссылку на страницу клиента, которая передаёт токен в API.
+func (s *AuthService) emailLink(path, token string) string {
+	return s.publicURL + path + "?token=" + url.QueryEscape(token)
+}
+
+// passwordFingerprint возвращает короткий отпечаток хеша пароля для привязки токена сброса.
+func passwordFingerprint(passwordHash string) string {
+	sum := sha256.Sum256([]byte("pwd|" + passwordHash))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,411 @@
+package services
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/sirupsen/logrus"
+	"go_project/internal/keyring"
+	"go_project/internal/models"
+	"go_project/internal/repositories"
+	"math/big"
+	"sync"
+	"time"
+)
+
+// Алгоритмы подписи JWT
+const (
+	JWTAlgorithmHS256 = "HS256"
+	JWTAlgorithmRS256 = "RS256"
+	JWTAlgorithmEdDSA = "EdDSA"
+)
+
+const (
+	// DefaultJWTKeyRotation — период ротации ключей подписи по умолчанию
+	DefaultJWTKeyRotation = 30 * 24 * time.Hour
+	// jwtKeyPublishLead — за сколько до начала использования новый ключ публикуется в JWKS,
+	// чтобы другие сервисы успели обновить кэш открытых ключей
+	jwtKeyPublishLead = time.Hour
+	// jwtKeyRetention — сколько старый ключ остаётся в JWKS после замены. Должно быть не меньше
+	// срока жизни самых долгоживущих подписанных токенов (ссылка подтверждения email — 24 часа).
+	jwtKeyRetention = 48 * time.Hour
+	// jwtKeyReloadInterval — как часто экземпляр перечитывает ключи, выпущенные другими экземплярами
+	jwtKeyReloadInterval = 10 * time.Minute
+	// jwtUnknownKidReloadDelay ограничивает перечитывание ключей при встрече неизвестного kid
+	jwtUnknownKidReloadDelay = 10 * time.Second
+	rsaKeyBits               = 2048
+)
+
+var (
+	ErrInvalidJWTAlgorithm = errors.New("unsupported jwt algorithm")
+	ErrNoSigningKey        = errors.New("no active jwt signing key")
+)
+
+// loadedKey — ключ подписи с разобранными ключами
+type loadedKey struct {
+	meta    models.SigningKey
+	private crypto.Signer
+	public  crypto.PublicKey
+}
+
+// JWTKeyService подписывает и проверяет JWT. В режиме RS256/EdDSA ключи хранятся в БД,
+// идентифицируются заголовком kid и регулярно ротируются; открытые ключи публикуются через JWKS.
+// При переходе с HS256 токены, подписанные jwt_secret, принимаются до момента hs256Until —
+// это позволяет сменить подпись без принудительного выхода пользователей. После него
+// и без явно заданного срока принимаются только токены с асимметричной подписью.
+type JWTKeyService struct {
+	repo       repositories.SigningKeys
+	keys       *keyring.Keyring
+	algorithm  string
+	secret     string
+	hs256Until time.Time
+	issuer     string
+	audience   string
+	rotation   time.Duration
+
+	mu         sync.RWMutex
+	loaded     []loadedKey // от новых к старым
+	lastReload time.Time
+}
+
+// NewJWTKeyService создаёт сервис подписи. hs256Until учитывается только в режиме RS256/EdDSA:
+// до этого момента принимаются ранее выданные токены HS256, нулевое значение отключает их сразу.
+func NewJWTKeyService(repo repositories.SigningKeys, keys *keyring.Keyring, algorithm, secret string, hs256Until time.Time, issuer, audience string, rotation time.Duration) (*JWTKeyService, error) {
+	if algorithm == "" {
+		algorithm = JWTAlgorithmHS256
+	}
+	switch algorithm {
+	case JWTAlgorithmHS256:
+		if secret == "" {
+			return nil, errors.New("jwt_secret is required for HS256")
+		}
+		hs256Until = time.Time{}
+	case JWTAlgorithmRS256, JWTAlgorithmEdDSA:
+		if !hs256Until.IsZero() && secret == "" {
+			return nil, errors.New("jwt_secret is required to accept HS256 tokens until jwt_hs256_accept_until")
+		}
+	default:
+		return nil, ErrInvalidJWTAlgorithm
+	}
+	if rotation <= 0 {
+		rotation = DefaultJWTKeyRotation
+	}
+	return &JWTKeyService{
+		repo:       repo,
+		keys:       keys,
+		algorithm:  algorithm,
+		secret:     secret,
+		hs256Until: hs256Until,
+		issuer:     issuer,
+		audience:   audience,
+		rotation:   rotation,
+	}, nil
+}
+
+func (s *JWTKeyService) asymmetric() bool {
+	return s.algorithm != JWTAlgorithmHS256
+}
+
+// acceptsHS256 сообщает, принимаются ли сейчас токены, подписанные jwt_secret.
+func (s *JWTKeyService) acceptsHS256(now time.Time) bool {
+	if !s.asymmetric() {
+		return true
+	}
+	return now.Before(s.hs256Until)
+}
+
+// Sign дополняет claims полями iss и aud и подписывает токен текущим ключом.
+func (s *JWTKeyService) Sign(ctx context.Context, claims jwt.MapClaims) (string, error) {
+	if s.issuer != "" {
+		claims["iss"] = s.issuer
+	}
+	if s.audience != "" {
+		claims["aud"] = s.audience
+	}
+	if !s.asymmetric() {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(s.secret))
+	}
+	key, err := s.currentKey(ctx)
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.meta.Algorithm), claims)
+	token.Header["kid"] = key.meta.ID
+	return token.SignedString(key.private)
+}
+
+// Parse проверяет подпись, срок действия, издателя и аудиторию токена и возвращает его claims.
+func (s *JWTKeyService) Parse(ctx context.Context, tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return s.keyfunc(ctx, token)
+	})
+	if err != nil || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("invalid token claims")
+	}
+	if s.issuer != "" && !claims.VerifyIssuer(s.issuer, true) {
+		return nil, errors.New("invalid token issuer")
+	}
+	if s.audience != "" && !claims.VerifyAudience(s.audience, true) {
+		return nil, errors.New("invalid token audience")
+	}
+	return claims, nil
+}
+
+// keyfunc выбирает ключ проверки по алгоритму и kid токена.
+func (s *JWTKeyService) keyfunc(ctx context.Context, token *jwt.Token) (interface{}, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		if !s.acceptsHS256(time.Now()) || token.Method.Alg() != JWTAlgorithmHS256 {
+			return nil, fmt.Errorf("unexpected signing method")
+		}
+		return []byte(s.secret), nil
+	}
+	if !s.asymmetric() {
+		return nil, fmt.Errorf("unexpected signing method")
+	}
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, fmt.Errorf("missing kid")
+	}
+	key, ok := s.findKey(kid)
+	if !ok {
+		// Ключ мог быть выпущен другим экземпляром сервиса
+		if err := s.reloadIfStale(ctx, jwtUnknownKidReloadDelay); err != nil {
+			return nil, err
+		}
+		if key, ok = s.findKey(kid); !ok {
+			return nil, fmt.Errorf("unknown kid %q", kid)
+		}
+	}
+	if token.Method.Alg() != key.meta.Algorithm {
+		return nil, fmt.Errorf("unexpected signing method")
+	}
+	return key.public, nil
+}
+
+func (s *JWTKeyService) findKey(kid string) (loadedKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, k := range s.loaded {
+		if k.meta.ID == kid {
+			return k, true
+		}
+	}
+	return loadedKey{}, false
+}
+
+// currentKey возвращает самый новый ключ, период использования которого уже начался.
+func (s *JWTKeyService) currentKey(ctx context.Context) (loadedKey, error) {
+	if err := s.reloadIfStale(ctx, jwtKeyReloadInterval); err != nil {
+		return loadedKey{}, err
+	}
+	now := time.Now()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, k := range s.loaded {
+		if !k.meta.ActivatesAt.After(now) && k.meta.Algorithm == s.algorithm {
+			return k, nil
+		}
+	}
+	return loadedKey{}, ErrNoSigningKey
+}
+
+// JWKS возвращает открытые ключи, которыми подписаны или будут подписаны действующие токены.
+func (s *JWTKeyService) JWKS(ctx context.Context) (*models.JWKS, error) {
+	set := &models.JWKS{Keys: []models.JWK{}}
+	if !s.asymmetric() {
+		return set, nil
+	}
+	if err := s.reloadIfStale(ctx, jwtKeyReloadInterval); err != nil {
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, k := range s.loaded {
+		jwk := models.JWK{Use: "sig", Kid: k.meta.ID, Alg: k.meta.Algorithm}
+		switch pub := k.public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set, nil
+}
+
+// Init загружает ключи из БД и выпускает первый ключ, если подходящих ключей нет.
+func (s *JWTKeyService) Init(ctx context.Context) error {
+	if !s.asymmetric() {
+		return nil
+	}
+	if err := s.reload(ctx); err != nil {
+		return err
+	}
+	if _, err := s.currentKey(ctx); err == nil {
+		return nil
+	}
+	// Первый ключ начинает использоваться сразу: токенов, подписанных им, ещё никто не видел
+	return s.rotate(ctx, 0)
+}
+
+// RotateIfDue выпускает новый ключ, если самому новому ключу скоро исполнится период ротации.
+func (s *JWTKeyService) RotateIfDue(ctx context.Context) error {
+	if !s.asymmetric() {
+		return nil
+	}
+	if err := s.reload(ctx); err != nil {
+		return err
+	}
+	if err := s.repo.DeleteExpired(ctx, time.Now()); err != nil {
+		return err
+	}
+	s.mu.RLock()
+	var newest time.Time
+	if len(s.loaded) > 0 {
+		newest = s.loaded[0].meta.CreatedAt
+	}
+	s.mu.RUnlock()
+	if time.Since(newest) < s.rotation-jwtKeyPublishLead {
+		return nil
+	}
+	return s.rotate(ctx, jwtKeyPublishLead)
+}
+
+// RunRotation ежечасно проверяет необходимость ротации ключей подписи, пока не отменён ctx.
+func (s *JWTKeyService) RunRotation(ctx context.Context) {
+	if !s.asymmetric() {
+		return
+	}
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.RotateIfDue(ctx); err != nil {
+				logrus.WithContext(ctx).Error("JWT signing key rotation failed: ", err)
+			}
+		}
+	}
+}
+
+// rotate генерирует ключ, который начнёт использоваться через lead.
+func (s *JWTKeyService) rotate(ctx context.Context, lead time.Duration) error {
+	now := time.Now()
+	key, err := generateSigningKey(s.algorithm, now, now.Add(lead))
+	if err != nil {
+		return err
+	}
+	keyID, encKey := s.keys.Active()
+	created, err := s.repo.Rotate(ctx, key, keyID, encKey, key.ActivatesAt.Add(jwtKeyRetention), now.Add(-(s.rotation - jwtKeyPublishLead)))
+	if err != nil {
+		return err
+	}
+	if created {
+		logrus.WithContext(ctx).Infof("New JWT signing key %s (%s) activates at %s", key.ID, key.Algorithm, key.ActivatesAt.Format(time.RFC3339))
+	}
+	return s.reload(ctx)
+}
+
+func (s *JWTKeyService) reloadIfStale(ctx context.Context, maxAge time.Duration) error {
+	s.mu.RLock()
+	stale := time.Since(s.lastReload) >= maxAge
+	s.mu.RUnlock()
+	if !stale {
+		return nil
+	}
+	return s.reload(ctx)
+}
+
+func (s *JWTKeyService) reload(ctx context.Context) error {
+	stored, err := s.repo.ListValid(ctx, time.Now(), s.keys.Key)
+	if err != nil {
+		return err
+	}
+	loaded := make([]loadedKey, 0, len(stored))
+	for _, meta := range stored {
+		k, err := parseSigningKey(meta)
+		if err != nil {
+			logrus.WithContext(ctx).Errorf("Skipping invalid JWT signing key %s: %v", meta.ID, err)
+			continue
+		}
+		loaded = append(loaded, k)
+	}
+	s.mu.Lock()
+	s.loaded = loaded
+	s.lastReload = time.Now()
+	s.mu.Unlock()
+	return nil
+}
+
+// generateSigningKey создаёт пару ключей и кодирует её в PEM (PKCS#8 / PKIX).
+func generateSigningKey(algorithm string, now, activatesAt time.Time) (*models.SigningKey, error) {
+	var private crypto.Signer
+	var err error
+	switch algorithm {
+	case JWTAlgorithmRS256:
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	case JWTAlgorithmEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, ErrInvalidJWTAlgorithm
+	}
+	if err != nil {
+		return nil, err
+	}
+	privateDER, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(private.Public())
+	if err != nil {
+		return nil, err
+	}
+	kid, err := randomToken(12)
+	if err != nil {
+		return nil, err
+	}
+	return &models.SigningKey{
+		ID:            kid,
+		Algorithm:     algorithm,
+		PrivateKeyPEM: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER})),
+		PublicKeyPEM:  string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})),
+		CreatedAt:     now,
+		ActivatesAt:   activatesAt,
+	}, nil
+}
+
+func parseSigningKey(meta models.SigningKey) (loadedKey, error) {
+	block, _ := pem.Decode([]byte(meta.PrivateKeyPEM))
+	if block == nil {
+		return loadedKey{}, errors.New("invalid private key PEM")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return loadedKey{}, err
+	}
+	private, ok := parsed.(crypto.Signer)
+	if !ok {
+		return loadedKey{}, errors.New("unsupported private key type")
+	}
+	return loadedKey{meta: meta, private: private, public: private.Public()}, nil
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"github.com/golang-jwt/jwt/v4"
+	"go_project/internal/keyring"
+	"go_project/internal/repositories/memory"
+	"testing"
+	"time"
+)
+
+func TestJWTKeyServiceAcceptsHS256UntilCutoff(t *testing.T) {
+	ctx := context.Background()
+	keys, err := keyring.New("k1", map[string]string{"k1": "encryption-key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := NewJWTKeyService(memory.NewSigningKeyRepository(memory.NewStore()), keys, JWTAlgorithmHS256, "jwt-secret", time.Time{}, "bank", "bank-api", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := legacy.Sign(ctx, jwt.MapClaims{"sub": "user", "exp": time.Now().Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		hs256Until time.Time
+		accept     bool
+	}{
+		{"before cutoff", time.Now().Add(time.Hour), true},
+		{"after cutoff", time.Now().Add(-time.Second), false},
+		// Одного секрета без явного срока недостаточно
+		{"no cutoff", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewJWTKeyService(memory.NewSigningKeyRepository(memory.NewStore()), keys, JWTAlgorithmEdDSA, "jwt-secret", tt.hs256Until, "bank", "bank-api", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.Init(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Parse(ctx, token); (err == nil) != tt.accept {
+				t.Fatalf("Parse HS256 token err = %v, want accepted %v", err, tt.accept)
+			}
+			signed, err := s.Sign(ctx, jwt.MapClaims{"sub": "user", "exp": time.Now().Add(time.Hour).Unix()})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Parse(ctx, signed); err != nil {
+				t.Fatalf("Parse EdDSA token: %v", err)
+			}
+		})
+	}
+
+	if _, err := NewJWTKeyService(memory.NewSigningKeyRepository(memory.NewStore()), keys, JWTAlgorithmEdDSA, "", time.Now().Add(time.Hour), "bank", "bank-api", 0); err == nil {
+		t.Fatal("NewJWTKeyService accepted a cutoff without jwt_secret")
+	}
+}
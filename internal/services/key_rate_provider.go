@@ -0,0 +1,178 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/beevik/etree"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go_project/internal/metrics"
+	"golang.org/x/sync/singleflight"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	keyRateURL = "https://www.cbr.ru/DailyInfoWebServ/DailyInfo.asmx"
+	// keyRateCacheTTL — сколько используется полученное значение ставки; ЦБ меняет её не чаще раза в день
+	keyRateCacheTTL = time.Hour
+	keyRateTimeout  = 10 * time.Second
+)
+
+var (
+	ErrKeyRateNotFound = errors.New("key rate not found in the response")
+	ErrInvalidKeyRate  = errors.New("key rate must be positive")
+)
+
+// KeyRateStatus — состояние источника ключевой ставки для /readyz
+type KeyRateStatus struct {
+	Rate      float64    `json:"rate"`
+	FetchedAt *time.Time `json:"fetched_at,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+}
+
+// KeyRateProvider получает ключевую ставку у ЦБ РФ и кэширует её. Если ЦБ недоступен,
+// используется последнее полученное значение.
+type KeyRateProvider struct {
+	client *http.Client
+	// group объединяет параллельные запросы к ЦБ при пустом или устаревшем кэше
+	group singleflight.Group
+
+	mu        sync.Mutex
+	rate      float64
+	fetchedAt time.Time
+	lastErr   error
+}
+
+func NewKeyRateProvider() *KeyRateProvider {
+	return &KeyRateProvider{client: &http.Client{
+		Timeout:   keyRateTimeout,
+		Transport: otelhttp.NewTransport(http.DefaultTransport),
+	}}
+}
+
+// KeyRate возвращает ключевую ставку из кэша или запрашивает её у ЦБ РФ. Запрос выполняется
+// без блокировки кэша, а параллельные вызовы ждут один общий запрос.
+func (p *KeyRateProvider) KeyRate(ctx context.Context) (float64, error) {
+	if rate, ok := p.cached(); ok {
+		return rate, nil
+	}
+	// Общий запрос не отменяется вместе с контекстом вызвавшего его клиента: его ждут и другие;
+	// время запроса ограничено таймаутом HTTP-клиента
+	ch := p.group.DoChan("key-rate", func() (interface{}, error) {
+		return p.refresh(context.WithoutCancel(ctx))
+	})
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return 0, res.Err
+		}
+		return res.Val.(float64), nil
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
+
+// cached возвращает ставку из кэша, если она ещё не устарела.
+func (p *KeyRateProvider) cached() (float64, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.rate, !p.fetchedAt.IsZero() && time.Since(p.fetchedAt) < keyRateCacheTTL
+}
+
+// refresh запрашивает ставку у ЦБ и обновляет кэш. При ошибке возвращается последнее
+// полученное значение, а если его нет — ошибка; ошибки не кэшируются.
+func (p *KeyRateProvider) refresh(ctx context.Context) (float64, error) {
+	// Кэш мог обновить общий запрос, завершившийся перед вызовом DoChan
+	if rate, ok := p.cached(); ok {
+		return rate, nil
+	}
+	start := time.Now()
+	rate, err := p.fetch(ctx)
+	metrics.CBRRequestDuration.Observe(time.Since(start).Seconds())
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lastErr = err
+	if err != nil {
+		metrics.CBRRequestFailures.Inc()
+		if !p.fetchedAt.IsZero() {
+			logrus.WithContext(ctx).Warn("Failed to refresh the key rate, using the cached value: ", err)
+			return p.rate, nil
+		}
+		return 0, err
+	}
+	p.rate = rate
+	p.fetchedAt = time.Now()
+	return rate, nil
+}
+
+// Status возвращает последнее полученное значение и ошибку последнего запроса.
+func (p *KeyRateProvider) Status() KeyRateStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	st := KeyRateStatus{Rate: p.rate}
+	if !p.fetchedAt.IsZero() {
+		fetchedAt := p.fetchedAt
+		st.FetchedAt = &fetchedAt
+	}
+	if p.lastErr != nil {
+		st.LastError = p.lastErr.Error()
+	}
+	return st
+}
+
+// fetch запрашивает у ЦБ РФ последнее значение ключевой ставки за год.
+func (p *KeyRateProvider) fetch(ctx context.Context) (float64, error) {
+	toDate := time.Now().Format("2006-01-02") + "T00:00:00"
+	fromDate := time.Now().AddDate(-1, 0, 0).Format("2006-01-02") + "T00:00:00"
+	soapEnvelope := fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?>
+<soap:Envelope xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"
+               xmlns:xsd="http://www.w3.org/2001/XMLSchema"
+               xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/">
+  <soap:Body>
+    <KeyRate xmlns="http://web.cbr.ru/">
+      <fromDate>%s</fromDate>
+      <ToDate>%s</ToDate>
+    </KeyRate>
+  </soap:Body>
+</soap:Envelope>`, fromDate, toDate)
+	req, err := http.NewRequestWithContext(ctx, "POST", keyRateURL, strings.NewReader(soapEnvelope))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "text/xml; charset=utf-8")
+	req.Header.Set("SoapAction", "http://web.cbr.ru/KeyRate")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("key rate service returned %s", resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(body); err != nil {
+		return 0, err
+	}
+	elements := doc.FindElements("//Rate")
+	if len(elements) == 0 {
+		return 0, ErrKeyRateNotFound
+	}
+	keyRate, err := strconv.ParseFloat(strings.TrimSpace(elements[len(elements)-1].Text()), 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse key rate: %w", err)
+	}
+	if keyRate <= 0 {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidKeyRate, keyRate)
+	}
+	return keyRate, nil
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// keyRateTransport отвечает на запросы к ЦБ ставкой rate и считает запросы.
+type keyRateTransport struct {
+	rate    string
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (t *keyRateTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.calls.Add(1)
+	if t.release != nil {
+		<-t.release
+	}
+	body := fmt.Sprintf(`<?xml version="1.0" encoding="utf-8"?><Envelope><Body><KR><Rate>%s</Rate></KR></Body></Envelope>`, t.rate)
+	return &http.Response{StatusCode: http.StatusOK, Status: "200 OK", Body: io.NopCloser(strings.NewReader(body)), Request: req}, nil
+}
+
+func TestKeyRateProviderDoesNotCacheInvalidRate(t *testing.T) {
+	transport := &keyRateTransport{rate: "0"}
+	p := &KeyRateProvider{client: &http.Client{Transport: transport}}
+	for i := 0; i < 2; i++ {
+		if _, err := p.KeyRate(context.Background()); !errors.Is(err, ErrInvalidKeyRate) {
+			t.Fatalf("KeyRate err = %v, want %v", err, ErrInvalidKeyRate)
+		}
+	}
+	if calls := transport.calls.Load(); calls != 2 {
+		t.Fatalf("requests = %d, want 2: an invalid rate must not be cached", calls)
+	}
+	if st := p.Status(); st.FetchedAt != nil || st.LastError == "" {
+		t.Fatalf("Status = %+v, want no cached rate and the last error", st)
+	}
+
+	transport.rate = "16.5"
+	if rate, err := p.KeyRate(context.Background()); err != nil || rate != 16.5 {
+		t.Fatalf("KeyRate = %v, %v, want 16.5", rate, err)
+	}
+	// Неверный ответ после успешного не затирает кэш
+	transport.rate = "-1"
+	p.fetchedAt = p.fetchedAt.Add(-keyRateCacheTTL)
+	if rate, err := p.KeyRate(context.Background()); err != nil || rate != 16.5 {
+		t.Fatalf("KeyRate after a bad response = %v, %v, want the cached 16.5", rate, err)
+	}
+}
+
+func TestKeyRateProviderSharesFetch(t *testing.T) {
+	transport := &keyRateTransport{rate: "21", release: make(chan struct{})}
+	p := &KeyRateProvider{client: &http.Client{Transport: transport}}
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if rate, err := p.KeyRate(context.Background()); err != nil || rate != 21 {
+				t.Errorf("KeyRate = %v, %v, want 21", rate, err)
+			}
+		}()
+	}
+	// Ждём, пока один из вызовов не начнёт запрос
+	for transport.calls.Load() == 0 {
+		runtime.Gosched()
+	}
+	// Status не ждёт ответа ЦБ
+	if st := p.Status(); st.FetchedAt != nil {
+		t.Errorf("Status during the fetch = %+v", st)
+	}
+	close(transport.release)
+	wg.Wait()
+	if calls := transport.calls.Load(); calls != 1 {
+		t.Fatalf("requests = %d, want 1 shared request", calls)
+	}
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"go_project/internal/models"
+	"strings"
+	"time"
+)
+
+// Защита входа от подбора пароля и кодов второго фактора. Счётчики ведутся отдельно по email,
+// по IP-адресу и по пользователю (для кодов второго фактора): первые попытки проходят без задержки,
+// затем интервал между попытками растёт экспоненциально, а после maxEmailFailures (maxIPFailures)
+// неудач вход временно блокируется.
+const (
+	loginFailureWindow  = time.Hour // неудачи старше окна не учитываются
+	loginFreeAttempts   = 3
+	loginBackoffBase    = time.Second
+	loginBackoffMax     = 5 * time.Minute
+	emailCaptchaAfter   = 3
+	ipCaptchaAfter      = 10
+	maxEmailFailures    = 10
+	maxIPFailures       = 100
+	loginLockoutTimeout = 15 * time.Minute
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrTooManyAttempts    = errors.New("too many login attempts")
+)
+
+// LoginError сообщает клиенту, нужна ли CAPTCHA и через сколько можно повторить попытку.
+type LoginError struct {
+	Err             error
+	RetryAfter      time.Duration
+	CaptchaRequired bool
+}
+
+func (e *LoginError) Error() string { return e.Err.Error() }
+func (e *LoginError) Unwrap() error { return e.Err }
+
+func emailAttemptKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipAttemptKey(ip string) string {
+	return "ip:" + ip
+}
+
+func secondFactorAttemptKey(userID string) string {
+	return "2fa:" + userID
+}
+
+// loginAttempt — попытка, учтённая по одному ключу до проверки пароля или кода
+type loginAttempt struct {
+	key        string
+	failures   int
+	previousAt time.Time
+}
+
+// beginLoginAttempt атомарно учитывает попытку по каждому ключу до проверки пароля, поэтому
+// параллельные запросы не проходят проверку по одному и тому же значению счётчика. Если по одному
+// из ключей действует блокировка или ещё не истёк интервал экспоненциальной задержки, учёт
+// отменяется и возвращается LoginError с ErrTooManyAttempts. Учтённую попытку завершает
+// loginSucceeded, loginFailed или cancelLoginAttempt.
+func (s *AuthService) beginLoginAttempt(ctx context.Context, keys ...string) ([]loginAttempt, error) {
+	now := time.Now()
+	var attempts []loginAttempt
+	var retryAfter time.Duration
+	captcha := false
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		a, previousAt, err := s.loginAttemptRepo.RecordAttempt(ctx, key, now, now.Add(-loginFailureWindow))
+		if err != nil {
+			s.cancelLoginAttempt(ctx, attempts)
+			return nil, err
+		}
+		attempts = append(attempts, loginAttempt{key: key, failures: a.Failures, previousAt: previousAt})
+		// Решение принимается по неудачам, учтённым до этой попытки
+		previous := a.Failures - 1
+		captcha = captcha || captchaRequired(key, previous)
+		if a.LockedUntil != nil && now.Before(*a.LockedUntil) {
+			retryAfter = maxDuration(retryAfter, a.LockedUntil.Sub(now))
+		}
+		if next := previousAt.Add(loginBackoff(previous)); previous > 0 && now.Before(next) {
+			retryAfter = maxDuration(retryAfter, next.Sub(now))
+		}
+	}
+	if retryAfter > 0 {
+		s.cancelLoginAttempt(ctx, attempts)
+		return nil, &LoginError{Err: ErrTooManyAttempts, RetryAfter: retryAfter, CaptchaRequired: captcha}
+	}
+	return attempts, nil
+}
+
+// loginFailed оставляет попытки учтёнными как неудачные, при превышении порога блокирует вход
+// и уведомляет владельца учётной записи.
+func (s *AuthService) loginFailed(ctx context.Context, attempts []loginAttempt, user *models.User) error {
+	until := time.Now().Add(loginLockoutTimeout)
+	for _, a := range attempts {
+		limit := maxEmailFailures
+		if isIPAttemptKey(a.key) {
+			limit = maxIPFailures
+		}
+		if a.failures < limit {
+			continue
+		}
+		if err := s.loginAttemptRepo.Lock(ctx, a.key, until); err != nil {
+			return err
+		}
+		if a.failures == limit {
+			logrus.WithContext(ctx).Warnf("Login locked for %s after %d failed attempts", a.key, a.failures)
+			if user != nil && !isIPAttemptKey(a.key) {
+				go s.sendLockoutEmail(context.WithoutCancel(ctx), user)
+			}
+		}
+	}
+	return nil
+}
+
+// loginSucceeded сбрасывает счётчики email (или второго фактора) после успешной проверки. Попытка
+// лишь не засчитывается в счётчик IP: успешный вход в свою учётную запись не должен позволять
+// продолжать подбор чужих паролей.
+func (s *AuthService) loginSucceeded(ctx context.Context, attempts []loginAttempt) {
+	for _, a := range attempts {
+		var err error
+		if isIPAttemptKey(a.key) {
+			err = s.loginAttemptRepo.Release(ctx, a.key, a.previousAt)
+		} else {
+			err = s.loginAttemptRepo.Reset(ctx, a.key)
+		}
+		if err != nil {
+			logrus.WithContext(ctx).Error("Failed to reset login attempts: ", err)
+		}
+	}
+}
+
+// cancelLoginAttempt отменяет учёт попыток, которые не дошли до проверки пароля или кода.
+func (s *AuthService) cancelLoginAttempt(ctx context.Context, attempts []loginAttempt) {
+	for _, a := range attempts {
+		if err := s.loginAttemptRepo.Release(ctx, a.key, a.previousAt); err != nil {
+			logrus.WithContext(ctx).Error("Failed to release login attempt: ", err)
+		}
+	}
+}
+
+// loginCaptchaRequired сообщает, нужна ли CAPTCHA перед следующей попыткой.
+func loginCaptchaRequired(attempts []loginAttempt) bool {
+	for _, a := range attempts {
+		if captchaRequired(a.key, a.failures) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *AuthService) sendLockoutEmail(ctx context.Context, user *models.User) {
+	data := struct {
+		Username string
+		Duration string
+	}{user.Username, "15 минут"}
+	if err := s.mailer.SendTemplateEmail(ctx, user.Email, "Sign-in temporarily locked", "account_locked.html", data); err != nil {
+		logrus.WithContext(ctx).Errorf("Failed to send lockout email to %s: %v", user.Email, err)
+	}
+}
+
+// loginBackoff возвращает минимальный интервал до следующей попытки после failures неудач.
+func loginBackoff(failures int) time.Duration {
+	if failures <= loginFreeAttempts {
+		return 0
+	}
+	shift := failures - loginFreeAttempts - 1
+	if shift >= 16 {
+		return loginBackoffMax
+	}
+	delay := loginBackoffBase << uint(shift)
+	if delay > loginBackoffMax {
+		return loginBackoffMax
+	}
+	return delay
+}
+
+func captchaRequired(key string, failures int) bool {
+	switch {
+	case isIPAttemptKey(key):
+		return failures >= ipCaptchaAfter
+	case strings.HasPrefix(key, "2fa:"):
+		// Коды второго фактора вводит уже вошедший пользователь, CAPTCHA для них не показывается
+		return false
+	}
+	return failures >= emailCaptchaAfter
+}
+
+func isIPAttemptKey(key string) bool {
+	return strings.HasPrefix(key, "ip:")
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
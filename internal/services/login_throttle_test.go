@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoginBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 0},
+		{1, 0},
+		{loginFreeAttempts, 0},
+		{loginFreeAttempts + 1, time.Second},
+		{loginFreeAttempts + 2, 2 * time.Second},
+		{loginFreeAttempts + 5, 16 * time.Second},
+		{loginFreeAttempts + 9, 256 * time.Second},
+		// Дальше задержка ограничена loginBackoffMax, в том числе без переполнения сдвига
+		{loginFreeAttempts + 10, loginBackoffMax},
+		{maxIPFailures, loginBackoffMax},
+		{1 << 20, loginBackoffMax},
+	}
+	for _, tt := range tests {
+		if got := loginBackoff(tt.failures); got != tt.want {
+			t.Errorf("loginBackoff(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestCaptchaRequired(t *testing.T) {
+	tests := []struct {
+		key      string
+		failures int
+		want     bool
+	}{
+		{emailAttemptKey("a@example.com"), emailCaptchaAfter - 1, false},
+		{emailAttemptKey("a@example.com"), emailCaptchaAfter, true},
+		{ipAttemptKey("10.0.0.1"), emailCaptchaAfter, false},
+		{ipAttemptKey("10.0.0.1"), ipCaptchaAfter - 1, false},
+		{ipAttemptKey("10.0.0.1"), ipCaptchaAfter, true},
+		{secondFactorAttemptKey("user"), maxEmailFailures, false},
+	}
+	for _, tt := range tests {
+		if got := captchaRequired(tt.key, tt.failures); got != tt.want {
+			t.Errorf("captchaRequired(%q, %d) = %v, want %v", tt.key, tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestLoginUserLockout(t *testing.T) {
+	f := newAuthFixture(t)
+	ctx := context.Background()
+	emailKey := emailAttemptKey("alice@example.com")
+
+	var loginErr *LoginError
+	for i := 1; i <= emailCaptchaAfter; i++ {
+		_, err := f.service.LoginUser(ctx, "alice@example.com", "wrong-password", testDevice)
+		if !errors.As(err, &loginErr) || !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("LoginUser attempt %d err = %v, want %v", i, err, ErrInvalidCredentials)
+		}
+		if want := i >= emailCaptchaAfter; loginErr.CaptchaRequired != want {
+			t.Fatalf("attempt %d captcha_required = %v, want %v", i, loginErr.CaptchaRequired, want)
+		}
+	}
+	// Успешный вход сбрасывает счётчик email, но не засчитывается в счётчик IP
+	f.login(t)
+	if _, err := f.attempts.Get(ctx, emailKey); err == nil {
+		t.Fatal("email counter was not reset after a successful login")
+	}
+	if a, err := f.attempts.Get(ctx, ipAttemptKey(testDevice.IP)); err != nil || a.Failures != emailCaptchaAfter {
+		t.Fatalf("ip counter = %+v, %v, want %d failures", a, err, emailCaptchaAfter)
+	}
+
+	// Неудачи до порога учитываются с отметками времени в прошлом, чтобы не ждать задержек
+	past := time.Now().Add(-30 * time.Minute)
+	for i := 0; i < maxEmailFailures-1; i++ {
+		if _, _, err := f.attempts.RecordAttempt(ctx, emailKey, past, past.Add(-loginFailureWindow)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := f.service.LoginUser(ctx, "alice@example.com", "wrong-password", testDevice)
+	if !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("LoginUser err = %v, want %v", err, ErrInvalidCredentials)
+	}
+	f.mailer.waitEmail(t, "account_locked.html")
+
+	// Во время блокировки отклоняется и верный пароль, а попытка не продлевает счётчик
+	_, err = f.service.LoginUser(ctx, "alice@example.com", testPassword, testDevice)
+	if !errors.As(err, &loginErr) || !errors.Is(err, ErrTooManyAttempts) || loginErr.RetryAfter < loginLockoutTimeout-time.Minute {
+		t.Fatalf("LoginUser while locked err = %v, want a %v lockout", err, ErrTooManyAttempts)
+	}
+	if a, err := f.attempts.Get(ctx, emailKey); err != nil || a.Failures != maxEmailFailures || a.LockedUntil == nil {
+		t.Fatalf("email counter = %+v, %v, want %d failures and a lock", a, err, maxEmailFailures)
+	}
+}
+
+// Параллельные запросы получают разные значения счётчика, поэтому пароль проверяется
+// не больше раз, чем разрешено до начала задержки.
+func TestLoginUserConcurrentAttempts(t *testing.T) {
+	f := newAuthFixture(t)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	results := map[error]int{}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := f.service.LoginUser(context.Background(), "alice@example.com", "wrong-password", testDevice)
+			var loginErr *LoginError
+			if !errors.As(err, &loginErr) {
+				t.Errorf("LoginUser err = %v, want a LoginError", err)
+				return
+			}
+			mu.Lock()
+			results[loginErr.Err]++
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	if results[ErrInvalidCredentials] != loginFreeAttempts+1 || results[ErrTooManyAttempts] != 20-loginFreeAttempts-1 {
+		t.Fatalf("results = %v, want %d password checks", results, loginFreeAttempts+1)
+	}
+}
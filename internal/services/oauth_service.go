@@ -0,0 +1,391 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/sirupsen/logrus"
+	"go_project/internal/models"
+	"go_project/internal/repositories"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// oauthCodeTTL — срок действия кода авторизации
+	oauthCodeTTL = 5 * time.Minute
+	// oauthAccessTokenTTL — срок действия токена доступа стороннего клиента
+	oauthAccessTokenTTL = time.Hour
+	// tokenTypeOAuth — тип токена (claim typ), выдаваемого сторонним клиентам
+	tokenTypeOAuth = "oauth"
+)
+
+// ScopeDescriptions — описания областей доступа для экрана согласия
+var ScopeDescriptions = map[string]string{
+	models.ScopeAccountsRead:     "Просмотр списка счетов и их балансов",
+	models.ScopeTransactionsRead: "Просмотр истории операций",
+	models.ScopePaymentsWrite:    "Переводы со счетов от вашего имени",
+	models.ScopeRatesRead:        "Справочные данные банка (ключевая ставка)",
+}
+
+// userScopes — области, для которых нужен пользователь и его согласие
+var userScopes = map[string]bool{
+	models.ScopeAccountsRead:     true,
+	models.ScopeTransactionsRead: true,
+	models.ScopePaymentsWrite:    true,
+}
+
+// OAuthError — ошибка OAuth2 с кодом из RFC 6749 (invalid_request, invalid_client и т. д.)
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+func (e *OAuthError) Error() string { return e.Code + ": " + e.Description }
+
+func oauthError(code, description string) *OAuthError {
+	return &OAuthError{Code: code, Description: description}
+}
+
+var ErrConsentNotFound = errors.New("consent not found")
+
+// OAuthService — сервер авторизации OAuth2 для сторонних клиентов (authorization code + PKCE
+// и client credentials). Токены подписываются теми же ключами, что и токены пользователей,
+// но имеют тип oauth и принимаются только маршрутами /partner.
+type OAuthService struct {
+	repo    repositories.OAuth
+	jwtKeys *JWTKeyService
+}
+
+func NewOAuthService(repo repositories.OAuth, jwtKeys *JWTKeyService) *OAuthService {
+	return &OAuthService{repo: repo, jwtKeys: jwtKeys}
+}
+
+// RegisterClient регистрирует клиента. Для конфиденциальных клиентов возвращает секрет,
+// который показывается один раз.
+func (s *OAuthService) RegisterClient(ctx context.Context, client models.OAuthClient) (*models.OAuthClient, string, error) {
+	if strings.TrimSpace(client.Name) == "" {
+		return nil, "", oauthError("invalid_client_metadata", "name is required")
+	}
+	for _, scope := range client.Scopes {
+		if _, ok := ScopeDescriptions[scope]; !ok {
+			return nil, "", oauthError("invalid_client_metadata", "unknown scope "+scope)
+		}
+	}
+	for _, grant := range client.GrantTypes {
+		switch grant {
+		case models.GrantAuthorizationCode:
+			if len(client.RedirectURIs) == 0 {
+				return nil, "", oauthError("invalid_client_metadata", "redirect_uris are required for authorization_code")
+			}
+		case models.GrantClientCredentials:
+			if !client.Confidential {
+				return nil, "", oauthError("invalid_client_metadata", "client_credentials requires a confidential client")
+			}
+		default:
+			return nil, "", oauthError("invalid_client_metadata", "unsupported grant type "+grant)
+		}
+	}
+	for _, uri := range client.RedirectURIs {
+		u, err := url.Parse(uri)
+		if err != nil || u.Scheme == "" || u.Host == "" || u.Fragment != "" {
+			return nil, "", oauthError("invalid_redirect_uri", uri)
+		}
+	}
+	id, err := randomToken(16)
+	if err != nil {
+		return nil, "", err
+	}
+	client.ID = id
+	var secret string
+	if client.Confidential {
+		if secret, err = randomToken(32); err != nil {
+			return nil, "", err
+		}
+		client.SecretHash = hashToken(secret)
+	}
+	if err := s.repo.CreateClient(ctx, &client); err != nil {
+		return nil, "", err
+	}
+	logrus.WithContext(ctx).Infof("OAuth client %s (%s) registered", client.ID, client.Name)
+	return &client, secret, nil
+}
+
+// ValidateAuthorize проверяет запрос авторизации и возвращает данные для экрана согласия.
+// До проверки redirect_uri ошибки нельзя передавать клиенту через перенаправление.
+func (s *OAuthService) ValidateAuthorize(ctx context.Context, userID string, req models.AuthorizeRequest) (*models.OAuthClient, []string, *models.ConsentScreen, error) {
+	client, err := s.repo.GetClient(ctx, req.ClientID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, nil, oauthError("invalid_client", "unknown client_id")
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if !contains(client.RedirectURIs, req.RedirectURI) {
+		return nil, nil, nil, oauthError("invalid_request", "redirect_uri is not registered for this client")
+	}
+	if req.ResponseType != "code" {
+		return client, nil, nil, oauthError("unsupported_response_type", "only response_type=code is supported")
+	}
+	if !contains(client.GrantTypes, models.GrantAuthorizationCode) {
+		return client, nil, nil, oauthError("unauthorized_client", "client is not allowed to use authorization_code")
+	}
+	// PKCE обязателен для всех клиентов, поддерживается только S256
+	if req.CodeChallengeMethod != "S256" || len(req.CodeChallenge) < 43 {
+		return client, nil, nil, oauthError("invalid_request", "code_challenge with code_challenge_method=S256 is required")
+	}
+	scopes, err := s.checkScopes(client, req.Scope)
+	if err != nil {
+		return client, nil, nil, err
+	}
+	for _, scope := range scopes {
+		if !userScopes[scope] {
+			return client, nil, nil, oauthError("invalid_scope", scope+" cannot be granted by a user")
+		}
+	}
+	screen := &models.ConsentScreen{ClientID: client.ID, ClientName: client.Name}
+	for _, scope := range scopes {
+		screen.Scopes = append(screen.Scopes, models.ScopeInfo{Name: scope, Description: ScopeDescriptions[scope]})
+	}
+	consent, err := s.repo.GetConsent(ctx, userID, client.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return client, nil, nil, err
+	}
+	screen.AlreadyGranted = consent != nil && containsAll(consent.Scopes, scopes)
+	return client, scopes, screen, nil
+}
+
+// Authorize обрабатывает решение пользователя на экране согласия и возвращает адрес,
+// на который нужно перенаправить браузер: с кодом авторизации или с ошибкой access_denied.
+func (s *OAuthService) Authorize(ctx context.Context, userID string, req models.AuthorizeRequest, approve bool) (string, error) {
+	client, scopes, _, err := s.ValidateAuthorize(ctx, userID, req)
+	if err != nil {
+		var oerr *OAuthError
+		if client != nil && errors.As(err, &oerr) {
+			return redirectWithParams(req.RedirectURI, map[string]string{
+				"error": oerr.Code, "error_description": oerr.Description, "state": req.State,
+			}), nil
+		}
+		return "", err
+	}
+	if !approve {
+		return redirectWithParams(req.RedirectURI, map[string]string{"error": "access_denied", "state": req.State}), nil
+	}
+	if err := s.repo.SaveConsent(ctx, userID, client.ID, scopes); err != nil {
+		return "", err
+	}
+	code, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	err = s.repo.CreateCode(ctx, &models.OAuthCode{
+		CodeHash:      hashToken(code),
+		ClientID:      client.ID,
+		UserID:        userID,
+		RedirectURI:   req.RedirectURI,
+		Scopes:        scopes,
+		CodeChallenge: req.CodeChallenge,
+		ExpiresAt:     time.Now().Add(oauthCodeTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	logrus.WithContext(ctx).Infof("User %s granted %v to OAuth client %s", userID, scopes, client.ID)
+	return redirectWithParams(req.RedirectURI, map[string]string{"code": code, "state": req.State}), nil
+}
+
+// ExchangeCode обменивает код авторизации на токен доступа (grant_type=authorization_code).
+func (s *OAuthService) ExchangeCode(ctx context.Context, clientID, clientSecret, code, redirectURI, codeVerifier string) (*models.OAuthToken, error) {
+	client, err := s.authenticateClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := s.repo.UseCode(ctx, hashToken(code), time.Now())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, oauthError("invalid_grant", "authorization code is invalid, expired or already used")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if stored.ClientID != client.ID || stored.RedirectURI != redirectURI {
+		return nil, oauthError("invalid_grant", "authorization code was issued to another client or redirect_uri")
+	}
+	sum := sha256.Sum256([]byte(codeVerifier))
+	challenge := base64.RawURLEncoding.EncodeToString(sum[:])
+	if subtle.ConstantTimeCompare([]byte(challenge), []byte(stored.CodeChallenge)) != 1 {
+		return nil, oauthError("invalid_grant", "code_verifier does not match code_challenge")
+	}
+	return s.issueToken(ctx, client.ID, stored.UserID, stored.Scopes)
+}
+
+// ClientCredentials выдаёт токен клиенту без участия пользователя (grant_type=client_credentials).
+func (s *OAuthService) ClientCredentials(ctx context.Context, clientID, clientSecret, scope string) (*models.OAuthToken, error) {
+	client, err := s.authenticateClient(ctx, clientID, clientSecret)
+	if err != nil {
+		return nil, err
+	}
+	if !client.Confidential || !contains(client.GrantTypes, models.GrantClientCredentials) {
+		return nil, oauthError("unauthorized_client", "client is not allowed to use client_credentials")
+	}
+	scopes, err := s.checkScopes(client, scope)
+	if err != nil {
+		return nil, err
+	}
+	for _, sc := range scopes {
+		if userScopes[sc] {
+			return nil, oauthError("invalid_scope", sc+" requires user consent")
+		}
+	}
+	return s.issueToken(ctx, client.ID, "", scopes)
+}
+
+// CheckOAuthToken проверяет, что согласие пользователя, по которому выдан токен, не отозвано
+// и по-прежнему покрывает области токена. Для токенов client_credentials проверка не нужна.
+func (s *OAuthService) CheckOAuthToken(ctx context.Context, userID, clientID string, scopes []string) error {
+	if userID == "" {
+		return nil
+	}
+	consent, err := s.repo.GetConsent(ctx, userID, clientID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrConsentNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if !containsAll(consent.Scopes, scopes) {
+		return ErrConsentNotFound
+	}
+	return nil
+}
+
+// ListConsents возвращает клиентов, которым пользователь дал доступ.
+func (s *OAuthService) ListConsents(ctx context.Context, userID string) ([]models.OAuthConsent, error) {
+	return s.repo.ListConsents(ctx, userID)
+}
+
+// RevokeConsent отзывает согласие; выданные клиенту токены перестают приниматься сразу.
+func (s *OAuthService) RevokeConsent(ctx context.Context, userID, clientID string) error {
+	ok, err := s.repo.DeleteConsent(ctx, userID, clientID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrConsentNotFound
+	}
+	logrus.WithContext(ctx).Infof("User %s revoked consent for OAuth client %s", userID, clientID)
+	return nil
+}
+
+// RunCodeCleanup ежечасно удаляет истёкшие коды авторизации, пока не отменён ctx.
+func (s *OAuthService) RunCodeCleanup(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.repo.DeleteExpiredCodes(ctx, time.Now()); err != nil {
+				logrus.WithContext(ctx).Error("Failed to delete expired OAuth codes: ", err)
+			}
+		}
+	}
+}
+
+func (s *OAuthService) authenticateClient(ctx context.Context, clientID, clientSecret string) (*models.OAuthClient, error) {
+	client, err := s.repo.GetClient(ctx, clientID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, oauthError("invalid_client", "client authentication failed")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if client.Confidential &&
+		subtle.ConstantTimeCompare([]byte(hashToken(clientSecret)), []byte(client.SecretHash)) != 1 {
+		return nil, oauthError("invalid_client", "client authentication failed")
+	}
+	return client, nil
+}
+
+// checkScopes разбирает параметр scope и проверяет, что клиенту разрешены все области.
+func (s *OAuthService) checkScopes(client *models.OAuthClient, scope string) ([]string, error) {
+	scopes := strings.Fields(scope)
+	if len(scopes) == 0 {
+		return nil, oauthError("invalid_scope", "scope is required")
+	}
+	for _, sc := range scopes {
+		if !contains(client.Scopes, sc) {
+			return nil, oauthError("invalid_scope", sc+" is not allowed for this client")
+		}
+	}
+	return scopes, nil
+}
+
+func (s *OAuthService) issueToken(ctx context.Context, clientID, userID string, scopes []string) (*models.OAuthToken, error) {
+	jti, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"typ":       tokenTypeOAuth,
+		"sub":       clientID,
+		"client_id": clientID,
+		"scope":     strings.Join(scopes, " "),
+		"jti":       jti,
+		"iat":       now.Unix(),
+		"exp":       now.Add(oauthAccessTokenTTL).Unix(),
+	}
+	if userID != "" {
+		claims["sub"] = userID
+		claims["user_id"] = userID
+	}
+	token, err := s.jwtKeys.Sign(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	return &models.OAuthToken{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(oauthAccessTokenTTL.Seconds()),
+		Scope:       strings.Join(scopes, " "),
+	}, nil
+}
+
+func redirectWithParams(redirectURI string, params map[string]string) string {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return redirectURI
+	}
+	q := u.Query()
+	for k, v := range params {
+		if v != "" {
+			q.Set(k, v)
+		}
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAll(list, values []string) bool {
+	for _, v := range values {
+		if !contains(list, v) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Значения политики паролей по умолчанию. bcrypt учитывает только первые 72 байта пароля,
+// поэтому более длинные пароли не принимаются.
+const (
+	DefaultPasswordMinLength      = 10
+	DefaultPasswordMinCharClasses = 3
+	passwordMaxBytes              = 72
+	minPersonalInfoLength         = 3
+	minUsernameLength             = 3
+	maxUsernameLength             = 32
+)
+
+// FieldError — ошибка проверки одного поля запроса. Params содержит значения, подставляемые
+// в переведённое сообщение (например, min и max для ограничений длины).
+type FieldError struct {
+	Field   string                 `json:"field"`
+	Code    string                 `json:"code"`
+	Message string                 `json:"message"`
+	Params  map[string]interface{} `json:"params,omitempty"`
+}
+
+// ValidationError содержит все найденные ошибки проверки, чтобы клиент мог показать их разом.
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		msgs = append(msgs, fe.Field+": "+fe.Message)
+	}
+	return "validation failed: " + strings.Join(msgs, "; ")
+}
+
+func (e *ValidationError) add(field, code, message string) {
+	e.Errors = append(e.Errors, FieldError{Field: field, Code: code, Message: message})
+}
+
+func (e *ValidationError) addWithParams(field, code, message string, params map[string]interface{}) {
+	e.Errors = append(e.Errors, FieldError{Field: field, Code: code, Message: message, Params: params})
+}
+
+// errOrNil возвращает nil, если ошибок нет; иначе сам ValidationError.
+func (e *ValidationError) errOrNil() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e
+}
+
+// PasswordPolicy проверяет длину пароля, число классов символов (строчные, заглавные, цифры,
+// прочие символы), отсутствие в пароле имени пользователя и email и наличие пароля в списке утечек.
+type PasswordPolicy struct {
+	minLength      int
+	minCharClasses int
+	breached       *BreachedPasswords
+}
+
+func NewPasswordPolicy(minLength, minCharClasses int, breached *BreachedPasswords) *PasswordPolicy {
+	if minLength <= 0 {
+		minLength = DefaultPasswordMinLength
+	}
+	if minCharClasses <= 0 {
+		minCharClasses = DefaultPasswordMinCharClasses
+	}
+	if minCharClasses > 4 {
+		minCharClasses = 4
+	}
+	return &PasswordPolicy{minLength: minLength, minCharClasses: minCharClasses, breached: breached}
+}
+
+// Check добавляет в verr нарушения политики для пароля пользователя с указанными username и email.
+func (p *PasswordPolicy) Check(verr *ValidationError, password, username, email string) error {
+	if utf8.RuneCountInString(password) < p.minLength {
+		verr.addWithParams("password", "too_short", "password must be at least "+strconv.Itoa(p.minLength)+" characters long",
+			map[string]interface{}{"min": p.minLength})
+	}
+	if len(password) > passwordMaxBytes {
+		verr.addWithParams("password", "too_long", "password must be at most "+strconv.Itoa(passwordMaxBytes)+" bytes long",
+			map[string]interface{}{"max": passwordMaxBytes})
+	}
+	if classes := charClasses(password); classes < p.minCharClasses {
+		verr.addWithParams("password", "too_simple", "password must contain at least "+strconv.Itoa(p.minCharClasses)+
+			" of: lowercase letters, uppercase letters, digits, symbols", map[string]interface{}{"min": p.minCharClasses})
+	}
+	lower := strings.ToLower(password)
+	localPart := email
+	if at := strings.LastIndex(email, "@"); at >= 0 {
+		localPart = email[:at]
+	}
+	for _, info := range []string{username, localPart} {
+		info = strings.ToLower(strings.TrimSpace(info))
+		if utf8.RuneCountInString(info) >= minPersonalInfoLength && strings.Contains(lower, info) {
+			verr.add("password", "contains_personal_info", "password must not contain the username or email")
+			break
+		}
+	}
+	if p.breached != nil && password != "" {
+		found, err := p.breached.Contains(password)
+		if err != nil {
+			return err
+		}
+		if found {
+			verr.add("password", "breached", "password appears in a list of leaked passwords")
+		}
+	}
+	return nil
+}
+
+// validateRegistration проверяет email, имя пользователя и пароль при регистрации.
+func (s *AuthService) validateRegistration(email, username, password string) error {
+	verr := &ValidationError{}
+	if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email || !strings.Contains(email, "@") {
+		verr.add("email", "invalid_email", "email address is invalid")
+	}
+	if n := utf8.RuneCountInString(username); n < minUsernameLength || n > maxUsernameLength {
+		verr.addWithParams("username", "invalid_length", "username must be "+strconv.Itoa(minUsernameLength)+"-"+strconv.Itoa(maxUsernameLength)+" characters long",
+			map[string]interface{}{"min": minUsernameLength, "max": maxUsernameLength})
+	}
+	if err := s.passwords.Check(verr, password, username, email); err != nil {
+		return err
+	}
+	return verr.errOrNil()
+}
+
+// validateNewPassword проверяет новый пароль существующего пользователя.
+func (s *AuthService) validateNewPassword(password, username, email string) error {
+	verr := &ValidationError{}
+	if err := s.passwords.Check(verr, password, username, email); err != nil {
+		return err
+	}
+	return verr.errOrNil()
+}
+
+func charClasses(password string) int {
+	var lower, upper, digit, other bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			other = true
+		}
+	}
+	n := 0
+	for _, ok := range []bool{lower, upper, digit, other} {
+		if ok {
+			n++
+		}
+	}
+	return n
+}
+
+// BreachedPasswords проверяет пароли по локальной копии базы утёкших паролей в формате
+// k-anonymity API Have I Been Pwned: каталог с файлами <первые 5 символов SHA-1>.txt,
+// каждая строка которых — оставшиеся 35 символов хеша и число утечек через двоеточие.
+// В память загружается только файл с нужным префиксом.
+type BreachedPasswords struct {
+	dir string
+}
+
+func NewBreachedPasswords(dir string) (*BreachedPasswords, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, errors.New(dir + " is not a directory")
+	}
+	return &BreachedPasswords{dir: dir}, nil
+}
+
+func (b *BreachedPasswords) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+	f, err := os.Open(filepath.Join(b.dir, prefix+".txt"))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		hashSuffix, count, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		// Строки с нулевым счётчиком — заполнение, добавляемое API для одинакового размера ответов
+		if strings.EqualFold(hashSuffix, suffix) && count != "0" {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
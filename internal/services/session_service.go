@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/sirupsen/logrus"
+	"go_project/internal/models"
+	"strings"
+	"time"
+)
+
+const (
+	// sessionTouchInterval — как часто обновляется время последней активности сессии
+	sessionTouchInterval = time.Minute
+	maxDeviceNameLength  = 100
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// startSession создаёт сессию для входа с устройства device. Если пользователь входит
+// с этого устройства впервые и ранее уже входил с других, ему отправляется уведомление.
+func (s *AuthService) startSession(ctx context.Context, user *models.User, device models.DeviceInfo) (string, error) {
+	session := &models.Session{
+		UserID:     user.ID,
+		DeviceName: deviceName(device),
+		DeviceHash: hashToken(device.UserAgent),
+		IP:         device.IP,
+		UserAgent:  device.UserAgent,
+	}
+	known, hasSessions, err := s.sessionRepo.KnownDevice(ctx, user.ID, session.DeviceHash)
+	if err != nil {
+		return "", err
+	}
+	if err := s.sessionRepo.Create(ctx, session); err != nil {
+		return "", err
+	}
+	if !known && hasSessions {
+		logrus.WithContext(ctx).Infof("User %s logged in from a new device (%s)", user.ID, session.DeviceName)
+		go s.sendNewDeviceEmail(context.WithoutCancel(ctx), user, session)
+	}
+	return session.ID, nil
+}
+
+// ListSessions возвращает активные сессии пользователя, отмечая текущую.
+func (s *AuthService) ListSessions(ctx context.Context, userID, currentSessionID string) ([]models.Session, error) {
+	sessions, err := s.sessionRepo.ListActive(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+	return sessions, nil
+}
+
+// RevokeSession завершает сессию: отзывает её токены обновления, а токены доступа
+// перестают приниматься middleware при следующем запросе.
+func (s *AuthService) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	ok, err := s.sessionRepo.Revoke(ctx, userID, sessionID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrSessionNotFound
+	}
+	if err := s.tokenRepo.RevokeSessionRefreshTokens(ctx, sessionID); err != nil {
+		return err
+	}
+	logrus.WithContext(ctx).Infof("Session %s of user %s was revoked", sessionID, userID)
+	return nil
+}
+
+// checkSession проверяет, что сессия токена не завершена, и отмечает её активность.
+func (s *AuthService) checkSession(ctx context.Context, userID, sessionID string) error {
+	session, err := s.sessionRepo.GetByID(ctx, sessionID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrTokenRevoked
+	}
+	if err != nil {
+		return err
+	}
+	if session.UserID != userID || session.RevokedAt != nil {
+		return ErrTokenRevoked
+	}
+	now := time.Now()
+	if now.Sub(session.LastSeenAt) >= sessionTouchInterval {
+		if err := s.sessionRepo.Touch(ctx, sessionID, "", now); err != nil {
+			logrus.WithContext(ctx).Error("Failed to update session activity: ", err)
+		}
+	}
+	return nil
+}
+
+func (s *AuthService) sendNewDeviceEmail(ctx context.Context, user *models.User, session *models.Session) {
+	data := struct {
+		Username   string
+		DeviceName string
+		IP         string
+		Time       string
+	}{user.Username, session.DeviceName, session.IP, session.CreatedAt.UTC().Format("02.01.2006 15:04 MST")}
+	if err := s.mailer.SendTemplateEmail(ctx, user.Email, "New sign-in to your account", "new_device.html", data); err != nil {
+		logrus.WithContext(ctx).Errorf("Failed to send new device email to %s: %v", user.Email, err)
+	}
+}
+
+// deviceName возвращает название устройства, указанное клиентом, или User-Agent.
+func deviceName(device models.DeviceInfo) string {
+	name := strings.TrimSpace(device.Name)
+	if name == "" {
+		name = strings.TrimSpace(device.UserAgent)
+	}
+	if name == "" {
+		return "Unknown device"
+	}
+	if len([]rune(name)) > maxDeviceNameLength {
+		name = string([]rune(name)[:maxDeviceNameLength])
+	}
+	return name
+}
@@ -0,0 +1,19 @@
+package services
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer создаёт спаны операций сервисов; SQL-запросы внутри них трассируются драйвером БД.
+var tracer = otel.Tracer("go_project/internal/services")
+
+// endSpan закрывает span операции, отмечая ошибку, если она есть.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
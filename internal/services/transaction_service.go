@@ -1,94 +1,289 @@
 package services
 
 import (
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
+	"database/sql"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"github.com/sirupsen/logrus"
+	"go_project/internal/metrics"
+	"go_project/internal/models"
 	"go_project/internal/repositories"
+	"math"
+	"strings"
 )
 
 type TransactionService struct {
-	accountRepo     *repositories.AccountRepository
-	transactionRepo *repositories.TransactionRepository
+	uow             repositories.UnitOfWork
+	accountRepo     repositories.Accounts
+	transactionRepo repositories.Transactions
+	userRepo        repositories.Users
+	cardService     *CardService
 	hmacSecret      string
+	feeAccountID    string
 }
 
-func NewTransactionService(accountRepo *repositories.AccountRepository, transactionRepo *repositories.TransactionRepository, hmacSecret string) *TransactionService {
-	return &TransactionService{accountRepo, transactionRepo, hmacSecret}
+// FeeAccountID — счёт банка, на который зачисляются комиссии; создаётся миграцией 0016
+const FeeAccountID = "00000000-0000-4000-8000-0000000000fe"
+
+func NewTransactionService(uow repositories.UnitOfWork, accountRepo repositories.Accounts, transactionRepo repositories.Transactions, userRepo repositories.Users, cardService *CardService, hmacSecret, feeAccountID string) *TransactionService {
+	return &TransactionService{uow, accountRepo, transactionRepo, userRepo, cardService, hmacSecret, feeAccountID}
+}
+
+// requireVerifiedEmail запрещает переводы пользователям, не подтвердившим email.
+func (s *TransactionService) requireVerifiedEmail(ctx context.Context, userID string) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.EmailVerified {
+		return ErrEmailNotVerified
+	}
+	return nil
 }
 
 var (
 	ErrSourceAccountNotFound      = errors.New("source account not found")
 	ErrDestinationAccountNotFound = errors.New("destination account not found")
+	ErrDestinationAccountFrozen   = errors.New("destination account is frozen")
 	ErrInvalidAmount              = errors.New("invalid amount")
 	ErrInsufficientFunds          = errors.New("insufficient funds")
 )
 
 // Transfer выполняет перевод суммы между счетами с проверками и целостностью данных.
-func (s *TransactionService) Transfer(userID, fromAccountID, toAccountID string, amount float64) (string, error) {
+func (s *TransactionService) Transfer(ctx context.Context, userID, fromAccountID, toAccountID string, amount float64) (txID string, err error) {
+	ctx, span := tracer.Start(ctx, "TransactionService.Transfer")
+	defer func() {
+		observeTransfer(metrics.TransferAccount, amount, err)
+		endSpan(span, err)
+	}()
 	if amount <= 0 {
 		return "", ErrInvalidAmount
 	}
-	fromAcc, err := s.accountRepo.GetByID(fromAccountID)
+	if err := s.requireVerifiedEmail(ctx, userID); err != nil {
+		return "", err
+	}
+	fromAcc, err := s.accountRepo.GetByID(ctx, fromAccountID)
 	if err != nil {
 		return "", ErrSourceAccountNotFound
 	}
 	if fromAcc.UserID != userID {
 		return "", ErrForbidden
 	}
-	_, err = s.accountRepo.GetByID(toAccountID)
+	_, err = s.accountRepo.GetByID(ctx, toAccountID)
 	if err != nil {
 		return "", ErrDestinationAccountNotFound
 	}
 	if fromAcc.Balance < amount {
 		return "", ErrInsufficientFunds
 	}
-	tx, err := s.accountRepo.DB.Begin()
-	if err != nil {
-		return "", err
-	}
-	defer func() {
+	var newTxID string
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		// Заморозка проверяется под блокировкой строк счетов, чтобы перевод не прошёл
+		// параллельно с заморозкой счёта администратором
+		locked, err := s.accountRepo.LockByID(ctx, fromAccountID)
 		if err != nil {
-			tx.Rollback()
+			return err
 		}
-	}()
-	_, err = tx.Exec("UPDATE accounts SET balance = balance - $1 WHERE id = $2", amount, fromAccountID)
+		if locked.Frozen {
+			return ErrAccountFrozen
+		}
+		if err := s.lockDestination(ctx, toAccountID); err != nil {
+			return err
+		}
+		// Баланс мог измениться после проверки выше, поэтому списание условное
+		ok, err := s.accountRepo.Debit(ctx, fromAccountID, amount)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrInsufficientFunds
+		}
+		if err := s.accountRepo.Credit(ctx, toAccountID, amount); err != nil {
+			return err
+		}
+		newTxID, err = s.recordTransfer(ctx, fromAccountID, toAccountID, amount)
+		return err
+	})
 	if err != nil {
 		return "", err
 	}
-	_, err = tx.Exec("UPDATE accounts SET balance = balance + $1 WHERE id = $2", amount, toAccountID)
+	logrus.WithContext(ctx).Infof("Transfer %s: %.2f from %s to %s", newTxID, amount, fromAccountID, toAccountID)
+	return newTxID, nil
+}
+
+// lockDestination блокирует счёт зачисления до конца транзакции и проверяет, что он не заморожен.
+func (s *TransactionService) lockDestination(ctx context.Context, accountID string) error {
+	acc, err := s.accountRepo.LockByID(ctx, accountID)
 	if err != nil {
-		return "", err
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrDestinationAccountNotFound
+		}
+		return err
+	}
+	if acc.Frozen {
+		return ErrDestinationAccountFrozen
 	}
+	return nil
+}
+
+// recordTransfer сохраняет запись о переводе, подписанную HMAC, и возвращает её идентификатор.
+// Пустой идентификатор счёта означает зачисление или списание без второй стороны.
+func (s *TransactionService) recordTransfer(ctx context.Context, fromAccountID, toAccountID string, amount float64) (string, error) {
 	// Рассчитываем HMAC для записи транзакции
 	data := fromAccountID + "|" + toAccountID + "|" + fmt.Sprintf("%.2f", amount)
 	mac := hmac.New(sha256.New, []byte(s.hmacSecret))
 	mac.Write([]byte(data))
-	signature := hex.EncodeToString(mac.Sum(nil))
-	// Вставляем запись о транзакции
-	var newTxID string
-	err = tx.QueryRow(`INSERT INTO transactions (id, from_account, to_account, amount, hmac) 
-                             VALUES (gen_random_uuid(), $1, $2, $3, $4) RETURNING id`,
-		fromAccountID, toAccountID, amount, signature).Scan(&newTxID)
-	if err != nil {
+	t := &models.Transaction{
+		FromAccountID: fromAccountID,
+		ToAccountID:   toAccountID,
+		Amount:        amount,
+		HMAC:          hex.EncodeToString(mac.Sum(nil)),
+	}
+	if err := s.transactionRepo.Create(ctx, t); err != nil {
 		return "", err
 	}
-	err = tx.Commit()
+	return t.ID, nil
+}
+
+// observeTransfer учитывает перевод в метриках: число переводов по результату и объём успешных.
+func observeTransfer(kind string, amount float64, err error) {
+	metrics.Transfers.WithLabelValues(kind, transferOutcome(err)).Inc()
+	if err == nil {
+		metrics.TransferredAmount.WithLabelValues(kind).Add(amount)
+	}
+}
+
+func transferOutcome(err error) string {
+	switch {
+	case err == nil:
+		return metrics.OutcomeSuccess
+	case errors.Is(err, ErrInsufficientFunds):
+		return metrics.OutcomeInsufficientFunds
+	case errors.Is(err, ErrInvalidAmount), errors.Is(err, ErrInvalidCardNumber), errors.Is(err, ErrVirtualCardTransfer):
+		return metrics.OutcomeInvalid
+	case errors.Is(err, ErrForbidden), errors.Is(err, ErrEmailNotVerified):
+		return metrics.OutcomeForbidden
+	case errors.Is(err, ErrSourceAccountNotFound), errors.Is(err, ErrDestinationAccountNotFound),
+		errors.Is(err, ErrDestinationCardNotFound), errors.Is(err, ErrCardNotFound):
+		return metrics.OutcomeNotFound
+	case errors.Is(err, ErrAccountFrozen), errors.Is(err, ErrDestinationAccountFrozen), errors.Is(err, ErrCardBlocked), errors.Is(err, ErrCardExpired),
+		errors.Is(err, ErrCardDestroyed), errors.Is(err, ErrCardLimitExceeded), errors.Is(err, ErrChannelDisabled),
+		errors.Is(err, ErrMerchantMismatch), errors.Is(err, ErrAmountCapExceeded):
+		return metrics.OutcomeDeclined
+	default:
+		return metrics.OutcomeError
+	}
+}
+
+// Комиссия за перевод с карты на карту: процент от суммы, но не меньше минимальной
+const (
+	cardTransferFeeRate = 0.01
+	cardTransferMinFee  = 30
+)
+
+// cardTransferMerchant — продавец, под которым перевод учитывается в лимитах карты
+const cardTransferMerchant = "KirBank card transfer"
+
+var (
+	ErrInvalidCardNumber       = errors.New("invalid card number")
+	ErrDestinationCardNotFound = errors.New("destination card not found")
+	ErrVirtualCardTransfer     = errors.New("transfers from virtual cards are not allowed")
+)
+
+// CardTransferFee рассчитывает комиссию за перевод с карты на карту.
+func CardTransferFee(amount float64) float64 {
+	fee := math.Round(amount*cardTransferFeeRate*100) / 100
+	return math.Max(fee, cardTransferMinFee)
+}
+
+// TransferByCard переводит деньги с карты пользователя на карту по её номеру. Карта получателя
+// ищется по HMAC номера, списание суммы с комиссией проходит авторизацию по лимитам карты
+// отправителя. Комиссия зачисляется на счёт банка, а перевод и комиссия записываются
+// в transactions отдельными операциями в той же транзакции.
+func (s *TransactionService) TransferByCard(ctx context.Context, userID, fromCardID, toCardNumber string, amount float64) (txID string, fee float64, err error) {
+	ctx, span := tracer.Start(ctx, "TransactionService.TransferByCard")
+	defer func() {
+		observeTransfer(metrics.TransferCard, amount, err)
+		endSpan(span, err)
+	}()
+	if amount <= 0 {
+		return "", 0, ErrInvalidAmount
+	}
+	toCardNumber = strings.ReplaceAll(toCardNumber, " ", "")
+	if len(toCardNumber) != 16 || strings.Trim(toCardNumber, "0123456789") != "" {
+		return "", 0, ErrInvalidCardNumber
+	}
+	if err := s.requireVerifiedEmail(ctx, userID); err != nil {
+		return "", 0, err
+	}
+	fromCard, err := s.cardService.OwnedCard(ctx, userID, fromCardID)
 	if err != nil {
-		return "", err
+		return "", 0, err
 	}
-	logrus.Infof("Transfer %s: %.2f from %s to %s", newTxID, amount, fromAccountID, toAccountID)
-	return newTxID, nil
+	if fromCard.Type != models.CardTypeStandard {
+		return "", 0, ErrVirtualCardTransfer
+	}
+	toCard, err := s.cardService.ActiveCardByPAN(ctx, toCardNumber)
+	if err != nil {
+		if errors.Is(err, ErrCardNotFound) {
+			return "", 0, ErrDestinationCardNotFound
+		}
+		return "", 0, err
+	}
+	fee = CardTransferFee(amount)
+	auth := &models.CardAuthorization{
+		CardID:   fromCard.ID,
+		Amount:   amount + fee,
+		Channel:  ChannelOnline,
+		Merchant: cardTransferMerchant,
+		Country:  homeCountry,
+	}
+	var newTxID string
+	err = s.uow.Do(ctx, func(ctx context.Context) error {
+		if err := s.cardService.AuthorizeInTx(ctx, auth); err != nil {
+			return err
+		}
+		if err := s.lockDestination(ctx, toCard.AccountID); err != nil {
+			return err
+		}
+		if err := s.accountRepo.Credit(ctx, toCard.AccountID, amount); err != nil {
+			return err
+		}
+		if err := s.accountRepo.Credit(ctx, s.feeAccountID, fee); err != nil {
+			return err
+		}
+		var err error
+		if newTxID, err = s.recordTransfer(ctx, fromCard.AccountID, toCard.AccountID, amount); err != nil {
+			return err
+		}
+		_, err = s.recordTransfer(ctx, fromCard.AccountID, s.feeAccountID, fee)
+		return err
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	logrus.WithContext(ctx).Infof("Card transfer %s: %.2f (fee %.2f) from card %s to card %s",
+		newTxID, amount, fee, fromCard.ID, toCard.ID)
+	return newTxID, fee, nil
 }
 
 // GetAnalytics возвращает статистику операций для пользователя.
-func (s *TransactionService) GetAnalytics(userID string) (int, float64, int, float64, error) {
-	totalSent, countSent, totalReceived, countReceived, err := s.transactionRepo.GetUserStats(userID)
+func (s *TransactionService) GetAnalytics(ctx context.Context, userID string) (int, float64, int, float64, error) {
+	totalSent, countSent, totalReceived, countReceived, err := s.transactionRepo.GetUserStats(ctx, userID)
 	if err != nil {
 		return 0, 0, 0, 0, err
 	}
 	return countSent, totalSent, countReceived, totalReceived, nil
 }
+
+// transactionHistoryLimit — максимальное число операций в выписке
+const transactionHistoryLimit = 100
+
+// ListTransactions возвращает последние операции по счетам пользователя.
+func (s *TransactionService) ListTransactions(ctx context.Context, userID string) ([]models.Transaction, error) {
+	return s.transactionRepo.ListByUserID(ctx, userID, transactionHistoryLimit)
+}
@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"go_project/internal/keyring"
+	"go_project/internal/models"
+	"go_project/internal/repositories"
+	"go_project/internal/repositories/memory"
+	"testing"
+)
+
+// failingTransactions не сохраняет записи о переводах, чтобы проверить откат перевода.
+type failingTransactions struct {
+	repositories.Transactions
+}
+
+var errStorage = errors.New("storage is unavailable")
+
+func (failingTransactions) Create(context.Context, *models.Transaction) error {
+	return errStorage
+}
+
+type transferFixture struct {
+	service  *TransactionService
+	cards    *CardService
+	accounts *memory.AccountRepository
+	users    *memory.UserRepository
+	history  *memory.TransactionRepository
+	userID   string
+	otherID  string
+	from, to string
+	fee      string
+}
+
+// newTransferFixture создаёт пользователя с подтверждённым email, его счёт с балансом balance,
+// счёт другого пользователя и счёт банка для комиссий.
+func newTransferFixture(t *testing.T, balance float64) *transferFixture {
+	t.Helper()
+	ctx := context.Background()
+	store := memory.NewStore()
+	f := &transferFixture{
+		accounts: memory.NewAccountRepository(store),
+		users:    memory.NewUserRepository(store),
+		history:  memory.NewTransactionRepository(store),
+	}
+	keys, err := keyring.New("k1", map[string]string{"k1": "encryption-key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.cards = NewCardService(store, memory.NewCardRepository(store), f.accounts, keys, "pin-key", "pan-index-key")
+
+	if f.userID, err = f.users.CreateUser(ctx, "alice@example.com", "alice", "hash"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.users.SetEmailVerified(ctx, f.userID, "alice@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if f.otherID, err = f.users.CreateUser(ctx, "bob@example.com", "bob", "hash"); err != nil {
+		t.Fatal(err)
+	}
+	bankID, err := f.users.CreateUser(ctx, "fees@example.com", "bank", "hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.from, err = f.accounts.CreateAccount(ctx, f.userID); err != nil {
+		t.Fatal(err)
+	}
+	if f.to, err = f.accounts.CreateAccount(ctx, f.otherID); err != nil {
+		t.Fatal(err)
+	}
+	if f.fee, err = f.accounts.CreateAccount(ctx, bankID); err != nil {
+		t.Fatal(err)
+	}
+	f.service = NewTransactionService(store, f.accounts, f.history, f.users, f.cards, "hmac-secret", f.fee)
+	if err := f.accounts.AddBalance(ctx, f.from, balance); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func (f *transferFixture) balances(t *testing.T) (float64, float64) {
+	t.Helper()
+	return f.balance(t, f.from), f.balance(t, f.to)
+}
+
+func (f *transferFixture) balance(t *testing.T, accountID string) float64 {
+	t.Helper()
+	balance, err := f.accounts.GetBalance(context.Background(), accountID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return balance
+}
+
+// issueCards выпускает карты отправителю и получателю и возвращает карту отправителя
+// и номер карты получателя.
+func (f *transferFixture) issueCards(t *testing.T) (string, string) {
+	t.Helper()
+	from, err := f.cards.CreateCard(context.Background(), f.userID, f.from)
+	if err != nil {
+		t.Fatal(err)
+	}
+	to, err := f.cards.CreateCard(context.Background(), f.otherID, f.to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return from.ID, to.CardNumber
+}
+
+func TestTransfer(t *testing.T) {
+	f := newTransferFixture(t, 100)
+	txID, err := f.service.Transfer(context.Background(), f.userID, f.from, f.to, 40.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from, to := f.balances(t); from != 59.5 || to != 40.5 {
+		t.Fatalf("balances = %v, %v, want 59.5, 40.5", from, to)
+	}
+	list, err := f.service.ListTransactions(context.Background(), f.userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].ID != txID || list[0].FromAccountID != f.from || list[0].ToAccountID != f.to {
+		t.Fatalf("transactions = %+v", list)
+	}
+}
+
+func TestTransferRejected(t *testing.T) {
+	tests := []struct {
+		name    string
+		prepare func(t *testing.T, f *transferFixture) (userID, from, to string, amount float64)
+		want    error
+	}{
+		{"insufficient funds", func(t *testing.T, f *transferFixture) (string, string, string, float64) {
+			return f.userID, f.from, f.to, 100.01
+		}, ErrInsufficientFunds},
+		{"invalid amount", func(t *testing.T, f *transferFixture) (string, string, string, float64) {
+			return f.userID, f.from, f.to, 0
+		}, ErrInvalidAmount},
+		{"foreign account", func(t *testing.T, f *transferFixture) (string, string, string, float64) {
+			return f.userID, f.to, f.from, 10
+		}, ErrForbidden},
+		{"missing destination", func(t *testing.T, f *transferFixture) (string, string, string, float64) {
+			return f.userID, f.from, "00000000-0000-4000-8000-000000000000", 10
+		}, ErrDestinationAccountNotFound},
+		{"frozen account", func(t *testing.T, f *transferFixture) (string, string, string, float64) {
+			if err := f.accounts.SetFrozen(context.Background(), f.from, true); err != nil {
+				t.Fatal(err)
+			}
+			return f.userID, f.from, f.to, 10
+		}, ErrAccountFrozen},
+		{"frozen destination", func(t *testing.T, f *transferFixture) (string, string, string, float64) {
+			if err := f.accounts.SetFrozen(context.Background(), f.to, true); err != nil {
+				t.Fatal(err)
+			}
+			return f.userID, f.from, f.to, 10
+		}, ErrDestinationAccountFrozen},
+		{"unverified email", func(t *testing.T, f *transferFixture) (string, string, string, float64) {
+			// Новый пользователь ещё не подтвердил адрес; проверка выполняется до проверки счёта
+			userID, err := f.users.CreateUser(context.Background(), "carol@example.com", "carol", "hash")
+			if err != nil {
+				t.Fatal(err)
+			}
+			return userID, f.from, f.to, 10
+		}, ErrEmailNotVerified},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTransferFixture(t, 100)
+			userID, from, to, amount := tt.prepare(t, f)
+			if _, err := f.service.Transfer(context.Background(), userID, from, to, amount); !errors.Is(err, tt.want) {
+				t.Fatalf("Transfer err = %v, want %v", err, tt.want)
+			}
+			if from, to := f.balances(t); from != 100 || to != 0 {
+				t.Fatalf("balances = %v, %v, want unchanged", from, to)
+			}
+		})
+	}
+}
+
+func TestTransferRollsBackWhenHistoryFails(t *testing.T) {
+	f := newTransferFixture(t, 100)
+	f.service.transactionRepo = failingTransactions{f.history}
+	if _, err := f.service.Transfer(context.Background(), f.userID, f.from, f.to, 40); !errors.Is(err, errStorage) {
+		t.Fatalf("Transfer err = %v, want %v", err, errStorage)
+	}
+	if from, to := f.balances(t); from != 100 || to != 0 {
+		t.Fatalf("balances = %v, %v, want the debit and credit rolled back", from, to)
+	}
+}
+
+func TestTransferByCard(t *testing.T) {
+	f := newTransferFixture(t, 5000)
+	fromCard, toNumber := f.issueCards(t)
+	// Номер карты принимается и с пробелами между группами цифр
+	spaced := toNumber[:4] + " " + toNumber[4:8] + " " + toNumber[8:12] + " " + toNumber[12:]
+	txID, fee, err := f.service.TransferByCard(context.Background(), f.userID, fromCard, spaced, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 1% от 1000 меньше минимальной комиссии
+	if fee != 30 {
+		t.Fatalf("fee = %v, want 30", fee)
+	}
+	if from, to, bank := f.balance(t, f.from), f.balance(t, f.to), f.balance(t, f.fee); from != 3970 || to != 1000 || bank != 30 {
+		t.Fatalf("balances = %v, %v, %v, want 3970, 1000, 30", from, to, bank)
+	}
+
+	list, err := f.service.ListTransactions(context.Background(), f.userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("transactions = %+v, want the transfer and the fee", list)
+	}
+	var transfer, charge *models.Transaction
+	for i := range list {
+		switch list[i].ToAccountID {
+		case f.to:
+			transfer = &list[i]
+		case f.fee:
+			charge = &list[i]
+		}
+	}
+	if transfer == nil || transfer.ID != txID || transfer.FromAccountID != f.from || transfer.Amount != 1000 {
+		t.Fatalf("transfer = %+v, want %s of 1000 from %s", transfer, txID, f.from)
+	}
+	if charge == nil || charge.FromAccountID != f.from || charge.Amount != 30 {
+		t.Fatalf("fee transaction = %+v, want 30 from %s", charge, f.from)
+	}
+}
+
+func TestTransferByCardRejected(t *testing.T) {
+	t.Run("insufficient funds for the fee", func(t *testing.T) {
+		f := newTransferFixture(t, 1000)
+		fromCard, toNumber := f.issueCards(t)
+		if _, _, err := f.service.TransferByCard(context.Background(), f.userID, fromCard, toNumber, 1000); !errors.Is(err, ErrInsufficientFunds) {
+			t.Fatalf("TransferByCard err = %v, want %v", err, ErrInsufficientFunds)
+		}
+		if from, to, bank := f.balance(t, f.from), f.balance(t, f.to), f.balance(t, f.fee); from != 1000 || to != 0 || bank != 0 {
+			t.Fatalf("balances = %v, %v, %v, want unchanged", from, to, bank)
+		}
+	})
+
+	t.Run("unknown card number", func(t *testing.T) {
+		f := newTransferFixture(t, 5000)
+		fromCard, _ := f.issueCards(t)
+		if _, _, err := f.service.TransferByCard(context.Background(), f.userID, fromCard, "4000000000000002", 100); !errors.Is(err, ErrDestinationCardNotFound) {
+			t.Fatalf("TransferByCard err = %v, want %v", err, ErrDestinationCardNotFound)
+		}
+	})
+
+	t.Run("frozen destination", func(t *testing.T) {
+		f := newTransferFixture(t, 5000)
+		fromCard, toNumber := f.issueCards(t)
+		if err := f.accounts.SetFrozen(context.Background(), f.to, true); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := f.service.TransferByCard(context.Background(), f.userID, fromCard, toNumber, 1000); !errors.Is(err, ErrDestinationAccountFrozen) {
+			t.Fatalf("TransferByCard err = %v, want %v", err, ErrDestinationAccountFrozen)
+		}
+		if from, to, bank := f.balance(t, f.from), f.balance(t, f.to), f.balance(t, f.fee); from != 5000 || to != 0 || bank != 0 {
+			t.Fatalf("balances = %v, %v, %v, want unchanged", from, to, bank)
+		}
+	})
+
+	t.Run("history failure", func(t *testing.T) {
+		f := newTransferFixture(t, 5000)
+		fromCard, toNumber := f.issueCards(t)
+		f.service.transactionRepo = failingTransactions{f.history}
+		if _, _, err := f.service.TransferByCard(context.Background(), f.userID, fromCard, toNumber, 1000); !errors.Is(err, errStorage) {
+			t.Fatalf("TransferByCard err = %v, want %v", err, errStorage)
+		}
+		if from, to, bank := f.balance(t, f.from), f.balance(t, f.to), f.balance(t, f.fee); from != 5000 || to != 0 || bank != 0 {
+			t.Fatalf("balances = %v, %v, %v, want the transfer rolled back", from, to, bank)
+		}
+	})
+}
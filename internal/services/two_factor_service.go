@@ -0,0 +1,286 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/sirupsen/logrus"
+	"go_project/internal/models"
+	"go_project/internal/utils"
+	"math/big"
+	"strings"
+	"time"
+)
+
+const (
+	// totpIssuer отображается в приложении-аутентификаторе
+	totpIssuer = "KirBank"
+	// mfaTokenTTL — время на ввод второго фактора после проверки пароля
+	mfaTokenTTL = 5 * time.Minute
+	// stepUpTokenTTL — срок действия подтверждения для чувствительных операций
+	stepUpTokenTTL = 5 * time.Minute
+	// recoveryCodeCount — количество резервных кодов, выдаваемых пользователю
+	recoveryCodeCount = 10
+	// recoveryCodeAlphabet не содержит похожих символов (0/o, 1/l/i)
+	recoveryCodeAlphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+)
+
+// LargeTransferThreshold — сумма перевода, начиная с которой требуется повторное подтверждение вторым фактором
+const LargeTransferThreshold = 100000
+
+var (
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnabled     = errors.New("two-factor authentication is not enabled")
+	ErrMFANotEnrolled    = errors.New("two-factor enrollment has not been started")
+	ErrInvalidMFACode    = errors.New("invalid two-factor code")
+	ErrInvalidMFAToken   = errors.New("invalid or expired mfa token")
+	ErrStepUpRequired    = errors.New("step-up verification required")
+)
+
+// EnrollTOTP создаёт новый секрет TOTP и возвращает его вместе с otpauth:// URI для QR-кода.
+// Двухфакторная аутентификация включается только после подтверждения кодом в ConfirmTOTP.
+func (s *AuthService) EnrollTOTP(ctx context.Context, userID string) (string, string, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return "", "", err
+	}
+	if user.TOTPEnabled {
+		return "", "", ErrMFAAlreadyEnabled
+	}
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	keyID, key := s.keys.Active()
+	if err := s.userRepo.SetTOTPSecret(ctx, userID, secret, keyID, key); err != nil {
+		return "", "", err
+	}
+	logrus.WithContext(ctx).Infof("User %s started TOTP enrollment", userID)
+	return secret, utils.TOTPProvisioningURI(totpIssuer, user.Email, secret), nil
+}
+
+// ConfirmTOTP включает двухфакторную аутентификацию после проверки первого кода
+// и возвращает одноразовые резервные коды.
+func (s *AuthService) ConfirmTOTP(ctx context.Context, userID, code string) ([]string, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if err := s.verifyTOTPCode(ctx, userID, code); err != nil {
+		return nil, err
+	}
+	codes, err := s.replaceRecoveryCodes(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.SetTOTPEnabled(ctx, userID, true); err != nil {
+		return nil, err
+	}
+	logrus.WithContext(ctx).Infof("User %s enabled two-factor authentication", userID)
+	return codes, nil
+}
+
+// DisableTOTP отключает двухфакторную аутентификацию после проверки кода или резервного кода.
+func (s *AuthService) DisableTOTP(ctx context.Context, userID, code, recoveryCode string) error {
+	if err := s.verifySecondFactor(ctx, userID, code, recoveryCode); err != nil {
+		return err
+	}
+	if err := s.recoveryCodeRepo.DeleteCodes(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.userRepo.SetTOTPEnabled(ctx, userID, false); err != nil {
+		return err
+	}
+	logrus.WithContext(ctx).Infof("User %s disabled two-factor authentication", userID)
+	return nil
+}
+
+// RegenerateRecoveryCodes выдаёт новый набор резервных кодов, аннулируя прежние.
+func (s *AuthService) RegenerateRecoveryCodes(ctx context.Context, userID, code string) ([]string, error) {
+	if err := s.verifySecondFactor(ctx, userID, code, ""); err != nil {
+		return nil, err
+	}
+	return s.replaceRecoveryCodes(ctx, userID)
+}
+
+// CompleteLogin выполняет второй шаг входа: проверяет mfa_token из LoginUser и код
+// из приложения-аутентификатора или резервный код, после чего выдаёт токены.
+func (s *AuthService) CompleteLogin(ctx context.Context, mfaToken, code, recoveryCode string, device models.DeviceInfo) (*models.TokenPair, error) {
+	userID, err := s.parseToken(ctx, mfaToken, tokenTypeMFA)
+	if err != nil {
+		return nil, ErrInvalidMFAToken
+	}
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.verifySecondFactor(ctx, userID, code, recoveryCode); err != nil {
+		return nil, err
+	}
+	tokens, err := s.issueTokens(ctx, user, device)
+	if err != nil {
+		return nil, err
+	}
+	logrus.WithContext(ctx).Infof("User %s logged in with second factor", userID)
+	return tokens, nil
+}
+
+// StepUp подтверждает личность пользователя вторым фактором и возвращает короткоживущий
+// токен, который передаётся в заголовке X-Step-Up-Token при чувствительных операциях.
+func (s *AuthService) StepUp(ctx context.Context, userID, code, recoveryCode string) (string, int, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return "", 0, err
+	}
+	if !user.TOTPEnabled {
+		return "", 0, ErrMFANotEnabled
+	}
+	if err := s.verifySecondFactor(ctx, userID, code, recoveryCode); err != nil {
+		return "", 0, err
+	}
+	token, err := s.signToken(ctx, jwt.MapClaims{
+		"user_id": userID,
+		"typ":     tokenTypeStepUp,
+		"exp":     time.Now().Add(stepUpTokenTTL).Unix(),
+	})
+	if err != nil {
+		return "", 0, err
+	}
+	return token, int(stepUpTokenTTL.Seconds()), nil
+}
+
+// CheckStepUp проверяет подтверждение чувствительной операции. Для пользователей
+// без двухфакторной аутентификации подтверждение не требуется.
+func (s *AuthService) CheckStepUp(ctx context.Context, userID, stepUpToken string) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.TOTPEnabled {
+		return nil
+	}
+	if stepUpToken == "" {
+		return ErrStepUpRequired
+	}
+	tokenUserID, err := s.parseToken(ctx, stepUpToken, tokenTypeStepUp)
+	if err != nil || tokenUserID != userID {
+		return ErrStepUpRequired
+	}
+	return nil
+}
+
+// verifySecondFactor проверяет код TOTP или, если он не передан, резервный код. Подбор кодов
+// ограничивается счётчиком попыток пользователя (2fa:<userID>) с теми же задержками и блокировкой,
+// что и подбор пароля: иначе владелец украденного токена доступа мог бы перебирать коды
+// для подтверждения операций или отключения второго фактора.
+func (s *AuthService) verifySecondFactor(ctx context.Context, userID, code, recoveryCode string) error {
+	attempts, err := s.beginLoginAttempt(ctx, secondFactorAttemptKey(userID))
+	if err != nil {
+		return err
+	}
+	err = s.checkSecondFactor(ctx, userID, code, recoveryCode)
+	switch {
+	case err == nil:
+		s.loginSucceeded(ctx, attempts)
+	case errors.Is(err, ErrInvalidMFACode):
+		user, _ := s.userRepo.GetByID(ctx, userID)
+		if lerr := s.loginFailed(ctx, attempts, user); lerr != nil {
+			return lerr
+		}
+	default:
+		s.cancelLoginAttempt(ctx, attempts)
+	}
+	return err
+}
+
+func (s *AuthService) checkSecondFactor(ctx context.Context, userID, code, recoveryCode string) error {
+	if code != "" {
+		return s.verifyTOTPCode(ctx, userID, code)
+	}
+	if recoveryCode == "" {
+		return ErrInvalidMFACode
+	}
+	ok, err := s.recoveryCodeRepo.UseCode(ctx, userID, hashToken(normalizeRecoveryCode(recoveryCode)))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidMFACode
+	}
+	logrus.WithContext(ctx).Warnf("User %s used a recovery code", userID)
+	return nil
+}
+
+// verifyTOTPCode проверяет код TOTP и не допускает повторного использования кода того же интервала.
+func (s *AuthService) verifyTOTPCode(ctx context.Context, userID, code string) error {
+	secret, keyID, lastStep, err := s.userRepo.GetTOTPSecret(ctx, userID, s.keys.Key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrMFANotEnrolled
+	}
+	if err != nil {
+		return err
+	}
+	step, ok := utils.ValidateTOTP(secret, strings.TrimSpace(code), time.Now())
+	if !ok || step <= lastStep {
+		return ErrInvalidMFACode
+	}
+	used, err := s.userRepo.UseTOTPStep(ctx, userID, step)
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidMFACode
+	}
+	// Секрет, зашифрованный старым ключом, перешифровывается активным ключом при использовании
+	if activeID, activeKey := s.keys.Active(); keyID != activeID {
+		if err := s.userRepo.SetTOTPSecret(ctx, userID, secret, activeID, activeKey); err != nil {
+			logrus.WithContext(ctx).Error("Failed to re-encrypt TOTP secret: ", err)
+		}
+	}
+	return nil
+}
+
+// replaceRecoveryCodes генерирует новый набор резервных кодов и сохраняет их хеши.
+func (s *AuthService) replaceRecoveryCodes(ctx context.Context, userID string) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		hashes[i] = hashToken(normalizeRecoveryCode(code))
+	}
+	if err := s.recoveryCodeRepo.ReplaceCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// generateRecoveryCode возвращает код вида xxxxx-xxxxx.
+func generateRecoveryCode() (string, error) {
+	var sb strings.Builder
+	max := big.NewInt(int64(len(recoveryCodeAlphabet)))
+	for i := 0; i < 10; i++ {
+		if i == 5 {
+			sb.WriteByte('-')
+		}
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteByte(recoveryCodeAlphabet[n.Int64()])
+	}
+	return sb.String(), nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"go_project/internal/utils"
+	"testing"
+	"time"
+)
+
+// enableTOTP включает пользователю фикстуры двухфакторную аутентификацию и возвращает секрет
+// и интервал, код которого использован при подтверждении.
+func (f *authFixture) enableTOTP(t *testing.T) (string, int64) {
+	t.Helper()
+	secret, _, err := f.service.EnrollTOTP(context.Background(), f.userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	step := utils.TOTPStep(time.Now())
+	if _, err := f.service.ConfirmTOTP(context.Background(), f.userID, totpCode(t, secret, step)); err != nil {
+		t.Fatal(err)
+	}
+	return secret, step
+}
+
+func totpCode(t *testing.T, secret string, step int64) string {
+	t.Helper()
+	code, err := utils.TOTPCode(secret, step)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func TestStepUpRejectsReusedCode(t *testing.T) {
+	f := newAuthFixture(t)
+	secret, step := f.enableTOTP(t)
+	ctx := context.Background()
+
+	// Код следующего интервала принимается в пределах допуска на рассинхронизацию часов
+	next := totpCode(t, secret, step+1)
+	if _, _, err := f.service.StepUp(ctx, f.userID, next, ""); err != nil {
+		t.Fatalf("StepUp = %v", err)
+	}
+	for _, code := range []string{next, totpCode(t, secret, step)} {
+		if _, _, err := f.service.StepUp(ctx, f.userID, code, ""); !errors.Is(err, ErrInvalidMFACode) {
+			t.Fatalf("StepUp with a used step err = %v, want %v", err, ErrInvalidMFACode)
+		}
+	}
+}
+
+func TestSecondFactorLockout(t *testing.T) {
+	f := newAuthFixture(t)
+	secret, step := f.enableTOTP(t)
+	ctx := context.Background()
+	wrong, valid := totpCode(t, secret, step+5), totpCode(t, secret, step+1)
+
+	for i := 1; i <= loginFreeAttempts+1; i++ {
+		if _, _, err := f.service.StepUp(ctx, f.userID, wrong, ""); !errors.Is(err, ErrInvalidMFACode) {
+			t.Fatalf("StepUp attempt %d err = %v, want %v", i, err, ErrInvalidMFACode)
+		}
+	}
+	// После бесплатных попыток следующая, даже с верным кодом, ждёт окончания задержки
+	var loginErr *LoginError
+	_, _, err := f.service.StepUp(ctx, f.userID, valid, "")
+	if !errors.As(err, &loginErr) || !errors.Is(err, ErrTooManyAttempts) || loginErr.RetryAfter <= 0 || loginErr.CaptchaRequired {
+		t.Fatalf("StepUp during backoff err = %#v, want %v with Retry-After", err, ErrTooManyAttempts)
+	}
+
+	// Неудачи до порога блокировки учитываются с отметками времени в прошлом, чтобы не ждать задержек
+	key := secondFactorAttemptKey(f.userID)
+	if err := f.attempts.Reset(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-30 * time.Minute)
+	for i := 0; i < maxEmailFailures-1; i++ {
+		if _, _, err := f.attempts.RecordAttempt(ctx, key, past, past.Add(-loginFailureWindow)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := f.service.DisableTOTP(ctx, f.userID, wrong, ""); !errors.Is(err, ErrInvalidMFACode) {
+		t.Fatalf("DisableTOTP err = %v, want %v", err, ErrInvalidMFACode)
+	}
+	f.mailer.waitEmail(t, "account_locked.html")
+	err = f.service.DisableTOTP(ctx, f.userID, valid, "")
+	if !errors.As(err, &loginErr) || !errors.Is(err, ErrTooManyAttempts) || loginErr.RetryAfter < loginLockoutTimeout-time.Minute {
+		t.Fatalf("DisableTOTP while locked err = %#v, want a %v lockout", err, ErrTooManyAttempts)
+	}
+	user, err := f.users.GetByID(ctx, f.userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !user.TOTPEnabled {
+		t.Fatal("two-factor authentication was disabled during the lockout")
+	}
+	// Блокировка распространяется и на второй шаг входа
+	if _, err := f.service.CompleteLogin(ctx, f.mfaToken(t), valid, "", testDevice); !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("CompleteLogin while locked err = %v, want %v", err, ErrTooManyAttempts)
+	}
+}
+
+// mfaToken выполняет первый шаг входа пользователя с включённым вторым фактором.
+func (f *authFixture) mfaToken(t *testing.T) string {
+	t.Helper()
+	result, err := f.service.LoginUser(context.Background(), "alice@example.com", testPassword, testDevice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.MFARequired {
+		t.Fatalf("LoginUser = %+v, want a second factor", result)
+	}
+	return result.MFAToken
+}
@@ -0,0 +1,43 @@
+// Package tracing настраивает трассировку OpenTelemetry: экспорт спанов по OTLP/HTTP
+// и распространение контекста трассировки через заголовки traceparent/baggage.
+package tracing
+
+import (
+	"context"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const defaultServiceName = "kirbank-api"
+
+// Init настраивает глобальный провайдер трассировок. Если endpoint пуст, провайдер остаётся
+// no-op: спаны создаются, но никуда не отправляются. Возвращаемая функция отправляет
+// накопленные спаны и должна вызываться при остановке приложения.
+func Init(ctx context.Context, endpoint, serviceName string, sampleRatio float64) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if endpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, err
+	}
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+	if sampleRatio <= 0 || sampleRatio > 1 {
+		sampleRatio = 1
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+		// Решение о записи принимает вызывающий сервис, если он передал traceparent
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
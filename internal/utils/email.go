@@ -1,27 +1,43 @@
 package utils
 
 import (
-	"github.com/sirupsen/logrus"
-	"go_project/internal/config"
+	"context"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 	"gopkg.in/gomail.v2"
 )
 
+// Mailer отправляет письма через SMTP. Настройки сервера передаются один раз при создании,
+// а ошибки отправки возвращаются вызывающему: письма часто отправляются из фоновых горутин,
+// и сбой почты не должен останавливать сервер.
+type Mailer struct {
+	host string
+	port int
+	user string
+	pass string
+	from string
+}
+
+func NewMailer(host string, port int, user, pass, from string) *Mailer {
+	return &Mailer{host: host, port: port, user: user, pass: pass, from: from}
+}
+
 // SendEmail отправляет письмо с заданными темой и текстом на указанный адрес.
-func SendEmail(to string, subject string, body string) error {
-	cfg, err := config.LoadConfig("config")
-	if err != nil {
-		logrus.Fatal("cannot load config:", err)
+func (m *Mailer) SendEmail(ctx context.Context, to string, subject string, body string) error {
+	msg := gomail.NewMessage()
+	msg.SetHeader("From", m.from)
+	msg.SetHeader("To", to)
+	msg.SetHeader("Subject", subject)
+	msg.SetBody("text/html", body)
+	_, span := otel.Tracer("go_project/internal/utils").Start(ctx, "smtp.send")
+	defer span.End()
+	span.SetAttributes(attribute.String("server.address", m.host), attribute.Int("server.port", m.port))
+	d := gomail.NewDialer(m.host, m.port, m.user, m.pass)
+	if err := d.DialAndSend(msg); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
 	}
-	var smtpHost = cfg.SMTP.Host
-	var smtpPort = cfg.SMTP.Port
-	var smtpUser = cfg.SMTP.User
-	var smtpPass = cfg.SMTP.Pass
-	var smtpFrom = cfg.SMTP.From
-	m := gomail.NewMessage()
-	m.SetHeader("From", smtpFrom)
-	m.SetHeader("To", to)
-	m.SetHeader("Subject", subject)
-	m.SetBody("text/html", body)
-	d := gomail.NewDialer(smtpHost, smtpPort, smtpUser, smtpPass)
-	return d.DialAndSend(m)
+	return nil
 }
@@ -0,0 +1,23 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"html/template"
+)
+
+//go:embed templates/*.html
+var emailTemplateFS embed.FS
+
+var emailTemplates = template.Must(template.ParseFS(emailTemplateFS, "templates/*.html"))
+
+// SendTemplateEmail формирует тело письма из шаблона templates/<name> и отправляет его через SendEmail.
+// Значения data экранируются html/template, поэтому имя пользователя и ссылки безопасно подставлять как есть.
+func (m *Mailer) SendTemplateEmail(ctx context.Context, to, subject, name string, data interface{}) error {
+	var body bytes.Buffer
+	if err := emailTemplates.ExecuteTemplate(&body, name, data); err != nil {
+		return err
+	}
+	return m.SendEmail(ctx, to, subject, body.String())
+}
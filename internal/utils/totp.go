@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Параметры TOTP (RFC 6238), совместимые с Google Authenticator и аналогами
+const (
+	TOTPPeriod = 30 * time.Second
+	TOTPDigits = 6
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret создаёт случайный 160-битный секрет в кодировке base32.
+func GenerateTOTPSecret() (string, error) {
+	secret := make([]byte, 20)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return totpEncoding.EncodeToString(secret), nil
+}
+
+// TOTPStep возвращает номер 30-секундного интервала для момента времени t.
+func TOTPStep(t time.Time) int64 {
+	return t.Unix() / int64(TOTPPeriod/time.Second)
+}
+
+// TOTPCode вычисляет одноразовый код для интервала step (HOTP, RFC 4226).
+func TOTPCode(secret string, step int64) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", value%1000000), nil
+}
+
+// ValidateTOTP проверяет код с допуском ±1 интервал на рассинхронизацию часов
+// и возвращает номер интервала, которому код соответствует.
+func ValidateTOTP(secret, code string, t time.Time) (int64, bool) {
+	if len(code) != TOTPDigits {
+		return 0, false
+	}
+	current := TOTPStep(t)
+	for _, step := range []int64{current - 1, current, current + 1} {
+		expected, err := TOTPCode(secret, step)
+		if err != nil {
+			return 0, false
+		}
+		if hmac.Equal([]byte(expected), []byte(code)) {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// TOTPProvisioningURI формирует otpauth:// URI для QR-кода приложения-аутентификатора.
+func TOTPProvisioningURI(issuer, account, secret string) string {
+	label := url.PathEscape(issuer + ":" + account)
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", issuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(TOTPDigits))
+	params.Set("period", fmt.Sprint(int(TOTPPeriod/time.Second)))
+	return "otpauth://totp/" + label + "?" + params.Encode()
+}
@@ -0,0 +1,219 @@
+// Package validation проверяет структуры запросов по тегам validate, например:
+//
+//	AccountID string  `json:"account_id" validate:"required,uuid"`
+//	Amount    float64 `json:"amount" validate:"min=0.01,max=10000000,decimals=2"`
+//	Type      string  `json:"type" validate:"oneof=single_use merchant_locked"`
+//
+// Правила: required (непустое значение), uuid, email, oneof=<значения через пробел>, min=/max=
+// (для чисел — диапазон значения, для строк — длина в символах, для списков — число элементов)
+// и decimals=<n> (не больше n знаков после запятой). Поле называется по тегу json; вложенные
+// структуры проверяются рекурсивно, встроенные — без префикса.
+package validation
+
+import (
+	"go_project/internal/problem"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// IsUUID сообщает, является ли строка UUID в каноническом виде.
+func IsUUID(s string) bool {
+	return uuidPattern.MatchString(s)
+}
+
+// Struct проверяет v (структуру или указатель на неё) и возвращает все найденные ошибки.
+func Struct(v interface{}) []problem.FieldError {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	var errs []problem.FieldError
+	checkStruct(rv, "", &errs)
+	return errs
+}
+
+func checkStruct(rv reflect.Value, prefix string, errs *[]problem.FieldError) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		sf := rt.Field(i)
+		fv := rv.Field(i)
+		if sf.Anonymous && fv.Kind() == reflect.Struct {
+			checkStruct(fv, prefix, errs)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		name := jsonName(sf)
+		if name == "-" {
+			continue
+		}
+		if tag := sf.Tag.Get("validate"); tag != "" {
+			checkField(fv, prefix+name, tag, errs)
+		}
+		if fv.Kind() == reflect.Struct {
+			checkStruct(fv, prefix+name+".", errs)
+		}
+	}
+}
+
+func jsonName(sf reflect.StructField) string {
+	name, _, _ := strings.Cut(sf.Tag.Get("json"), ",")
+	if name == "" {
+		return sf.Name
+	}
+	return name
+}
+
+// checkField применяет правила тега к одному полю. Пустое необязательное поле не проверяется,
+// а после первой ошибки по полю остальные правила пропускаются.
+func checkField(fv reflect.Value, field, tag string, errs *[]problem.FieldError) {
+	rules := strings.Split(tag, ",")
+	if fv.IsZero() {
+		for _, rule := range rules {
+			if rule == "required" {
+				add(errs, field, "required", "field is required", nil)
+				return
+			}
+		}
+		// Для чисел ноль — обычное значение, его диапазон проверяется
+		if !isNumber(fv) {
+			return
+		}
+	}
+	for _, rule := range rules {
+		name, arg, _ := strings.Cut(rule, "=")
+		var ok bool
+		switch name {
+		case "required":
+			ok = true
+		case "uuid":
+			ok = checkUUID(fv, field, errs)
+		case "email":
+			ok = checkEmail(fv, field, errs)
+		case "oneof":
+			ok = checkOneOf(fv, field, arg, errs)
+		case "min":
+			ok = checkBound(fv, field, arg, true, errs)
+		case "max":
+			ok = checkBound(fv, field, arg, false, errs)
+		case "decimals":
+			ok = checkDecimals(fv, field, arg, errs)
+		default:
+			panic("validation: unknown rule " + name + " for field " + field)
+		}
+		if !ok {
+			return
+		}
+	}
+}
+
+func add(errs *[]problem.FieldError, field, code, message string, params map[string]interface{}) {
+	*errs = append(*errs, problem.FieldError{Field: field, Code: code, Message: message, Params: params})
+}
+
+func isNumber(fv reflect.Value) bool {
+	switch fv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+func checkUUID(fv reflect.Value, field string, errs *[]problem.FieldError) bool {
+	if !IsUUID(fv.String()) {
+		add(errs, field, "invalid_uuid", "must be a UUID", nil)
+		return false
+	}
+	return true
+}
+
+func checkEmail(fv reflect.Value, field string, errs *[]problem.FieldError) bool {
+	s := fv.String()
+	if addr, err := mail.ParseAddress(s); err != nil || addr.Address != s {
+		add(errs, field, "invalid_email", "email address is invalid", nil)
+		return false
+	}
+	return true
+}
+
+func checkOneOf(fv reflect.Value, field, arg string, errs *[]problem.FieldError) bool {
+	allowed := strings.Fields(arg)
+	for _, a := range allowed {
+		if fv.String() == a {
+			return true
+		}
+	}
+	list := strings.Join(allowed, ", ")
+	add(errs, field, "invalid_value", "must be one of: "+list, map[string]interface{}{"allowed": list})
+	return false
+}
+
+// checkBound проверяет нижнюю (isMin) или верхнюю границу значения, длины строки или размера списка.
+func checkBound(fv reflect.Value, field, arg string, isMin bool, errs *[]problem.FieldError) bool {
+	limit, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		panic("validation: invalid bound " + arg + " for field " + field)
+	}
+	var value float64
+	var code, message string
+	switch fv.Kind() {
+	case reflect.String:
+		value = float64(utf8.RuneCountInString(fv.String()))
+		code, message = "min_length", "must be at least "+arg+" characters long"
+		if !isMin {
+			code, message = "max_length", "must be at most "+arg+" characters long"
+		}
+	case reflect.Slice:
+		value = float64(fv.Len())
+		code, message = "too_few_items", "must contain at least "+arg+" items"
+		if !isMin {
+			code, message = "too_many_items", "must contain at most "+arg+" items"
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value = float64(fv.Int())
+		code, message = "too_small", "must be at least "+arg
+		if !isMin {
+			code, message = "too_large", "must be at most "+arg
+		}
+	case reflect.Float32, reflect.Float64:
+		value = fv.Float()
+		code, message = "too_small", "must be at least "+arg
+		if !isMin {
+			code, message = "too_large", "must be at most "+arg
+		}
+	default:
+		panic("validation: min/max is not supported for field " + field)
+	}
+	if isMin && value < limit || !isMin && value > limit {
+		key := "max"
+		if isMin {
+			key = "min"
+		}
+		add(errs, field, code, message, map[string]interface{}{key: limit})
+		return false
+	}
+	return true
+}
+
+// checkDecimals проверяет число знаков после запятой по кратчайшему десятичному представлению числа,
+// то есть так, как оно было записано в JSON.
+func checkDecimals(fv reflect.Value, field, arg string, errs *[]problem.FieldError) bool {
+	maxDecimals, err := strconv.Atoi(arg)
+	if err != nil || fv.Kind() != reflect.Float64 && fv.Kind() != reflect.Float32 {
+		panic("validation: invalid decimals rule for field " + field)
+	}
+	s := strconv.FormatFloat(fv.Float(), 'f', -1, fv.Type().Bits())
+	if _, frac, ok := strings.Cut(s, "."); ok && len(frac) > maxDecimals {
+		add(errs, field, "too_many_decimals", "must have at most "+arg+" decimal places",
+			map[string]interface{}{"max": maxDecimals})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,116 @@
+package validation
+
+import (
+	"go_project/internal/problem"
+	"reflect"
+	"testing"
+)
+
+type amountRequest struct {
+	Amount float64 `json:"amount" validate:"min=0.01,max=1000,decimals=2"`
+}
+
+type rateRequest struct {
+	Rate float32 `json:"rate" validate:"decimals=1"`
+}
+
+type optionalRequest struct {
+	AccountID string   `json:"account_id" validate:"uuid"`
+	Email     string   `json:"email" validate:"required,email"`
+	Name      string   `json:"name" validate:"min=3,max=5"`
+	Scopes    []string `json:"scopes" validate:"min=1"`
+	Count     int      `json:"count" validate:"min=1"`
+}
+
+type limits struct {
+	Daily float64 `json:"daily" validate:"required,min=1"`
+}
+
+type device struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type session struct {
+	Device device `json:"device"`
+}
+
+type nestedRequest struct {
+	device
+	Limits  limits  `json:"limits"`
+	Session session `json:"session"`
+	Ignored limits  `json:"-"`
+	NoTag   limits
+}
+
+// codes сводит ошибки к парам "поле: код" для сравнения.
+func codes(errs []problem.FieldError) []string {
+	var out []string
+	for _, e := range errs {
+		out = append(out, e.Field+": "+e.Code)
+	}
+	return out
+}
+
+func TestStruct(t *testing.T) {
+	tenth, fifth := 0.1, 0.2
+	tests := []struct {
+		name string
+		in   interface{}
+		want []string
+	}{
+		{"valid amount", amountRequest{Amount: 10.25}, nil},
+		{"amount with one decimal", &amountRequest{Amount: 10.5}, nil},
+		{"too many decimals", amountRequest{Amount: 10.255}, []string{"amount: too_many_decimals"}},
+		// Сумма 0.1+0.2 не записывается двумя знаками: её кратчайшее представление 0.30000000000000004
+		{"float arithmetic", amountRequest{Amount: tenth + fifth}, []string{"amount: too_many_decimals"}},
+		// Ноль не пропускается как пустое значение: для чисел проверяется диапазон
+		{"zero amount", amountRequest{}, []string{"amount: too_small"}},
+		// После первой ошибки по полю остальные правила не проверяются
+		{"too large", amountRequest{Amount: 1000.001}, []string{"amount: too_large"}},
+		// Точность float32 учитывается: 0.1 не превращается в 0.10000000149011612
+		{"float32 decimals", rateRequest{Rate: 0.1}, nil},
+		{"float32 too many decimals", rateRequest{Rate: 0.25}, []string{"rate: too_many_decimals"}},
+
+		{"empty optional fields", optionalRequest{Email: "a@example.com", Count: 1}, nil},
+		{"required and zero number", optionalRequest{}, []string{"email: required", "count: too_small"}},
+		{"invalid values", optionalRequest{AccountID: "42", Email: "Alice <a@example.com>", Name: "ab", Scopes: []string{}, Count: 1},
+			[]string{"account_id: invalid_uuid", "email: invalid_email", "name: min_length", "scopes: too_few_items"}},
+		{"string length in runes", optionalRequest{Email: "a@example.com", Name: "ёжики", Count: 1}, nil},
+
+		// Встроенная структура проверяется без префикса, поле без тега json называется по имени в Go
+		{"nested prefixes", nestedRequest{},
+			[]string{"name: required", "limits.daily: required", "session.device.name: required", "NoTag.daily: required"}},
+		{"valid nested", nestedRequest{device: device{Name: "phone"}, Limits: limits{Daily: 5},
+			Session: session{Device: device{Name: "tablet"}}, NoTag: limits{Daily: 1}}, nil},
+		{"not a struct", 42, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := codes(Struct(tt.in)); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Struct = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStructParams(t *testing.T) {
+	errs := Struct(amountRequest{Amount: 10.255})
+	if len(errs) != 1 || errs[0].Params["max"] != 2 {
+		t.Fatalf("Struct = %+v, want the decimals limit in params", errs)
+	}
+	errs = Struct(amountRequest{Amount: 2000})
+	if len(errs) != 1 || errs[0].Params["max"] != float64(1000) {
+		t.Fatalf("Struct = %+v, want the bound in params", errs)
+	}
+}
+
+func TestStructUnknownRule(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Struct with an unknown rule did not panic")
+		}
+	}()
+	Struct(struct {
+		Name string `json:"name" validate:"lowercase"`
+	}{Name: "x"})
+}
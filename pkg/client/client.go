@@ -0,0 +1,66 @@
+// Package client — Go-клиент KirBank API для других сервисов.
+//
+// Типы запросов и ответов, Client и ClientWithResponses находятся в client.gen.go и генерируются
+// oapi-codegen по internal/openapi/openapi.json командой go generate ./pkg/client (настройки —
+// oapi-codegen.yaml); вручную этот файл не правят. Здесь — способы аутентификации и разбор ошибок.
+//
+//	c, err := client.NewClientWithResponses("https://api.kirbank.example", client.WithBearerToken(token))
+//	resp, err := c.GetBalanceWithResponse(ctx, accountID)
+//	if client.ErrorCode(resp.HTTPResponse, resp.Body) == "account_not_found" {
+//		...
+//	}
+package client
+
+//go:generate go tool oapi-codegen -config oapi-codegen.yaml ../../internal/openapi/openapi.json
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// WithBearerToken передаёт токен доступа (JWT пользователя или токен OAuth2) в заголовке Authorization.
+func WithBearerToken(token string) ClientOption {
+	return withAuthorization("Bearer " + token)
+}
+
+// WithAPIKey передаёт персональный API-ключ в заголовке Authorization: ApiKey <ключ>.
+func WithAPIKey(key string) ClientOption {
+	return withAuthorization("ApiKey " + key)
+}
+
+// WithClientCredentials аутентифицирует клиента OAuth2 через HTTP Basic для POST /oauth/token.
+func WithClientCredentials(clientID, clientSecret string) ClientOption {
+	return withAuthorization("Basic " + base64.StdEncoding.EncodeToString([]byte(clientID+":"+clientSecret)))
+}
+
+func withAuthorization(value string) ClientOption {
+	return WithRequestEditorFn(func(ctx context.Context, req *http.Request) error {
+		req.Header.Set("Authorization", value)
+		return nil
+	})
+}
+
+// ErrorCode возвращает стабильный код ошибки API: поле code ответа application/problem+json
+// или error ошибки OAuth2 (RFC 6749). Для успешных и нераспознанных ответов возвращается пустая строка.
+func ErrorCode(resp *http.Response, body []byte) string {
+	if resp == nil || resp.StatusCode < 300 {
+		return ""
+	}
+	mediaType := strings.TrimSpace(strings.Split(resp.Header.Get("Content-Type"), ";")[0])
+	switch mediaType {
+	case "application/problem+json":
+		var p Problem
+		if json.Unmarshal(body, &p) == nil {
+			return p.Code
+		}
+	case "application/json":
+		var o OAuthError
+		if json.Unmarshal(body, &o) == nil {
+			return o.Error
+		}
+	}
+	return ""
+}
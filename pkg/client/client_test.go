@@ -0,0 +1,72 @@
+package client
+
+import (
+	"bytes"
+	"github.com/oapi-codegen/oapi-codegen/v2/pkg/codegen"
+	"github.com/oapi-codegen/oapi-codegen/v2/pkg/util"
+	"gopkg.in/yaml.v2"
+	"net/http"
+	"os"
+	"testing"
+)
+
+// TestClientUpToDate проверяет, что client.gen.go сгенерирован по текущему openapi.json.
+func TestClientUpToDate(t *testing.T) {
+	data, err := os.ReadFile("oapi-codegen.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg struct {
+		codegen.Configuration `yaml:",inline"`
+		Output                string `yaml:"output"`
+	}
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	spec, err := util.LoadSwagger("../../internal/openapi/openapi.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := codegen.Generate(spec, cfg.Configuration.UpdateDefaults())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(cfg.Output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Заголовок содержит имя и версию модуля, из которого запущен генератор, поэтому не сравнивается
+	if !bytes.Equal(withoutHeader(got), withoutHeader([]byte(want))) {
+		t.Error("client.gen.go is out of date, run go generate ./pkg/client")
+	}
+}
+
+func withoutHeader(code []byte) []byte {
+	if i := bytes.Index(code, []byte("\npackage ")); i >= 0 {
+		return code[i:]
+	}
+	return code
+}
+
+func TestErrorCode(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		body        string
+		want        string
+	}{
+		{"problem", 404, "application/problem+json", `{"code":"account_not_found","title":"Not Found","status":404}`, "account_not_found"},
+		{"oauth", 400, "application/json;charset=utf-8", `{"error":"invalid_grant"}`, "invalid_grant"},
+		{"success", 200, "application/json", `{"error":"ignored"}`, ""},
+		{"not an API error", 502, "text/html", `<html></html>`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.status, Header: http.Header{"Content-Type": {tt.contentType}}}
+			if got := ErrorCode(resp, []byte(tt.body)); got != tt.want {
+				t.Fatalf("ErrorCode = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}